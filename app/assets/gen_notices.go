@@ -0,0 +1,91 @@
+//go:build ignore
+
+// gen_notices writes THIRD_PARTY_NOTICES.txt with the license of every module
+// compiled into the Windows app. Run with `go generate ./assets` before a
+// release build.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+)
+
+var licenseFiles = []string{"LICENSE", "LICENSE.txt", "LICENSE.md", "COPYING", "NOTICE"}
+
+type module struct {
+	Path    string
+	Version string
+	Dir     string
+	Main    bool
+}
+
+type pkg struct {
+	Module *module
+}
+
+func main() {
+	cmd := exec.Command("go", "list", "-e", "-deps", "-json", "..")
+	cmd.Env = append(os.Environ(), "GOOS=windows")
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		log.Fatalf("go list failed: %v", err)
+	}
+
+	modules := map[string]*module{}
+	dec := json.NewDecoder(bytes.NewReader(out))
+	for dec.More() {
+		var p pkg
+		if err := dec.Decode(&p); err != nil {
+			log.Fatalf("malformed go list output: %v", err)
+		}
+		if p.Module != nil && !p.Module.Main {
+			modules[p.Module.Path] = p.Module
+		}
+	}
+
+	paths := make([]string, 0, len(modules))
+	for path := range modules {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	// The Go runtime and standard library are compiled in as well
+	goroot, err := exec.Command("go", "env", "GOROOT").Output()
+	if err != nil {
+		log.Fatalf("go env failed: %v", err)
+	}
+	modules["std"] = &module{Path: "Go standard library", Version: runtime.Version(), Dir: string(bytes.TrimSpace(goroot))}
+	paths = append([]string{"std"}, paths...)
+
+	var buf bytes.Buffer
+	buf.WriteString("ReEnvision AI includes the following third-party software.\n")
+	for _, path := range paths {
+		m := modules[path]
+		fmt.Fprintf(&buf, "\n================================================================================\n%s %s\n================================================================================\n\n", m.Path, m.Version)
+		found := false
+		for _, name := range licenseFiles {
+			text, err := os.ReadFile(filepath.Join(m.Dir, name))
+			if err != nil {
+				continue
+			}
+			buf.Write(bytes.TrimSpace(text))
+			buf.WriteString("\n")
+			found = true
+		}
+		if !found {
+			log.Fatalf("no license file found for %s in %s", m.Path, m.Dir)
+		}
+	}
+
+	if err := os.WriteFile("THIRD_PARTY_NOTICES.txt", buf.Bytes(), 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,13 @@
+package assets
+
+import _ "embed"
+
+//go:generate go run gen_notices.go
+
+//go:embed THIRD_PARTY_NOTICES.txt
+var thirdPartyNotices string
+
+// ThirdPartyNotices returns the licenses of the modules built into the app.
+func ThirdPartyNotices() string {
+	return thirdPartyNotices
+}
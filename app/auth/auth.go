@@ -0,0 +1,240 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrNoSession is returned when an operation needs a signed in user.
+var ErrNoSession = errors.New("not signed in")
+
+// Client talks to the Supabase auth (GoTrue) API of the ReEnvision backend.
+type Client struct {
+	BaseURL string
+	AnonKey string
+	HTTP    *http.Client
+}
+
+type User struct {
+	ID    string `json:"id"`
+	Email string `json:"email"`
+}
+
+type Session struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresAt    int64  `json:"expires_at"` // Unix seconds
+	User         User   `json:"user"`
+}
+
+// Expired reports whether the access token expires within the given margin.
+func (s *Session) Expired(margin time.Duration) bool {
+	return time.Now().Add(margin).Unix() >= s.ExpiresAt
+}
+
+// APIError is a non-2xx response from the backend.
+type APIError struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("%s (%d %s)", e.Message, e.StatusCode, e.Code)
+	}
+	return fmt.Sprintf("%s (%d)", e.Message, e.StatusCode)
+}
+
+// RateLimited reports whether the backend refused the request because too
+// many were made.
+func (e *APIError) RateLimited() bool {
+	return e.StatusCode == http.StatusTooManyRequests || strings.HasPrefix(e.Code, "over_")
+}
+
+// CaptchaRequired reports whether the backend requires a CAPTCHA, which only
+// the website can show.
+func (e *APIError) CaptchaRequired() bool {
+	return e.Code == "captcha_failed" || strings.Contains(strings.ToLower(e.Message), "captcha")
+}
+
+// EmailNotConfirmed reports whether sign in was refused because the account's
+// email address wasn't confirmed yet.
+func (e *APIError) EmailNotConfirmed() bool {
+	return e.Code == "email_not_confirmed" || strings.Contains(strings.ToLower(e.Message), "email not confirmed")
+}
+
+// CheckAnonKey reports whether key looks like a Supabase anon key, either a
+// JWT or a publishable key. The backend rejects every request made with
+// anything else, like a key that is still encrypted.
+func CheckAnonKey(key string) error {
+	if strings.HasPrefix(key, "sb_publishable_") {
+		return nil
+	}
+	parts := strings.Split(key, ".")
+	if len(parts) != 3 {
+		return errors.New("anon key is not a JWT or publishable key")
+	}
+	header, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return fmt.Errorf("anon key is not a JWT: %w", err)
+	}
+	var fields struct {
+		Alg string `json:"alg"`
+	}
+	if err := json.Unmarshal(header, &fields); err != nil || fields.Alg == "" {
+		return errors.New("anon key is not a JWT: invalid header")
+	}
+	return nil
+}
+
+func NewClient(baseURL, anonKey string) *Client {
+	return &Client{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		AnonKey: anonKey,
+		HTTP:    http.DefaultClient,
+	}
+}
+
+// SignIn exchanges an email and password for a session.
+func (c *Client) SignIn(ctx context.Context, email, password string) (*Session, error) {
+	body := map[string]string{"email": email, "password": password}
+	return c.token(ctx, "password", body)
+}
+
+// Refresh exchanges a refresh token for a new session.
+func (c *Client) Refresh(ctx context.Context, refreshToken string) (*Session, error) {
+	body := map[string]string{"refresh_token": refreshToken}
+	return c.token(ctx, "refresh_token", body)
+}
+
+// SignOut revokes the session on the backend.
+func (c *Client) SignOut(ctx context.Context, s *Session) error {
+	req, err := c.NewRequest(ctx, http.MethodPost, "/auth/v1/logout", nil, s)
+	if err != nil {
+		return err
+	}
+	return c.Do(req, nil)
+}
+
+// ResendConfirmation sends the email confirming a new account again.
+func (c *Client) ResendConfirmation(ctx context.Context, email string) error {
+	return c.post(ctx, "/auth/v1/resend", map[string]string{"type": "signup", "email": email})
+}
+
+// ResetPassword sends an email with a link to set a new password.
+func (c *Client) ResetPassword(ctx context.Context, email string) error {
+	return c.post(ctx, "/auth/v1/recover", map[string]string{"email": email})
+}
+
+func (c *Client) post(ctx context.Context, path string, body any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := c.NewRequest(ctx, http.MethodPost, path, bytes.NewReader(payload), nil)
+	if err != nil {
+		return err
+	}
+	return c.Do(req, nil)
+}
+
+func (c *Client) token(ctx context.Context, grantType string, body any) (*Session, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := c.NewRequest(ctx, http.MethodPost, "/auth/v1/token?grant_type="+url.QueryEscape(grantType), bytes.NewReader(payload), nil)
+	if err != nil {
+		return nil, err
+	}
+	var s Session
+	if err := c.Do(req, &s); err != nil {
+		return nil, err
+	}
+	if s.ExpiresAt == 0 {
+		return nil, errors.New("malformed session response")
+	}
+	return &s, nil
+}
+
+// NewRequest builds a request against the backend. If s is not nil the
+// request is authenticated as the signed in user.
+func (c *Client) NewRequest(ctx context.Context, method, path string, body io.Reader, s *Session) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("apikey", c.AnonKey)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if s != nil {
+		req.Header.Set("Authorization", "Bearer "+s.AccessToken)
+	}
+	return req, nil
+}
+
+// Do sends the request and decodes a JSON response into v, if v is not nil.
+// Non-2xx responses are returned as *APIError.
+func (c *Client) Do(req *http.Request, v any) error {
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return parseAPIError(resp.StatusCode, data)
+	}
+	if v == nil || len(data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("malformed response: %w", err)
+	}
+	return nil
+}
+
+// parseAPIError understands both the legacy and current GoTrue error formats.
+func parseAPIError(statusCode int, data []byte) error {
+	var body struct {
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+		ErrorCode        string `json:"error_code"`
+		Msg              string `json:"msg"`
+		Message          string `json:"message"`
+	}
+	apiErr := &APIError{StatusCode: statusCode}
+	if err := json.Unmarshal(data, &body); err != nil {
+		apiErr.Message = strings.TrimSpace(string(data))
+		if apiErr.Message == "" {
+			apiErr.Message = http.StatusText(statusCode)
+		}
+		return apiErr
+	}
+	apiErr.Code = body.ErrorCode
+	if apiErr.Code == "" {
+		apiErr.Code = body.Error
+	}
+	for _, msg := range []string{body.ErrorDescription, body.Msg, body.Message, body.Error, http.StatusText(statusCode)} {
+		if msg != "" {
+			apiErr.Message = msg
+			break
+		}
+	}
+	return apiErr
+}
@@ -0,0 +1,43 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"io"
+)
+
+// Requests to the app's own endpoints, like update checks, are signed with
+// an Ed25519 key generated by each install, so the server can tell clients
+// apart and rate limit the abusive ones. Keys are encoded as the base64 of
+// their seed.
+
+// NewNonce returns length random bytes read from r, base64 encoded for a URL.
+func NewNonce(r io.Reader, length int) (string, error) {
+	nonce := make([]byte, length)
+	if _, err := io.ReadFull(r, nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(nonce), nil
+}
+
+// NewSigningKey returns a new signing key, with its seed read from r.
+func NewSigningKey(r io.Reader) (string, error) {
+	seed := make([]byte, ed25519.SeedSize)
+	if _, err := io.ReadFull(r, seed); err != nil {
+		return "", fmt.Errorf("failed to generate signing key: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(seed), nil
+}
+
+// Sign signs data with key. The signature is sent as the Authorization
+// header, as the base64 public key and signature separated by a colon.
+func Sign(key string, data []byte) (string, error) {
+	seed, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return "", fmt.Errorf("invalid signing key")
+	}
+	private := ed25519.NewKeyFromSeed(seed)
+	public := private.Public().(ed25519.PublicKey)
+	return base64.StdEncoding.EncodeToString(public) + ":" + base64.StdEncoding.EncodeToString(ed25519.Sign(private, data)), nil
+}
@@ -0,0 +1,63 @@
+//go:build unit_test
+
+package auth
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestSign(t *testing.T) {
+	key, err := NewSigningKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte("GET,/api/update?nonce=abc")
+	signature, err := Sign(key, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	publicText, sigText, ok := strings.Cut(signature, ":")
+	if !ok {
+		t.Fatalf("expected the public key and signature, got %q", signature)
+	}
+	public, err := base64.StdEncoding.DecodeString(publicText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := base64.StdEncoding.DecodeString(sigText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ed25519.Verify(public, data, sig) {
+		t.Error("signature doesn't verify")
+	}
+	if ed25519.Verify(public, []byte("GET,/api/update?nonce=abd"), sig) {
+		t.Error("signature verifies other data")
+	}
+
+	if again, err := Sign(key, data); err != nil || !strings.HasPrefix(again, publicText+":") {
+		t.Errorf("expected the same public key for the same key, got %q, %v", again, err)
+	}
+	if _, err := Sign("not a key", data); err == nil {
+		t.Error("expected an error for an invalid key")
+	}
+}
+
+func TestNewNonce(t *testing.T) {
+	nonce, err := NewNonce(bytes.NewReader(bytes.Repeat([]byte{0xff}, 16)), 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nonce != "_____________________w" {
+		t.Errorf("unexpected nonce %q", nonce)
+	}
+	if _, err := NewNonce(bytes.NewReader(nil), 16); err == nil {
+		t.Error("expected an error when the random source runs out")
+	}
+}
@@ -0,0 +1,201 @@
+// Package datadir locates the folder the app keeps its data in, like the
+// store, logs, history and staged updates.
+//
+// It is normally "ReEnvision AI" in the local application data folder. When
+// that folder is on a network share, as with folder redirection, or is synced
+// by OneDrive, the sync client locks files the app writes and uploads every
+// rotated log again, so the data is kept in a local folder instead. Relocate
+// moves the data there once, and it stays there from then on. A local folder
+// is only used if it is private to the user, see isPrivateDir. The folder of
+// config.json, ReEnvisionAI next to the data folder, is moved the same way.
+package datadir
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// folder is a folder of the local application data folder Relocate may move.
+type folder struct {
+	name  string // In the local application data folder
+	local string // Folder of the local folder's root it is moved to, see localDir
+}
+
+var (
+	dataFolder   = folder{name: "ReEnvision AI", local: "Users"}
+	configFolder = folder{name: "ReEnvisionAI", local: "Config"} // Where the installer puts config.json
+)
+
+// notMoved are the entries of the data folder Relocate leaves behind, as they
+// are downloaded again.
+var notMoved = []string{"updates"}
+
+// Environment variables of the folders OneDrive syncs
+var syncedRootVars = []string{"OneDrive", "OneDriveCommercial", "OneDriveConsumer"}
+
+// Dir returns the data folder, which may not exist yet. It is the local
+// folder once Relocate moved the data there.
+func Dir() string {
+	return dataFolder.dir()
+}
+
+// ConfigDir returns the folder of config.json, which may not exist yet. It
+// is the local folder once Relocate moved it there.
+func ConfigDir() string {
+	return configFolder.dir()
+}
+
+func (f folder) dir() string {
+	if local := localDir(f); local != "" && isPrivateDir(local) {
+		return local
+	}
+	return f.preferredDir()
+}
+
+// preferredDir returns the folder in the local application data folder.
+func (f folder) preferredDir() string {
+	return filepath.Join(os.Getenv("LOCALAPPDATA"), f.name)
+}
+
+// UnsafeReason tells why path is no place for the app's data, empty if it is
+// fine.
+func UnsafeReason(path string) string {
+	if isNetworkPath(path) {
+		return "on a network drive"
+	}
+	for _, env := range syncedRootVars {
+		if root := os.Getenv(env); root != "" && within(path, root) {
+			return "synced by OneDrive"
+		}
+	}
+	return ""
+}
+
+// within reports whether path is root or in it.
+func within(path, root string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// Relocate moves the data and config folders to the local folder if the
+// local application data folder is unsafe, unless they were moved already.
+// Returns why a folder was moved, empty if none was.
+func Relocate() (reason string, err error) {
+	for _, f := range []folder{dataFolder, configFolder} {
+		moved, err := f.relocate()
+		if moved != "" {
+			reason = moved
+		}
+		if err != nil {
+			return reason, fmt.Errorf("failed to move %s: %w", f.name, err)
+		}
+	}
+	return reason, nil
+}
+
+func (f folder) relocate() (reason string, err error) {
+	preferred, dir := f.preferredDir(), localDir(f)
+	reason = UnsafeReason(preferred)
+	if reason == "" || dir == "" {
+		return "", nil
+	}
+	if _, err := os.Stat(dir); err == nil {
+		if !isPrivateDir(dir) {
+			return "", fmt.Errorf("%s was not created by this user, ignoring it", dir)
+		}
+		// Moved already. Files written to the old folder since, like the
+		// config.json of an update, replace the local ones.
+		if err := copyData(preferred, dir, true); err != nil {
+			return "", err
+		}
+		os.RemoveAll(preferred)
+		return "", nil
+	}
+
+	// Copied aside first, so an interrupted move is started over
+	staging := dir + ".moving"
+	if err := os.RemoveAll(staging); err != nil {
+		return reason, err
+	}
+	if err := os.MkdirAll(filepath.Dir(dir), 0o755); err != nil {
+		return reason, err
+	}
+	if err := createPrivateDir(staging); err != nil {
+		return reason, fmt.Errorf("failed to create %s: %w", staging, err)
+	}
+	if err := copyData(preferred, staging, false); err != nil {
+		os.RemoveAll(staging)
+		return reason, fmt.Errorf("failed to copy the data from %s: %w", preferred, err)
+	}
+	if err := os.Rename(staging, dir); err != nil {
+		os.RemoveAll(staging)
+		return reason, err
+	}
+	// What can't be removed, like a file the sync client locked, only costs space
+	os.RemoveAll(preferred)
+	return reason, nil
+}
+
+// copyData copies the data in from to to, except notMoved. A missing from
+// has nothing to copy. With onlyNewer, files are only copied if they are
+// newer than the ones in to, so copies left behind by a move that couldn't
+// be removed don't replace the data written since.
+func copyData(from, to string, onlyNewer bool) error {
+	if _, err := os.Stat(from); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return filepath.WalkDir(from, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(from, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		if d.IsDir() {
+			if slices.Contains(notMoved, rel) {
+				return filepath.SkipDir
+			}
+			return os.MkdirAll(filepath.Join(to, rel), 0o755)
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if onlyNewer {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			if existing, err := os.Stat(filepath.Join(to, rel)); err == nil && !info.ModTime().After(existing.ModTime()) {
+				return nil
+			}
+		}
+		return copyFile(path, filepath.Join(to, rel))
+	})
+}
+
+func copyFile(from, to string) error {
+	in, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
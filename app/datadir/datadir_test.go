@@ -0,0 +1,114 @@
+//go:build windows && unit_test
+
+package datadir
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestUnsafeReason(t *testing.T) {
+	oneDrive := t.TempDir()
+	t.Setenv("OneDrive", oneDrive)
+	t.Setenv("OneDriveCommercial", "")
+	t.Setenv("OneDriveConsumer", "")
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{filepath.Join(oneDrive, "AppData", dataFolder.name), "synced by OneDrive"},
+		{oneDrive + "-backup", ""},
+		{`\\server\profiles\user\AppData\Local\` + dataFolder.name, "on a network drive"},
+		{`\\?\UNC\server\profiles\user`, "on a network drive"},
+		{t.TempDir(), ""},
+	}
+	for _, test := range tests {
+		if got := UnsafeReason(test.path); got != test.want {
+			t.Errorf("%s: expected %q, got %q", test.path, test.want, got)
+		}
+	}
+}
+
+func TestCopyData(t *testing.T) {
+	from, to := t.TempDir(), t.TempDir()
+	for path, data := range map[string]string{
+		"config.json":                 `{"id": "node-1"}`,
+		filepath.Join("secrets", "a"): "secret",
+		filepath.Join("updates", "b"): "installer",
+	} {
+		os.MkdirAll(filepath.Join(from, filepath.Dir(path)), 0o755)
+		if err := os.WriteFile(filepath.Join(from, path), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := copyData(from, to, false); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(filepath.Join(to, "config.json")); err != nil || string(data) != `{"id": "node-1"}` {
+		t.Errorf("expected the store to be copied, got %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(to, "secrets", "a")); err != nil {
+		t.Errorf("expected subfolders to be copied, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(to, "updates")); err == nil {
+		t.Error("expected staged updates not to be copied")
+	}
+	if err := copyData(filepath.Join(from, "missing"), t.TempDir(), false); err != nil {
+		t.Errorf("expected nothing to copy from a missing folder, got %v", err)
+	}
+}
+
+func TestCopyDataOnlyNewer(t *testing.T) {
+	from, to := t.TempDir(), t.TempDir()
+	now := time.Now()
+	write := func(dir, name, data string, modTime time.Time) {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(to, "config.json", "local", now.Add(-time.Hour))
+	write(from, "config.json", "installer", now)
+	write(to, "store.json", "local", now)
+	write(from, "store.json", "left behind", now.Add(-time.Hour))
+
+	if err := copyData(from, to, true); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(to, "config.json")); string(data) != "installer" {
+		t.Errorf("expected a newer file to replace the local one, got %q", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(to, "store.json")); string(data) != "local" {
+		t.Errorf("expected an older file to be left alone, got %q", data)
+	}
+}
+
+func TestIsPrivateDir(t *testing.T) {
+	private := filepath.Join(t.TempDir(), "private")
+	if err := createPrivateDir(private); err != nil {
+		t.Fatal(err)
+	}
+	if !isPrivateDir(private) {
+		t.Errorf("%s: expected a private folder", private)
+	}
+
+	// Inherits the permissions of the temporary folder, as a folder another
+	// user made in ProgramData would
+	shared := filepath.Join(t.TempDir(), "shared")
+	if err := os.Mkdir(shared, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if isPrivateDir(shared) {
+		t.Errorf("%s: expected a folder that isn't private", shared)
+	}
+	if isPrivateDir(filepath.Join(t.TempDir(), "missing")) {
+		t.Error("expected a missing folder not to be private")
+	}
+}
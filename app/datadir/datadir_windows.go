@@ -0,0 +1,111 @@
+package datadir
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// localDir returns the local folder f is kept in when the local application
+// data folder is unsafe, in ProgramData as it is never redirected, empty if
+// there is none.
+func localDir(f folder) string {
+	programData := os.Getenv("ProgramData")
+	if programData == "" || UnsafeReason(programData) != "" {
+		return ""
+	}
+	user, err := windows.GetCurrentProcessToken().GetTokenUser()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(programData, dataFolder.name, f.local, user.User.Sid.String())
+}
+
+// isNetworkPath reports whether path is a UNC path or on a mapped network
+// drive.
+func isNetworkPath(path string) bool {
+	volume := filepath.VolumeName(path)
+	switch {
+	case strings.HasPrefix(strings.ToUpper(volume), `\\?\UNC\`):
+		return true
+	case strings.HasPrefix(volume, `\\?\`), strings.HasPrefix(volume, `\\.\`):
+		volume = volume[len(`\\?\`):]
+	case strings.HasPrefix(volume, `\\`):
+		return true
+	}
+	if volume == "" {
+		return false
+	}
+	root, err := windows.UTF16PtrFromString(volume + `\`)
+	if err != nil {
+		return false
+	}
+	return windows.GetDriveType(root) == windows.DRIVE_REMOTE
+}
+
+// createPrivateDir creates a folder owned by the user that only the user,
+// administrators and the system can access, as other users can read the
+// folders of ProgramData.
+func createPrivateDir(path string) error {
+	user, err := windows.GetCurrentProcessToken().GetTokenUser()
+	if err != nil {
+		return err
+	}
+	sid := user.User.Sid.String()
+	sd, err := windows.SecurityDescriptorFromString("O:" + sid + "D:P(A;OICI;FA;;;" + sid + ")(A;OICI;FA;;;SY)(A;OICI;FA;;;BA)")
+	if err != nil {
+		return err
+	}
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return err
+	}
+	sa := windows.SecurityAttributes{SecurityDescriptor: sd}
+	sa.Length = uint32(unsafe.Sizeof(sa))
+	return windows.CreateDirectory(p, &sa)
+}
+
+// isPrivateDir reports whether path is a folder createPrivateDir made: owned
+// by the user, with a protected DACL that only allows the user,
+// administrators and the system. Other users can create folders in
+// ProgramData, so one they made in the user's place, with a config.json
+// running hooks or to read the store, is not trusted.
+func isPrivateDir(path string) bool {
+	user, err := windows.GetCurrentProcessToken().GetTokenUser()
+	if err != nil {
+		return false
+	}
+	sd, err := windows.GetNamedSecurityInfo(path, windows.SE_FILE_OBJECT, windows.OWNER_SECURITY_INFORMATION|windows.DACL_SECURITY_INFORMATION)
+	if err != nil {
+		return false
+	}
+	owner, _, err := sd.Owner()
+	if err != nil || owner == nil || !owner.Equals(user.User.Sid) {
+		return false
+	}
+	control, _, err := sd.Control()
+	if err != nil || control&windows.SE_DACL_PROTECTED == 0 {
+		return false
+	}
+	dacl, _, err := sd.DACL()
+	if err != nil || dacl == nil {
+		return false
+	}
+	for i := range uint32(dacl.AceCount) {
+		var ace *windows.ACCESS_ALLOWED_ACE
+		if err := windows.GetAce(dacl, i, &ace); err != nil {
+			return false
+		}
+		if ace.Header.AceType != windows.ACCESS_ALLOWED_ACE_TYPE {
+			return false
+		}
+		sid := (*windows.SID)(unsafe.Pointer(&ace.SidStart))
+		if !sid.Equals(user.User.Sid) && !sid.IsWellKnown(windows.WinLocalSystemSid) && !sid.IsWellKnown(windows.WinBuiltinAdministratorsSid) {
+			return false
+		}
+	}
+	return true
+}
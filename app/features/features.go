@@ -0,0 +1,99 @@
+// Package features decides whether risky features are enabled on this node.
+//
+// Each flag has a compile-time default, which the experimental build tag turns
+// on for internal builds. The server can override it with a rule that enables
+// a flag for a percentage of nodes. The rules are evaluated against the node
+// ID, so the same node always lands in the same bucket. The results are cached
+// in the store, so overrides survive restarts and work while offline.
+package features
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/ReEnvision-AI/systray/app/store"
+)
+
+type Flag string
+
+const (
+	NewRuntimeBackend Flag = "new-runtime-backend" // Manage the container through the Podman REST API rather than the CLI
+	ContainerSwitch   Flag = "container-switch"    // Switch to a container with an updated image instead of restarting
+)
+
+// defaults holds the compile-time value of every known flag
+var defaults = map[Flag]bool{
+	NewRuntimeBackend: false,
+	ContainerSwitch:   false,
+}
+
+// Rule is the server-side configuration of a single flag.
+type Rule struct {
+	Enabled bool `json:"enabled"` // Kill switch, false disables the flag everywhere
+	Rollout int  `json:"rollout"` // Percentage of nodes, 0-100, that get the flag
+}
+
+type flagsResponse struct {
+	Flags map[Flag]Rule `json:"flags"`
+}
+
+// Enabled reports whether the flag is on for this node.
+func Enabled(f Flag) bool {
+	if enabled, ok := store.GetFeatureFlags()[string(f)]; ok {
+		return enabled
+	}
+	return defaults[f]
+}
+
+// Refresh fetches the flag rules from flagsURL, evaluates them for this node
+// and caches the result. Flags missing from the response fall back to their
+// compile-time default.
+func Refresh(ctx context.Context, flagsURL, userAgent string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, flagsURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch feature flags: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status fetching feature flags: %d", resp.StatusCode)
+	}
+
+	var flagsResp flagsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&flagsResp); err != nil {
+		return fmt.Errorf("malformed feature flags response: %w", err)
+	}
+
+	flags := evaluate(flagsResp.Flags, store.GetID())
+	slog.Debug("feature flags refreshed", "flags", flags)
+	store.SetFeatureFlags(flags)
+	return nil
+}
+
+func evaluate(rules map[Flag]Rule, nodeID string) map[string]bool {
+	flags := make(map[string]bool, len(rules))
+	for f, rule := range rules {
+		if _, known := defaults[f]; !known {
+			continue
+		}
+		flags[string(f)] = rule.Enabled && bucket(f, nodeID) < rule.Rollout
+	}
+	return flags
+}
+
+// bucket places the node in 0-99 for the given flag. The flag name is part of
+// the hash so the same nodes aren't always first to get every feature.
+func bucket(f Flag, nodeID string) int {
+	sum := sha256.Sum256([]byte(string(f) + ":" + nodeID))
+	return int(binary.BigEndian.Uint32(sum[:4]) % 100)
+}
@@ -0,0 +1,11 @@
+//go:build experimental
+
+package features
+
+// Internal builds get every flag by default so new code paths see real use
+// before they are rolled out. The server can still turn them off.
+func init() {
+	for f := range defaults {
+		defaults[f] = true
+	}
+}
@@ -0,0 +1,53 @@
+//go:build windows && unit_test
+
+package features
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestBucketIsStable(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		id := fmt.Sprintf("node-%d", i)
+		b := bucket(ContainerSwitch, id)
+		if b < 0 || b >= 100 {
+			t.Fatalf("bucket out of range: %d", b)
+		}
+		if b != bucket(ContainerSwitch, id) {
+			t.Fatalf("bucket for %s changed between calls", id)
+		}
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	rules := map[Flag]Rule{
+		ContainerSwitch:   {Enabled: true, Rollout: 100},
+		NewRuntimeBackend: {Enabled: false, Rollout: 100},
+		"unknown-flag":    {Enabled: true, Rollout: 100},
+	}
+	flags := evaluate(rules, "node")
+
+	if !flags[string(ContainerSwitch)] {
+		t.Error("Expected a 100% rollout to enable the flag")
+	}
+	if enabled, ok := flags[string(NewRuntimeBackend)]; !ok || enabled {
+		t.Error("Expected a disabled rule to turn the flag off")
+	}
+	if _, ok := flags["unknown-flag"]; ok {
+		t.Error("Expected unknown flags to be ignored")
+	}
+}
+
+func TestEvaluateRolloutPercentage(t *testing.T) {
+	rules := map[Flag]Rule{ContainerSwitch: {Enabled: true, Rollout: 25}}
+	enabled := 0
+	for i := 0; i < 1000; i++ {
+		if evaluate(rules, fmt.Sprintf("node-%d", i))[string(ContainerSwitch)] {
+			enabled++
+		}
+	}
+	if enabled < 150 || enabled > 350 {
+		t.Errorf("Expected roughly 25%% of nodes enabled, got %d of 1000", enabled)
+	}
+}
@@ -0,0 +1,85 @@
+// Package format renders counts, byte sizes and durations for display in the
+// tray, dialogs and notifications using the user's locale.
+package format
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+var (
+	mu      sync.Mutex
+	printer *message.Printer
+)
+
+var byteUnits = []string{"KiB", "MiB", "GiB", "TiB", "PiB"}
+
+// SetLocale overrides the locale detected from the system.
+func SetLocale(tag language.Tag) {
+	mu.Lock()
+	defer mu.Unlock()
+	printer = message.NewPrinter(tag)
+}
+
+func getPrinter() *message.Printer {
+	mu.Lock()
+	defer mu.Unlock()
+	if printer == nil {
+		tag, err := language.Parse(userLocale())
+		if err != nil {
+			slog.Debug("unable to parse user locale, using English", "error", err)
+			tag = language.English
+		}
+		printer = message.NewPrinter(tag)
+	}
+	return printer
+}
+
+// Count formats an integer with the locale's digit grouping, e.g. 1,234,567.
+func Count(n int64) string {
+	return getPrinter().Sprintf("%d", n)
+}
+
+// Bytes formats a size using binary units, e.g. 12.3 GiB.
+func Bytes(n int64) string {
+	p := getPrinter()
+	if n < 1024 && n > -1024 {
+		return p.Sprintf("%d B", n)
+	}
+	value := float64(n) / 1024
+	unit := 0
+	for (value >= 1024 || value <= -1024) && unit < len(byteUnits)-1 {
+		value /= 1024
+		unit++
+	}
+	return p.Sprintf("%.1f %s", value, byteUnits[unit])
+}
+
+// Duration formats a duration using its two most significant units, e.g.
+// 2 h 5 min. Anything under a second is shown as 0 s.
+func Duration(d time.Duration) string {
+	p := getPrinter()
+	if d < 0 {
+		d = -d
+	}
+	d = d.Round(time.Second)
+	days := int64(d / (24 * time.Hour))
+	hours := int64(d/time.Hour) % 24
+	minutes := int64(d/time.Minute) % 60
+	seconds := int64(d/time.Second) % 60
+
+	switch {
+	case days > 0:
+		return p.Sprintf("%d d %d h", days, hours)
+	case hours > 0:
+		return p.Sprintf("%d h %d min", hours, minutes)
+	case minutes > 0:
+		return p.Sprintf("%d min %d s", minutes, seconds)
+	default:
+		return p.Sprintf("%d s", seconds)
+	}
+}
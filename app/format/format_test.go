@@ -0,0 +1,63 @@
+//go:build unit_test
+
+package format
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/text/language"
+)
+
+func TestBytes(t *testing.T) {
+	SetLocale(language.English)
+	tests := []struct {
+		n        int64
+		expected string
+	}{
+		{0, "0 B"},
+		{1023, "1,023 B"},
+		{1536, "1.5 KiB"},
+		{5 << 30, "5.0 GiB"},
+		{3 << 40, "3.0 TiB"},
+	}
+	for _, test := range tests {
+		if got := Bytes(test.n); got != test.expected {
+			t.Errorf("Bytes(%d) = %q, expected %q", test.n, got, test.expected)
+		}
+	}
+}
+
+func TestCountLocale(t *testing.T) {
+	SetLocale(language.English)
+	if got := Count(1234567); got != "1,234,567" {
+		t.Errorf("expected 1,234,567, got %q", got)
+	}
+
+	SetLocale(language.German)
+	if got := Count(1234567); got != "1.234.567" {
+		t.Errorf("expected 1.234.567, got %q", got)
+	}
+	if got := Bytes(1536); got != "1,5 KiB" {
+		t.Errorf("expected 1,5 KiB, got %q", got)
+	}
+}
+
+func TestDuration(t *testing.T) {
+	SetLocale(language.English)
+	tests := []struct {
+		d        time.Duration
+		expected string
+	}{
+		{400 * time.Millisecond, "0 s"},
+		{45 * time.Second, "45 s"},
+		{3*time.Minute + 5*time.Second, "3 min 5 s"},
+		{2*time.Hour + 5*time.Minute, "2 h 5 min"},
+		{50 * time.Hour, "2 d 2 h"},
+	}
+	for _, test := range tests {
+		if got := Duration(test.d); got != test.expected {
+			t.Errorf("Duration(%v) = %q, expected %q", test.d, got, test.expected)
+		}
+	}
+}
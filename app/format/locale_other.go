@@ -0,0 +1,21 @@
+//go:build !windows
+
+package format
+
+import (
+	"os"
+	"strings"
+)
+
+// userLocale returns the BCP 47 name of the locale from the POSIX
+// environment, e.g. en_US.UTF-8 becomes en-US.
+func userLocale() string {
+	for _, env := range []string{"LC_ALL", "LC_NUMERIC", "LANG"} {
+		if value := os.Getenv(env); value != "" && value != "C" && value != "POSIX" {
+			value, _, _ = strings.Cut(value, ".")
+			value, _, _ = strings.Cut(value, "@")
+			return strings.ReplaceAll(value, "_", "-")
+		}
+	}
+	return "en"
+}
@@ -0,0 +1,20 @@
+package format
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var pGetUserDefaultLocaleName = windows.NewLazySystemDLL("kernel32.dll").NewProc("GetUserDefaultLocaleName")
+
+// userLocale returns the BCP 47 name of the user's locale, e.g. en-US.
+func userLocale() string {
+	const LOCALE_NAME_MAX_LENGTH = 85
+	buf := make([]uint16, LOCALE_NAME_MAX_LENGTH)
+	ret, _, _ := pGetUserDefaultLocaleName.Call(uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf)))
+	if ret == 0 {
+		return "en"
+	}
+	return windows.UTF16ToString(buf)
+}
@@ -0,0 +1,99 @@
+// Package heartbeat reports the node status to the backend.
+//
+// The transport is behind the Backend interface so self-hosted deployments
+// can receive heartbeats at any HTTPS endpoint, while the hosted service
+// uses a Supabase edge function. The backend is selected by the "heartbeat"
+// block of config.json.
+package heartbeat
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ReEnvision-AI/systray/app/auth"
+)
+
+// Values for Config.Backend
+const (
+	BackendSupabase = "supabase" // The default
+	BackendHTTPS    = "https"
+)
+
+const DefaultInterval = 5 * time.Minute
+
+// Config selects and configures the heartbeat backend.
+type Config struct {
+	Backend         string            `json:"backend"`
+	URL             string            `json:"url"`              // Endpoint of the https backend
+	Headers         map[string]string `json:"headers"`          // Extra request headers of the https backend, e.g. for auth
+	IntervalSeconds int               `json:"interval_seconds"` // 0 for DefaultInterval
+}
+
+// Beat is a single status report of the node.
+type Beat struct {
+	NodeID             string       `json:"node_id"`
+	State              string       `json:"state"`
+	Uptime             int64        `json:"uptime_seconds"` // Time running in this session, 0 unless running
+	SentAt             time.Time    `json:"sent_at"`
+	Operation          string       `json:"operation,omitempty"`           // Start or stop attempt the state belongs to
+	OrganizationID     string       `json:"organization_id,omitempty"`     // Organization the node contributes with
+	MachineFingerprint string       `json:"machine_fingerprint,omitempty"` // Hash telling machines with the same node ID apart
+	Details            *Details     `json:"details,omitempty"`             // Only sent with telemetry enabled
+	Summaries          []DaySummary `json:"daily_summaries,omitempty"`     // Finished days not reported yet
+	UninstallReason    string       `json:"uninstall_reason,omitempty"`    // Chosen in the uninstall survey, only in the final heartbeat
+}
+
+// Reply is what the backend answers to a heartbeat.
+type Reply struct {
+	// Another machine fingerprint recently reported the same node ID, as
+	// when a VM or disk image was copied with the app's settings
+	DuplicateNode bool `json:"duplicate_node"`
+}
+
+// DaySummary is the contribution of the node on one local day.
+type DaySummary struct {
+	Date           string `json:"date"` // YYYY-MM-DD
+	RunningSeconds int64  `json:"running_seconds"`
+	Starts         int    `json:"starts"`
+	Errors         int    `json:"errors"`
+}
+
+// Details are the optional parts of a heartbeat, left out in minimal mode.
+type Details struct {
+	Version string `json:"version"`
+	Channel string `json:"channel"`
+	OS      string `json:"os"`
+	Arch    string `json:"arch"`
+}
+
+// Backend delivers heartbeats.
+type Backend interface {
+	Send(ctx context.Context, beat Beat) (Reply, error)
+}
+
+// New returns the backend selected by cfg. The Supabase backend sends through
+// supabase, which may be nil when another backend is configured.
+func New(cfg Config, supabase *auth.Client, userAgent string) (Backend, error) {
+	switch cfg.Backend {
+	case "", BackendSupabase:
+		if supabase == nil {
+			return nil, fmt.Errorf("heartbeat backend %q needs the Supabase URL and key", BackendSupabase)
+		}
+		return &SupabaseBackend{Client: supabase, Path: SupabasePath, UserAgent: userAgent}, nil
+	case BackendHTTPS:
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("heartbeat backend %q needs a url", BackendHTTPS)
+		}
+		return &HTTPSBackend{URL: cfg.URL, Headers: cfg.Headers, UserAgent: userAgent}, nil
+	}
+	return nil, fmt.Errorf("unknown heartbeat backend %q (expected %q or %q)", cfg.Backend, BackendSupabase, BackendHTTPS)
+}
+
+// Interval returns how often heartbeats are sent.
+func (cfg Config) Interval() time.Duration {
+	if cfg.IntervalSeconds > 0 {
+		return time.Duration(cfg.IntervalSeconds) * time.Second
+	}
+	return DefaultInterval
+}
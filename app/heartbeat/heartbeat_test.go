@@ -0,0 +1,115 @@
+//go:build unit_test
+
+package heartbeat
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ReEnvision-AI/systray/app/auth"
+)
+
+func TestNew(t *testing.T) {
+	supabase := auth.NewClient("https://example.supabase.co", "anon")
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{"default", Config{}, false},
+		{"supabase", Config{Backend: BackendSupabase}, false},
+		{"https", Config{Backend: BackendHTTPS, URL: "https://example.com/beat"}, false},
+		{"https without url", Config{Backend: BackendHTTPS}, true},
+		{"unknown", Config{Backend: "carrier-pigeon"}, true},
+	}
+	for _, test := range tests {
+		_, err := New(test.cfg, supabase, "reai")
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		}
+	}
+	if _, err := New(Config{}, nil, "reai"); err == nil {
+		t.Error("expected an error for the supabase backend without a client")
+	}
+}
+
+func TestBackendsSend(t *testing.T) {
+	beat := Beat{NodeID: "node-1", State: "running", Uptime: 60, SentAt: time.Now().UTC()}
+
+	var got []*http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r)
+		var received Beat
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("failed to decode heartbeat: %v", err)
+		}
+		if received.NodeID != beat.NodeID || received.Details != nil {
+			t.Errorf("unexpected heartbeat %+v", received)
+		}
+	}))
+	defer srv.Close()
+
+	backends := []Backend{
+		&SupabaseBackend{Client: auth.NewClient(srv.URL, "anon"), Path: SupabasePath, UserAgent: "reai"},
+		&HTTPSBackend{URL: srv.URL + "/beat", Headers: map[string]string{"Authorization": "Bearer secret"}, UserAgent: "reai"},
+	}
+	for _, b := range backends {
+		if reply, err := b.Send(context.Background(), beat); err != nil || reply.DuplicateNode {
+			t.Fatalf("%T: unexpected reply %+v, error %v", b, reply, err)
+		}
+	}
+
+	if len(got) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(got))
+	}
+	if got[0].URL.Path != SupabasePath || got[0].Header.Get("apikey") != "anon" {
+		t.Errorf("unexpected supabase request %s %v", got[0].URL, got[0].Header)
+	}
+	if got[1].URL.Path != "/beat" || got[1].Header.Get("Authorization") != "Bearer secret" {
+		t.Errorf("unexpected https request %s %v", got[1].URL, got[1].Header)
+	}
+}
+
+func TestHTTPSBackendError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	if _, err := (&HTTPSBackend{URL: srv.URL}).Send(context.Background(), Beat{}); err == nil {
+		t.Error("expected an error for a 403 response")
+	}
+}
+
+func TestBackendsReply(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/text" {
+			w.Write([]byte("ok")) //nolint:errcheck
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"duplicate_node":true}`)) //nolint:errcheck
+	}))
+	defer srv.Close()
+
+	backends := []Backend{
+		&SupabaseBackend{Client: auth.NewClient(srv.URL, "anon"), Path: SupabasePath},
+		&HTTPSBackend{URL: srv.URL + "/beat"},
+	}
+	for _, b := range backends {
+		reply, err := b.Send(context.Background(), Beat{NodeID: "node-1"})
+		if err != nil || !reply.DuplicateNode {
+			t.Errorf("%T: expected a duplicate node reply, got %+v, error %v", b, reply, err)
+		}
+	}
+
+	// Self-hosted endpoints may not answer with JSON
+	reply, err := (&HTTPSBackend{URL: srv.URL + "/text"}).Send(context.Background(), Beat{})
+	if err != nil || reply.DuplicateNode {
+		t.Errorf("expected an empty reply for a text answer, got %+v, error %v", reply, err)
+	}
+}
@@ -0,0 +1,57 @@
+package heartbeat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+const maxReplySize = 64 << 10
+
+// HTTPSBackend posts heartbeats as JSON to any endpoint, for self-hosted
+// deployments of the backend.
+type HTTPSBackend struct {
+	URL       string
+	Headers   map[string]string
+	UserAgent string
+	HTTP      *http.Client // nil for http.DefaultClient
+}
+
+// Send posts the beat. The reply is read if the endpoint answers with JSON,
+// as self-hosted endpoints may answer with anything.
+func (b *HTTPSBackend) Send(ctx context.Context, beat Beat) (Reply, error) {
+	var reply Reply
+	body, err := json.Marshal(beat)
+	if err != nil {
+		return reply, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.URL, bytes.NewReader(body))
+	if err != nil {
+		return reply, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", b.UserAgent)
+	for k, v := range b.Headers {
+		req.Header.Set(k, v)
+	}
+	client := b.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return reply, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return reply, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	if data, err := io.ReadAll(io.LimitReader(resp.Body, maxReplySize)); err == nil {
+		json.Unmarshal(data, &reply) //nolint:errcheck
+	}
+	return reply, nil
+}
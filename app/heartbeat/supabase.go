@@ -0,0 +1,37 @@
+package heartbeat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/ReEnvision-AI/systray/app/auth"
+)
+
+// SupabasePath is the edge function receiving heartbeats, relative to the
+// Supabase URL.
+var SupabasePath = "/functions/v1/node-heartbeat"
+
+// SupabaseBackend sends heartbeats to an edge function of the hosted backend,
+// authenticated with the project's anon key.
+type SupabaseBackend struct {
+	Client    *auth.Client
+	Path      string
+	UserAgent string
+}
+
+func (b *SupabaseBackend) Send(ctx context.Context, beat Beat) (Reply, error) {
+	var reply Reply
+	body, err := json.Marshal(beat)
+	if err != nil {
+		return reply, err
+	}
+	req, err := b.Client.NewRequest(ctx, http.MethodPost, b.Path, bytes.NewReader(body), nil)
+	if err != nil {
+		return reply, err
+	}
+	req.Header.Set("User-Agent", b.UserAgent)
+	err = b.Client.Do(req, &reply)
+	return reply, err
+}
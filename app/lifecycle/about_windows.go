@@ -0,0 +1,41 @@
+package lifecycle
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/ReEnvision-AI/systray/app/assets"
+	"github.com/ReEnvision-AI/systray/app/store"
+	"github.com/ReEnvision-AI/systray/version"
+	"golang.org/x/sys/windows"
+)
+
+// handleShowAbout shows the version and build details of the app, the
+// signed in account and node ID, and offers to open the third-party notices.
+func handleShowAbout() {
+	account := signedInEmail()
+	if account == "" {
+		account = "Not signed in"
+	}
+	message := "Version " + version.String() + "\n" +
+		"Built with " + runtime.Version() + " for " + runtime.GOOS + "/" + runtime.GOARCH + "\n\n" +
+		"Account: " + account + "\n" +
+		"Node ID: " + store.GetID() + "\n\n" +
+		"ReEnvision AI includes open source software. View the third-party notices?"
+	if messageBox("About ReEnvision AI", message, windows.MB_YESNO|windows.MB_ICONINFORMATION) != IDYES {
+		return
+	}
+
+	// Notepad is a better viewer for a few hundred lines of license text than a
+	// message box, so write a copy to the temp directory and open that
+	path := filepath.Join(os.TempDir(), "ReEnvisionAI-THIRD_PARTY_NOTICES.txt")
+	if err := os.WriteFile(path, []byte(assets.ThirdPartyNotices()), 0o644); err != nil {
+		slog.Error("Failed to write third-party notices", "path", path, "error", err)
+		return
+	}
+	if err := openURL(path); err != nil {
+		slog.Error("Failed to open third-party notices", "error", err)
+	}
+}
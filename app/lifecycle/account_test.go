@@ -0,0 +1,43 @@
+//go:build windows && unit_test
+
+package lifecycle
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ReEnvision-AI/systray/app/auth"
+)
+
+func TestSignInBackoff(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{1, 2 * time.Second},
+		{2, 4 * time.Second},
+		{5, 32 * time.Second},
+		{6, time.Minute},
+		{100, time.Minute},
+	}
+	for _, tt := range tests {
+		if got := signInBackoff(tt.failures); got != tt.want {
+			t.Errorf("signInBackoff(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestSessionRefreshDelay(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	if got := sessionRefreshDelay(nil, now); got != sessionCheckInterval {
+		t.Errorf("expected %v without a session, got %v", sessionCheckInterval, got)
+	}
+	s := &auth.Session{ExpiresAt: now.Add(time.Hour).Unix()}
+	if got, want := sessionRefreshDelay(s, now), time.Hour-sessionRefreshMargin; got != want {
+		t.Errorf("expected refresh in %v, got %v", want, got)
+	}
+	s.ExpiresAt = now.Add(-time.Hour).Unix()
+	if got := sessionRefreshDelay(s, now); got != 0 {
+		t.Errorf("expired session should be refreshed at once, got %v", got)
+	}
+}
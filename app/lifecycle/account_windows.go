@@ -0,0 +1,366 @@
+package lifecycle
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/ReEnvision-AI/systray/app/auth"
+	"github.com/ReEnvision-AI/systray/app/secrets"
+	"golang.org/x/sys/windows"
+)
+
+const (
+	sessionCredentialTarget = "ReEnvisionAI/session"
+	signInBackoffBase       = 2 * time.Second
+	signInBackoffMax        = time.Minute
+	sessionRefreshMargin    = 5 * time.Minute
+	sessionCheckInterval    = time.Hour        // How often to look for a session while nobody is signed in
+	sessionRefreshTimeout   = 30 * time.Second // For refreshes in the background
+	sessionRetryInterval    = 5 * time.Minute  // After a failed background refresh
+)
+
+// BrowserSignInURL is the website's sign in page, used when the app can't
+// sign in itself.
+var BrowserSignInURL = "https://sociallyshaped.net/login"
+
+var (
+	sessionMu sync.Mutex
+	session   *auth.Session // Cached copy of the session stored in Credential Manager
+
+	// Failed sign ins in a row and when the next one may be attempted, guarded
+	// by sessionMu
+	signInFailures int
+	signInRetryAt  time.Time
+
+	// Signalled to recompute when the session is refreshed next, as timers
+	// don't count the time the system slept
+	sessionRescheduled = make(chan struct{}, 1)
+)
+
+func newAuthClient(cfg AppConfig) (*auth.Client, error) {
+	if cfg.SupabaseURL == "" || cfg.SupabaseAnonKey == "" {
+		return nil, errors.New("backend is not configured (supabaseUrl, supabaseAnonKey)")
+	}
+	if err := auth.CheckAnonKey(cfg.SupabaseAnonKey); err != nil {
+		return nil, fmt.Errorf("backend is not configured (supabaseAnonKey): %w", err)
+	}
+	return auth.NewClient(cfg.SupabaseURL, cfg.SupabaseAnonKey), nil
+}
+
+// getSession returns a valid session for the signed in user, refreshing it
+// or prompting the user to sign in as needed.
+func getSession(ctx context.Context, client *auth.Client) (*auth.Session, error) {
+	sessionMu.Lock()
+	defer sessionMu.Unlock()
+
+	if session == nil {
+		s, err := loadSession()
+		if err != nil {
+			slog.Debug("no stored session", "error", err)
+		}
+		session = s
+	}
+
+	if session != nil && session.Expired(sessionRefreshMargin) {
+		slog.Info("Refreshing session")
+		s, err := client.Refresh(ctx, session.RefreshToken)
+		if err != nil {
+			slog.Warn("Failed to refresh session, signing in again", "error", err)
+			session = nil
+		} else {
+			session = s
+			saveSession(s)
+		}
+	}
+
+	if session == nil {
+		s, err := signIn(ctx, client)
+		if err != nil {
+			return nil, err
+		}
+		session = s
+		saveSession(s)
+	}
+	return session, nil
+}
+
+// storedSession returns the stored session, refreshed if needed, without
+// prompting. Returns auth.ErrNoSession if nobody is signed in, for
+// background requests.
+func storedSession(ctx context.Context, client *auth.Client) (*auth.Session, error) {
+	sessionMu.Lock()
+	defer sessionMu.Unlock()
+
+	if session == nil {
+		s, err := loadSession()
+		if err != nil {
+			return nil, auth.ErrNoSession
+		}
+		session = s
+	}
+	if session.Expired(sessionRefreshMargin) {
+		s, err := client.Refresh(ctx, session.RefreshToken)
+		if err != nil {
+			return nil, fmt.Errorf("failed to refresh session: %w", err)
+		}
+		session = s
+		saveSession(s)
+	}
+	return session, nil
+}
+
+// backendSession returns the backend client and a session from getter,
+// getSession to prompt for signing in or storedSession in the background.
+func backendSession(ctx context.Context, getter func(context.Context, *auth.Client) (*auth.Session, error)) (*auth.Client, *auth.Session, error) {
+	cfg, err := readConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+	client, err := newAuthClient(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	s, err := getter(ctx, client)
+	if err != nil {
+		return nil, nil, err
+	}
+	return client, s, nil
+}
+
+// signIn prompts for credentials until sign in succeeds or the user cancels.
+// Each failed attempt delays the next one longer. When the backend rate
+// limits sign ins or wants a CAPTCHA, the website's sign in page is opened
+// instead, as only it can show one. Must be called with sessionMu held.
+func signIn(ctx context.Context, client *auth.Client) (*auth.Session, error) {
+	var email, errText string
+	for {
+		if wait := time.Until(signInRetryAt); wait > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(wait):
+			}
+		}
+		var password string
+		var action loginAction
+		email, password, action = promptForCredentials("ReEnvision AI", "Sign in with your ReEnvision AI account", email, errText)
+		switch action {
+		case loginCancelled:
+			return nil, auth.ErrNoSession
+		case loginResetPassword:
+			errText = sendPasswordReset(ctx, client, email)
+			continue
+		}
+		s, err := client.SignIn(ctx, email, password)
+		if err == nil {
+			slog.Info("Signed in", "user_id", s.User.ID)
+			signInFailures, signInRetryAt = 0, time.Time{}
+			return s, nil
+		}
+		var apiErr *auth.APIError
+		if !errors.As(err, &apiErr) {
+			return nil, fmt.Errorf("sign in failed: %w", err)
+		}
+		if apiErr.RateLimited() || apiErr.CaptchaRequired() {
+			slog.Warn("Sign in refused, continuing on the website", "error", err)
+			messageBox("ReEnvision AI", "Your account needs to be verified on the website before signing in here. "+
+				"The sign in page opens in your browser, sign in there and then try again.", windows.MB_OK|windows.MB_ICONINFORMATION)
+			if err := openURL(BrowserSignInURL); err != nil {
+				slog.Warn("failed to open the sign in page", "error", err)
+			}
+			return nil, auth.ErrNoSession
+		}
+		if apiErr.EmailNotConfirmed() {
+			errText = resendConfirmation(ctx, client, email)
+			continue
+		}
+
+		signInFailures++
+		delay := signInBackoff(signInFailures)
+		signInRetryAt = time.Now().Add(delay)
+		slog.Warn("Sign in failed", "failures", signInFailures, "retry_in", delay, "error", err)
+		errText = fmt.Sprintf("Sign in failed: %s. Please try again.", apiErr.Message)
+	}
+}
+
+// sendPasswordReset has the password reset email sent to email and returns
+// the text telling the user about it. The reset page of the website is opened
+// instead if the backend refuses to send it.
+func sendPasswordReset(ctx context.Context, client *auth.Client, email string) string {
+	err := client.ResetPassword(ctx, email)
+	if err == nil {
+		slog.Info("Sent password reset email")
+		return "We sent a link to set a new password to " + email + ". Open it, then sign in with the new password."
+	}
+	slog.Warn("Failed to send password reset email", "error", err)
+	if err := openURL(ForgotPasswordURL); err != nil {
+		slog.Warn("failed to open the password reset page", "error", err)
+		return "The password reset email couldn't be sent. Please try again later."
+	}
+	return "The password reset email couldn't be sent, reset your password on the page opened in your browser."
+}
+
+// resendConfirmation offers to send the confirmation email of a new account
+// again and returns the text telling the user what to do.
+func resendConfirmation(ctx context.Context, client *auth.Client, email string) string {
+	if !confirm("ReEnvision AI", "The email address "+email+" isn't confirmed yet. Open the link in the email we sent "+
+		"when you signed up to confirm it.\n\nSend the confirmation email again?") {
+		return "Confirm your email address with the link we sent to it, then sign in."
+	}
+	if err := client.ResendConfirmation(ctx, email); err != nil {
+		slog.Warn("Failed to resend confirmation email", "error", err)
+		return "The confirmation email couldn't be sent. Please try again later."
+	}
+	slog.Info("Sent confirmation email again")
+	return "We sent a new confirmation link to " + email + ". Open it, then sign in."
+}
+
+// StartSessionRefresh refreshes the stored session shortly before it expires
+// until ctx is cancelled, so it is still valid when the node or a background
+// check needs it.
+func StartSessionRefresh(ctx context.Context) {
+	go func() {
+		var failed bool
+		for {
+			sessionMu.Lock()
+			if session == nil {
+				session, _ = loadSession()
+			}
+			delay := sessionRefreshDelay(session, time.Now())
+			sessionMu.Unlock()
+			if failed {
+				delay = max(delay, sessionRetryInterval) // Likely offline, don't retry at once
+			}
+			slog.Debug("next session refresh", "in", delay)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-sessionRescheduled:
+				continue
+			case <-time.After(delay):
+			}
+			err := refreshStoredSession(ctx, false)
+			if failed = err != nil; failed {
+				slog.Warn("Failed to refresh session", "error", err)
+			}
+		}
+	}()
+}
+
+// sessionRefreshDelay returns the time until s is refreshed, or when to look
+// again if nobody is signed in.
+func sessionRefreshDelay(s *auth.Session, now time.Time) time.Duration {
+	if s == nil {
+		return sessionCheckInterval
+	}
+	return max(time.Unix(s.ExpiresAt, 0).Add(-sessionRefreshMargin).Sub(now), 0)
+}
+
+// refreshSessionAfterWake refreshes the stored session after the system
+// slept, as its token is often stale after a long sleep.
+func refreshSessionAfterWake() {
+	ctx, cancel := context.WithTimeout(context.Background(), sessionRefreshTimeout)
+	defer cancel()
+	if err := refreshStoredSession(ctx, true); err != nil {
+		slog.Warn("Failed to refresh session after wake", "error", err)
+	}
+	select {
+	case sessionRescheduled <- struct{}{}:
+	default:
+	}
+}
+
+// refreshStoredSession refreshes the stored session if it is about to expire
+// or force is set. Does nothing if nobody is signed in.
+func refreshStoredSession(ctx context.Context, force bool) error {
+	client, err := newAuthClient(currentAppConfig())
+	if err != nil {
+		return nil // Not loaded yet, or no backend to sign in to
+	}
+	ctx, cancel := context.WithTimeout(ctx, sessionRefreshTimeout)
+	defer cancel()
+
+	sessionMu.Lock()
+	defer sessionMu.Unlock()
+	if session == nil {
+		s, err := loadSession()
+		if err != nil {
+			return nil
+		}
+		session = s
+	}
+	if !force && !session.Expired(sessionRefreshMargin) {
+		return nil
+	}
+	s, err := client.Refresh(ctx, session.RefreshToken)
+	if err != nil {
+		return err
+	}
+	session = s
+	saveSession(s)
+	slog.Info("Refreshed session", "expires_in", time.Until(time.Unix(s.ExpiresAt, 0)).Round(time.Second))
+	return nil
+}
+
+// signInBackoff returns the delay after failures failed sign ins in a row.
+func signInBackoff(failures int) time.Duration {
+	delay := signInBackoffBase
+	for i := 1; i < failures && delay < signInBackoffMax; i++ {
+		delay *= 2
+	}
+	return min(delay, signInBackoffMax)
+}
+
+// signedInEmail returns the email of the stored session without refreshing
+// it or prompting, or "" if nobody is signed in.
+func signedInEmail() string {
+	sessionMu.Lock()
+	defer sessionMu.Unlock()
+	if session == nil {
+		s, err := loadSession()
+		if err != nil {
+			return ""
+		}
+		session = s
+	}
+	return session.User.Email
+}
+
+func loadSession() (*auth.Session, error) {
+	blob, err := secrets.Default().Get(sessionCredentialTarget)
+	if err != nil {
+		return nil, err
+	}
+	var s auth.Session
+	if err := json.Unmarshal(blob, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse stored session: %w", err)
+	}
+	return &s, nil
+}
+
+func saveSession(s *auth.Session) {
+	blob, err := json.Marshal(s)
+	if err != nil {
+		slog.Error("failed to marshal session", "error", err)
+		return
+	}
+	if err := secrets.Default().Set(sessionCredentialTarget, blob); err != nil {
+		slog.Error("failed to store session", "error", err)
+	}
+}
+
+// clearSession forgets the session locally. It doesn't revoke it on the backend.
+func clearSession() {
+	sessionMu.Lock()
+	defer sessionMu.Unlock()
+	session = nil
+	if err := secrets.Default().Delete(sessionCredentialTarget); err != nil {
+		slog.Warn("failed to delete stored session", "error", err)
+	}
+}
@@ -0,0 +1,140 @@
+package lifecycle
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/ReEnvision-AI/systray/app/store"
+	"github.com/ReEnvision-AI/systray/app/tray/commontray"
+)
+
+// Announcements are scheduled maintenance windows and incidents published by
+// the backend, so contributors don't take planned downtime for a local
+// failure. Each one is notified once and the most relevant one stays in the
+// tray menu until it ends.
+
+var (
+	AnnouncementsURL          = "https://sociallyshaped.net/api/announcements"
+	AnnouncementCheckInterval = 15 * time.Minute
+)
+
+type Announcement struct {
+	ID       string    `json:"id"`
+	Title    string    `json:"title"` // Short enough for a menu line, e.g. "Network maintenance"
+	Message  string    `json:"message"`
+	StartsAt time.Time `json:"starts_at"` // Zero for incidents that already started
+	EndsAt   time.Time `json:"ends_at"`   // Zero until the end is known
+}
+
+// StartAnnouncementChecker polls the backend for announcements until ctx is
+// cancelled.
+func StartAnnouncementChecker(ctx context.Context) {
+	go func() {
+		for {
+			announcements, err := fetchAnnouncements(ctx, currentEndpoints().Announcements)
+			if err != nil {
+				slog.Debug("failed to fetch announcements", "error", err)
+			} else {
+				showAnnouncements(announcements, time.Now())
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(AnnouncementCheckInterval):
+			}
+		}
+	}()
+}
+
+func fetchAnnouncements(ctx context.Context, announcementsURL string) ([]Announcement, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, announcementsURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent())
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	var announcements []Announcement
+	if err := json.NewDecoder(resp.Body).Decode(&announcements); err != nil {
+		return nil, err
+	}
+	return announcements, nil
+}
+
+// showAnnouncements notifies the announcements not seen before and puts the
+// current one in the tray menu.
+func showAnnouncements(announcements []Announcement, now time.Time) {
+	seen := store.GetSeenAnnouncements()
+	var keep []string
+	var current *Announcement
+	for i, a := range announcements {
+		if !a.EndsAt.IsZero() && !a.EndsAt.After(now) {
+			continue
+		}
+		keep = append(keep, a.ID)
+		if !slices.Contains(seen, a.ID) {
+			slog.Info("Backend announcement", "id", a.ID, "title", a.Title, "starts_at", a.StartsAt, "ends_at", a.EndsAt)
+			notify(commontray.NotifyInfo, announcementText(a, now), a.Message)
+		}
+		if current == nil || a.StartsAt.Before(current.StartsAt) {
+			current = &announcements[i]
+		}
+	}
+	// Only IDs still published are kept, so the list doesn't grow forever
+	store.SetSeenAnnouncements(keep)
+
+	text := ""
+	if current != nil {
+		text = announcementText(*current, now)
+	}
+	if err := t.SetAnnouncement(text); err != nil {
+		slog.Warn("failed to update tray announcement", "error", err)
+	}
+}
+
+// announcementText describes an announcement and its window in local time,
+// e.g. "Network maintenance tonight 02:00–03:00 CET".
+func announcementText(a Announcement, now time.Time) string {
+	if a.StartsAt.IsZero() || a.EndsAt.IsZero() {
+		return a.Title
+	}
+	start, end := a.StartsAt.In(now.Location()), a.EndsAt.In(now.Location())
+
+	if !start.After(now) {
+		return fmt.Sprintf("%s now until %s", a.Title, end.Format("15:04 MST"))
+	}
+	day := start.Format("Mon Jan 2")
+	switch {
+	case sameDay(start, now):
+		day = "today"
+		if start.Hour() >= 18 {
+			day = "tonight"
+		}
+	case sameDay(start, now.AddDate(0, 0, 1)):
+		day = "tomorrow"
+		// The small hours after midnight are still "tonight"
+		if start.Hour() < 6 {
+			day = "tonight"
+		}
+	}
+	return fmt.Sprintf("%s %s %s–%s", a.Title, day, start.Format("15:04"), end.Format("15:04 MST"))
+}
+
+func sameDay(a, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return ay == by && am == bm && ad == bd
+}
@@ -0,0 +1,37 @@
+//go:build windows && unit_test
+
+package lifecycle
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAnnouncementText(t *testing.T) {
+	now := time.Date(2025, 3, 10, 14, 0, 0, 0, time.UTC)
+	window := func(start, end time.Time) Announcement {
+		return Announcement{Title: "Network maintenance", StartsAt: start, EndsAt: end}
+	}
+	tests := []struct {
+		name     string
+		a        Announcement
+		expected string
+	}{
+		{"open ended", Announcement{Title: "Degraded performance"}, "Degraded performance"},
+		{"after midnight", window(time.Date(2025, 3, 11, 2, 0, 0, 0, time.UTC), time.Date(2025, 3, 11, 3, 0, 0, 0, time.UTC)),
+			"Network maintenance tonight 02:00–03:00 UTC"},
+		{"this evening", window(time.Date(2025, 3, 10, 20, 0, 0, 0, time.UTC), time.Date(2025, 3, 10, 21, 0, 0, 0, time.UTC)),
+			"Network maintenance tonight 20:00–21:00 UTC"},
+		{"tomorrow", window(time.Date(2025, 3, 11, 9, 0, 0, 0, time.UTC), time.Date(2025, 3, 11, 10, 0, 0, 0, time.UTC)),
+			"Network maintenance tomorrow 09:00–10:00 UTC"},
+		{"later", window(time.Date(2025, 3, 14, 9, 0, 0, 0, time.UTC), time.Date(2025, 3, 14, 10, 0, 0, 0, time.UTC)),
+			"Network maintenance Fri Mar 14 09:00–10:00 UTC"},
+		{"ongoing", window(time.Date(2025, 3, 10, 13, 0, 0, 0, time.UTC), time.Date(2025, 3, 10, 15, 0, 0, 0, time.UTC)),
+			"Network maintenance now until 15:00 UTC"},
+	}
+	for _, test := range tests {
+		if got := announcementText(test.a, now); got != test.expected {
+			t.Errorf("%s: got %q, expected %q", test.name, got, test.expected)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+//go:build windows && unit_test
+
+package lifecycle
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestAnonymousMode(t *testing.T) {
+	saved := appConfig
+	defer func() { appConfig = saved }()
+	appConfig = AppConfig{ContainerName: "reai", ContainerImage: "node:1", ModelName: "org/model", Anonymous: true}
+
+	if !anonymousMode() {
+		t.Fatal("anonymous in config.json should turn on anonymous mode")
+	}
+	args := buildPodmanRunCommandArgs(nil, nil, nil)
+	if i := slices.Index(args, "--public_name"); i < 0 || i+1 >= len(args) || args[i+1] != anonymousPublicName {
+		t.Errorf("expected the anonymous public name in %v", args)
+	}
+	if tooltip := trayTooltip("Running"); !strings.Contains(tooltip, "not credited") {
+		t.Errorf("tooltip should show contribution isn't credited, got %q", tooltip)
+	}
+}
@@ -0,0 +1,56 @@
+package lifecycle
+
+import (
+	"log/slog"
+
+	"github.com/ReEnvision-AI/systray/app/store"
+	"golang.org/x/sys/windows"
+)
+
+// In anonymous mode the node runs without a ReEnvision AI account, for
+// evaluation and air-gapped demos. It sends no heartbeats and joins the swarm
+// under an anonymous public name, so its contribution isn't credited to
+// anyone, which the tray shows next to the status. It is chosen from the tray
+// menu, or with "anonymous": true in config.json for deployments where it
+// can't be turned off.
+
+const anonymousPublicName = "anonymous"
+
+// anonymousMode reports whether the node runs without an account.
+func anonymousMode() bool {
+	return currentAppConfig().Anonymous || store.GetAnonymousMode()
+}
+
+// refreshAnonymousMode updates the tray after anonymous mode changed.
+func refreshAnonymousMode() {
+	if err := t.SetAnonymousMode(anonymousMode()); err != nil {
+		slog.Warn("failed to update tray for anonymous mode", "error", err)
+	}
+	stateMu.Lock()
+	state := currentState
+	stateMu.Unlock()
+	setStatusText(state, "")
+	t.SetStatusInfo(statusInfo(state))
+}
+
+func handleToggleAnonymousMode() {
+	anonymous := !store.GetAnonymousMode()
+	if !anonymous && currentAppConfig().Anonymous {
+		messageBox("ReEnvision AI", "Anonymous mode is turned on in config.json, so it can't be turned off here.", windows.MB_OK|windows.MB_ICONINFORMATION)
+		return
+	}
+	if anonymous && !confirm("ReEnvision AI", "In anonymous mode the node runs without your account. It doesn't report its status "+
+		"and the time it contributes isn't credited to anyone.\n\nRun anonymously?") {
+		return
+	}
+	store.SetAnonymousMode(anonymous)
+	slog.Info("Anonymous mode changed", "enabled", anonymous)
+	refreshAnonymousMode()
+
+	stateMu.Lock()
+	running := currentState == StateRunning || currentState == StateLoading || currentState == StateStarting || currentState == StatePaused
+	stateMu.Unlock()
+	if running && confirm("ReEnvision AI", "The node joins the network under its new name the next time it starts. Restart the node now?") {
+		restartRunningNode()
+	}
+}
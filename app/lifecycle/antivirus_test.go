@@ -0,0 +1,56 @@
+//go:build windows && unit_test
+
+package lifecycle
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"testing"
+
+	"golang.org/x/sys/windows"
+)
+
+func TestAVInterferenceKind(t *testing.T) {
+	stageDir := UpdateStageDir
+	UpdateStageDir = `C:\Users\user\AppData\Local\ReEnvision AI\updates`
+	defer func() { UpdateStageDir = stageDir }()
+
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"staged installer denied", fmt.Errorf("failed to create update file: %w",
+			&fs.PathError{Op: "open", Path: `C:\Users\user\AppData\Local\ReEnvision AI\Updates\abc\setup.exe`, Err: windows.ERROR_ACCESS_DENIED}), avBlockedUpdate},
+		{"denied elsewhere", &fs.PathError{Op: "open", Path: `C:\Windows\setup.exe`, Err: windows.ERROR_ACCESS_DENIED}, ""},
+		{"installer quarantined", fmt.Errorf("unable to start ReEnvision AI app %w",
+			&fs.PathError{Op: "fork/exec", Path: `D:\setup.exe`, Err: windows.ERROR_VIRUS_INFECTED}), avBlockedUpdate},
+		{"podman pipe denied", errors.New(`unable to connect to Podman: open \\.\pipe\podman-machine-default: Access is denied.`), avBlockedPodman},
+		{"podman unreachable", errors.New(`unable to connect to Podman: connection refused`), ""},
+		{"no error", nil, ""},
+	}
+	for _, test := range tests {
+		if got := avInterferenceKind(test.err); got != test.want {
+			t.Errorf("%s: expected %q, got %q", test.name, test.want, got)
+		}
+	}
+}
+
+func TestAVGuidance(t *testing.T) {
+	finding := avInterference{
+		Kind:     avBlockedPodman,
+		Folders:  []string{`C:\Users\user\AppData\Local\ReEnvision AI`},
+		Programs: []string{`C:\Program Files\RedHat\Podman\podman.exe`},
+	}
+	text := finding.guidance()
+	for _, want := range []string{"Podman machine", `Folder: C:\Users\user\AppData\Local\ReEnvision AI`, `Program: C:\Program Files\RedHat\Podman\podman.exe`} {
+		if !strings.Contains(text, want) {
+			t.Errorf("expected the guidance to contain %q, got %q", want, text)
+		}
+	}
+	if got := antivirusText(nil); got != "None detected\r\n" {
+		t.Errorf("expected no interference, got %q", got)
+	}
+}
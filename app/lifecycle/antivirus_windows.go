@@ -0,0 +1,173 @@
+package lifecycle
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ReEnvision-AI/systray/app/tray/commontray"
+	"golang.org/x/sys/windows"
+)
+
+// Antivirus and endpoint protection products sometimes quarantine the staged
+// installer or block the named pipe of the Podman machine, which otherwise
+// shows up as unexplained update and start failures. Errors with those
+// signatures are recognised, and the user is told once per run which folders
+// and programs to allow. The findings are included in the diagnostics.
+
+// Kinds of antivirus interference
+const (
+	avBlockedUpdate = "update" // Writing or running the staged installer was refused
+	avBlockedPodman = "podman" // The named pipe of the Podman machine was refused
+)
+
+const avGuidanceClickTime = 30 * time.Minute
+
+// avInterference is an error showing antivirus interference.
+type avInterference struct {
+	Kind     string
+	Time     time.Time
+	Err      string
+	Folders  []string // To allow in the antivirus
+	Programs []string
+}
+
+var (
+	avMu       sync.Mutex
+	avFindings = map[string]avInterference{} // The latest of each kind
+)
+
+// avInterferenceKind returns the kind of antivirus interference err shows,
+// empty if none.
+func avInterferenceKind(err error) string {
+	if err == nil {
+		return ""
+	}
+	if errors.Is(err, windows.ERROR_VIRUS_INFECTED) || errors.Is(err, windows.ERROR_VIRUS_DELETED) {
+		return avBlockedUpdate
+	}
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) && inDir(pathErr.Path, UpdateStageDir) &&
+		(errors.Is(err, windows.ERROR_ACCESS_DENIED) || errors.Is(err, windows.ERROR_SHARING_VIOLATION)) {
+		return avBlockedUpdate
+	}
+	text := strings.ToLower(err.Error())
+	if strings.Contains(text, `\pipe\`) && strings.Contains(text, "access is denied") {
+		return avBlockedPodman
+	}
+	return ""
+}
+
+// inDir reports whether path is in dir, ignoring case like Windows.
+func inDir(path, dir string) bool {
+	return strings.HasPrefix(strings.ToLower(filepath.Clean(path)), strings.ToLower(filepath.Clean(dir))+`\`)
+}
+
+// avAllowlist returns the folders and programs the antivirus should allow
+// against interference of kind.
+func avAllowlist(kind string) (folders, programs []string) {
+	folders = []string{AppDataDir}
+	if exe, err := os.Executable(); err == nil {
+		programs = append(programs, exe)
+	}
+	if kind == avBlockedPodman {
+		if podman, err := exec.LookPath("podman"); err == nil {
+			// gvproxy and win-sshproxy serve the machine's pipe
+			dir := filepath.Dir(podman)
+			programs = append(programs, podman, filepath.Join(dir, "gvproxy.exe"), filepath.Join(dir, "win-sshproxy.exe"))
+		}
+	}
+	return folders, programs
+}
+
+// avDescription says what the interference of kind broke.
+func avDescription(kind string) string {
+	if kind == avBlockedPodman {
+		return "Access to the Podman machine was blocked"
+	}
+	return "The app update was blocked or quarantined"
+}
+
+// guidance tells the user what to allow in their antivirus.
+func (f avInterference) guidance() string {
+	var b strings.Builder
+	b.WriteString(avDescription(f.Kind))
+	b.WriteString(", likely by your antivirus or endpoint protection. Add these exclusions in its settings, or ask your IT team to:\r\n")
+	for _, folder := range f.Folders {
+		fmt.Fprintf(&b, "\r\nFolder: %s", folder)
+	}
+	for _, program := range f.Programs {
+		fmt.Fprintf(&b, "\r\nProgram: %s", program)
+	}
+	return b.String()
+}
+
+// checkAntivirus records err if it shows antivirus interference, notifying
+// the user the first time of a run. Reports whether it did.
+func checkAntivirus(err error) bool {
+	kind := avInterferenceKind(err)
+	if kind == "" {
+		return false
+	}
+	folders, programs := avAllowlist(kind)
+	finding := avInterference{Kind: kind, Time: time.Now(), Err: err.Error(), Folders: folders, Programs: programs}
+	avMu.Lock()
+	_, seen := avFindings[kind]
+	avFindings[kind] = finding
+	avMu.Unlock()
+	slog.Warn("Antivirus interference detected", "kind", kind, "error", err)
+	if !seen {
+		go offerAVGuidance(finding)
+	}
+	return true
+}
+
+// offerAVGuidance notifies the interference, showing the exclusions to add
+// when the notification is clicked.
+func offerAVGuidance(finding avInterference) {
+	click := make(chan struct{}, 1)
+	if !notifyAction(commontray.NotifyError, "Your antivirus may be blocking ReEnvision AI",
+		avDescription(finding.Kind)+". Click here for the folders and programs to allow", click) {
+		return
+	}
+	select {
+	case <-click:
+		messageBox("ReEnvision AI", finding.guidance(), windows.MB_OK|windows.MB_ICONWARNING)
+	case <-time.After(avGuidanceClickTime):
+	}
+}
+
+// avInterferences returns the latest interference of each kind, oldest
+// first.
+func avInterferences() []avInterference {
+	avMu.Lock()
+	defer avMu.Unlock()
+	var findings []avInterference
+	for _, finding := range avFindings {
+		findings = append(findings, finding)
+	}
+	slices.SortFunc(findings, func(a, b avInterference) int { return a.Time.Compare(b.Time) })
+	return findings
+}
+
+// antivirusText lists the interferences for the diagnostics.
+func antivirusText(findings []avInterference) string {
+	if len(findings) == 0 {
+		return "None detected\r\n"
+	}
+	var b strings.Builder
+	for _, finding := range findings {
+		fmt.Fprintf(&b, "%s  %s\r\n", finding.Time.Format(time.DateTime), finding.Err)
+		b.WriteString(finding.guidance())
+		b.WriteString("\r\n\r\n")
+	}
+	return b.String()
+}
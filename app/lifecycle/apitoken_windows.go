@@ -0,0 +1,49 @@
+package lifecycle
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/ReEnvision-AI/systray/app/store"
+	"github.com/ReEnvision-AI/systray/app/tray/commontray"
+	"golang.org/x/sys/windows"
+)
+
+// requireAPIToken rejects requests to the local control API that don't carry
+// the node's API token as a bearer token, so other users and processes on the
+// machine can't control the node.
+func requireAPIToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(store.GetAPIToken())) != 1 {
+			slog.Warn("rejected local API request without a valid token", "method", r.Method, "path", r.URL.Path, "remote", r.RemoteAddr)
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleShowAPIToken shows the local API token with options to copy or rotate it.
+func handleShowAPIToken() {
+	token := store.GetAPIToken()
+	message := fmt.Sprintf("Local API token:\n\n%s\n\nSend it in an \"Authorization: Bearer\" header when calling the local API.\n\n"+
+		"Yes: copy the token to the clipboard\nNo: generate a new token, the current one stops working", token)
+	switch messageBox("ReEnvision AI API token", message, windows.MB_YESNOCANCEL|windows.MB_ICONINFORMATION) {
+	case IDYES:
+		if err := copyToClipboard(token); err != nil {
+			slog.Warn("failed to copy API token", "error", err)
+			notify(commontray.NotifyError, "Unable to copy the API token", err.Error())
+		}
+	case IDNO:
+		if confirm("Rotate API token", "Scripts using the current token will stop working. Generate a new token?") {
+			store.RotateAPIToken()
+			slog.Info("API token rotated")
+			handleShowAPIToken()
+		}
+	}
+}
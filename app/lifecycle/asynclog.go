@@ -0,0 +1,97 @@
+package lifecycle
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"time"
+)
+
+// The app log is written to its file from a goroutine, so a slow or locked
+// file, like one an antivirus is scanning or OneDrive is syncing, never
+// stalls the state machine or the tray. Lines queue up meanwhile, and past
+// logQueueSize the oldest are dropped and counted in the log.dropped_lines
+// metric. The in-memory log the viewer shows keeps every line.
+
+const (
+	logQueueSize    = 1000            // Lines waiting for the log file before the oldest are dropped
+	logFlushTimeout = 2 * time.Second // How long closing the log waits for queued lines
+)
+
+// asyncWriter writes to w from a goroutine, dropping the oldest writes
+// while more than size are queued.
+type asyncWriter struct {
+	w       io.Writer
+	size    int
+	mu      sync.Mutex
+	queue   [][]byte
+	dropped int64
+	closed  bool
+	ready   chan struct{} // Signaled when writes are queued, closed by close
+	done    chan struct{} // Closed once the queue is written after close
+}
+
+func newAsyncWriter(w io.Writer, size int) *asyncWriter {
+	aw := &asyncWriter{w: w, size: size, ready: make(chan struct{}, 1), done: make(chan struct{})}
+	go aw.run()
+	return aw
+}
+
+func (w *asyncWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return len(p), nil
+	}
+	if len(w.queue) >= w.size {
+		w.queue = w.queue[1:]
+		w.dropped++
+		nodeMetrics.Add("log.dropped_lines", 1)
+	}
+	w.queue = append(w.queue, bytes.Clone(p))
+	select {
+	case w.ready <- struct{}{}:
+	default:
+	}
+	return len(p), nil
+}
+
+func (w *asyncWriter) run() {
+	defer close(w.done)
+	for range w.ready {
+		for {
+			w.mu.Lock()
+			queue := w.queue
+			w.queue = nil
+			w.mu.Unlock()
+			if len(queue) == 0 {
+				break
+			}
+			for _, p := range queue {
+				w.w.Write(p) //nolint:errcheck // Nowhere to report it
+			}
+		}
+	}
+}
+
+// droppedWrites returns how many writes were dropped.
+func (w *asyncWriter) droppedWrites() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.dropped
+}
+
+// close writes the queued lines, waiting up to timeout, and drops later
+// writes.
+func (w *asyncWriter) close(timeout time.Duration) {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.ready)
+	}
+	w.mu.Unlock()
+	select {
+	case <-w.done:
+	case <-time.After(timeout):
+	}
+}
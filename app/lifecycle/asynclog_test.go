@@ -0,0 +1,54 @@
+//go:build windows && unit_test
+
+package lifecycle
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// blockedWriter is a log file that can't be written until unblock is closed.
+type blockedWriter struct {
+	unblock chan struct{}
+	buf     bytes.Buffer
+}
+
+func (w *blockedWriter) Write(p []byte) (int, error) {
+	<-w.unblock
+	return w.buf.Write(p)
+}
+
+func TestAsyncWriter(t *testing.T) {
+	file := &blockedWriter{unblock: make(chan struct{})}
+	w := newAsyncWriter(file, 3)
+
+	done := make(chan struct{})
+	go func() {
+		for i := range 10 {
+			fmt.Fprintf(w, "line %d\n", i)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected writes not to wait for the file")
+	}
+
+	close(file.unblock)
+	w.close(5 * time.Second)
+	// Up to 3 lines may have been taken before the file blocked
+	if got := w.droppedWrites(); got < 4 {
+		t.Errorf("expected the oldest writes to be dropped, got %d dropped", got)
+	}
+	if text := file.buf.String(); !strings.HasSuffix(text, "line 7\nline 8\nline 9\n") {
+		t.Errorf("expected the latest lines to be written, got %q", text)
+	}
+	fmt.Fprintln(w, "after close")
+	if bytes.Contains(file.buf.Bytes(), []byte("after close")) {
+		t.Error("expected writes after close to be dropped")
+	}
+}
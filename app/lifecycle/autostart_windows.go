@@ -0,0 +1,82 @@
+package lifecycle
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/ReEnvision-AI/systray/app/store"
+	"github.com/ReEnvision-AI/systray/app/tray/commontray"
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+)
+
+// The installer adds a shortcut to the Startup folder of all users, which the
+// app can't remove without elevation. Turning Start with Windows off in the
+// tray menu instead has the app exit when that shortcut launches it. Turning
+// it back on adds a Run entry for the user if the shortcut is gone.
+
+const (
+	autostartRunKey     = `SOFTWARE\Microsoft\Windows\CurrentVersion\Run`
+	autostartValueName  = "ReEnvisionAI"
+	startupShortcutName = "ReEnvision AI.lnk" // Named after the installer's MyAppName
+)
+
+// autostartDisabled reports whether the app was launched at login though the
+// user turned autostart off.
+func autostartDisabled() bool {
+	return launchedAtLogin() && !store.GetAutostart()
+}
+
+func handleToggleAutostart() {
+	enabled := !store.GetAutostart()
+	if err := setRunEntry(enabled); err != nil {
+		slog.Error("Failed to change autostart", "enabled", enabled, "error", err)
+		notify(commontray.NotifyError, "Unable to change starting with Windows", err.Error())
+		return
+	}
+	store.SetAutostart(enabled)
+	slog.Info("Autostart changed", "enabled", enabled)
+	if err := t.SetAutostart(enabled); err != nil {
+		slog.Warn("failed to update tray for autostart", "error", err)
+	}
+}
+
+// setRunEntry adds the app to the user's Run key when enabled, unless the
+// installer's Startup shortcut launches it already, and removes it
+// otherwise.
+func setRunEntry(enabled bool) error {
+	key, _, err := registry.CreateKey(registry.CURRENT_USER, autostartRunKey, registry.SET_VALUE)
+	if err != nil {
+		return fmt.Errorf("failed to open the Run key: %w", err)
+	}
+	defer key.Close()
+	if !enabled || startupShortcutExists() {
+		if err := key.DeleteValue(autostartValueName); err != nil && !errors.Is(err, registry.ErrNotExist) {
+			return fmt.Errorf("failed to remove the Run entry: %w", err)
+		}
+		return nil
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if err := key.SetStringValue(autostartValueName, syscall.EscapeArg(exe)+" "+AutostartFlag); err != nil {
+		return fmt.Errorf("failed to add the Run entry: %w", err)
+	}
+	return nil
+}
+
+// startupShortcutExists reports whether the installer's Startup shortcut is
+// there.
+func startupShortcutExists() bool {
+	dir, err := windows.KnownFolderPath(windows.FOLDERID_CommonStartup, 0)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(filepath.Join(dir, startupShortcutName))
+	return err == nil
+}
@@ -0,0 +1,33 @@
+//go:build windows && unit_test
+
+package lifecycle
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAutoStartContainerConfig(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), configFileName)
+	for content, want := range map[string]string{
+		`{"container_name": "reai", "container_image": "node:1", "model_name": "org/model"}`:                                  autoStartMenu,
+		`{"container_name": "reai", "container_image": "node:1", "model_name": "org/model", "auto_start_container": "never"}`: autoStartNever,
+		`{"container_name": "reai", "container_image": "node:1", "model_name": "org/model", "auto_start_container": "off"}`:   "",
+	} {
+		if err := os.WriteFile(configFile, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := readConfigFile(configFile)
+		if want == "" {
+			if err == nil || !strings.Contains(err.Error(), "auto_start_container") {
+				t.Errorf("expected an invalid auto_start_container error, got %v", err)
+			}
+			continue
+		}
+		if err != nil || cfg.AutoStartContainer != want {
+			t.Errorf("expected auto_start_container %q, got %q, %v", want, cfg.AutoStartContainer, err)
+		}
+	}
+}
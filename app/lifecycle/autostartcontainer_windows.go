@@ -0,0 +1,57 @@
+package lifecycle
+
+import (
+	"log/slog"
+
+	"github.com/ReEnvision-AI/systray/app/store"
+	"golang.org/x/sys/windows"
+)
+
+// The node normally starts as soon as the app does. Users who want the tray
+// at hand without it taking their GPU can turn that off from the tray menu,
+// then start the node themselves. "auto_start_container" in config.json
+// overrides the menu for managed deployments.
+
+// Values for AppConfig.AutoStartContainer
+const (
+	autoStartMenu   = "menu" // The default, as chosen in the tray menu
+	autoStartAlways = "always"
+	autoStartNever  = "never"
+)
+
+// configAutoStart returns the auto_start_container policy, from the file
+// until the node first started.
+func configAutoStart() string {
+	if policy := currentAppConfig().AutoStartContainer; policy != "" {
+		return policy
+	}
+	cfg, err := readConfig()
+	if err != nil {
+		return autoStartMenu // Starting reports the error
+	}
+	return cfg.AutoStartContainer
+}
+
+// autoStartContainer reports whether the node starts when the app starts.
+func autoStartContainer() bool {
+	switch configAutoStart() {
+	case autoStartAlways:
+		return true
+	case autoStartNever:
+		return false
+	}
+	return store.GetAutoStartContainer()
+}
+
+func handleToggleAutoStartContainer() {
+	if policy := configAutoStart(); policy != autoStartMenu {
+		messageBox("ReEnvision AI", "Starting the node when the app starts is set to \""+policy+"\" in config.json, so it can't be changed here.", windows.MB_OK|windows.MB_ICONINFORMATION)
+		return
+	}
+	enabled := !store.GetAutoStartContainer()
+	store.SetAutoStartContainer(enabled)
+	slog.Info("Auto start of the node changed", "enabled", enabled)
+	if err := t.SetAutoRun(enabled); err != nil {
+		slog.Warn("failed to update tray for auto start of the node", "error", err)
+	}
+}
@@ -0,0 +1,47 @@
+//go:build windows && unit_test
+
+package lifecycle
+
+import "testing"
+
+func TestBackendConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		backend BackendConfig
+		wantErr bool
+	}{
+		{"empty", BackendConfig{}, false},
+		{"self-hosted", BackendConfig{AuthURL: "https://auth.lab.example", AuthAnonKey: "key", UpdateURL: "https://reai.lab.example/update"}, false},
+		{"plain http", BackendConfig{HeartbeatURL: "http://10.0.0.5:8080/beat"}, false},
+		{"auth url without key", BackendConfig{AuthURL: "https://auth.lab.example"}, true},
+		{"relative url", BackendConfig{UpdateURL: "/update"}, true},
+		{"other scheme", BackendConfig{ModelCatalogURL: "ftp://hub.lab.example"}, true},
+	}
+	for _, test := range tests {
+		if err := test.backend.validate(); (err != nil) != test.wantErr {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		}
+	}
+}
+
+func TestBackendConfigEndpoints(t *testing.T) {
+	t.Cleanup(func() { activeEndpoints = nil })
+
+	setEndpoints(BackendConfig{UpdateURL: "https://reai.lab.example/update", ModelCatalogURL: "https://hub.lab.example"})
+	e := currentEndpoints()
+	if e.UpdateCheck != "https://reai.lab.example/update" {
+		t.Errorf("update endpoint not overridden, got %s", e.UpdateCheck)
+	}
+	if e.ModelPage != "https://hub.lab.example/" || e.HFWhoAmI != "https://hub.lab.example/api/whoami-v2" {
+		t.Errorf("model catalog endpoints not overridden, got %s and %s", e.ModelPage, e.HFWhoAmI)
+	}
+	if e.Announcements != AnnouncementsURL {
+		t.Errorf("announcements endpoint changed without an override, got %s", e.Announcements)
+	}
+
+	// Removing the overrides restores the public endpoints
+	setEndpoints(BackendConfig{})
+	if e := currentEndpoints(); e != defaultEndpoints() {
+		t.Errorf("expected the default endpoints, got %+v", e)
+	}
+}
@@ -0,0 +1,156 @@
+package lifecycle
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/ReEnvision-AI/systray/app/heartbeat"
+)
+
+// BackendConfig overrides the endpoints of the ReEnvision services, so
+// private deployments of the stack can run the same app without the public
+// cloud. Empty fields keep the public endpoints.
+type BackendConfig struct {
+	AuthURL          string `json:"auth_url"`      // Supabase project URL, replaces supabaseUrl
+	AuthAnonKey      string `json:"auth_anon_key"` // Replaces supabaseAnonKey, required with auth_url
+	UpdateURL        string `json:"update_url"`
+	FlagsURL         string `json:"flags_url"`
+	AnnouncementsURL string `json:"announcements_url"`
+	HeartbeatURL     string `json:"heartbeat_url"`     // Selects the https heartbeat backend
+	ModelCatalogURL  string `json:"model_catalog_url"` // HuggingFace compatible hub the models are downloaded from
+}
+
+func (b BackendConfig) validate() error {
+	if (b.AuthURL == "") != (b.AuthAnonKey == "") {
+		return errors.New("auth_url and auth_anon_key must be set together")
+	}
+	for _, endpoint := range []struct{ name, value string }{
+		{"auth_url", b.AuthURL},
+		{"update_url", b.UpdateURL},
+		{"flags_url", b.FlagsURL},
+		{"announcements_url", b.AnnouncementsURL},
+		{"heartbeat_url", b.HeartbeatURL},
+		{"model_catalog_url", b.ModelCatalogURL},
+	} {
+		if endpoint.value == "" {
+			continue
+		}
+		u, err := url.Parse(endpoint.value)
+		if err != nil {
+			return fmt.Errorf("%s is not a valid URL: %w", endpoint.name, err)
+		}
+		if (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("%s must be an absolute http or https URL, got %q", endpoint.name, endpoint.value)
+		}
+		if u.Scheme == "http" {
+			slog.Warn("Backend endpoint doesn't use https", "setting", endpoint.name, "url", endpoint.value)
+		}
+	}
+	return nil
+}
+
+// endpoints are the URLs of the services outside the config's own Supabase
+// project and heartbeat settings.
+type endpoints struct {
+	UpdateCheck   string
+	FeatureFlags  string
+	Announcements string
+	ModelPage     string // The model name is appended
+	HFWhoAmI      string
+}
+
+var (
+	endpointsMu     sync.Mutex
+	activeEndpoints *endpoints // Nil until the backend config was loaded
+)
+
+// defaultEndpoints returns the public endpoints.
+func defaultEndpoints() endpoints {
+	return endpoints{
+		UpdateCheck:   UpdateCheckURLBase,
+		FeatureFlags:  FeatureFlagsURL,
+		Announcements: AnnouncementsURL,
+		ModelPage:     ModelPageBaseURL,
+		HFWhoAmI:      HFWhoAmIURL,
+	}
+}
+
+// currentEndpoints returns the endpoints in use.
+func currentEndpoints() endpoints {
+	endpointsMu.Lock()
+	defer endpointsMu.Unlock()
+	if activeEndpoints == nil {
+		return defaultEndpoints()
+	}
+	return *activeEndpoints
+}
+
+// setEndpoints switches the app to the endpoints resolved from b.
+func setEndpoints(b BackendConfig) {
+	e := b.endpoints()
+	endpointsMu.Lock()
+	activeEndpoints = &e
+	endpointsMu.Unlock()
+}
+
+// endpoints resolves the overrides against the public endpoints, so an
+// override that is removed again restores the default.
+func (b BackendConfig) endpoints() endpoints {
+	e := defaultEndpoints()
+	if b.UpdateURL != "" {
+		e.UpdateCheck = b.UpdateURL
+	}
+	if b.FlagsURL != "" {
+		e.FeatureFlags = b.FlagsURL
+	}
+	if b.AnnouncementsURL != "" {
+		e.Announcements = b.AnnouncementsURL
+	}
+	if b.ModelCatalogURL != "" {
+		hub := strings.TrimRight(b.ModelCatalogURL, "/") + "/"
+		e.ModelPage = hub
+		e.HFWhoAmI = hub + "api/whoami-v2"
+	}
+	return e
+}
+
+// apply points the config at the configured Supabase project and heartbeat
+// endpoint. The other endpoints are app wide, see setEndpoints.
+func (b BackendConfig) apply(cfg *AppConfig) {
+	if b.AuthURL != "" {
+		cfg.SupabaseURL = b.AuthURL
+		cfg.SupabaseAnonKey = b.AuthAnonKey
+	}
+	if b.HeartbeatURL != "" {
+		cfg.Heartbeat.Backend = heartbeat.BackendHTTPS
+		cfg.Heartbeat.URL = b.HeartbeatURL
+	}
+}
+
+// loadBackendConfig applies the endpoint overrides at startup, before the
+// full config is loaded when the node first starts, so update checks and
+// announcements go to the right backend from the start. They are resolved
+// again whenever the node starts, like the other settings of the backend
+// block.
+func loadBackendConfig() {
+	configFile, err := configFilePath()
+	if err != nil {
+		slog.Warn("Unable to locate config for backend endpoints", "error", err)
+		return
+	}
+	cfg, err := readConfigFile(configFile)
+	if err != nil {
+		slog.Warn("Unable to load backend endpoints, using the public backend", "error", err)
+		return
+	}
+	setEndpoints(cfg.Backend)
+	if cfg.Backend != (BackendConfig{}) {
+		b := cfg.Backend
+		slog.Info("Using self-hosted backend endpoints", "auth", b.AuthURL, "update", b.UpdateURL, "flags", b.FlagsURL,
+			"announcements", b.AnnouncementsURL, "heartbeat", b.HeartbeatURL, "model_catalog", b.ModelCatalogURL)
+	}
+}
@@ -0,0 +1,45 @@
+//go:build windows && unit_test
+
+package lifecycle
+
+import "testing"
+
+func TestNextContainerSlot(t *testing.T) {
+	name, port := nextContainerSlot("reai-node-1234abcd", 31330)
+	if name != "reai-node-1234abcd-next" || port != 31331 {
+		t.Errorf("expected the alternate slot, got %q on %d", name, port)
+	}
+	name, port = nextContainerSlot(name, port)
+	if name != "reai-node-1234abcd" || port != 31330 {
+		t.Errorf("expected to switch back to the configured slot, got %q on %d", name, port)
+	}
+}
+
+func TestSameImageID(t *testing.T) {
+	const id = "3f57d9401f8d42f986df300f0c69192fc41da28ccc8d797829467780db3dd741"
+	cases := []struct {
+		a, b string
+		same bool
+	}{
+		{id, id, true},
+		{"sha256:" + id, id + "\n", true},
+		{id[:12], id, true},
+		{id, "8a1f3c0b9e2d" + id[12:], false},
+		{"", id, false},
+		{"", "", false},
+	}
+	for _, c := range cases {
+		if got := sameImageID(c.a, c.b); got != c.same {
+			t.Errorf("sameImageID(%q, %q) = %v, expected %v", c.a, c.b, got, c.same)
+		}
+	}
+}
+
+func TestLastOutputLine(t *testing.T) {
+	if got := lastOutputLine("Trying to pull...\r\nabc123\r\n\r\n"); got != "abc123" {
+		t.Errorf("expected the image ID, got %q", got)
+	}
+	if got := lastOutputLine(""); got != "" {
+		t.Errorf("expected no line, got %q", got)
+	}
+}
@@ -0,0 +1,315 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ReEnvision-AI/systray/app/features"
+	"github.com/ReEnvision-AI/systray/app/tray/commontray"
+)
+
+// While the node runs, its image is checked for updates every
+// imageUpdateInterval. A newer image is pulled and started as a second
+// container serving on the alternate port, and once that container is ready
+// the node switches to it and stops the old one, so the node keeps
+// contributing while the new container loads its blocks. Should the new
+// container not get ready, e.g. as the GPU doesn't fit two, the node is
+// restarted on the new image instead. image_updates in config.json restarts
+// right away or turns the checks off. Until the container-switch feature
+// flag is on for the node, it is restarted as well.
+
+// Values for AppConfig.ImageUpdates
+const (
+	imageUpdatesSwitch  = "switch" // The default
+	imageUpdatesRestart = "restart"
+	imageUpdatesOff     = "off"
+)
+
+const (
+	imageUpdateInterval = 6 * time.Hour
+	imagePullTimeout    = time.Hour
+	switchReadyTimeout  = 30 * time.Minute
+	switchProbeInterval = 30 * time.Second
+	switchingText       = "Running (Updating)"
+
+	containerSlotSuffix = "-next" // Appended to the container name on the alternate port
+)
+
+var errSwitchAborted = errors.New("the node stopped or restarted while switching containers")
+
+var (
+	switchMu     sync.Mutex
+	switchingCmd *exec.Cmd // The `podman run` command of the container being switched to, guarded by stateMu
+)
+
+// StartImageUpdateCheck checks the image of the running node for updates
+// until ctx is cancelled.
+func StartImageUpdateCheck(ctx context.Context) {
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(imageUpdateInterval):
+			}
+			if _, _, _, ok := runningContainer(); !ok || currentAppConfig().ImageUpdates == imageUpdatesOff || meteredLimited() {
+				continue
+			}
+			updated, err := pullImageUpdate(ctx)
+			if err != nil {
+				slog.Warn("Failed to check for node image updates", "error", err)
+				continue
+			}
+			if updated {
+				applyImageUpdate(ctx)
+			}
+		}
+	}()
+}
+
+// runningContainer returns the `podman run` command, name and port of the
+// node's container, and whether the node is running.
+func runningContainer() (*exec.Cmd, string, uint64, bool) {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	return currentCmd, currentAppConfig().ContainerName, Port, currentState == StateRunning && currentCmd != nil
+}
+
+// containerImageID returns the ID of the image the named container runs.
+func containerImageID(ctx context.Context, name string) (string, error) {
+	if api, err := newPodmanAPI(); err == nil {
+		id, err := api.containerImageID(ctx, name)
+		if !errors.Is(err, errPodmanAPIUnavailable) {
+			return id, err
+		}
+	}
+	output, err := helperCombinedOutput(podmanCommand(ctx, "inspect", "--format", "{{.Image}}", name))
+	if err != nil {
+		return "", podmanError(err, output)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// pullImageUpdate pulls the node image and reports whether it differs from
+// the image of the running container.
+func pullImageUpdate(ctx context.Context) (bool, error) {
+	_, name, _, ok := runningContainer()
+	if !ok {
+		return false, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, imagePullTimeout)
+	defer cancel()
+	running, err := containerImageID(ctx, name)
+	if err != nil {
+		return false, err
+	}
+	image := currentAppConfig().ContainerImage
+	output, err := helperCombinedOutput(podmanCommand(ctx, "pull", "--quiet", image))
+	if err != nil {
+		return false, podmanError(err, output)
+	}
+	pulled := lastOutputLine(string(output))
+	if sameImageID(running, pulled) {
+		slog.Debug("node image is up to date", "image", image, "id", running)
+		return false, nil
+	}
+	slog.Info("Pulled a new node image", "image", image, "id", pulled, "running_id", running)
+	return true, nil
+}
+
+// applyImageUpdate moves the node to the new image, switching containers
+// unless configured to restart.
+func applyImageUpdate(ctx context.Context) {
+	if currentAppConfig().ImageUpdates == imageUpdatesRestart || !features.Enabled(features.ContainerSwitch) {
+		slog.Info("Restarting the node on the new image")
+		restartRunningNode()
+		return
+	}
+	err := switchContainer(ctx)
+	switch {
+	case err == nil:
+	case errors.Is(err, errSwitchAborted) || ctx.Err() != nil:
+		slog.Info("Container switch abandoned", "error", err)
+	default:
+		slog.Error("Failed to switch to a container with the new image, restarting the node", "error", err)
+		if _, _, _, ok := runningContainer(); ok {
+			notify(commontray.NotifyWarning, "Restarting your node", "The node is restarted to run its updated image")
+			restartRunningNode()
+		}
+	}
+}
+
+// switchContainer starts a container with the new image on the alternate
+// port, and once it is ready makes it the node's container and stops the
+// previous one.
+func switchContainer(ctx context.Context) error {
+	if !switchMu.TryLock() {
+		return nil // Already switching
+	}
+	defer switchMu.Unlock()
+	previousCmd, previousName, previousPort, ok := runningContainer()
+	if !ok {
+		return errSwitchAborted
+	}
+	name, port := nextContainerSlot(previousName, previousPort)
+	if err := removeStaleContainer(ctx, name); err != nil {
+		return err
+	}
+
+	var cpuPinArgs []string
+	if !currentAppConfig().UseGPU {
+		cpuPinArgs = cpuArgs(ctx)
+	}
+	securityArgs, err := sandboxArgs()
+	if err != nil {
+		return fmt.Errorf("failed to set up the container sandbox: %w", err)
+	}
+	netArgs, err := networkArgs(ctx, port)
+	if err != nil {
+		return fmt.Errorf("failed to set up the container network: %w", err)
+	}
+
+	cmdCtx, cmdCancel := context.WithCancel(context.Background())
+	cmd := podmanRunCommand(cmdCtx, podmanRunArgs(name, port, netArgs, securityArgs, cpuPinArgs)...)
+	stdoutPipe, err := cmd.StdoutPipe()
+	if err != nil {
+		cmdCancel()
+		return fmt.Errorf("failed to get stdout pipe: %w", err)
+	}
+	stderrPipe, err := cmd.StderrPipe()
+	if err != nil {
+		cmdCancel()
+		return fmt.Errorf("failed to get stderr pipe: %w", err)
+	}
+
+	ready := make(chan struct{})
+	markReady := sync.OnceFunc(func() { close(ready) })
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go captureOutput(&wg, stdoutPipe, "stdout", markReady)
+	go captureOutput(&wg, stderrPipe, "stderr", markReady)
+
+	stateMu.Lock()
+	switchingCmd = cmd
+	stateMu.Unlock()
+	slog.Info("Starting container with the new image", "command", cmd.String())
+	if err := startHelper(cmd); err != nil {
+		cmdCancel()
+		stateMu.Lock()
+		switchingCmd = nil
+		stateMu.Unlock()
+		return fmt.Errorf("failed to start podman command: %w", err)
+	}
+	exited := make(chan struct{})
+	go func() {
+		watchContainerExit(cmd, name, cmdCancel, &wg)
+		close(exited)
+	}()
+	setStatusText(StateRunning, switchingText)
+	defer func() { setStatusText(StateRunning, runningStatusText()) }()
+
+	if err := awaitSwitchReady(ctx, previousCmd, name, port, ready, exited); err != nil {
+		abandonSwitch(cmd, name, cmdCancel)
+		return err
+	}
+
+	stateMu.Lock()
+	if switchingCmd != cmd || currentCmd != previousCmd || currentState != StateRunning {
+		stateMu.Unlock()
+		abandonSwitch(cmd, name, cmdCancel)
+		return errSwitchAborted
+	}
+	previousCancel := cancelCmd
+	currentCmd, cancelCmd, switchingCmd = cmd, cmdCancel, nil
+	updateAppConfig(func(c *AppConfig) { c.ContainerName = name })
+	Port = port
+	stateMu.Unlock()
+	startContainerWatch(cmdCtx, name)
+	nodeMetrics.Add("node.container_switches", 1)
+	slog.Info("Switched to the container with the new image", "name", name, "port", port, "previous_name", previousName, "previous_port", previousPort)
+
+	stopCtx, cancel := context.WithTimeout(context.Background(), podmanStopTimeout)
+	defer cancel()
+	if err := stopContainer(stopCtx, previousName, previousCancel); err != nil {
+		slog.Warn("Failed to stop the previous container", "name", previousName, "error", err)
+	}
+	return nil
+}
+
+// awaitSwitchReady waits until the container being switched to shows it is
+// ready or passes the health probe. Fails once it exited, didn't get ready
+// within switchReadyTimeout or the node's container is no longer
+// previousCmd.
+func awaitSwitchReady(ctx context.Context, previousCmd *exec.Cmd, name string, port uint64, ready, exited <-chan struct{}) error {
+	deadline := time.After(switchReadyTimeout)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ready:
+			return nil
+		case <-exited:
+			return errors.New("the new container exited before it was ready")
+		case <-deadline:
+			return fmt.Errorf("the new container wasn't ready within %v", switchReadyTimeout)
+		case <-time.After(switchProbeInterval):
+		}
+		if cmd, _, _, ok := runningContainer(); !ok || cmd != previousCmd {
+			return errSwitchAborted
+		}
+		if err := probeContainer(ctx, name, currentAppConfig().HealthCheck.commandFor(port)); err != nil {
+			slog.Debug("new container is not ready yet", "error", err)
+			continue
+		}
+		slog.Info("New container passed the health probe without reporting it is ready")
+		return nil
+	}
+}
+
+// abandonSwitch stops the container that was being switched to.
+func abandonSwitch(cmd *exec.Cmd, name string, cmdCancel context.CancelFunc) {
+	stateMu.Lock()
+	if switchingCmd == cmd {
+		switchingCmd = nil
+	}
+	stateMu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), podmanStopTimeout)
+	defer cancel()
+	if err := stopContainer(ctx, name, cmdCancel); err != nil {
+		slog.Warn("Failed to stop the new container", "name", name, "error", err)
+	}
+}
+
+// nextContainerSlot returns the name and port of the container to switch to
+// from the one with name, serving on port. Containers alternate between the
+// configured name and port, and the name with containerSlotSuffix on the
+// next port.
+func nextContainerSlot(name string, port uint64) (string, uint64) {
+	if base, ok := strings.CutSuffix(name, containerSlotSuffix); ok {
+		return base, port - 1
+	}
+	return name + containerSlotSuffix, port + 1
+}
+
+// sameImageID reports whether two image IDs, either of them possibly
+// shortened, are of the same image.
+func sameImageID(a, b string) bool {
+	a = strings.TrimPrefix(strings.TrimSpace(a), "sha256:")
+	b = strings.TrimPrefix(strings.TrimSpace(b), "sha256:")
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	return a != "" && strings.HasPrefix(b, a)
+}
+
+// lastOutputLine returns the last line of command output that isn't empty.
+func lastOutputLine(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
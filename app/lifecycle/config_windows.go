@@ -6,9 +6,15 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
 
-	"github.com/danieljoos/wincred"
+	"github.com/ReEnvision-AI/systray/app/datadir"
+	"github.com/ReEnvision-AI/systray/app/heartbeat"
+	"github.com/ReEnvision-AI/systray/app/metrics"
+	"github.com/ReEnvision-AI/systray/app/secrets"
+	"github.com/ReEnvision-AI/systray/app/store"
 	"golang.org/x/sys/windows/registry"
 	"golang.org/x/text/encoding/unicode"
 	"golang.org/x/text/transform"
@@ -16,60 +22,156 @@ import (
 
 // AppConfig struct holds values loaded from config.json and Windows Credential Manager.
 type AppConfig struct {
-	ContainerName   string `json:"container_name"`
-	ContainerImage  string `json:"container_image"`
-	InitialPeers    string `json:"initial_peers"`
-	ModelName       string `json:"model_name"`
-	DefaultPort     uint64 `json:"default_port"`
-	UseGPU          bool   `json:"use_gpu"`
-	SupabaseURL     string `json:"supabaseUrl"`
-	SupabaseAnonKey string `json:"supabaseAnonKey"`
-	Token           string // Loaded separately from Credential Manager
+	ConfigVersion       int                `json:"config_version"` // Version of the file's format, see currentConfigVersion
+	ContainerName       string             `json:"container_name"`
+	LegacyContainerName bool               `json:"legacy_container_name"` // Don't suffix the container name with the node ID
+	ContainerImage      string             `json:"container_image"`
+	InitialPeers        string             `json:"initial_peers"`
+	ModelName           string             `json:"model_name"`
+	DefaultPort         uint64             `json:"default_port"`
+	UseGPU              bool               `json:"use_gpu"`
+	GPUSetup            string             `json:"gpu_setup"`           // One of "auto", "skip" or "force"
+	ReservedCores       int                `json:"reserved_cores"`      // Physical cores left for the user on CPU-only nodes, 0 for defaultReservedCores
+	DisableCPUPinning   bool               `json:"disable_cpu_pinning"` // Don't pin CPU-only nodes to cores
+	HelperPriority      string             `json:"helper_priority"`     // One of "below_normal", "idle" or "normal"
+	HelperIOPriority    string             `json:"helper_io_priority"`  // One of "low", "very_low" or "normal"
+	PodmanConnection    string             `json:"podman_connection"`   // Connection name or "rootful", empty for the default
+	PodmanURL           string             `json:"podman_url"`          // Podman service URL, alternative to PodmanConnection
+	ContainerSandbox    string             `json:"container_sandbox"`   // One of "seccomp", "default" or "privileged"
+	SeccompProfile      string             `json:"seccomp_profile"`     // Custom profile used instead of the generated one
+	WritableRootFS      bool               `json:"writable_root_fs"`    // Compatibility flag, don't mount the root filesystem read-only
+	RestrictEgress      bool               `json:"restrict_egress"`     // Only allow connections to the swarm, HuggingFace and EgressAllowlist
+	EgressAllowlist     []string           `json:"egress_allowlist"`    // Extra hostnames, IPv4 addresses or CIDR networks
+	SupabaseURL         string             `json:"supabaseUrl"`
+	SupabaseAnonKey     string             `json:"supabaseAnonKey"`
+	Hooks               Hooks              `json:"hooks"`
+	HealthCheck         HealthCheck        `json:"health_check"`   // Probing of the running node
+	CrashRestarts       int                `json:"crash_restarts"` // Automatic restarts after the container crashes, 0 for defaultCrashRestarts, negative disables them
+	MachineSetup        []MachineSetupStep `json:"machine_setup"`  // Commands run in the Podman machine before starting
+	Heartbeat           heartbeat.Config   `json:"heartbeat"`      // Defaults to the Supabase backend
+	Metrics             metrics.Config     `json:"metrics"`        // StatsD and OTLP exporters, none by default
+	Backend             BackendConfig      `json:"backend"`        // Endpoint overrides for self-hosted deployments
+	Anonymous           bool               `json:"anonymous"`      // Run without an account, see anonymousMode
+	WakeRestart         string             `json:"wake_restart"`   // One of "always", "never" or "ask", restarting the node after the system slept
+	MeteredPolicy       string             `json:"metered_policy"` // "limit" to not download on metered connections, or "ignore"
+	ImageUpdates        string             `json:"image_updates"`  // "switch" to switch containers when the image updates, "restart" or "off"
+	VPN                 VPNConfig          `json:"vpn"`            // What the node does while a VPN is connected
+	StateNotifications  StateNotifications `json:"state_notifications"`
+	UpdateDownloads     UpdateDownloads    `json:"update_downloads"`     // When app updates download, by default once the user is idle
+	AutoStartContainer  string             `json:"auto_start_container"` // One of "menu", "always" or "never", starting the node when the app starts
+	ControlAPI          ControlAPI         `json:"control_api"`          // Local HTTP API for scripts, off by default
+	Token               string             // Loaded separately from Credential Manager
+	baseContainerName   string             // ContainerName as configured, before the node ID suffix
+}
+
+// Hooks are command lines run through cmd.exe when the node changes state,
+// with the transition details passed as REAI_* environment variables.
+type Hooks struct {
+	OnStart        string `json:"on_start"` // Run once the node is running
+	OnStop         string `json:"on_stop"`
+	OnError        string `json:"on_error"`
+	TimeoutSeconds int    `json:"timeout_seconds"` // 0 for defaultHookTimeout
 }
 
 var (
 	Port uint64
 )
 
+// Values for AppConfig.GPUSetup
+const (
+	gpuSetupAuto  = "auto"  // Generate the CDI spec if an Nvidia GPU is detected
+	gpuSetupSkip  = "skip"  // CDI is pre-provisioned, don't touch the Podman machine
+	gpuSetupForce = "force" // Generate the CDI spec even if no GPU is detected
+)
+
 const (
-	configDirName     = "ReEnvisionAI"
 	configFileName    = "config.json"
 	registryKeyPath   = `SOFTWARE\ReEnvisionAI\ReEnvisionAI`
 	registryPortValue = "Port"
+
+	hfTokenCredentialTarget = "ReEnvisionAI/hf_token" // The target name used in Credential Manager
+
+	fallbackPort = 31330 // Used when config.json sets no default_port
 )
 
+// errHFTokenMissing is returned by loadAppConfig when no HuggingFace token is
+// stored.
+var errHFTokenMissing = errors.New("HuggingFace token not found in Windows Credential Manager")
+
+// LoadConfig loads the config the node starts with, asking the user for the
+// HuggingFace token if none is stored. Everything else reads the config with
+// readConfig, which never prompts.
 func LoadConfig() (AppConfig, error) {
-	configDir, err := os.UserCacheDir()
+	configFile, err := configFilePath()
 	if err != nil {
-		slog.Warn("Failed to get user cache directory, falling back to working directory", "error", err)
-		configDir, err = os.Getwd()
-		if err != nil {
-			return AppConfig{}, fmt.Errorf("cann ot determine config directory: %w", err)
-		}
-	} else {
-		configDir = filepath.Join(configDir, configDirName)
-		if err := os.MkdirAll(configDir, 0750); err != nil {
-			return AppConfig{}, fmt.Errorf("failed to create config directory %q: %w", configDir, err)
-		}
+		return AppConfig{}, err
 	}
-
-	configFile := filepath.Join(configDir, configFileName)
 	slog.Info("Using configuration file", "path", configFile)
+	if err := migrateConfigFile(configFile); err != nil {
+		slog.Warn("Failed to upgrade config file", "error", err)
+	}
 
 	appConfig, err := loadAppConfig(configFile)
+	if errors.Is(err, errHFTokenMissing) {
+		// Ask the user for the token instead of requiring manual Credential Manager setup
+		slog.Info("HuggingFace token not found, prompting user", "target", hfTokenCredentialTarget)
+		token, promptErr := promptForHFToken()
+		if promptErr != nil {
+			return AppConfig{}, fmt.Errorf("%w and none was entered (%v)", err, promptErr)
+		}
+		appConfig.Token, err = token, nil
+	}
 	if err != nil {
 		return AppConfig{}, fmt.Errorf("failed to load configuration from %q: %w", configFile, err)
 	}
+	registerSecret(appConfig.Token) // On the container's command line
+	setEndpoints(appConfig.Backend)
+	applyOrgPolicy(&appConfig, currentOrgPolicy())
 
 	// Set default port initially from config
 	Port = appConfig.DefaultPort
 	slog.Info("Default port set from config", "port", Port)
 
 	loadPortFromRegistry()
+	if _, ok := lookupConfigEnv(configEnvPrefix + "DEFAULT_PORT"); ok {
+		Port = appConfig.DefaultPort // The override wins over the registry
+	}
 
 	return appConfig, nil
 }
 
+// readConfig reads config.json without the HuggingFace token, for the app's
+// own settings and backend endpoints. appConfig is only loaded once the node
+// starts.
+func readConfig() (AppConfig, error) {
+	configFile, err := configFilePath()
+	if err != nil {
+		return AppConfig{}, err
+	}
+	return readConfigFile(configFile)
+}
+
+// configFilePath returns the path of config.json, creating its directory if
+// needed.
+func configFilePath() (string, error) {
+	// os.UserCacheDir is the local application data folder, where
+	// datadir.ConfigDir is unless it was moved
+	configDir, err := os.UserCacheDir()
+	if err != nil {
+		slog.Warn("Failed to get user cache directory, falling back to working directory", "error", err)
+		configDir, err = os.Getwd()
+		if err != nil {
+			return "", fmt.Errorf("cann ot determine config directory: %w", err)
+		}
+	} else {
+		configDir = datadir.ConfigDir()
+		if err := os.MkdirAll(configDir, 0750); err != nil {
+			return "", fmt.Errorf("failed to create config directory %q: %w", configDir, err)
+		}
+	}
+	return filepath.Join(configDir, configFileName), nil
+}
+
 func loadPortFromRegistry() {
 	key, err := registry.OpenKey(registry.LOCAL_MACHINE, registryKeyPath, registry.QUERY_VALUE)
 	if err != nil {
@@ -92,7 +194,9 @@ func loadPortFromRegistry() {
 	slog.Info("Port loaded from registry", "port", Port)
 }
 
-func loadAppConfig(filePath string) (AppConfig, error) {
+// readConfigFile reads and validates config.json, without the secrets
+// stored in Credential Manager.
+func readConfigFile(filePath string) (AppConfig, error) {
 	var cfg AppConfig
 
 	// --- Load from JSON file ---
@@ -105,33 +209,190 @@ func loadAppConfig(filePath string) (AppConfig, error) {
 	if err != nil {
 		return cfg, fmt.Errorf("failed to parse config file '%s': %w", filePath, err)
 	}
+	if profile := store.GetActiveProfile(); profile != "" {
+		if err := applyProfile(&cfg, filePath, profile); err != nil {
+			return cfg, err
+		}
+	}
+	overrides, err := applyEnvOverrides(&cfg, lookupConfigEnv)
+	if err != nil {
+		return cfg, err
+	}
+	if len(overrides) > 0 {
+		slog.Info("Config overridden by environment variables", "variables", overrides)
+	}
 
 	// --- Validate required fields from JSON ---
 	if cfg.ContainerName == "" || cfg.ContainerImage == "" || cfg.ModelName == "" {
 		return cfg, fmt.Errorf("config file '%s' is missing required fields (container_name, container_image, model_name)", filePath)
 	}
 
+	switch cfg.GPUSetup {
+	case "":
+		cfg.GPUSetup = gpuSetupAuto
+	case gpuSetupAuto, gpuSetupSkip, gpuSetupForce:
+	default:
+		return cfg, fmt.Errorf("config file '%s' has invalid gpu_setup %q (expected %q, %q or %q)", filePath, cfg.GPUSetup, gpuSetupAuto, gpuSetupSkip, gpuSetupForce)
+	}
+
+	if cfg.PodmanConnection != "" && cfg.PodmanURL != "" {
+		return cfg, fmt.Errorf("config file '%s' sets both podman_connection and podman_url, only one may be used", filePath)
+	}
+	if cfg.PodmanConnection == podmanRootfulAlias {
+		cfg.PodmanConnection = podmanRootfulConnection
+	}
+
+	switch cfg.ContainerSandbox {
+	case "":
+		cfg.ContainerSandbox = sandboxSeccomp
+	case sandboxSeccomp, sandboxDefault, sandboxPrivileged:
+	default:
+		return cfg, fmt.Errorf("config file '%s' has invalid container_sandbox %q (expected %q, %q or %q)", filePath, cfg.ContainerSandbox, sandboxSeccomp, sandboxDefault, sandboxPrivileged)
+	}
+	if cfg.SeccompProfile != "" && cfg.ContainerSandbox != sandboxSeccomp {
+		return cfg, fmt.Errorf("config file '%s' sets seccomp_profile, which needs container_sandbox %q", filePath, sandboxSeccomp)
+	}
+
+	switch cfg.Heartbeat.Backend {
+	case "", heartbeat.BackendSupabase:
+	case heartbeat.BackendHTTPS:
+		if cfg.Heartbeat.URL == "" {
+			return cfg, fmt.Errorf("config file '%s' selects the https heartbeat backend without heartbeat.url", filePath)
+		}
+	default:
+		return cfg, fmt.Errorf("config file '%s' has invalid heartbeat.backend %q (expected %q or %q)", filePath, cfg.Heartbeat.Backend, heartbeat.BackendSupabase, heartbeat.BackendHTTPS)
+	}
+
+	switch cfg.HelperPriority {
+	case "":
+		cfg.HelperPriority = helperPriorityBelowNormal
+	case helperPriorityBelowNormal, helperPriorityIdle, helperPriorityNormal:
+	default:
+		return cfg, fmt.Errorf("config file '%s' has invalid helper_priority %q (expected %q, %q or %q)", filePath, cfg.HelperPriority, helperPriorityBelowNormal, helperPriorityIdle, helperPriorityNormal)
+	}
+	switch cfg.HelperIOPriority {
+	case "":
+		cfg.HelperIOPriority = helperIOPriorityLow
+	case helperIOPriorityLow, helperIOPriorityVeryLow, helperIOPriorityNormal:
+	default:
+		return cfg, fmt.Errorf("config file '%s' has invalid helper_io_priority %q (expected %q, %q or %q)", filePath, cfg.HelperIOPriority, helperIOPriorityLow, helperIOPriorityVeryLow, helperIOPriorityNormal)
+	}
+
+	switch cfg.WakeRestart {
+	case "":
+		cfg.WakeRestart = wakeRestartAlways
+	case wakeRestartAlways, wakeRestartNever, wakeRestartAsk:
+	default:
+		return cfg, fmt.Errorf("config file '%s' has invalid wake_restart %q (expected %q, %q or %q)", filePath, cfg.WakeRestart, wakeRestartAlways, wakeRestartNever, wakeRestartAsk)
+	}
+
+	switch cfg.AutoStartContainer {
+	case "":
+		cfg.AutoStartContainer = autoStartMenu
+	case autoStartMenu, autoStartAlways, autoStartNever:
+	default:
+		return cfg, fmt.Errorf("config file '%s' has invalid auto_start_container %q (expected %q, %q or %q)", filePath, cfg.AutoStartContainer, autoStartMenu, autoStartAlways, autoStartNever)
+	}
+
+	switch cfg.MeteredPolicy {
+	case "":
+		cfg.MeteredPolicy = meteredPolicyLimit
+	case meteredPolicyLimit, meteredPolicyIgnore:
+	default:
+		return cfg, fmt.Errorf("config file '%s' has invalid metered_policy %q (expected %q or %q)", filePath, cfg.MeteredPolicy, meteredPolicyLimit, meteredPolicyIgnore)
+	}
+
+	switch cfg.ImageUpdates {
+	case "":
+		cfg.ImageUpdates = imageUpdatesSwitch
+	case imageUpdatesSwitch, imageUpdatesRestart, imageUpdatesOff:
+	default:
+		return cfg, fmt.Errorf("config file '%s' has invalid image_updates %q (expected %q, %q or %q)", filePath, cfg.ImageUpdates, imageUpdatesSwitch, imageUpdatesRestart, imageUpdatesOff)
+	}
+
+	switch cfg.VPN.Policy {
+	case "":
+		cfg.VPN.Policy = vpnPolicyWarn
+	case vpnPolicyWarn, vpnPolicyPause, vpnPolicyIgnore:
+	default:
+		return cfg, fmt.Errorf("config file '%s' has invalid vpn policy %q (expected %q, %q or %q)", filePath, cfg.VPN.Policy, vpnPolicyWarn, vpnPolicyPause, vpnPolicyIgnore)
+	}
+	for _, pattern := range slices.Concat(cfg.VPN.Allow, cfg.VPN.Deny) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return cfg, fmt.Errorf("config file '%s' has invalid vpn adapter pattern %q: %w", filePath, pattern, err)
+		}
+	}
+
+	if cfg.StateNotifications.MaxPerHour < 0 {
+		return cfg, fmt.Errorf("config file '%s' has negative state_notifications.max_per_hour", filePath)
+	}
+	if err := validateSchedule(cfg.StateNotifications.DoNotDisturb); err != nil {
+		return cfg, fmt.Errorf("config file '%s' has an invalid state_notifications.do_not_disturb: %w", filePath, err)
+	}
+
+	if err := cfg.UpdateDownloads.validate(); err != nil {
+		return cfg, fmt.Errorf("config file '%s' has an invalid update_downloads: %w", filePath, err)
+	}
+
+	if err := cfg.ControlAPI.validate(); err != nil {
+		return cfg, fmt.Errorf("config file '%s' has an invalid control_api: %w", filePath, err)
+	}
+
+	if cfg.ReservedCores < 0 {
+		return cfg, fmt.Errorf("config file '%s' has negative reserved_cores", filePath)
+	}
+
+	if err := cfg.Metrics.Validate(); err != nil {
+		return cfg, fmt.Errorf("config file '%s' has an invalid metrics block: %w", filePath, err)
+	}
+
+	if err := validateMachineSetup(cfg.MachineSetup); err != nil {
+		return cfg, fmt.Errorf("config file '%s' has an invalid machine_setup: %w", filePath, err)
+	}
+
+	if cfg.HealthCheck.Failures < 0 {
+		return cfg, fmt.Errorf("config file '%s' has negative health_check.failures", filePath)
+	}
+
+	if cfg.Hooks.TimeoutSeconds < 0 {
+		return cfg, fmt.Errorf("config file '%s' has negative hooks.timeout_seconds", filePath)
+	}
+
 	if cfg.DefaultPort == 0 {
-		slog.Warn("DefaultPort is zero in config, using fallback 31330", "filePath", filePath)
-		cfg.DefaultPort = 31330 // Provide a default fallback
+		slog.Warn("DefaultPort is zero in config, using fallback", "filePath", filePath, "port", fallbackPort)
+		cfg.DefaultPort = fallbackPort
 	}
 
-	// --- Load Token from Windows Credential Manager ---
-	targetName := "ReEnvisionAI/hf_token" // The target name used in Credential Manager
+	if err := cfg.Backend.validate(); err != nil {
+		return cfg, fmt.Errorf("config file '%s' has an invalid backend block: %w", filePath, err)
+	}
+	cfg.Backend.apply(&cfg)
+
+	return cfg, nil
+}
 
-	cred, err := wincred.GetGenericCredential(targetName)
+func loadAppConfig(filePath string) (AppConfig, error) {
+	cfg, err := readConfigFile(filePath)
 	if err != nil {
-		// Check if the error specifically means the credential wasn't found
-		if errors.Is(err, wincred.ErrElementNotFound) {
-			// Return a specific error indicating the credential is missing
-			return cfg, fmt.Errorf("credential '%s' not found in Windows Credential Manager. Please ensure it has been added: %w", targetName, err)
+		if !errors.Is(err, os.ErrNotExist) {
+			err = fmt.Errorf("%w: %w", errConfigInvalid, err)
 		}
+		return cfg, err
+	}
+
+	// --- Load Token from Windows Credential Manager ---
+	targetName := hfTokenCredentialTarget
+
+	apiTokenBytesUTF16LE, err := secrets.Default().Get(targetName)
+	if errors.Is(err, secrets.ErrNotFound) {
+		return cfg, fmt.Errorf("%w (%s)", errHFTokenMissing, targetName)
+	}
+	if err != nil {
 		// Return other potential errors (e.g., access permissions)
 		return cfg, fmt.Errorf("error retrieving credential '%s': %w", targetName, err)
 	}
 
 	// Decode the token from UTF-16LE (as stored by Windows) to UTF-8
-	apiTokenBytesUTF16LE := cred.CredentialBlob
 	utf16leDecoder := unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM).NewDecoder()
 
 	apiTokenBytesUTF8, _, err := transform.Bytes(utf16leDecoder, apiTokenBytesUTF16LE)
@@ -0,0 +1,65 @@
+//go:build windows && unit_test
+
+package lifecycle
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestApplyEnvOverrides(t *testing.T) {
+	env := map[string]string{
+		"REAI_MODEL_NAME":            "org/other",
+		"REAI_DEFAULT_PORT":          "31400",
+		"REAI_USE_GPU":               "true",
+		"REAI_EGRESS_ALLOWLIST":      "example.com, 10.0.0.0/8",
+		"REAI_HEALTH_CHECK_FAILURES": "7",
+		"REAI_SUPABASE_URL":          "https://backend.example.com",
+	}
+	lookup := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+
+	cfg := AppConfig{ModelName: "org/model", DefaultPort: 31330, ContainerImage: "node:1"}
+	applied, err := applyEnvOverrides(&cfg, lookup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != len(env) {
+		t.Errorf("expected %d overrides, got %v", len(env), applied)
+	}
+	if cfg.ModelName != "org/other" || cfg.DefaultPort != 31400 || !cfg.UseGPU {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+	if !slices.Equal(cfg.EgressAllowlist, []string{"example.com", "10.0.0.0/8"}) {
+		t.Errorf("unexpected allowlist %v", cfg.EgressAllowlist)
+	}
+	if cfg.HealthCheck.Failures != 7 {
+		t.Errorf("expected nested override, got %d", cfg.HealthCheck.Failures)
+	}
+	if cfg.SupabaseURL != "https://backend.example.com" {
+		t.Errorf("unexpected Supabase URL %q", cfg.SupabaseURL)
+	}
+	if cfg.ContainerImage != "node:1" {
+		t.Errorf("fields without a variable should be kept, got %q", cfg.ContainerImage)
+	}
+
+	env = map[string]string{"REAI_USE_GPU": "maybe"}
+	if _, err := applyEnvOverrides(&cfg, lookup); err == nil || !strings.Contains(err.Error(), "REAI_USE_GPU") {
+		t.Errorf("expected an error naming the variable, got %v", err)
+	}
+}
+
+func TestConfigEnvName(t *testing.T) {
+	for key, want := range map[string]string{
+		"model_name":      "MODEL_NAME",
+		"supabaseUrl":     "SUPABASE_URL",
+		"supabaseAnonKey": "SUPABASE_ANON_KEY",
+	} {
+		if got := configEnvName(key); got != want {
+			t.Errorf("configEnvName(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
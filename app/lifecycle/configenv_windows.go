@@ -0,0 +1,124 @@
+package lifecycle
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Every field of config.json can be overridden by an environment variable,
+// for fleet deployments and debugging without touching the user's file. The
+// variable is REAI_ followed by the JSON key in upper case, with nested keys
+// joined by underscores, e.g. REAI_MODEL_NAME or REAI_HEALTH_CHECK_FAILURES.
+// Lists of strings are comma separated, and other values that aren't numbers,
+// booleans or strings are given as JSON.
+
+const configEnvPrefix = "REAI_"
+
+// configEnvAliases are shorter names of common overrides.
+var configEnvAliases = map[string]string{
+	"REAI_DEFAULT_PORT": "REAI_PORT",
+}
+
+// lookupConfigEnv returns the override of the variable name, or its alias.
+func lookupConfigEnv(name string) (string, bool) {
+	if value, ok := os.LookupEnv(name); ok {
+		return value, true
+	}
+	if alias, ok := configEnvAliases[name]; ok {
+		return os.LookupEnv(alias)
+	}
+	return "", false
+}
+
+// applyEnvOverrides sets the fields of cfg overridden with lookup and returns
+// the names of the variables used.
+func applyEnvOverrides(cfg *AppConfig, lookup func(string) (string, bool)) ([]string, error) {
+	var applied []string
+	err := overrideFields(reflect.ValueOf(cfg).Elem(), configEnvPrefix, lookup, &applied)
+	return applied, err
+}
+
+func overrideFields(v reflect.Value, prefix string, lookup func(string) (string, bool), applied *[]string) error {
+	t := v.Type()
+	for i := range t.NumField() {
+		field := t.Field(i)
+		key, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if !field.IsExported() || key == "" || key == "-" {
+			continue // Not read from config.json, like the token
+		}
+		name := prefix + configEnvName(key)
+		if field.Type.Kind() == reflect.Struct {
+			if err := overrideFields(v.Field(i), name+"_", lookup, applied); err != nil {
+				return err
+			}
+			continue
+		}
+		value, ok := lookup(name)
+		if !ok {
+			continue
+		}
+		if err := setFromEnv(v.Field(i), value); err != nil {
+			return fmt.Errorf("environment variable %s has an invalid value: %w", name, err)
+		}
+		*applied = append(*applied, name)
+	}
+	return nil
+}
+
+// configEnvName converts a JSON key to its variable name, e.g. model_name or
+// supabaseUrl to MODEL_NAME or SUPABASE_URL.
+func configEnvName(key string) string {
+	var b strings.Builder
+	for i, r := range key {
+		if unicode.IsUpper(r) && i > 0 {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
+
+func setFromEnv(v reflect.Value, value string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.String && !strings.HasPrefix(strings.TrimSpace(value), "[") {
+			var items []string
+			for _, item := range strings.Split(value, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+			v.Set(reflect.ValueOf(items).Convert(v.Type()))
+			return nil
+		}
+		return json.Unmarshal([]byte(value), v.Addr().Interface())
+	default:
+		return json.Unmarshal([]byte(value), v.Addr().Interface())
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+//go:build windows && unit_test
+
+package lifecycle
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrateConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, configFileName)
+	original := `{"container_image": "node:1", "model_name": "org/model", "custom_field": 1}`
+	if err := os.WriteFile(configFile, []byte(original), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := migrateConfigFile(configFile); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := readConfigFile(configFile)
+	if err != nil {
+		t.Fatalf("upgraded config should be valid: %v", err)
+	}
+	if cfg.ConfigVersion != currentConfigVersion || cfg.ContainerName != "ReEnvisionAI" {
+		t.Errorf("unexpected upgraded config %+v", cfg)
+	}
+	var fields map[string]json.RawMessage
+	data, _ := os.ReadFile(configFile)
+	if err := json.Unmarshal(data, &fields); err != nil || string(fields["custom_field"]) != "1" {
+		t.Errorf("unknown fields should be kept, got %s", data)
+	}
+	if backup, err := os.ReadFile(configFile + ".v1.bak"); err != nil || string(backup) != original {
+		t.Errorf("expected the original as backup, got %q, %v", backup, err)
+	}
+
+	// Current and newer files are left alone
+	for _, content := range []string{string(data), `{"config_version": 99, "container_name": ""}`} {
+		if err := os.WriteFile(configFile, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := migrateConfigFile(configFile); err != nil {
+			t.Fatal(err)
+		}
+		if after, _ := os.ReadFile(configFile); string(after) != content {
+			t.Errorf("config should not change, got %s", after)
+		}
+	}
+}
+
+func TestAppUpgrades(t *testing.T) {
+	defer func(saved []appUpgrade) { appUpgrades = saved }(appUpgrades)
+	var ran []string
+	upgrade := func(version string) appUpgrade {
+		return appUpgrade{version, func(fields map[string]json.RawMessage) error {
+			ran = append(ran, version)
+			fields["runtime"] = json.RawMessage(`"podman"`)
+			return nil
+		}}
+	}
+	appUpgrades = []appUpgrade{upgrade("1.2.0"), upgrade("1.3.0"), upgrade("2.0.0")}
+
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, configFileName)
+	original := `{"config_version": 2, "container_name": "reai"}`
+	if err := os.WriteFile(configFile, []byte(original), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := upgradeConfigFile(configFile, "v1.2.0", "v1.3.1"); err != nil {
+		t.Fatal(err)
+	}
+	if len(ran) != 1 || ran[0] != "1.3.0" {
+		t.Errorf("expected only the upgrade of 1.3.0 to run, ran %v", ran)
+	}
+	var fields map[string]json.RawMessage
+	data, _ := os.ReadFile(configFile)
+	if err := json.Unmarshal(data, &fields); err != nil || string(fields["runtime"]) != `"podman"` {
+		t.Errorf("expected the upgraded field, got %s", data)
+	}
+	if backup, err := os.ReadFile(configFile + ".v1.2.0.bak"); err != nil || string(backup) != original {
+		t.Errorf("expected the original as backup, got %q, %v", backup, err)
+	}
+
+	ran = nil
+	if err := upgradeConfigFile(configFile, "1.3.1", "1.3.1"); err != nil || len(ran) != 0 {
+		t.Errorf("expected no upgrades for the same version, ran %v, error %v", ran, err)
+	}
+	if err := upgradeConfigFile(configFile, "", "1.3.1"); err != nil || len(ran) != 2 {
+		t.Errorf("expected all upgrades up to the version when the last one is unknown, ran %v, error %v", ran, err)
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.2.3", "1.2.3", 0},
+		{"v1.2.3", "1.2.3", 0},
+		{"1.2", "1.2.0", 0},
+		{"1.2.3-rc1", "1.2.3", 0},
+		{"1.2.3", "1.10.0", -1},
+		{"2.0.0", "1.99.99", 1},
+		{"0.0.0", "0.1.0", -1},
+	}
+	for _, test := range tests {
+		if got := compareVersions(test.a, test.b); got != test.want {
+			t.Errorf("compareVersions(%q, %q): expected %d, got %d", test.a, test.b, test.want, got)
+		}
+	}
+}
+
+func TestRemoveConfigBackups(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, configFileName)
+	removed := []string{".v1.bak", ".0.9.0.bak", ".bak", ".damaged"}
+	kept := []string{"", ".lastgood", ".tmp"}
+	for _, suffix := range append(removed, kept...) {
+		if err := os.WriteFile(configFile+suffix, []byte("{}"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "other.json.bak"), []byte("{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := removeConfigBackups(configFile); err != nil {
+		t.Fatal(err)
+	}
+	for _, suffix := range removed {
+		if _, err := os.Stat(configFile + suffix); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be removed, got %v", configFileName+suffix, err)
+		}
+	}
+	for _, suffix := range kept {
+		if _, err := os.Stat(configFile + suffix); err != nil {
+			t.Errorf("expected %s to be kept, got %v", configFileName+suffix, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "other.json.bak")); err != nil {
+		t.Errorf("expected the backup of another file to be kept, got %v", err)
+	}
+}
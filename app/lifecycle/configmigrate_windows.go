@@ -0,0 +1,210 @@
+package lifecycle
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ReEnvision-AI/systray/app/store"
+	"github.com/ReEnvision-AI/systray/version"
+)
+
+// config.json carries a config_version so files written for an older release
+// are upgraded in place when fields are renamed or defaults change, instead
+// of failing validation after the update. Files without one are version 1.
+// The file is upgraded before it is loaded and the previous content kept as
+// config.json.v<version>.bak. A file from a newer release is left alone.
+//
+// Changes that depend on the release rather than the file's version, like a
+// new default that files written by hand should pick up, are appUpgrades.
+// They run once when the app version recorded in the store is older than
+// theirs, and may run again after a downgrade, so they must be idempotent.
+// The previous content is then kept as config.json.<app version>.bak.
+
+// currentConfigVersion is the version of config.json this release writes.
+// Raise it together with a new entry of configMigrations.
+const currentConfigVersion = 2
+
+// configMigrations upgrade the fields of config.json by one version, the
+// first from version 1 to 2.
+var configMigrations = []func(fields map[string]json.RawMessage) error{
+	migrateConfigV1,
+}
+
+// appUpgrade changes config.json for the app versions from version on.
+type appUpgrade struct {
+	version string
+	migrate func(fields map[string]json.RawMessage) error
+}
+
+// appUpgrades are ordered by version. Add an entry with the release that
+// needs it.
+var appUpgrades []appUpgrade
+
+// migrateConfigV1 fills in the container name, which files of the first
+// releases didn't have before it became required.
+func migrateConfigV1(fields map[string]json.RawMessage) error {
+	var name string
+	if raw, ok := fields["container_name"]; ok {
+		if err := json.Unmarshal(raw, &name); err != nil {
+			return fmt.Errorf("container_name: %w", err)
+		}
+	}
+	if name == "" {
+		fields["container_name"] = json.RawMessage(`"ReEnvisionAI"`)
+	}
+	return nil
+}
+
+// migrateConfigFile upgrades configFile to currentConfigVersion if it is
+// older, and for the app versions released since the last one that ran.
+func migrateConfigFile(configFile string) error {
+	lastRun := store.GetLastRunVersion()
+	if lastRun == "" && store.Created() {
+		lastRun = version.Version // Nothing to upgrade on a new install
+	}
+	if err := upgradeConfigFile(configFile, lastRun, version.Version); err != nil {
+		return err
+	}
+	store.SetLastRunVersion(version.Version)
+	return nil
+}
+
+// upgradeConfigFile upgrades configFile to currentConfigVersion, and applies
+// the appUpgrades after fromApp up to toApp. fromApp is empty if unknown, as
+// from a release before app versions were recorded.
+func upgradeConfigFile(configFile, fromApp, toApp string) error {
+	data, err := os.ReadFile(configFile)
+	if err != nil {
+		return err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", configFile, err)
+	}
+	configVersion := 1
+	if raw, ok := fields["config_version"]; ok {
+		if err := json.Unmarshal(raw, &configVersion); err != nil || configVersion < 1 {
+			return fmt.Errorf("%s has an invalid config_version %s", configFile, raw)
+		}
+	}
+	if configVersion > currentConfigVersion {
+		slog.Warn("Config file is from a newer version of the app", "config_version", configVersion, "supported", currentConfigVersion)
+		return nil
+	}
+	upgrades := pendingAppUpgrades(fromApp, toApp)
+	if configVersion == currentConfigVersion && len(upgrades) == 0 {
+		return nil
+	}
+
+	for v := configVersion; v < currentConfigVersion; v++ {
+		if err := configMigrations[v-1](fields); err != nil {
+			return fmt.Errorf("failed to upgrade %s from version %d: %w", configFile, v, err)
+		}
+	}
+	for _, upgrade := range upgrades {
+		if err := upgrade.migrate(fields); err != nil {
+			return fmt.Errorf("failed to upgrade %s for app version %s: %w", configFile, upgrade.version, err)
+		}
+	}
+	fields["config_version"] = json.RawMessage(fmt.Sprint(currentConfigVersion))
+	updated, err := json.MarshalIndent(fields, "", "  ")
+	if err != nil {
+		return err
+	}
+	updated = append(updated, '\n')
+
+	backup := fmt.Sprintf("%s.v%d.bak", configFile, configVersion)
+	if configVersion == currentConfigVersion {
+		backup = fmt.Sprintf("%s.%s.bak", configFile, cmp.Or(fromApp, "old"))
+	}
+	if err := os.WriteFile(backup, data, 0o644); err != nil {
+		return fmt.Errorf("failed to back up %s: %w", configFile, err)
+	}
+	tmp := configFile + ".tmp"
+	if err := os.WriteFile(tmp, updated, 0o644); err != nil {
+		return fmt.Errorf("failed to write upgraded config: %w", err)
+	}
+	rememberConfigContent(updated)
+	if err := os.Rename(tmp, configFile); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write upgraded config: %w", err)
+	}
+	slog.Info("Upgraded config file", "from", configVersion, "to", currentConfigVersion, "app_upgrades", len(upgrades), "backup", backup)
+	return nil
+}
+
+// pendingAppUpgrades returns the appUpgrades after fromApp up to toApp, all
+// up to toApp if fromApp is empty.
+func pendingAppUpgrades(fromApp, toApp string) []appUpgrade {
+	var pending []appUpgrade
+	for _, upgrade := range appUpgrades {
+		if (fromApp == "" || compareVersions(fromApp, upgrade.version) < 0) && compareVersions(upgrade.version, toApp) <= 0 {
+			pending = append(pending, upgrade)
+		}
+	}
+	return pending
+}
+
+// compareVersions compares app versions like v1.2.3, ignoring pre-release
+// and build suffixes. Missing or invalid parts count as 0.
+func compareVersions(a, b string) int {
+	parts := func(v string) []int {
+		v = strings.TrimPrefix(v, "v")
+		if i := strings.IndexAny(v, "-+"); i >= 0 {
+			v = v[:i]
+		}
+		var numbers []int
+		for _, part := range strings.Split(v, ".") {
+			n, _ := strconv.Atoi(part)
+			numbers = append(numbers, n)
+		}
+		return numbers
+	}
+	pa, pb := parts(a), parts(b)
+	for i := range max(len(pa), len(pb)) {
+		var x, y int
+		if i < len(pa) {
+			x = pa[i]
+		}
+		if i < len(pb) {
+			y = pb[i]
+		}
+		if c := cmp.Compare(x, y); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// removeConfigBackups deletes the copies of configFile kept when it was
+// upgraded, changed from the settings or found damaged, as they hold the
+// token and the node's settings.
+func removeConfigBackups(configFile string) error {
+	dir, base := filepath.Split(configFile)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	var errs []error
+	for _, entry := range entries {
+		name := entry.Name()
+		backup := strings.HasPrefix(name, base+".") && strings.HasSuffix(name, ".bak")
+		if !backup && name != base+store.DamagedSuffix {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,38 @@
+//go:build windows && unit_test
+
+package lifecycle
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestConfigRestartFields(t *testing.T) {
+	running := AppConfig{ContainerImage: "node:1", ModelName: "org/model", DefaultPort: 31330, EgressAllowlist: []string{"example.com"}}
+
+	changed := running
+	changed.Hooks.OnStart = "notify.cmd"
+	changed.HealthCheck.Failures = 5
+	if fields := configRestartFields(running, changed); len(fields) != 0 {
+		t.Errorf("live settings should not need a restart, got %v", fields)
+	}
+
+	changed.ModelName = "org/other"
+	changed.EgressAllowlist = []string{"example.com", "example.org"}
+	fields := configRestartFields(running, changed)
+	if !slices.Equal(fields, []string{"model_name", "egress_allowlist"}) {
+		t.Errorf("unexpected restart fields %v", fields)
+	}
+
+	// The running container's name is derived from the configured one
+	running.ContainerName, running.baseContainerName = "reai-1234abcd-next", "reai"
+	changed.ContainerName = "reai"
+	if fields := configRestartFields(running, changed); slices.Contains(fields, "container_name") {
+		t.Errorf("container name compared against the running name, got %v", fields)
+	}
+
+	applyLiveConfig(&running, changed)
+	if running.Hooks.OnStart != "notify.cmd" || running.HealthCheck.Failures != 5 || running.ModelName != "org/model" {
+		t.Errorf("only live settings should be applied, got %+v", running)
+	}
+}
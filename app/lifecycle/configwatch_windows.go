@@ -0,0 +1,202 @@
+package lifecycle
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ReEnvision-AI/systray/app/tray/commontray"
+	"golang.org/x/sys/windows"
+)
+
+// config.json is watched so changes, e.g. pushed by support, apply without
+// quitting the app. The config is loaded whenever the node starts, so a
+// stopped node needs nothing. For a running node the settings read while it
+// runs, like hooks and the health check, apply at once, and a notification
+// offers to restart it when settings of the container changed.
+
+const (
+	configWatchPoll        = time.Second            // How often the watch checks for cancellation
+	configSettleDelay      = 500 * time.Millisecond // Editors write a file in several steps
+	configRestartClickTime = 5 * time.Minute        // How long the notification's restart action is waited for
+)
+
+var (
+	configContentMu   sync.Mutex
+	lastConfigContent []byte // The config last loaded or written by the app
+)
+
+// restartConfigFields are the settings that only apply when the container
+// is started.
+var restartConfigFields = []struct {
+	name string
+	get  func(AppConfig) any
+}{
+	{"container_name", func(c AppConfig) any { return c.configuredContainerName() }},
+	{"legacy_container_name", func(c AppConfig) any { return c.LegacyContainerName }},
+	{"container_image", func(c AppConfig) any { return c.ContainerImage }},
+	{"initial_peers", func(c AppConfig) any { return c.InitialPeers }},
+	{"model_name", func(c AppConfig) any { return c.ModelName }},
+	{"default_port", func(c AppConfig) any { return c.DefaultPort }},
+	{"use_gpu", func(c AppConfig) any { return c.UseGPU }},
+	{"gpu_setup", func(c AppConfig) any { return c.GPUSetup }},
+	{"reserved_cores", func(c AppConfig) any { return c.ReservedCores }},
+	{"disable_cpu_pinning", func(c AppConfig) any { return c.DisableCPUPinning }},
+	{"podman_connection", func(c AppConfig) any { return c.PodmanConnection }},
+	{"podman_url", func(c AppConfig) any { return c.PodmanURL }},
+	{"container_sandbox", func(c AppConfig) any { return c.ContainerSandbox }},
+	{"seccomp_profile", func(c AppConfig) any { return c.SeccompProfile }},
+	{"writable_root_fs", func(c AppConfig) any { return c.WritableRootFS }},
+	{"restrict_egress", func(c AppConfig) any { return c.RestrictEgress }},
+	{"egress_allowlist", func(c AppConfig) any { return c.EgressAllowlist }},
+	{"machine_setup", func(c AppConfig) any { return c.MachineSetup }},
+	{"backend", func(c AppConfig) any { return c.Backend }},
+	{"anonymous", func(c AppConfig) any { return c.Anonymous }},
+}
+
+// configuredContainerName returns the container name as set in config.json.
+// The running container's name has the node ID and, after switching
+// containers, the slot suffix appended.
+func (c AppConfig) configuredContainerName() string {
+	if c.baseContainerName != "" {
+		return c.baseContainerName
+	}
+	return c.ContainerName
+}
+
+// configRestartFields returns the names of the settings that differ between
+// the running config and the changed one and need a restart.
+func configRestartFields(running, changed AppConfig) []string {
+	var names []string
+	for _, field := range restartConfigFields {
+		if !reflect.DeepEqual(field.get(running), field.get(changed)) {
+			names = append(names, field.name)
+		}
+	}
+	return names
+}
+
+// applyLiveConfig copies the settings that are read while the node runs.
+func applyLiveConfig(running *AppConfig, changed AppConfig) {
+	running.Hooks = changed.Hooks
+	running.HealthCheck = changed.HealthCheck
+	running.CrashRestarts = changed.CrashRestarts
+	running.Heartbeat = changed.Heartbeat
+	running.Metrics = changed.Metrics
+	running.HelperPriority = changed.HelperPriority
+	running.HelperIOPriority = changed.HelperIOPriority
+	running.WakeRestart = changed.WakeRestart
+	running.MeteredPolicy = changed.MeteredPolicy
+	running.ImageUpdates = changed.ImageUpdates
+	running.VPN = changed.VPN
+	running.StateNotifications = changed.StateNotifications
+	running.UpdateDownloads = changed.UpdateDownloads
+	running.AutoStartContainer = changed.AutoStartContainer
+}
+
+// rememberConfigContent records data as the config the app knows, so the
+// watch ignores the app's own writes.
+func rememberConfigContent(data []byte) {
+	configContentMu.Lock()
+	lastConfigContent = bytes.Clone(data)
+	configContentMu.Unlock()
+}
+
+// StartConfigWatch applies changes of config.json until ctx is cancelled.
+func StartConfigWatch(ctx context.Context) {
+	configFile, err := configFilePath()
+	if err != nil {
+		slog.Warn("Not watching the config file", "error", err)
+		return
+	}
+	if data, err := os.ReadFile(configFile); err == nil {
+		rememberConfigContent(data)
+	}
+	handle, err := windows.FindFirstChangeNotification(filepath.Dir(configFile), false, windows.FILE_NOTIFY_CHANGE_LAST_WRITE|windows.FILE_NOTIFY_CHANGE_FILE_NAME)
+	if err != nil {
+		slog.Warn("Not watching the config file", "error", err)
+		return
+	}
+
+	go func() {
+		defer windows.FindCloseChangeNotification(handle) //nolint:errcheck
+		for ctx.Err() == nil {
+			event, err := windows.WaitForSingleObject(handle, uint32(configWatchPoll.Milliseconds()))
+			if err != nil {
+				slog.Warn("Stopped watching the config file", "error", err)
+				return
+			}
+			if event != windows.WAIT_OBJECT_0 {
+				continue
+			}
+			time.Sleep(configSettleDelay)
+			if err := windows.FindNextChangeNotification(handle); err != nil {
+				slog.Warn("Stopped watching the config file", "error", err)
+				return
+			}
+			reloadConfig(configFile)
+		}
+	}()
+}
+
+// reloadConfig applies config.json if its content changed.
+func reloadConfig(configFile string) {
+	data, err := os.ReadFile(configFile)
+	if err != nil {
+		return // Being replaced, the rename is seen as another change
+	}
+	configContentMu.Lock()
+	unchanged := bytes.Equal(data, lastConfigContent)
+	lastConfigContent = data
+	configContentMu.Unlock()
+	if unchanged {
+		return
+	}
+
+	cfg, err := readConfigFile(configFile)
+	if err != nil {
+		slog.Warn("Ignoring invalid config file change", "error", err)
+		notify(commontray.NotifyWarning, "Configuration not applied", "config.json has an error, the node keeps its current settings. Open the logs from the tray menu for details")
+		return
+	}
+	stateMu.Lock()
+	running := currentState == StateRunning || currentState == StateLoading || currentState == StateStarting || currentState == StatePaused
+	stateMu.Unlock()
+	if !running {
+		slog.Info("Config file changed, applying it when the node starts")
+		return
+	}
+
+	applyOrgPolicy(&cfg, currentOrgPolicy())
+	var restart []string
+	updateAppConfig(func(running *AppConfig) {
+		applyLiveConfig(running, cfg)
+		restart = configRestartFields(*running, cfg)
+	})
+	slog.Info("Config file changed", "needs_restart", restart)
+	if len(restart) > 0 {
+		go offerConfigRestart(restart)
+	}
+}
+
+// offerConfigRestart notifies that changed settings need a restart and
+// restarts the node if the notification is clicked.
+func offerConfigRestart(fields []string) {
+	restart := make(chan struct{}, 1)
+	if !notifyAction(commontray.NotifyInfo, "Configuration changed",
+		"Changes to "+strings.Join(fields, ", ")+" apply after a restart. Click here to restart the node", restart) {
+		return
+	}
+	select {
+	case <-restart:
+		slog.Info("Restarting node to apply the config")
+		restartRunningNode()
+	case <-time.After(configRestartClickTime):
+	}
+}
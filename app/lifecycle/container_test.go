@@ -0,0 +1,22 @@
+//go:build windows && unit_test
+
+package lifecycle
+
+import "testing"
+
+func TestOwnedByNode(t *testing.T) {
+	tests := []struct {
+		labels map[string]string
+		want   bool
+	}{
+		{nil, true}, // From a release before containers were labelled
+		{map[string]string{"app": "reenvision-ai"}, true},
+		{map[string]string{nodeIDLabel: "c1f0"}, true},
+		{map[string]string{nodeIDLabel: "9b2e"}, false},
+	}
+	for _, test := range tests {
+		if got := ownedByNode(test.labels, "c1f0"); got != test.want {
+			t.Errorf("%v: expected %v, got %v", test.labels, test.want, got)
+		}
+	}
+}
@@ -3,54 +3,129 @@ package lifecycle
 import (
 	"bufio"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"os/exec"
 	"strconv"
+	"strings"
 	"sync"
-	"syscall"
+	"sync/atomic"
 	"time"
+
+	"github.com/ReEnvision-AI/systray/app/store"
 )
 
 const (
-	podmanVolumeName          = "reai-cache:/cache"
 	nvidiaCDIConfPath         = "/etc/cdi/nvidia.yaml"
 	podmanMachineStartTimeout = 5 * time.Minute
 	podmanInfoPollInterval    = 5 * time.Second
 	podmanStopTimeout         = 30 * time.Second
+
+	containerNameIDLength = 8         // Characters of the node ID appended to the container name
+	nodeIDLabel           = "node-id" // Label of the node's containers and volume with its ID
+
+	podmanRootfulAlias      = "rootful"
+	podmanRootfulConnection = "podman-machine-default-root" // Created by `podman machine init`
 )
 
 var (
 	currentCmd *exec.Cmd          // Holds the running podman command
 	cancelCmd  context.CancelFunc // Function to cancel the currentCmd context
-	appConfig  AppConfig
+
+	appConfigMu sync.RWMutex
+	appConfig   AppConfig // The config the node was started with, read it with currentAppConfig
+
+	modelLicenseRequired atomic.Bool // Set when the container output shows a gated model was refused
 )
 
 func StartContainer(ctx context.Context) error {
-	var err error
-	appConfig, err = LoadConfig()
+	if diskFull.Load() {
+		return errDiskFull
+	}
+	cfg, err := LoadConfig()
 	if err != nil {
 		slog.Error("Failed to load configuration", "error", err)
 		return err
 	}
+	cfg.baseContainerName = cfg.ContainerName
+	if !cfg.LegacyContainerName {
+		cfg.ContainerName = uniqueContainerName(cfg.ContainerName)
+	}
+	updateAppConfig(func(c *AppConfig) { *c = cfg })
+	if cfg.Anonymous {
+		refreshAnonymousMode() // Set in config.json rather than from the menu
+	}
+	refreshProfiles() // Pick up profiles added since the app started
 
 	// Wait for Podman Service
 	if err := waitForPodman(ctx); err != nil {
-		return fmt.Errorf("podman service check failed")
+		return fmt.Errorf("podman service check failed: %w", err)
+	}
+	recordMachineBoot(ctx)
+	refreshMetered()
+	if err := checkMeteredStart(ctx); err != nil {
+		return err
+	}
+	if err := checkVPNStart(); err != nil {
+		return err
+	}
+
+	// A container of this node left over from a crash would make `podman run
+	// --name` fail, so remove it, as well as one left over from switching
+	// containers. Names derived from the node ID are always removed. A
+	// container with the shared name is removed if it is this node's: one
+	// left over in legacy mode, or the container of the release before
+	// names were suffixed, which keeps the port and GPU after an upgrade.
+	if !cfg.LegacyContainerName {
+		for _, name := range []string{cfg.ContainerName, cfg.ContainerName + containerSlotSuffix} {
+			if err := removeStaleContainer(ctx, name); err != nil {
+				return err
+			}
+		}
+	}
+	for _, name := range []string{cfg.baseContainerName, cfg.baseContainerName + containerSlotSuffix} {
+		if err := removeStaleNodeContainer(ctx, name); err != nil {
+			return err
+		}
+	}
+
+	if err := ensureCacheVolume(ctx); err != nil {
+		return err
+	}
+	go logCacheVolumeSize()
+
+	if err := setupMachine(ctx); err != nil {
+		return err
 	}
 
 	setupCtx, setupCancel := context.WithTimeout(ctx, 2*time.Minute)
 	defer setupCancel()
-	if err := setupPodmanNvidia(setupCtx); err != nil {
-		return fmt.Errorf("failed to setup Podman for NVIDIA: %w", err)
+	var cpuPinArgs []string
+	if cfg.UseGPU {
+		// Not setupCtx, installing nvidia-ctk can take longer
+		if err := setupPodmanNvidia(ctx); err != nil {
+			return fmt.Errorf("failed to setup Podman for NVIDIA: %w", err)
+		}
+	} else {
+		cpuPinArgs = cpuArgs(setupCtx)
+	}
+
+	securityArgs, err := sandboxArgs()
+	if err != nil {
+		return fmt.Errorf("failed to set up the container sandbox: %w", err)
+	}
+	netArgs, err := networkArgs(ctx, Port)
+	if err != nil {
+		return fmt.Errorf("failed to set up the container network: %w", err)
 	}
 
 	stateMu.Lock()
 	//check the state
-	if currentState != StateStarting {
-		slog.Warn("Container start aborted.", "state", currentState)
+	if currentState != StateStarting || stopQueued || ctx.Err() != nil {
+		slog.Warn("Container start aborted.", "state", currentState, "stop_queued", stopQueued)
 		stateMu.Unlock()
 
 		return nil
@@ -59,9 +134,11 @@ func StartContainer(ctx context.Context) error {
 	cmdCtx, cmdCancel := context.WithCancel(context.Background())
 	cancelCmd = cmdCancel
 
-	args := buildPodmanRunCommandArgs()
-	currentCmd = exec.CommandContext(cmdCtx, "podman", args...)
-	currentCmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+	modelLicenseRequired.Store(false)
+	readOnlyRootReported.Store(false)
+	resetServerProgress()
+	args := buildPodmanRunCommandArgs(netArgs, securityArgs, cpuPinArgs)
+	currentCmd = podmanRunCommand(cmdCtx, args...)
 	slog.Info("Starting container", "command", currentCmd.String())
 
 	stdoutPipe, err := currentCmd.StdoutPipe()
@@ -86,10 +163,11 @@ func StartContainer(ctx context.Context) error {
 	// Start capturing output *before* starting the command
 	var wg sync.WaitGroup
 	wg.Add(2)
-	go captureOutput(&wg, stdoutPipe, "stdout")
-	go captureOutput(&wg, stderrPipe, "stderr")
+	go captureOutput(&wg, stdoutPipe, "stdout", markServerReady)
+	go captureOutput(&wg, stderrPipe, "stderr", markServerReady)
+	startContainerWatch(cmdCtx, cfg.ContainerName)
 
-	if err := currentCmd.Start(); err != nil {
+	if err := startHelper(currentCmd); err != nil {
 		cancelCmd() // Clean up context
 		stateMu.Lock()
 		currentCmd = nil
@@ -111,53 +189,104 @@ func StartContainer(ctx context.Context) error {
 	}
 
 	slog.Info("Container process started successfully.", "pid", currentCmd.Process.Pid)
-	SetState(StateRunning) // Transition to Running state *after* successful start
+	SetState(StateLoading) // Running once the server reports it is ready
+	go awaitServerReady(cmdCtx)
 
 	// Goroutine to wait for the command to exit and handle cleanup
-	go func() {
-		// Wait for the command to finish (either normally, by error, or cancellation)
-		waitErr := currentCmd.Wait()
+	go watchContainerExit(currentCmd, cfg.ContainerName, cmdCancel, &wg)
 
-		// Wait for output streams to be fully processed
-		wg.Wait()
+	return nil
+}
 
-		stateMu.Lock()
-		// Check if we are supposed to be stopping; if so, the state is handled by stopContainerProcess
-		isStopping := currentState == StateStopping
-		// Clear command and cancel function regardless
-		currentCmd = nil
-		cancelCmd = nil // Allow GC
-		stateMu.Unlock()
+// watchContainerExit waits for the `podman run` command of the named
+// container to exit and handles the exit, unless switchContainer replaced
+// the command meanwhile.
+func watchContainerExit(cmd *exec.Cmd, name string, cmdCancel context.CancelFunc, wg *sync.WaitGroup) {
+	defer cmdCancel() // Ends the container watch
+	// Wait for the command to finish (either normally, by error, or cancellation)
+	waitErr := cmd.Wait()
+	helperProcs.Done(cmd)
+
+	// Wait for output streams to be fully processed
+	wg.Wait()
 
-		if waitErr != nil {
-			// Log error unless it was context cancellation during a planned stop
-			if !(errors.Is(waitErr, context.Canceled) && isStopping) {
-				slog.Error("Container process exited unexpectedly.", "error", waitErr)
-				if !isStopping { // Avoid overwriting Stopping state
-					SetState(StateError)
+	stateMu.Lock()
+	if currentCmd != cmd {
+		// Being switched to or replaced, the state is about another container
+		if switchingCmd == cmd {
+			switchingCmd = nil
+		}
+		stateMu.Unlock()
+		slog.Info("Container process exited while not the node's container.", "error", waitErr)
+		return
+	}
+	// Check if we are supposed to be stopping; if so, the state is handled by stopContainerProcess
+	isStopping := currentState == StateStopping
+	var ranFor time.Duration
+	if !runningSince.IsZero() {
+		ranFor = time.Since(runningSince)
+	}
+	// Clear command and cancel function regardless
+	currentCmd = nil
+	cancelCmd = nil // Allow GC
+	stateMu.Unlock()
+	resetServerProgress()
+	containerPaused.Store(false)
+
+	if waitErr != nil {
+		// Log error unless it was context cancellation during a planned stop
+		if !(errors.Is(waitErr, context.Canceled) && isStopping) {
+			waitErr = containerExitError(name, waitErr)
+			slog.Error("Container process exited unexpectedly.", "error", waitErr)
+			if !isStopping && !restartAfterMachineRestart() { // Avoid overwriting Stopping state
+				setErrorState(waitErr)
+				if modelLicenseRequired.Load() {
+					go handleModelLicenseRequired()
+				} else {
+					scheduleCrashRestart(ranFor)
 				}
-			} else {
-				slog.Info("Container process exited after cancellation (likely during stop).")
-				// State should already be Stopping or Stopped
 			}
 		} else {
-			slog.Info("Container process exited normally.")
-			if !isStopping { // If it exited normally without a stop request
-				SetState(StateStopped)
-			}
+			slog.Info("Container process exited after cancellation (likely during stop).")
+			// State should already be Stopping or Stopped
 		}
-	}()
-
-	return nil
+	} else {
+		slog.Info("Container process exited normally.")
+		if !isStopping { // If it exited normally without a stop request
+			SetState(StateStopped)
+		}
+	}
 }
 
 func StopContainer(ctx context.Context) error {
-	slog.Info("Attempting to stop container.", "name", appConfig.ContainerName)
+	// A frozen container can't shut down gracefully
+	if err := unpauseContainer(ctx); err != nil {
+		slog.Warn("Failed to resume the container before stopping it", "error", err)
+	}
+	return stopContainer(ctx, currentAppConfig().ContainerName, cancelContainerCommand)
+}
+
+// stopContainer stops the named container, then calls cancelRun to cancel
+// its `podman run` command.
+func stopContainer(ctx context.Context, name string, cancelRun func()) error {
+	slog.Info("Attempting to stop container.", "name", name)
+
+	if api, err := newPodmanAPI(); err == nil {
+		err = api.stopContainer(ctx, name, podmanStopTimeout-5*time.Second)
+		if !errors.Is(err, errPodmanAPIUnavailable) {
+			cancelRun()
+			if err != nil && ctx.Err() == nil {
+				return fmt.Errorf("podman stop failed: %w", err)
+			}
+			slog.Info("Container stopped through the Podman API")
+			return nil
+		}
+		slog.Warn("Podman API is unavailable, stopping with the CLI", "error", err)
+	}
 
 	// Use `podman stop` first for graceful shutdown within the container
-	stopCmd := exec.CommandContext(ctx, "podman", "stop", appConfig.ContainerName)
-	stopCmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
-	stopOutput, stopErr := stopCmd.CombinedOutput()
+	stopCmd := podmanCommand(ctx, "stop", name)
+	stopOutput, stopErr := helperCombinedOutput(stopCmd)
 
 	if stopErr != nil {
 		// Log the error but continue, as we might need to cancel the `podman run` process anyway
@@ -177,16 +306,7 @@ func StopContainer(ctx context.Context) error {
 
 	// Regardless of `podman stop` success, cancel the `podman run` command's context.
 	// This signals `currentCmd.Wait()` to unblock if it hasn't already.
-	stateMu.Lock()
-	if cancelCmd != nil {
-		slog.Info("Cancelling container command context.")
-		cancelCmd()
-		// The goroutine waiting on currentCmd.Wait() should handle subsequent cleanup (setting currentCmd=nil etc.)
-	} else {
-		slog.Info("No active container command context to cancel.")
-	}
-	// We don't set currentCmd = nil here; the Wait() goroutine does that upon exit confirmation.
-	stateMu.Unlock()
+	cancelRun()
 
 	// Note: We don't forcefully kill the `podman run` process (`currentCmd.Process.Kill()`)
 	// because `podman stop` followed by context cancellation should be sufficient.
@@ -198,24 +318,184 @@ func StopContainer(ctx context.Context) error {
 
 	// Return the error from `podman stop` if there was one, allowing caller to know if graceful stop failed.
 	if stopErr != nil && !errors.Is(stopErr, context.Canceled) && !errors.Is(stopErr, context.DeadlineExceeded) {
-		return fmt.Errorf("podman stop failed: %w", stopErr)
+		return fmt.Errorf("podman stop failed: %w", podmanError(stopErr, stopOutput))
 	}
 
 	return nil
 }
 
-func buildPodmanRunCommandArgs() []string {
+// cancelContainerCommand cancels the context of the `podman run` command.
+// The goroutine waiting on currentCmd.Wait() handles the cleanup (setting
+// currentCmd=nil etc.) once it exits.
+func cancelContainerCommand() {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	if cancelCmd != nil {
+		slog.Info("Cancelling container command context.")
+		cancelCmd()
+	} else {
+		slog.Info("No active container command context to cancel.")
+	}
+}
+
+// podmanCommand builds a hidden podman CLI command that talks to the
+// configured connection. Not for `podman machine` subcommands, which
+// address the machine rather than a connection.
+func podmanCommand(ctx context.Context, args ...string) *exec.Cmd {
+	return helperCommand(ctx, "podman", podmanArgs(args)...)
+}
+
+// podmanRunCommand builds the `podman run` command of a container, which runs
+// until ctx is cancelled.
+func podmanRunCommand(ctx context.Context, args ...string) *exec.Cmd {
+	return persistentHelperCommand(ctx, "podman", podmanArgs(args)...)
+}
+
+// podmanArgs prefixes args with the connection to use.
+func podmanArgs(args []string) []string {
+	var globalArgs []string
+	cfg := currentAppConfig()
+	if cfg.PodmanURL != "" {
+		globalArgs = []string{"--url", cfg.PodmanURL}
+	} else if cfg.PodmanConnection != "" {
+		globalArgs = []string{"--connection", cfg.PodmanConnection}
+	}
+	return append(globalArgs, args...)
+}
+
+// currentAppConfig returns a copy of the config the node was started with,
+// including the changes applied while it runs.
+func currentAppConfig() AppConfig {
+	appConfigMu.RLock()
+	defer appConfigMu.RUnlock()
+	return appConfig
+}
+
+// updateAppConfig changes the config the node was started with.
+func updateAppConfig(update func(*AppConfig)) {
+	appConfigMu.Lock()
+	defer appConfigMu.Unlock()
+	update(&appConfig)
+}
+
+// uniqueContainerName suffixes the configured container name with the short
+// node ID so different users or profiles on one machine don't collide.
+func uniqueContainerName(base string) string {
+	id := store.GetID()
+	if len(id) > containerNameIDLength {
+		id = id[:containerNameIDLength]
+	}
+	return base + "-" + id
+}
+
+// removeStaleContainer force removes the named container if it exists.
+func removeStaleContainer(ctx context.Context, name string) error {
+	if api, err := newPodmanAPI(); err == nil {
+		exists, err := api.containerExists(ctx, name)
+		if !errors.Is(err, errPodmanAPIUnavailable) {
+			if err != nil || !exists {
+				return err
+			}
+			slog.Warn("Removing stale container", "name", name)
+			if err := api.removeContainer(ctx, name); err != nil {
+				return fmt.Errorf("failed to remove stale container %s: %w", name, err)
+			}
+			return nil
+		}
+	}
+
+	if err := runHelper(podmanCommand(ctx, "container", "exists", name)); err != nil {
+		// Exit status 1 means the container doesn't exist
+		return nil
+	}
+	slog.Warn("Removing stale container", "name", name)
+	output, err := helperCombinedOutput(podmanCommand(ctx, "rm", "--force", name))
+	if err != nil {
+		return fmt.Errorf("failed to remove stale container %s: %w", name, podmanError(err, output))
+	}
+	return nil
+}
+
+// removeStaleNodeContainer removes the named container if it belongs to this
+// node. Containers carry the ID of their node as a label, except those of
+// releases before it was added, which only ran one node per machine under
+// the shared name, so a container without one is taken to be this node's.
+func removeStaleNodeContainer(ctx context.Context, name string) error {
+	labels, exists, err := containerLabels(ctx, name)
+	if err != nil || !exists {
+		return err
+	}
+	if !ownedByNode(labels, store.GetID()) {
+		slog.Info("Leaving the container of another node", "name", name, "node_id", labels[nodeIDLabel])
+		return nil
+	}
+	return removeStaleContainer(ctx, name)
+}
+
+// ownedByNode reports whether a container with labels belongs to the node
+// with nodeID, see removeStaleNodeContainer.
+func ownedByNode(labels map[string]string, nodeID string) bool {
+	owner := labels[nodeIDLabel]
+	return owner == "" || owner == nodeID
+}
+
+// containerLabels returns the labels of the named container and whether it
+// exists.
+func containerLabels(ctx context.Context, name string) (map[string]string, bool, error) {
+	if api, err := newPodmanAPI(); err == nil {
+		labels, err := api.containerLabels(ctx, name)
+		if isAPINotFound(err) {
+			return nil, false, nil
+		}
+		if !errors.Is(err, errPodmanAPIUnavailable) {
+			return labels, err == nil, err
+		}
+	}
+
+	if err := runHelper(podmanCommand(ctx, "container", "exists", name)); err != nil {
+		// Exit status 1 means the container doesn't exist
+		return nil, false, nil
+	}
+	output, err := helperCombinedOutput(podmanCommand(ctx, "inspect", "--format", "{{json .Config.Labels}}", name))
+	if err != nil {
+		return nil, false, podmanError(err, output)
+	}
+	var labels map[string]string
+	if err := json.Unmarshal(output, &labels); err != nil {
+		return nil, false, fmt.Errorf("failed to parse the labels of %s: %w", name, err)
+	}
+	return labels, true, nil
+}
+
+func buildPodmanRunCommandArgs(netArgs, securityArgs, cpuPinArgs []string) []string {
+	return podmanRunArgs(currentAppConfig().ContainerName, Port, netArgs, securityArgs, cpuPinArgs)
+}
+
+// podmanRunArgs returns the podman run arguments of a container with the
+// name, serving on port.
+func podmanRunArgs(name string, port uint64, netArgs, securityArgs, cpuPinArgs []string) []string {
+	cfg := currentAppConfig()
 
 	// Base arguments
 	args := []string{
 		"run",
-		"--network=host", // Use host networking
-		"--rm",           // Remove container on exit
-		"--name=" + appConfig.ContainerName,
-		"--volume=" + podmanVolumeName, // Mount cache volume
-		"--pull=newer",                 // Pulls newer image even if same version
+		"--rm", // Remove container on exit
+		"--name=" + name,
+		"--label=" + cacheVolumeAppLabel,
+		"--label=" + nodeIDLabel + "=" + store.GetID(),
+		"--volume=" + cacheVolumeName + ":" + cacheVolumeMountPath, // Mount cache volume
 		"-e AGENT_GRID_VERSION=1.6.0",
 	}
+	args = append(args, pullArgs()...) // No downloads on metered connections
+	if id := operationID(); id != "" {
+		args = append(args, "--env=REAI_OPERATION_ID="+id, "--label=ai.reenvision.operation="+id)
+	}
+	if cfg.Backend.ModelCatalogURL != "" {
+		args = append(args, "--env=HF_ENDPOINT="+strings.TrimRight(cfg.Backend.ModelCatalogURL, "/"))
+	}
+	args = append(args, netArgs...) // Host networking unless egress is restricted
+	args = append(args, securityArgs...)
+	args = append(args, readOnlyRootArgs()...)
 
 	// GPU arguments - Use CDI if available, requires Podman >= 4.x
 	// Using --device nvidia.com/gpu=all enables CDI discovery.
@@ -224,30 +504,33 @@ func buildPodmanRunCommandArgs() []string {
 	// Assuming setupPodmanNvidia was successful if GPU is desired/present.
 	// We might need a config flag or runtime check result to decide if GPU args are added.
 	// For now, add them conditionally based on a simple config flag (example)
-	if appConfig.UseGPU { // Assuming an `UseGPU bool` field in config.AppConfig
+	if cfg.UseGPU { // Assuming an `UseGPU bool` field in config.AppConfig
 		slog.Info("Adding GPU arguments to podman run command.")
 		args = append(args, "--device=nvidia.com/gpu=all")
-		// Privilege/IPC might be needed for some GPU setups/drivers
-		args = append(args, "--privileged") // CAUTION: Security risk! Evaluate if necessary.
-		args = append(args, "--ipc=host")   // Often needed for CUDA multi-process
+		// CDI exposes the GPU without privileged mode, see container_sandbox
+		args = append(args, "--ipc=host") // Often needed for CUDA multi-process
 	} else {
 		slog.Info("GPU arguments omitted based on configuration.")
+		args = append(args, cpuPinArgs...)
 	}
 
 	// Add image and command parts
-	args = append(args, appConfig.ContainerImage) // The image name
-	args = append(args,                           // The command and its arguments within the container
+	args = append(args, cfg.ContainerImage) // The image name
+	args = append(args,                     // The command and its arguments within the container
 		"python", "-m", "agentgrid.cli.run_server",
 		"--inference_max_length", "136192",
-		"--port", strconv.FormatUint(Port, 10),
+		"--port", strconv.FormatUint(port, 10),
 		"--max_alloc_timeout", "6000",
 		"--quant_type", "nf4",
 		"--attn_cache_tokens", "128000",
-		appConfig.ModelName,
-		"--token", appConfig.Token,
+		cfg.ModelName,
+		"--token", cfg.Token,
 		"--throughput", "eval",
 		//"--initial_peers", appConfig.InitialPeers,
 	)
+	if anonymousMode() {
+		args = append(args, "--public_name", anonymousPublicName)
+	}
 
 	return args
 }
@@ -255,15 +538,22 @@ func buildPodmanRunCommandArgs() []string {
 func waitForPodman(ctx context.Context) error {
 	slog.Info("Waiting for Podman machine and service...")
 
-	// Attempt to start the machine, ignore errors for now (might already be running)
+	// Attempt to start the machine, it might already be running
 	// Hide the window for this command.
-	startCmd := exec.CommandContext(ctx, "podman", "machine", "start")
-	startCmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
-	startOutput, startErr := startCmd.CombinedOutput()
+	startCmd := helperCommand(ctx, "podman", "machine", "start")
+	startOutput, startErr := machineHelperCombinedOutput(startCmd)
 	if startErr != nil {
-		// Log output only if there was an error, might contain useful info
-		slog.Warn("Podman machine start command finished", "output", string(startOutput), "error", startErr)
-		// Don't return yet, maybe it's already running and 'podman info' will succeed
+		startErr = podmanError(startErr, startOutput)
+		switch {
+		case errors.Is(startErr, ErrPodmanMachineRunning):
+			slog.Info("Podman machine is already running")
+		case errors.Is(startErr, ErrPodmanMachineNotFound):
+			// Polling podman info can't succeed without a machine
+			return startErr
+		default:
+			// Don't return yet, 'podman info' may still succeed
+			slog.Warn("Podman machine start command finished", "error", startErr)
+		}
 	} else {
 		slog.Info("Podman machine start command finished", "output", string(startOutput))
 	}
@@ -276,73 +566,77 @@ func waitForPodman(ctx context.Context) error {
 	waitCtx, cancel := context.WithTimeout(ctx, podmanMachineStartTimeout)
 	defer cancel()
 
+	var lastErr error
 	for {
 		select {
 		case <-waitCtx.Done():
+			if lastErr != nil {
+				return fmt.Errorf("timed out after %v waiting for podman service: %w", podmanMachineStartTimeout, lastErr)
+			}
 			return fmt.Errorf("timed out after %v waiting for podman service", podmanMachineStartTimeout)
 		case <-ticker.C:
 			slog.Info("Checking podman status...")
-			cmd := exec.CommandContext(waitCtx, "podman", "info")
-			cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
-			// Run and discard output, we only care about the exit code
-			if err := cmd.Run(); err == nil {
+			cmd := podmanCommand(waitCtx, "info")
+			// The output only matters for classifying a failure
+			output, err := helperCombinedOutput(cmd)
+			if err == nil {
 				slog.Info("Podman service is ready.")
 				return nil // Podman is ready
-			} else {
-				// Log the specific error from podman info
-				slog.Info("Podman service not ready yet", "error", err)
 			}
+			// Log the specific error from podman info
+			lastErr = podmanError(err, output)
+			slog.Info("Podman service not ready yet", "error", lastErr)
 		}
 	}
 }
 
 func setupPodmanNvidia(ctx context.Context) error {
-	hasGPU, err := checkNvidiaGPU(ctx)
-	if err != nil {
-		// Log the error but don't necessarily block startup if check fails
-		slog.Error("Error checking for Nvidia GPU", "error", err)
-		// Decide if this is fatal. If GPU support is optional, maybe just warn and continue.
-		// For now, let's warn and proceed without GPU setup.
-		slog.Warn("Proceeding without attempting Nvidia CDI setup due to GPU check error.")
-		return errors.New("error checking for Nvidia GPU")
-	}
-
-	if !hasGPU {
-		slog.Info("No Nvidia GPU detected or nvidia-smi failed, skipping Nvidia CDI setup for Podman.")
-		SetState(StateThankyou)
-		return errors.New("no Nvidia GPU detected")
-	}
+	switch currentAppConfig().GPUSetup {
+	case gpuSetupSkip:
+		slog.Info("gpu_setup is skip, assuming Nvidia CDI is already provisioned in the Podman machine.")
+		return nil
+	case gpuSetupForce:
+		slog.Info("gpu_setup is force, configuring Podman machine via CDI without checking for a GPU...")
+	default:
+		checkCtx, checkCancel := context.WithTimeout(ctx, nvidiaSetupTimeout)
+		hasGPU, err := checkNvidiaGPU(checkCtx)
+		checkCancel()
+		if err != nil {
+			// Log the error but don't necessarily block startup if check fails
+			slog.Error("Error checking for Nvidia GPU", "error", err)
+			// Decide if this is fatal. If GPU support is optional, maybe just warn and continue.
+			// For now, let's warn and proceed without GPU setup.
+			slog.Warn("Proceeding without attempting Nvidia CDI setup due to GPU check error.")
+			return errors.New("error checking for Nvidia GPU")
+		}
 
-	slog.Info("Nvidia GPU detected, attempting to configure Podman machine via CDI...")
+		if !hasGPU {
+			slog.Info("No Nvidia GPU detected or nvidia-smi failed, skipping Nvidia CDI setup for Podman.")
+			SetState(StateThankyou)
+			return errors.New("no Nvidia GPU detected")
+		}
 
-	// Command to generate CDI spec inside the podman machine VM
-	// IMPORTANT: This assumes passwordless sudo and nvidia-ctk installed in the VM.
-	cdiCmd := fmt.Sprintf("sudo nvidia-ctk cdi generate --output=%s", nvidiaCDIConfPath)
-	cmd := exec.CommandContext(ctx, "podman", "machine", "ssh", cdiCmd)
-	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+		slog.Info("Nvidia GPU detected, attempting to configure Podman machine via CDI...")
+	}
 
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		slog.Error("Failed to generate Nvidia CDI configuration in Podman machine.",
-			"command", cmd.String(),
-			"output", string(output),
-			"error", err)
+	// Generate the CDI spec inside the podman machine VM
+	if err := generateNvidiaCDI(ctx); err != nil {
+		slog.Error("Failed to generate Nvidia CDI configuration in Podman machine.", "error", err)
 		// This might be critical depending on whether GPU is required.
 		// Returning an error signals failure.
-		return fmt.Errorf("nvidia CDI setup failed: %w. Output: %s", err, string(output))
+		return fmt.Errorf("nvidia CDI setup failed: %w", err)
 	}
 
-	slog.Info("Successfully generated Nvidia CDI configuration.", "path_in_vm", nvidiaCDIConfPath, "output", string(output))
+	slog.Info("Successfully generated Nvidia CDI configuration.", "path_in_vm", nvidiaCDIConfPath)
 	return nil
 }
 
 func checkNvidiaGPU(ctx context.Context) (bool, error) {
 
 	slog.Info("Checking for Nvidia GPU using nvidia-smi...")
-	cmd := exec.CommandContext(ctx, "nvidia-smi", "--list-gpus")
-	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+	cmd := helperCommand(ctx, "nvidia-smi", "--list-gpus")
 
-	output, err := cmd.Output() // Use Output instead of CombinedOutput if stderr is not needed for success check
+	output, err := helperOutput(cmd) // Use Output instead of CombinedOutput if stderr is not needed for success check
 	if err != nil {
 		// Check if the error is because the command wasn't found or failed execution
 		var exitErr *exec.ExitError
@@ -364,12 +658,29 @@ func checkNvidiaGPU(ctx context.Context) (bool, error) {
 	return found, nil
 }
 
-func captureOutput(wg *sync.WaitGroup, rc io.ReadCloser, streamName string) {
+// captureOutput logs the container output read from rc, calling ready when
+// it shows the server is ready.
+func captureOutput(wg *sync.WaitGroup, rc io.ReadCloser, streamName string, ready func()) {
 	defer wg.Done()
 	defer rc.Close()
 	scanner := bufio.NewScanner(rc)
 	for scanner.Scan() {
-		slog.Info(scanner.Text())
+		line := redactSecrets(scanner.Text())
+		if recordServerProgress(line) {
+			slog.Debug(line)
+		} else {
+			slog.Info(line)
+		}
+		containerLog.add(streamName, line, time.Now())
+		if isModelLicenseError(line) {
+			modelLicenseRequired.Store(true)
+		}
+		if path, ok := readOnlyRootError(line); ok {
+			reportReadOnlyRootError(path)
+		}
+		if isServerReadyLine(line) {
+			ready()
+		}
 	}
 	if err := scanner.Err(); err != nil {
 		// Don't log EOF errors, they are expected
@@ -0,0 +1,106 @@
+package lifecycle
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// The latest lines the container printed are kept in memory for the live
+// log viewer, so users don't have to find the log file. Each line is given
+// a level guessed from its text, as the server logs everything to stderr.
+
+const containerLogLines = 2000 // Lines kept for the live log viewer
+
+// logLevel is the severity of a line of container output.
+type logLevel int
+
+const (
+	levelDebug logLevel = iota
+	levelInfo
+	levelWarning
+	levelError
+)
+
+// logLine is a line of container output.
+type logLine struct {
+	Seq    uint64 // Counts the lines since the app started
+	Time   time.Time
+	Stream string // "stdout" or "stderr", or "app" for the app's own log
+	Level  logLevel
+	Text   string
+}
+
+// lineLevel guesses the level of a line of output from its text.
+func lineLevel(text string) logLevel {
+	upper := strings.ToUpper(text)
+	has := func(words ...string) bool {
+		for _, word := range words {
+			if strings.Contains(upper, word) {
+				return true
+			}
+		}
+		return false
+	}
+	switch {
+	case has("ERROR", "CRITICAL", "FATAL", "TRACEBACK", "EXCEPTION"):
+		return levelError
+	case has("WARN"):
+		return levelWarning
+	case has("DEBUG"):
+		return levelDebug
+	}
+	return levelInfo
+}
+
+// lineRing keeps the latest lines of output.
+type lineRing struct {
+	mu    sync.Mutex
+	lines []logLine // Ordered by Seq, at most size
+	size  int
+	next  uint64 // Seq of the next line
+}
+
+var containerLog = &lineRing{size: containerLogLines}
+
+func (r *lineRing) add(stream, text string, now time.Time) {
+	r.addLevel(stream, text, lineLevel(text), now)
+}
+
+// addLevel adds a line whose level is known, instead of guessed from its text.
+func (r *lineRing) addLevel(stream, text string, level logLevel, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.lines) >= r.size {
+		// The oldest quarter is dropped at once, so lines aren't moved for every line added
+		r.lines = append(r.lines[:0], r.lines[r.size/4+1:]...)
+	}
+	r.lines = append(r.lines, logLine{Seq: r.next, Time: now, Stream: stream, Level: level, Text: text})
+	r.next++
+}
+
+// since returns the lines kept from seq on, and the seq of the next line.
+func (r *lineRing) since(seq uint64) ([]logLine, uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := 0
+	for i < len(r.lines) && r.lines[i].Seq < seq {
+		i++
+	}
+	lines := make([]logLine, len(r.lines)-i)
+	copy(lines, r.lines[i:])
+	return lines, r.next
+}
+
+// logFilter selects the lines the live log viewer shows.
+type logFilter struct {
+	MinLevel logLevel
+	Search   string // Case insensitive, empty to show all lines
+}
+
+func (f logFilter) matches(line logLine) bool {
+	if line.Level < f.MinLevel {
+		return false
+	}
+	return f.Search == "" || strings.Contains(strings.ToLower(line.Text), strings.ToLower(f.Search))
+}
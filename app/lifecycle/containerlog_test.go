@@ -0,0 +1,65 @@
+//go:build windows && unit_test
+
+package lifecycle
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLineLevel(t *testing.T) {
+	for text, want := range map[string]logLevel{
+		"May 06 12:00:00.123 [INFO] Announced that blocks [0, 12) are joining": levelInfo,
+		"May 06 12:00:01.456 [WARN] Connection to peer lost":                   levelWarning,
+		"Traceback (most recent call last):":                                   levelError,
+		"torch.cuda.OutOfMemoryError: CUDA out of memory":                      levelError,
+		"[DEBUG] sending heartbeat":                                            levelDebug,
+		"Loading checkpoint shards: 100%":                                      levelInfo,
+	} {
+		if got := lineLevel(text); got != want {
+			t.Errorf("%q: expected level %d, got %d", text, want, got)
+		}
+	}
+}
+
+func TestLineRing(t *testing.T) {
+	ring := &lineRing{size: 8}
+	now := time.Now()
+	for i := range 20 {
+		ring.add("stderr", fmt.Sprintf("line %d", i), now)
+	}
+	lines, next := ring.since(0)
+	if next != 20 || len(lines) == 0 || len(lines) > 8 || lines[len(lines)-1].Text != "line 19" {
+		t.Fatalf("expected at most 8 of the latest lines and next 20, got %d lines ending %q, next %d", len(lines), lines[len(lines)-1].Text, next)
+	}
+	for i := 1; i < len(lines); i++ {
+		if lines[i].Seq != lines[i-1].Seq+1 {
+			t.Fatalf("expected consecutive lines, got %d after %d", lines[i].Seq, lines[i-1].Seq)
+		}
+	}
+	if lines, _ := ring.since(18); len(lines) != 2 || lines[0].Text != "line 18" {
+		t.Errorf("expected the lines from 18, got %v", lines)
+	}
+	if lines, next := ring.since(20); len(lines) != 0 || next != 20 {
+		t.Errorf("expected no new lines, got %v, next %d", lines, next)
+	}
+}
+
+func TestRenderLogLines(t *testing.T) {
+	now := time.Date(2024, 5, 6, 12, 30, 0, 0, time.Local)
+	lines := []logLine{
+		{Time: now, Level: levelInfo, Text: "Loading model"},
+		{Time: now, Level: levelWarning, Text: "Slow peer"},
+		{Time: now, Level: levelError, Text: "CUDA error"},
+	}
+	text, count := renderLogLines(lines, logFilter{MinLevel: levelWarning})
+	if count != 2 || text != "12:30:00  Slow peer\r\n12:30:00  CUDA error\r\n" {
+		t.Errorf("expected warnings and errors, got %d: %q", count, text)
+	}
+	text, count = renderLogLines(lines, logFilter{Search: "cuda"})
+	if count != 1 || !strings.Contains(text, "CUDA error") {
+		t.Errorf("expected the search to ignore case, got %d: %q", count, text)
+	}
+}
@@ -0,0 +1,131 @@
+//go:build windows && unit_test
+
+package lifecycle
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ReEnvision-AI/systray/app/store"
+	"github.com/ReEnvision-AI/systray/app/tray/commontray"
+)
+
+func TestControlAPI(t *testing.T) {
+	t.Setenv("LOCALAPPDATA", t.TempDir())
+	auth := http.Header{"Authorization": {"Bearer " + store.GetAPIToken()}}
+	callbacks := commontray.Callbacks{
+		StartContainer: make(chan struct{}, 1),
+		StopContainer:  make(chan struct{}, 1),
+		Restart:        make(chan struct{}, 1),
+	}
+	handler := controlAPIHandler(callbacks)
+	serve := func(method, target string, header http.Header) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, nil)
+		r.Host = "127.0.0.1:8642"
+		for key, values := range header {
+			r.Header[key] = values
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	w := serve("GET", "/v1/status", auth)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status: expected 200, got %d", w.Code)
+	}
+	var status controlStatus
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+		t.Fatalf("status: invalid JSON %q: %v", w.Body.String(), err)
+	}
+
+	if w := serve("POST", "/v1/stop", auth); w.Code != http.StatusAccepted {
+		t.Errorf("stop: expected 202, got %d", w.Code)
+	}
+	select {
+	case <-callbacks.StopContainer:
+	default:
+		t.Error("stop: expected the stop callback")
+	}
+	if w := serve("GET", "/v1/stop", auth); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET stop: expected 405, got %d", w.Code)
+	}
+	if w := serve("GET", "/v1/logs?lines=0", auth); w.Code != http.StatusBadRequest {
+		t.Errorf("logs: expected 400 for invalid lines, got %d", w.Code)
+	}
+	if w := serve("POST", "/v1/start", http.Header{"Origin": {"https://example.com"}, "Authorization": auth["Authorization"]}); w.Code != http.StatusForbidden {
+		t.Errorf("web page: expected 403, got %d", w.Code)
+	}
+	if len(callbacks.StartContainer) != 0 {
+		t.Error("web page: expected no start callback")
+	}
+
+	for name, header := range map[string]http.Header{
+		"no token":    nil,
+		"wrong token": {"Authorization": {"Bearer wrong"}},
+		"no bearer":   {"Authorization": {store.GetAPIToken()}},
+	} {
+		if w := serve("POST", "/v1/stop", header); w.Code != http.StatusUnauthorized {
+			t.Errorf("%s: expected 401, got %d", name, w.Code)
+		}
+		if w := serve("GET", "/v1/logs", header); w.Code != http.StatusUnauthorized {
+			t.Errorf("%s: expected 401 for logs, got %d", name, w.Code)
+		}
+	}
+	if len(callbacks.StopContainer) != 0 {
+		t.Error("unauthorized: expected no stop callback")
+	}
+}
+
+func TestControlAPIRebinding(t *testing.T) {
+	r := httptest.NewRequest("GET", "/v1/status", nil)
+	r.Host = "attacker.example:8642"
+	w := httptest.NewRecorder()
+	controlAPIHandler(commontray.Callbacks{}).ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for another host name, got %d", w.Code)
+	}
+}
+
+func TestControlAPIValidate(t *testing.T) {
+	for _, port := range []int{0, 8642, 65535} {
+		if err := (ControlAPI{Port: port}).validate(); err != nil {
+			t.Errorf("port %d: expected no error, got %v", port, err)
+		}
+	}
+	for _, port := range []int{-1, 65536} {
+		if err := (ControlAPI{Port: port}).validate(); err == nil {
+			t.Errorf("port %d: expected an error", port)
+		}
+	}
+}
+
+func TestControlAPIRotatedToken(t *testing.T) {
+	t.Setenv("LOCALAPPDATA", t.TempDir())
+	handler := controlAPIHandler(commontray.Callbacks{})
+	status := func(token string) int {
+		r := httptest.NewRequest("GET", "/v1/version", nil)
+		r.Host = "127.0.0.1:8642"
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	old := store.GetAPIToken()
+	if code := status(old); code != http.StatusOK {
+		t.Fatalf("current token: expected 200, got %d", code)
+	}
+	rotated := store.RotateAPIToken()
+	if rotated == old {
+		t.Fatal("expected a new token")
+	}
+	if code := status(old); code != http.StatusUnauthorized {
+		t.Errorf("old token: expected 401, got %d", code)
+	}
+	if code := status(rotated); code != http.StatusOK {
+		t.Errorf("rotated token: expected 200, got %d", code)
+	}
+}
@@ -0,0 +1,184 @@
+package lifecycle
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ReEnvision-AI/systray/app/store"
+	"github.com/ReEnvision-AI/systray/app/tray/commontray"
+	"github.com/ReEnvision-AI/systray/version"
+)
+
+// The control API lets scripts, dashboards and the installer query and
+// control the node over HTTP on the loopback interface:
+//
+//	GET  /v1/status   State and progress of the node
+//	GET  /v1/version  Version of the app
+//	GET  /v1/logs     Latest node output lines, ?lines=N, ?source=app for the app log
+//	POST /v1/start, /v1/stop and /v1/restart, like the tray menu entries
+//
+// It is off unless control_api.port is set in config.json, which is read
+// when the app starts. Requests must carry the node's API token as a bearer
+// token, shown by "Show API token" in the tray. Requests from web pages are
+// refused, so a site can't have the browser stop the node: they carry an
+// Origin header, or the host name a rebound DNS name resolved to the
+// loopback address.
+
+// ControlAPI configures the local HTTP control API.
+type ControlAPI struct {
+	Port int `json:"port"` // Port on 127.0.0.1, 0 for off
+}
+
+const (
+	controlAPILogLines        = 100 // Lines of /v1/logs unless ?lines= is set
+	controlAPIShutdownTimeout = 5 * time.Second
+)
+
+// controlStatus is the response of /v1/status.
+type controlStatus struct {
+	State        string     `json:"state"`  // Like "Running"
+	Status       string     `json:"status"` // The status text of the tray
+	NodeID       string     `json:"node_id"`
+	RunningSince *time.Time `json:"running_since,omitempty"`
+	Blocks       int        `json:"blocks"` // -1 until the node announced its blocks
+	Peers        int        `json:"peers"`  // -1 until reported
+	Throughput   float64    `json:"throughput"`
+	Downloading  bool       `json:"downloading"`
+	Download     int        `json:"download_percent"`
+}
+
+func (c ControlAPI) validate() error {
+	if c.Port < 0 || c.Port > 65535 {
+		return fmt.Errorf("invalid port %d", c.Port)
+	}
+	return nil
+}
+
+// StartControlAPI serves the control API until ctx is cancelled, if it is
+// turned on. Actions are sent on the callbacks of the tray menu.
+func StartControlAPI(ctx context.Context, callbacks commontray.Callbacks) {
+	cfg, err := readConfig()
+	if err != nil || cfg.ControlAPI.Port == 0 {
+		return
+	}
+	listener, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(cfg.ControlAPI.Port)))
+	if err != nil {
+		slog.Warn("Failed to start the control API", "port", cfg.ControlAPI.Port, "error", err)
+		return
+	}
+	server := &http.Server{Handler: controlAPIHandler(callbacks), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), controlAPIShutdownTimeout)
+		defer cancel()
+		server.Shutdown(shutdownCtx) //nolint:errcheck
+	}()
+	go func() {
+		slog.Info("Control API listening", "address", listener.Addr().String())
+		if err := server.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+			slog.Warn("Control API stopped", "error", err)
+		}
+	}()
+}
+
+func controlAPIHandler(callbacks commontray.Callbacks) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/status", handleControlStatus)
+	mux.HandleFunc("GET /v1/version", handleControlVersion)
+	mux.HandleFunc("GET /v1/logs", handleControlLogs)
+	mux.Handle("POST /v1/start", controlAction(callbacks.StartContainer))
+	mux.Handle("POST /v1/stop", controlAction(callbacks.StopContainer))
+	mux.Handle("POST /v1/restart", controlAction(callbacks.Restart))
+	return localOnly(requireAPIToken(mux))
+}
+
+// localOnly refuses requests made by web pages.
+func localOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		if r.Header.Get("Origin") != "" || (host != "127.0.0.1" && !strings.EqualFold(host, "localhost")) {
+			http.Error(w, "requests from web pages are not allowed", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// controlAction sends on the tray menu callback action, answering once the
+// app took the request.
+func controlAction(action chan struct{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case action <- struct{}{}:
+			w.WriteHeader(http.StatusAccepted)
+		case <-r.Context().Done():
+		}
+	})
+}
+
+func handleControlStatus(w http.ResponseWriter, _ *http.Request) {
+	stateMu.Lock()
+	status := controlStatus{State: currentState.String(), Status: statusText}
+	if !runningSince.IsZero() {
+		since := runningSince
+		status.RunningSince = &since
+	}
+	stateMu.Unlock()
+	progress := currentServerProgress()
+	status.NodeID = store.GetID()
+	status.Blocks, status.Peers, status.Throughput = progress.Blocks, progress.Peers, progress.Throughput
+	status.Downloading, status.Download = progress.Downloading, progress.Download
+	writeControlJSON(w, status)
+}
+
+func handleControlVersion(w http.ResponseWriter, _ *http.Request) {
+	writeControlJSON(w, map[string]string{
+		"version":    version.Version,
+		"commit":     version.Commit,
+		"build_date": version.BuildDate,
+		"channel":    version.Channel,
+	})
+}
+
+func handleControlLogs(w http.ResponseWriter, r *http.Request) {
+	count := controlAPILogLines
+	if value := r.URL.Query().Get("lines"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid lines", http.StatusBadRequest)
+			return
+		}
+		count = n
+	}
+	var lines []logLine
+	switch r.URL.Query().Get("source") {
+	case "", "node":
+		lines, _ = containerLog.since(0)
+	case "app":
+		lines, _ = recentLog.since(0)
+	default:
+		http.Error(w, "source must be node or app", http.StatusBadRequest)
+		return
+	}
+	text, _ := renderLogLines(lines[max(len(lines)-count, 0):], logFilter{})
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(text)) //nolint:errcheck
+}
+
+func writeControlJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Debug("failed to write control API response", "error", err)
+	}
+}
@@ -0,0 +1,70 @@
+//go:build windows && unit_test
+
+package lifecycle
+
+import (
+	"slices"
+	"testing"
+)
+
+// 4 cores with SMT siblings numbered like Linux does, siblings in the upper half
+const lscpuSingleNode = `# The following is the parsable format, which can be fed to other
+# programs. Each different item in every column has an unique ID
+# starting from zero.
+# CPU,Core,Socket,Node
+0,0,0,
+1,1,0,
+2,2,0,
+3,3,0,
+4,0,0,
+5,1,0,
+6,2,0,
+7,3,0,
+`
+
+const lscpuTwoNodes = `# CPU,Core,Socket,Node
+0,0,0,0
+1,1,0,0
+2,2,0,0
+3,0,1,1
+4,1,1,1
+5,2,1,1
+`
+
+func TestSelectCPUsSingleNode(t *testing.T) {
+	pinning, ok := selectCPUs(parseLscpu([]byte(lscpuSingleNode)), 1)
+	if !ok {
+		t.Fatal("expected a pinning")
+	}
+	if expected := []int{1, 2, 3, 5, 6, 7}; !slices.Equal(pinning.CPUs, expected) {
+		t.Errorf("expected CPUs %v, got %v", expected, pinning.CPUs)
+	}
+	if pinning.Cores != 3 || pinning.Node != -1 {
+		t.Errorf("expected 3 cores without a node, got %d cores on node %d", pinning.Cores, pinning.Node)
+	}
+}
+
+func TestSelectCPUsPicksLargestNode(t *testing.T) {
+	pinning, ok := selectCPUs(parseLscpu([]byte(lscpuTwoNodes)), 2)
+	if !ok {
+		t.Fatal("expected a pinning")
+	}
+	if expected := []int{3, 4, 5}; !slices.Equal(pinning.CPUs, expected) {
+		t.Errorf("expected CPUs %v, got %v", expected, pinning.CPUs)
+	}
+	if pinning.Node != 1 {
+		t.Errorf("expected node 1, got %d", pinning.Node)
+	}
+}
+
+func TestSelectCPUsTooFewCores(t *testing.T) {
+	if _, ok := selectCPUs(parseLscpu([]byte(lscpuSingleNode)), 4); ok {
+		t.Error("expected no pinning when every core is reserved")
+	}
+}
+
+func TestFormatCPUList(t *testing.T) {
+	if got := formatCPUList([]int{1, 2, 3, 5, 7, 8}); got != "1-3,5,7-8" {
+		t.Errorf("unexpected CPU list %q", got)
+	}
+}
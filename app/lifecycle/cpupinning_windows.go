@@ -0,0 +1,179 @@
+package lifecycle
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Nodes without use_gpu serve on the CPU. Left alone the model server spreads
+// over every core and the desktop stutters, so the container is pinned to the
+// physical cores remaining after reserved_cores are left for the user. The
+// topology comes from lscpu in the Podman machine, since cpuset numbers are
+// the machine's and not Windows'. On multi-socket machines the container
+// stays on one NUMA node, as crossing nodes costs more memory bandwidth than
+// the extra cores gain.
+
+// defaultReservedCores are left for the user when reserved_cores is 0.
+const defaultReservedCores = 2
+
+type cpuInfo struct {
+	CPU  int // Logical CPU number, as used by --cpuset-cpus
+	Core int // Physical core, shared by SMT siblings
+	Node int // NUMA node
+}
+
+// cpuPinning is the CPU selection of the container.
+type cpuPinning struct {
+	CPUs  []int // Logical CPUs
+	Cores int   // Physical cores among CPUs
+	Node  int   // NUMA node of CPUs, -1 if the machine has a single node
+}
+
+// cpuArgs returns the podman run arguments for a CPU-only node.
+func cpuArgs(ctx context.Context) []string {
+	if currentAppConfig().DisableCPUPinning {
+		slog.Info("CPU pinning disabled (disable_cpu_pinning)")
+		return nil
+	}
+
+	output, err := helperOutput(helperCommand(ctx, "podman", "machine", "ssh", "lscpu -p=CPU,CORE,SOCKET,NODE"))
+	if err != nil {
+		slog.Warn("Unable to detect CPU topology, running unpinned", "error", err)
+		return nil
+	}
+
+	reserved := currentAppConfig().ReservedCores
+	if reserved == 0 {
+		reserved = defaultReservedCores
+	}
+	pinning, ok := selectCPUs(parseLscpu(output), reserved)
+	if !ok {
+		slog.Warn("Too few cores to pin the container, running unpinned", "reserved_cores", reserved)
+		return nil
+	}
+	slog.Info("Pinning container CPUs", "cpus", formatCPUList(pinning.CPUs), "cores", pinning.Cores, "node", pinning.Node, "reserved_cores", reserved)
+
+	args := []string{
+		"--cpuset-cpus=" + formatCPUList(pinning.CPUs),
+		// One thread per physical core, SMT siblings only add contention
+		"--env=OMP_NUM_THREADS=" + strconv.Itoa(pinning.Cores),
+	}
+	if pinning.Node >= 0 {
+		args = append(args, "--cpuset-mems="+strconv.Itoa(pinning.Node))
+	}
+	return args
+}
+
+// parseLscpu parses the output of lscpu -p=CPU,CORE,SOCKET,NODE. Cores are
+// renumbered to be unique across sockets.
+func parseLscpu(output []byte) []cpuInfo {
+	var cpus []cpuInfo
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, ",")
+		if len(fields) < 4 {
+			continue
+		}
+		var values [4]int
+		valid := true
+		for i, field := range fields[:4] {
+			if field == "" {
+				continue // NODE is empty on machines without NUMA
+			}
+			v, err := strconv.Atoi(field)
+			if err != nil {
+				valid = false
+				break
+			}
+			values[i] = v
+		}
+		if !valid {
+			continue
+		}
+		cpus = append(cpus, cpuInfo{
+			CPU:  values[0],
+			Core: values[2]<<16 | values[1],
+			Node: values[3],
+		})
+	}
+	return cpus
+}
+
+// selectCPUs leaves the reserved physical cores with the lowest numbers to
+// the user, as the OS favours CPU 0, and returns the rest of the largest NUMA
+// node. It fails if no core would be left for the container.
+func selectCPUs(cpus []cpuInfo, reserved int) (cpuPinning, bool) {
+	var cores []int
+	coreNode := map[int]int{}
+	nodes := map[int]bool{}
+	for _, cpu := range slices.SortedFunc(slices.Values(cpus), func(a, b cpuInfo) int { return a.CPU - b.CPU }) {
+		if _, ok := coreNode[cpu.Core]; !ok {
+			cores = append(cores, cpu.Core)
+			coreNode[cpu.Core] = cpu.Node
+		}
+		nodes[cpu.Node] = true
+	}
+	if reserved >= len(cores) {
+		return cpuPinning{}, false
+	}
+
+	// Count the available cores per node and pick the largest, the lowest
+	// node on a tie
+	available := map[int]bool{}
+	perNode := map[int]int{}
+	for _, core := range cores[reserved:] {
+		available[core] = true
+		perNode[coreNode[core]]++
+	}
+	node := -1
+	for n, count := range perNode {
+		if node == -1 || count > perNode[node] || count == perNode[node] && n < node {
+			node = n
+		}
+	}
+
+	pinning := cpuPinning{Node: node}
+	if len(nodes) == 1 {
+		pinning.Node = -1
+	}
+	for _, core := range cores {
+		if available[core] && coreNode[core] == node {
+			pinning.Cores++
+		}
+	}
+	for _, cpu := range cpus {
+		if available[cpu.Core] && cpu.Node == node {
+			pinning.CPUs = append(pinning.CPUs, cpu.CPU)
+		}
+	}
+	slices.Sort(pinning.CPUs)
+	return pinning, true
+}
+
+// formatCPUList formats sorted CPU numbers as a cpuset list, e.g. "2-7,10".
+func formatCPUList(cpus []int) string {
+	var parts []string
+	for i := 0; i < len(cpus); {
+		j := i
+		for j+1 < len(cpus) && cpus[j+1] == cpus[j]+1 {
+			j++
+		}
+		if j == i {
+			parts = append(parts, strconv.Itoa(cpus[i]))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", cpus[i], cpus[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
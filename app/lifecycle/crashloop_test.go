@@ -0,0 +1,47 @@
+//go:build windows && unit_test
+
+package lifecycle
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCrashBackoff(t *testing.T) {
+	expected := []time.Duration{10 * time.Second, 20 * time.Second, 40 * time.Second, 80 * time.Second, 160 * time.Second, 5 * time.Minute, 5 * time.Minute}
+	for i, want := range expected {
+		if got := crashBackoff(i + 1); got != want {
+			t.Errorf("attempt %d: expected %v, got %v", i+1, want, got)
+		}
+	}
+}
+
+func TestNextCrashRestart(t *testing.T) {
+	resetCrashRestarts()
+	defer resetCrashRestarts()
+
+	for want := 1; want <= 3; want++ {
+		attempt, _, ok := nextCrashRestart(time.Minute, 3)
+		if !ok || attempt != want {
+			t.Fatalf("expected attempt %d, got %d (ok %v)", want, attempt, ok)
+		}
+	}
+	if _, _, ok := nextCrashRestart(time.Minute, 3); ok {
+		t.Error("expected no restart past the limit")
+	}
+	if attempt, _, ok := nextCrashRestart(crashStableAfter, 3); !ok || attempt != 1 {
+		t.Errorf("expected a stable run to reset the attempts, got %d (ok %v)", attempt, ok)
+	}
+}
+
+func TestMaxCrashRestarts(t *testing.T) {
+	saved := appConfig
+	defer func() { appConfig = saved }()
+
+	for _, tt := range []struct{ configured, expected int }{{0, defaultCrashRestarts}, {-1, 0}, {2, 2}} {
+		appConfig.CrashRestarts = tt.configured
+		if got := maxCrashRestarts(); got != tt.expected {
+			t.Errorf("crash_restarts %d: expected %d, got %d", tt.configured, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package lifecycle
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/ReEnvision-AI/systray/app/format"
+	"github.com/ReEnvision-AI/systray/app/tray/commontray"
+)
+
+// When the container crashes the node stays in the error state while it is
+// restarted with exponential backoff, the attempt shown in the status text.
+// After crash_restarts attempts the node is left in the error state. A node
+// that ran for crashStableAfter before crashing starts counting again.
+
+const (
+	defaultCrashRestarts = 5
+	crashBackoffBase     = 10 * time.Second
+	crashBackoffMax      = 5 * time.Minute
+	crashStableAfter     = 10 * time.Minute
+)
+
+var (
+	crashMu       sync.Mutex
+	crashRestarts int // Automatic restarts since the node last ran stably
+)
+
+// maxCrashRestarts returns how often a crashed container is restarted, 0 if
+// never.
+func maxCrashRestarts() int {
+	restarts := currentAppConfig().CrashRestarts
+	switch {
+	case restarts < 0:
+		return 0
+	case restarts == 0:
+		return defaultCrashRestarts
+	}
+	return restarts
+}
+
+// crashBackoff returns the delay before restart attempt, counting from 1.
+func crashBackoff(attempt int) time.Duration {
+	delay := crashBackoffBase
+	for i := 1; i < attempt && delay < crashBackoffMax; i++ {
+		delay *= 2
+	}
+	return min(delay, crashBackoffMax)
+}
+
+// resetCrashRestarts forgets the automatic restarts, when the user starts the
+// node.
+func resetCrashRestarts() {
+	crashMu.Lock()
+	crashRestarts = 0
+	crashMu.Unlock()
+}
+
+// nextCrashRestart counts a restart attempt for a container that ran for
+// ranFor, returning the attempt and its delay. ok is false once limit
+// attempts were made.
+func nextCrashRestart(ranFor time.Duration, limit int) (attempt int, delay time.Duration, ok bool) {
+	crashMu.Lock()
+	defer crashMu.Unlock()
+	if ranFor >= crashStableAfter {
+		crashRestarts = 0
+	}
+	if crashRestarts >= limit {
+		return crashRestarts, 0, false
+	}
+	crashRestarts++
+	return crashRestarts, crashBackoff(crashRestarts), true
+}
+
+// continueCrashRestarts schedules the next attempt when an automatic restart
+// failed to start the node.
+func continueCrashRestarts() {
+	crashMu.Lock()
+	restarting := crashRestarts > 0
+	crashMu.Unlock()
+	if restarting {
+		scheduleCrashRestart(0)
+	}
+}
+
+// scheduleCrashRestart restarts the crashed node after a backoff, unless the
+// user starts or stops it meanwhile. Must be called after entering
+// StateError.
+func scheduleCrashRestart(ranFor time.Duration) {
+	limit := maxCrashRestarts()
+	if limit == 0 {
+		return
+	}
+	attempt, delay, ok := nextCrashRestart(ranFor, limit)
+	if !ok {
+		slog.Warn("Container keeps crashing, not restarting it", "restarts", attempt)
+		setStatusText(StateError, fmt.Sprintf("Error (gave up after %d restarts)", attempt))
+		notify(commontray.NotifyError, "ReEnvision AI keeps crashing",
+			fmt.Sprintf("The node was restarted %d times and crashed again. Open the logs from the tray menu for details", attempt))
+		return
+	}
+
+	slog.Info("Restarting crashed container", "attempt", attempt, "max_attempts", limit, "delay", delay)
+	nodeMetrics.Add("node.crash_restarts", 1)
+	setStatusText(StateError, fmt.Sprintf("Restarting in %s (attempt %d of %d)", format.Duration(delay), attempt, limit))
+	time.AfterFunc(delay, func() {
+		stateMu.Lock()
+		state := currentState
+		stateMu.Unlock()
+		if state != StateError {
+			return // Started or stopped by the user meanwhile
+		}
+		setStatusText(StateError, fmt.Sprintf("Restarting (attempt %d of %d)", attempt, limit))
+		handleStartRequest()
+	})
+}
@@ -0,0 +1,86 @@
+package lifecycle
+
+import (
+	"fmt"
+	"log/slog"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/ReEnvision-AI/systray/app/format"
+	"github.com/ReEnvision-AI/systray/app/procs"
+	"github.com/ReEnvision-AI/systray/app/store"
+	"github.com/ReEnvision-AI/systray/app/tray/commontray"
+	"github.com/ReEnvision-AI/systray/version"
+)
+
+// Copy diagnostics puts the app version, node state, running helper processes,
+// detected antivirus interference and the latest lines of the app log and node output on the clipboard, to paste into a support
+// request or forum post without looking for the log files.
+
+const (
+	diagnosticsAppLines  = 500 // Latest app log lines copied
+	diagnosticsNodeLines = 200 // Latest node output lines copied
+)
+
+func handleCopyDiagnostics() {
+	stateMu.Lock()
+	state := currentState
+	stateMu.Unlock()
+	nodeLines, _ := containerLog.since(0)
+
+	text := diagnosticsText(store.GetID(), state, time.Now(), helperProcs.List(), avInterferences(), GetRecentLogs(), nodeLines)
+	if err := copyToClipboard(text); err != nil {
+		slog.Warn("failed to copy diagnostics", "error", err)
+		notify(commontray.NotifyError, "Unable to copy diagnostics", err.Error())
+		return
+	}
+	notify(commontray.NotifyInfo, "Diagnostics copied", "Paste them into your support request")
+}
+
+// diagnosticsText returns the diagnostics of the node copied at now, ending with the
+// latest of the app log and node output lines.
+func diagnosticsText(nodeID string, state AppState, now time.Time, helpers []procs.Process, antivirus []avInterference, appLines []string, nodeLines []logLine) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "ReEnvision AI diagnostics, %s\r\n", now.Format(time.RFC3339))
+	fmt.Fprintf(&b, "Version: %s\r\n", version.String())
+	fmt.Fprintf(&b, "Architecture: %s\r\n", runtime.GOARCH)
+	fmt.Fprintf(&b, "Node ID: %s\r\n", nodeID)
+	fmt.Fprintf(&b, "State: %s\r\n", state)
+
+	b.WriteString("\r\nHelper processes:\r\n")
+	b.WriteString(processesText(helpers, now))
+
+	b.WriteString("\r\nAntivirus interference:\r\n")
+	b.WriteString(antivirusText(antivirus))
+
+	b.WriteString("\r\nApp log:\r\n")
+	appLines = appLines[max(len(appLines)-diagnosticsAppLines, 0):]
+	for _, line := range appLines {
+		b.WriteString(line)
+		b.WriteString("\r\n")
+	}
+
+	b.WriteString("\r\nNode output:\r\n")
+	nodeLines = nodeLines[max(len(nodeLines)-diagnosticsNodeLines, 0):]
+	text, _ := renderLogLines(nodeLines, logFilter{})
+	b.WriteString(text)
+	return b.String()
+}
+
+// processesText lists helper processes one per line, like
+// "4242  podman machine start  (running for 12 s)".
+func processesText(helpers []procs.Process, now time.Time) string {
+	if len(helpers) == 0 {
+		return "None\r\n"
+	}
+	var b strings.Builder
+	for _, p := range helpers {
+		status := "running for " + format.Duration(now.Sub(p.Started))
+		if !p.Running {
+			status = "exited"
+		}
+		fmt.Fprintf(&b, "%d  %s  (%s)\r\n", p.PID, p.Command, status)
+	}
+	return b.String()
+}
@@ -0,0 +1,432 @@
+package lifecycle
+
+import (
+	"fmt"
+	"log/slog"
+	"runtime"
+	"strings"
+	"unicode/utf16"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// messageBox shows a modal message box with the given MB_* flags and returns
+// the ID of the button the user clicked, or 0 on failure.
+func messageBox(title, message string, flags uint32) int32 {
+	titlePtr, err := windows.UTF16PtrFromString(title)
+	if err != nil {
+		return 0
+	}
+	messagePtr, err := windows.UTF16PtrFromString(message)
+	if err != nil {
+		return 0
+	}
+	ret, err := windows.MessageBox(0, messagePtr, titlePtr, flags|windows.MB_SETFOREGROUND|windows.MB_TOPMOST)
+	if ret == 0 {
+		slog.Warn("failed to show dialog", "title", title, "error", err)
+	}
+	return ret
+}
+
+// confirm shows a modal Yes/No question and reports whether the user chose Yes.
+func confirm(title, message string) bool {
+	return messageBox(title, message, windows.MB_YESNO|windows.MB_ICONWARNING) == IDYES
+}
+
+// openURL opens the given URL or file with its default handler, e.g. the
+// user's browser.
+func openURL(url string) error {
+	verb, _ := windows.UTF16PtrFromString("open")
+	target, err := windows.UTF16PtrFromString(url)
+	if err != nil {
+		return err
+	}
+	if err := windows.ShellExecute(0, verb, target, nil, nil, windows.SW_SHOWNORMAL); err != nil {
+		return fmt.Errorf("unable to open %s: %w", url, err)
+	}
+	return nil
+}
+
+// Message box button IDs
+const (
+	IDOK     = 1
+	IDCANCEL = 2
+	IDYES    = 6
+	IDNO     = 7
+)
+
+var (
+	credui                     = windows.NewLazySystemDLL("credui.dll")
+	pCredUIPromptForCredential = credui.NewProc("CredUIPromptForCredentialsW")
+
+	user32            = windows.NewLazySystemDLL("user32.dll")
+	pOpenClipboard    = user32.NewProc("OpenClipboard")
+	pEmptyClipboard   = user32.NewProc("EmptyClipboard")
+	pSetClipboardData = user32.NewProc("SetClipboardData")
+	pCloseClipboard   = user32.NewProc("CloseClipboard")
+	kernel32          = windows.NewLazySystemDLL("kernel32.dll")
+	pGlobalAlloc      = kernel32.NewProc("GlobalAlloc")
+	pGlobalLock       = kernel32.NewProc("GlobalLock")
+	pGlobalUnlock     = kernel32.NewProc("GlobalUnlock")
+	pGlobalFree       = kernel32.NewProc("GlobalFree")
+	pRtlMoveMemory    = kernel32.NewProc("RtlMoveMemory")
+
+	comdlg32         = windows.NewLazySystemDLL("comdlg32.dll")
+	pGetOpenFileName = comdlg32.NewProc("GetOpenFileNameW")
+	pGetSaveFileName = comdlg32.NewProc("GetSaveFileNameW")
+)
+
+// promptForCredentials shows the sign in dialog with email prefilled and
+// errText shown below the fields, and returns the entered email and password
+// and how the dialog was closed.
+func promptForCredentials(caption, message, email, errText string) (string, string, loginAction) {
+	return showLoginDialog(caption, message, email, errText)
+}
+
+// promptForSecret shows the Windows credential dialog with a fixed, read-only
+// user name and returns the masked value entered as the password.
+func promptForSecret(caption, message, name string) (secret string, ok bool) {
+	const CREDUI_FLAGS_KEEP_USERNAME = 0x100000
+	_, secret, ok = credUIPrompt(caption, message, name, CREDUI_FLAGS_KEEP_USERNAME)
+	return secret, ok
+}
+
+func credUIPrompt(caption, message, user string, flags uint32) (string, string, bool) {
+	const (
+		CREDUI_MAX_USERNAME_LENGTH       = 513
+		CREDUI_MAX_PASSWORD_LENGTH       = 256
+		CREDUI_FLAGS_DO_NOT_PERSIST      = 0x00002
+		CREDUI_FLAGS_ALWAYS_SHOW_UI      = 0x00080
+		CREDUI_FLAGS_GENERIC_CREDENTIALS = 0x40000
+	)
+	info := struct {
+		Size    uint32
+		Parent  windows.HWND
+		Message *uint16
+		Caption *uint16
+		Banner  windows.Handle
+	}{}
+	info.Size = uint32(unsafe.Sizeof(info))
+	info.Message, _ = windows.UTF16PtrFromString(message)
+	info.Caption, _ = windows.UTF16PtrFromString(caption)
+	target, _ := windows.UTF16PtrFromString(caption)
+
+	userBuf := make([]uint16, CREDUI_MAX_USERNAME_LENGTH+1)
+	copy(userBuf[:CREDUI_MAX_USERNAME_LENGTH], windows.StringToUTF16(user))
+	passBuf := make([]uint16, CREDUI_MAX_PASSWORD_LENGTH+1)
+	defer clear(passBuf)
+	var save int32
+
+	ret, _, _ := pCredUIPromptForCredential.Call(
+		uintptr(unsafe.Pointer(&info)),
+		uintptr(unsafe.Pointer(target)),
+		0,
+		0,
+		uintptr(unsafe.Pointer(&userBuf[0])),
+		uintptr(len(userBuf)),
+		uintptr(unsafe.Pointer(&passBuf[0])),
+		uintptr(len(passBuf)),
+		uintptr(unsafe.Pointer(&save)),
+		uintptr(CREDUI_FLAGS_GENERIC_CREDENTIALS|CREDUI_FLAGS_ALWAYS_SHOW_UI|CREDUI_FLAGS_DO_NOT_PERSIST|flags),
+	)
+	switch windows.Errno(ret) {
+	case windows.ERROR_SUCCESS:
+		return windows.UTF16ToString(userBuf), windows.UTF16ToString(passBuf), true
+	case windows.ERROR_CANCELLED:
+		return "", "", false
+	default:
+		slog.Warn("failed to show credential dialog", "error", windows.Errno(ret))
+		return "", "", false
+	}
+}
+
+// copyToClipboard replaces the clipboard contents with text.
+func copyToClipboard(text string) error {
+	const (
+		CF_UNICODETEXT = 13
+		GMEM_MOVEABLE  = 0x0002
+	)
+	data, err := windows.UTF16FromString(text)
+	if err != nil {
+		return err
+	}
+
+	if ret, _, err := pOpenClipboard.Call(0); ret == 0 {
+		return fmt.Errorf("failed to open clipboard: %w", err)
+	}
+	defer pCloseClipboard.Call() //nolint:errcheck
+	if ret, _, err := pEmptyClipboard.Call(); ret == 0 {
+		return fmt.Errorf("failed to empty clipboard: %w", err)
+	}
+
+	size := uintptr(len(data)) * unsafe.Sizeof(data[0])
+	hMem, _, err := pGlobalAlloc.Call(GMEM_MOVEABLE, size)
+	if hMem == 0 {
+		return fmt.Errorf("failed to allocate clipboard memory: %w", err)
+	}
+	ptr, _, err := pGlobalLock.Call(hMem)
+	if ptr == 0 {
+		pGlobalFree.Call(hMem) //nolint:errcheck
+		return fmt.Errorf("failed to lock clipboard memory: %w", err)
+	}
+	pRtlMoveMemory.Call(ptr, uintptr(unsafe.Pointer(&data[0])), size) //nolint:errcheck
+	pGlobalUnlock.Call(hMem)                                          //nolint:errcheck
+
+	if ret, _, err := pSetClipboardData.Call(CF_UNICODETEXT, hMem); ret == 0 {
+		pGlobalFree.Call(hMem) //nolint:errcheck
+		return fmt.Errorf("failed to set clipboard data: %w", err)
+	}
+	// The clipboard owns the memory now
+	return nil
+}
+
+// fileFilter is an entry of the file type list of a file dialog.
+type fileFilter struct {
+	Description string
+	Pattern     string // e.g. "*.json"
+}
+
+// openFileDialog shows the Windows file picker for an existing file matching
+// pattern, e.g. "*.json". ok is false if the user cancelled.
+func openFileDialog(title, description, pattern string) (path string, ok bool) {
+	const (
+		OFN_PATHMUSTEXIST = 0x00000800
+		OFN_FILEMUSTEXIST = 0x00001000
+	)
+	path, _, ok = fileDialog(pGetOpenFileName, title, "", []fileFilter{{description, pattern}}, OFN_FILEMUSTEXIST|OFN_PATHMUSTEXIST)
+	return path, ok
+}
+
+// saveFileDialog shows the Windows save dialog starting with name, and
+// returns the chosen path and the index of the chosen filter. The extension
+// of the filter is added if the user typed none. ok is false if the user
+// cancelled.
+func saveFileDialog(title, name string, filters []fileFilter) (path string, filter int, ok bool) {
+	const (
+		OFN_OVERWRITEPROMPT = 0x00000002
+		OFN_PATHMUSTEXIST   = 0x00000800
+	)
+	return fileDialog(pGetSaveFileName, title, name, filters, OFN_OVERWRITEPROMPT|OFN_PATHMUSTEXIST)
+}
+
+func fileDialog(proc *windows.LazyProc, title, name string, filters []fileFilter, flags uint32) (string, int, bool) {
+	const (
+		OFN_NOCHANGEDIR     = 0x00000008
+		OFN_EXPLORER        = 0x00080000
+		maxDialogPathLength = 1024
+	)
+	// OPENFILENAMEW
+	ofn := struct {
+		StructSize    uint32
+		Owner         windows.Handle
+		Instance      windows.Handle
+		Filter        *uint16
+		CustomFilter  *uint16
+		MaxCustFilter uint32
+		FilterIndex   uint32
+		File          *uint16
+		MaxFile       uint32
+		FileTitle     *uint16
+		MaxFileTitle  uint32
+		InitialDir    *uint16
+		Title         *uint16
+		Flags         uint32
+		FileOffset    uint16
+		FileExtension uint16
+		DefExt        *uint16
+		CustData      uintptr
+		Hook          uintptr
+		TemplateName  *uint16
+		Reserved      uintptr
+		Reserved2     uint32
+		FlagsEx       uint32
+	}{}
+	// The filter is a list of NUL separated description and pattern pairs
+	var filterText string
+	for _, f := range filters {
+		filterText += f.Description + " (" + f.Pattern + ")\x00" + f.Pattern + "\x00"
+	}
+	filter := utf16.Encode([]rune(filterText + "\x00"))
+	file := make([]uint16, maxDialogPathLength)
+	copy(file[:maxDialogPathLength-1], utf16.Encode([]rune(name)))
+	titlePtr, err := windows.UTF16PtrFromString(title)
+	if err != nil {
+		return "", 0, false
+	}
+	// Without an extension typed, the one of the chosen filter is added
+	defExt, err := windows.UTF16PtrFromString(strings.TrimPrefix(filters[0].Pattern, "*."))
+	if err != nil {
+		return "", 0, false
+	}
+
+	ofn.StructSize = uint32(unsafe.Sizeof(ofn))
+	ofn.Filter = &filter[0]
+	ofn.FilterIndex = 1
+	ofn.File = &file[0]
+	ofn.MaxFile = uint32(len(file))
+	ofn.Title = titlePtr
+	ofn.DefExt = defExt
+	ofn.Flags = OFN_EXPLORER | OFN_NOCHANGEDIR | flags
+
+	if ret, _, _ := proc.Call(uintptr(unsafe.Pointer(&ofn))); ret == 0 {
+		// Cancelled, or failed, which CommDlgExtendedError would tell apart
+		return "", 0, false
+	}
+	return windows.UTF16ToString(file), int(ofn.FilterIndex) - 1, true
+}
+
+// Styles of dialog templates and their controls
+const (
+	WS_POPUP         = 0x80000000
+	WS_CHILD         = 0x40000000
+	WS_VISIBLE       = 0x10000000
+	WS_CAPTION       = 0x00C00000
+	WS_SYSMENU       = 0x00080000
+	WS_TABSTOP       = 0x00010000
+	WS_EX_TOPMOST    = 0x00000008
+	WS_EX_CLIENTEDGE = 0x00000200
+	DS_SETFONT       = 0x40
+	DS_MODALFRAME    = 0x80
+	DS_SETFOREGROUND = 0x200
+	DS_CENTER        = 0x800
+	ES_PASSWORD      = 0x20
+	ES_AUTOHSCROLL   = 0x80
+	ES_NUMBER        = 0x2000
+	BS_DEFPUSHBUTTON = 0x1
+	BS_AUTOCHECKBOX  = 0x3
+	SS_NOPREFIX      = 0x80
+
+	dialogChild   = WS_CHILD | WS_VISIBLE
+	dialogLabelID = 0xFFFF // Static labels aren't addressed
+)
+
+// Dialog messages, and results of dialog procedures
+const (
+	WM_INITDIALOG     = 0x0110
+	WM_COMMAND        = 0x0111
+	WM_CTLCOLORSTATIC = 0x0138
+	BN_CLICKED        = 0
+
+	dialogDefault  = 0 // Not handled, the dialog manager's default applies
+	dialogHandled  = 1
+	dialogFocusSet = 0 // WM_INITDIALOG result when the procedure set the focus itself
+)
+
+var (
+	gdi32               = windows.NewLazySystemDLL("gdi32.dll")
+	pSetTextColor       = gdi32.NewProc("SetTextColor")
+	pSetBkMode          = gdi32.NewProc("SetBkMode")
+	pDialogBoxIndirect  = user32.NewProc("DialogBoxIndirectParamW")
+	pEndDialog          = user32.NewProc("EndDialog")
+	pGetDlgItem         = user32.NewProc("GetDlgItem")
+	pGetDlgItemText     = user32.NewProc("GetDlgItemTextW")
+	pSetDlgItemText     = user32.NewProc("SetDlgItemTextW")
+	pIsDlgButtonChecked = user32.NewProc("IsDlgButtonChecked")
+	pCheckDlgButton     = user32.NewProc("CheckDlgButton")
+	pSendMessage        = user32.NewProc("SendMessageW")
+	pSetFocus           = user32.NewProc("SetFocus")
+	pInvalidateRect     = user32.NewProc("InvalidateRect")
+	pGetSysColorBrush   = user32.NewProc("GetSysColorBrush")
+)
+
+// dialogItem is a control of a dialog template.
+type dialogItem struct {
+	class        uint16 // Atom of a system class
+	style        uint32
+	exStyle      uint32
+	x, y, cx, cy int16 // In dialog units
+	id           uint16
+	title        string
+}
+
+// Atoms of the system control classes
+const (
+	dialogButton   = 0x0080
+	dialogEdit     = 0x0081
+	dialogStatic   = 0x0082
+	dialogComboBox = 0x0085
+)
+
+// buildDialogTemplate encodes a DLGTEMPLATE of a centered, topmost modal
+// dialog with its items. Items start on DWORD boundaries, so the returned
+// slice must be DWORD aligned, which Go allocations of its size are.
+func buildDialogTemplate(title string, cx, cy int16, items []dialogItem) []uint16 {
+	var t []uint16
+	dword := func(v uint32) { t = append(t, uint16(v), uint16(v>>16)) }
+	str := func(s string) { t = append(t, windows.StringToUTF16(s)...) }
+
+	dword(WS_POPUP | WS_CAPTION | WS_SYSMENU | DS_MODALFRAME | DS_SETFOREGROUND | DS_CENTER | DS_SETFONT)
+	dword(WS_EX_TOPMOST)
+	t = append(t, uint16(len(items)), 0, 0, uint16(cx), uint16(cy))
+	t = append(t, 0, 0) // No menu, default class
+	str(title)
+	t = append(t, 9) // Point size
+	str("Segoe UI")
+
+	for _, item := range items {
+		if len(t)%2 != 0 {
+			t = append(t, 0)
+		}
+		dword(item.style)
+		dword(item.exStyle)
+		t = append(t, uint16(item.x), uint16(item.y), uint16(item.cx), uint16(item.cy), item.id)
+		t = append(t, 0xFFFF, item.class)
+		str(item.title)
+		t = append(t, 0) // No creation data
+	}
+	return t
+}
+
+// runDialog shows the modal dialog of template, handled by the dialog
+// procedure callback, until the procedure ends it.
+func runDialog(template []uint16, callback uintptr) error {
+	// The dialog runs its own message loop and calls back on this thread
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	var instance windows.Handle
+	if err := windows.GetModuleHandleEx(0, nil, &instance); err != nil {
+		return err
+	}
+	ret, _, err := pDialogBoxIndirect.Call(uintptr(instance), uintptr(unsafe.Pointer(&template[0])), 0, callback, 0)
+	runtime.KeepAlive(template)
+	if int32(ret) == -1 {
+		return err
+	}
+	return nil
+}
+
+func dlgItem(hwnd uintptr, id uint16) uintptr {
+	h, _, _ := pGetDlgItem.Call(hwnd, uintptr(id))
+	return h
+}
+
+func setDlgItemText(hwnd uintptr, id uint16, text string) {
+	textPtr, err := windows.UTF16PtrFromString(text)
+	if err != nil {
+		return
+	}
+	pSetDlgItemText.Call(hwnd, uintptr(id), uintptr(unsafe.Pointer(textPtr))) //nolint:errcheck
+}
+
+func getDlgItemText(hwnd uintptr, id uint16, maxLength int) string {
+	buf := make([]uint16, maxLength+1)
+	defer clear(buf)
+	pGetDlgItemText.Call(hwnd, uintptr(id), uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf))) //nolint:errcheck
+	return windows.UTF16ToString(buf)
+}
+
+// paintErrorText colors a static control red for WM_CTLCOLORSTATIC and
+// returns the background brush.
+func paintErrorText(hdc uintptr) uintptr {
+	const (
+		TRANSPARENT   = 1
+		COLOR_BTNFACE = 15
+		errorColor    = 0x0000C0 // Dark red, as COLORREF 0x00BBGGRR
+	)
+	pSetTextColor.Call(hdc, errorColor) //nolint:errcheck
+	pSetBkMode.Call(hdc, TRANSPARENT)   //nolint:errcheck
+	brush, _, _ := pGetSysColorBrush.Call(COLOR_BTNFACE)
+	return brush
+}
@@ -0,0 +1,79 @@
+//go:build windows && unit_test
+
+package lifecycle
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+// fullDisk is a file on a disk that fills up when full is set.
+type fullDisk struct {
+	bytes.Buffer
+	full bool
+}
+
+func (d *fullDisk) Write(p []byte) (int, error) {
+	if d.full {
+		return 0, &os.PathError{Op: "write", Path: "app.log", Err: windows.ERROR_DISK_FULL}
+	}
+	return d.Buffer.Write(p)
+}
+
+func TestIsDiskFull(t *testing.T) {
+	if !isDiskFull(&os.PathError{Op: "write", Path: "store", Err: windows.ERROR_HANDLE_DISK_FULL}) {
+		t.Error("expected a write to a full disk to be recognised")
+	}
+	if isDiskFull(errors.New("access denied")) || isDiskFull(nil) {
+		t.Error("expected other errors not to be a full disk")
+	}
+}
+
+func TestLogWriterDiskFull(t *testing.T) {
+	disk := &fullDisk{}
+	full := make(chan error, 1)
+	w := &logWriter{file: disk, onFull: func(err error) { full <- err }}
+
+	w.Write([]byte("before\n"))
+	disk.full = true
+	if _, err := w.Write([]byte("first buffered\n")); err != nil {
+		t.Fatalf("expected the write to be buffered, got %v", err)
+	}
+	select {
+	case err := <-full:
+		if !isDiskFull(err) {
+			t.Errorf("expected a disk full error, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the full disk to be reported")
+	}
+
+	line := strings.Repeat("x", 1023) + "\n"
+	for range memoryLogSize/len(line) + 10 {
+		w.Write([]byte(line))
+	}
+	w.Write([]byte("last buffered\n"))
+	if len(w.memory) > memoryLogSize {
+		t.Errorf("expected at most %d bytes buffered, got %d", memoryLogSize, len(w.memory))
+	}
+
+	if err := w.flush(); err == nil {
+		t.Error("expected the flush to fail while the disk is full")
+	}
+	disk.full = false
+	if err := w.flush(); err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("after\n"))
+	log := disk.String()
+	if !strings.HasPrefix(log, "before\n") || strings.Contains(log, "first buffered") ||
+		!strings.Contains(log, "last buffered\nafter\n") || !strings.HasPrefix(strings.TrimPrefix(log, "before\n"), "x") {
+		t.Errorf("expected whole buffered lines to be written out in order, got %q...", log[:min(len(log), 40)])
+	}
+}
@@ -0,0 +1,193 @@
+package lifecycle
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ReEnvision-AI/systray/app/store"
+	"github.com/ReEnvision-AI/systray/app/tray/commontray"
+	"golang.org/x/sys/windows"
+)
+
+// When the disk of the app data fills up, writes fail half way and would
+// leave a truncated store or log. Instead logging moves to a small buffer in
+// memory, the store keeps its last complete copy, and the node is stopped
+// cleanly, as its cache and the Podman machine need the disk too. The status
+// shows the disk is full until enough space is free again, when the buffered
+// log is written out and a node that was paused resumes.
+
+const (
+	diskSpaceCheckInterval = time.Minute
+	diskFullNotifyInterval = time.Hour // How often the notification is repeated while the disk is full
+	diskFreeToResume       = 256 << 20 // Bytes free before the app writes to the disk again
+	memoryLogSize          = 256 << 10 // Bytes of log kept in memory while the disk is full
+	diskFullText           = "Disk full, node paused"
+)
+
+var errDiskFull = errors.New("the disk is full")
+
+var (
+	diskFull atomic.Bool
+
+	diskFullMu       sync.Mutex
+	pausedByDiskFull bool // Guarded by diskFullMu
+)
+
+// appLog is the output of the log.
+var appLog *logWriter
+
+// isDiskFull reports whether err is from writing to a full disk.
+func isDiskFull(err error) bool {
+	return errors.Is(err, windows.ERROR_DISK_FULL) || errors.Is(err, windows.ERROR_HANDLE_DISK_FULL)
+}
+
+// logWriter writes the log file, or a buffer in memory while the disk is
+// full.
+type logWriter struct {
+	mu     sync.Mutex
+	file   io.Writer
+	memory []byte          // The latest log lines while the disk is full, nil otherwise
+	onFull func(err error) // Called when a write finds the disk full
+	err    error           // The write error while the disk is full
+}
+
+// setOnFull sets the callback of a write finding the disk full, calling it
+// at once if one already did.
+func (w *logWriter) setOnFull(onFull func(err error)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.onFull = onFull
+	if w.memory != nil {
+		go onFull(w.err)
+	}
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.memory == nil {
+		n, err := w.file.Write(p)
+		if err == nil || !isDiskFull(err) {
+			return n, err
+		}
+		w.memory = make([]byte, 0, memoryLogSize)
+		w.err = err
+		if w.onFull != nil {
+			go w.onFull(err)
+		}
+	}
+	w.memory = append(w.memory, p...)
+	if over := len(w.memory) - memoryLogSize; over > 0 {
+		w.memory = w.memory[over:]
+		if i := bytes.IndexByte(w.memory, '\n'); i >= 0 {
+			w.memory = w.memory[i+1:] // Drop the partial line
+		}
+	}
+	return len(p), nil
+}
+
+// flush writes the buffered log to the file and logs to the file again.
+func (w *logWriter) flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.memory == nil {
+		return nil
+	}
+	if _, err := w.file.Write(w.memory); err != nil {
+		return err
+	}
+	w.memory, w.err = nil, nil
+	return nil
+}
+
+// watchDiskFull handles writes of the log and the store finding the disk
+// full, once the tray can show it.
+func watchDiskFull() {
+	store.OnWriteError = func(err error) {
+		if isDiskFull(err) {
+			go handleDiskFull(err)
+		}
+	}
+	if appLog != nil {
+		appLog.setOnFull(handleDiskFull)
+	}
+}
+
+// handleDiskFull pauses the node after a write found the disk full, and
+// resumes once enough space is free.
+func handleDiskFull(err error) {
+	if !diskFull.CompareAndSwap(false, true) {
+		return
+	}
+	slog.Error("Disk is full, pausing the node", "error", err)
+	stateMu.Lock()
+	running := currentState == StateRunning || currentState == StateLoading || currentState == StateStarting || currentState == StatePaused
+	stateMu.Unlock()
+	if running {
+		diskFullMu.Lock()
+		pausedByDiskFull = true
+		diskFullMu.Unlock()
+		handleStopRequest()
+	}
+	showDiskFull()
+	go awaitDiskSpace()
+}
+
+// showDiskFull shows the disk is full in the status and a notification.
+func showDiskFull() {
+	stateMu.Lock()
+	state := currentState
+	stateMu.Unlock()
+	setStatusText(state, diskFullText)
+	notify(commontray.NotifyError, diskFullText, "Free up space on the disk of "+AppDataDir+". The node resumes once there is room again")
+}
+
+// awaitDiskSpace waits until enough space is free, then writes the buffered
+// log and resumes the node if it was paused.
+func awaitDiskSpace() {
+	notified := time.Now()
+	for {
+		time.Sleep(diskSpaceCheckInterval)
+		free, err := diskFreeBytes(AppDataDir)
+		if err == nil && free >= diskFreeToResume && (appLog == nil || appLog.flush() == nil) {
+			break
+		}
+		if time.Since(notified) >= diskFullNotifyInterval {
+			notified = time.Now()
+			showDiskFull()
+		}
+	}
+	diskFull.Store(false)
+	slog.Info("Disk space is available again")
+
+	diskFullMu.Lock()
+	resume := pausedByDiskFull
+	pausedByDiskFull = false
+	diskFullMu.Unlock()
+	stateMu.Lock()
+	state := currentState
+	stateMu.Unlock()
+	setStatusText(state, "")
+	if resume && state == StateStopped {
+		slog.Info("Resuming node after the disk was full")
+		handleStartRequest()
+	}
+}
+
+// diskFreeBytes returns the space available on the disk of dir.
+func diskFreeBytes(dir string) (uint64, error) {
+	path, err := windows.UTF16PtrFromString(dir)
+	if err != nil {
+		return 0, err
+	}
+	var free uint64
+	if err := windows.GetDiskFreeSpaceEx(path, &free, nil, nil); err != nil {
+		return 0, err
+	}
+	return free, nil
+}
@@ -0,0 +1,56 @@
+//go:build windows && unit_test
+
+package lifecycle
+
+import (
+	"net"
+	"testing"
+)
+
+func TestFingerprintFrom(t *testing.T) {
+	mac := func(s string) net.HardwareAddr {
+		addr, err := net.ParseMAC(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return addr
+	}
+	adapter := func(s string, up, defaultRoute bool) fingerprintAdapter {
+		return fingerprintAdapter{MAC: mac(s), Up: up, DefaultRoute: defaultRoute}
+	}
+	const node = "5d7c1f0e-8a4b-4c2d-9e6f-1a2b3c4d5e6f"
+	physical := adapter("00:1a:2b:3c:4d:5e", true, true)
+	other := adapter("00:1a:2b:3c:4d:5f", true, false)
+	virtual := adapter("02:00:4c:4f:4f:50", true, false) // Locally administered
+
+	fingerprint := fingerprintFrom(node, []fingerprintAdapter{other, virtual, physical}, 0x1234abcd)
+	if len(fingerprint) != 64 {
+		t.Fatalf("expected a hex SHA-256, got %q", fingerprint)
+	}
+	if got := fingerprintFrom(node, []fingerprintAdapter{physical, other}, 0x1234abcd); got != fingerprint {
+		t.Error("expected the same fingerprint regardless of adapter order and virtual adapters")
+	}
+	if got := fingerprintFrom(node, []fingerprintAdapter{physical, other, adapter("02:11:22:33:44:55", true, false)}, 0x1234abcd); got != fingerprint {
+		t.Error("expected a new virtual adapter to keep the fingerprint")
+	}
+	dock := adapter("00:00:00:00:00:01", true, false)
+	if got := fingerprintFrom(node, []fingerprintAdapter{physical, other, dock}, 0x1234abcd); got != fingerprint {
+		t.Error("expected an adapter with a lower address to keep the default route's adapter")
+	}
+	down := adapter("00:00:00:00:00:02", false, false)
+	if got := fingerprintFrom(node, []fingerprintAdapter{other, down}, 0x1234abcd); got != fingerprintFrom(node, []fingerprintAdapter{other}, 0x1234abcd) {
+		t.Error("expected an adapter that is up to be preferred over a lower one that is down")
+	}
+	if got := fingerprintFrom(node, []fingerprintAdapter{other}, 0x1234abcd); got == fingerprint {
+		t.Error("expected a copy with another MAC address to have another fingerprint")
+	}
+	if got := fingerprintFrom(node, []fingerprintAdapter{physical}, 0x1234abce); got == fingerprint {
+		t.Error("expected another volume to give another fingerprint")
+	}
+	if got := fingerprintFrom("8e3a6b1c-2d4f-4a5b-8c7d-9e0f1a2b3c4d", []fingerprintAdapter{physical}, 0x1234abcd); got == fingerprint {
+		t.Error("expected another node ID to give another fingerprint for the same machine")
+	}
+	if got := fingerprintFrom(node, []fingerprintAdapter{virtual, {}}, 0); got != "" {
+		t.Errorf("expected no fingerprint without a MAC address or volume serial, got %q", got)
+	}
+}
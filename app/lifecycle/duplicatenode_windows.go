@@ -0,0 +1,169 @@
+package lifecycle
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ReEnvision-AI/systray/app/store"
+	"github.com/ReEnvision-AI/systray/app/tray/commontray"
+	"github.com/google/uuid"
+	"golang.org/x/sys/windows"
+)
+
+// Heartbeats carry a fingerprint of the machine's hardware, so the backend
+// can tell when copies of a VM or disk image report the same node ID. The
+// fingerprint is an HMAC of a network adapter's MAC address and the serial
+// of the system volume, keyed with the node ID, so neither can be read back
+// from it and fingerprints of different nodes can't be matched up. Copies
+// share the Windows machine GUID used for node identities, but usually get
+// their own MAC address. When the backend finds a duplicate, the user is
+// warned once per run and offered a new node ID for this machine.
+//
+// The fingerprint is derived from the hardware, so like the other heartbeat
+// extras it is only sent with telemetry enabled. In minimal mode duplicates
+// go unnoticed.
+
+const duplicateNodeClickTime = 30 * time.Minute
+
+var duplicateWarned atomic.Bool
+
+// defaultRouteProbe is the address whose route picks the default adapter. No
+// packet is sent to it.
+var defaultRouteProbe = [4]byte{8, 8, 8, 8}
+
+// fingerprintAdapter is a network adapter the fingerprint may use.
+type fingerprintAdapter struct {
+	MAC          net.HardwareAddr
+	Up           bool
+	DefaultRoute bool // Carries the default IPv4 route
+}
+
+// machineHardware returns the adapters and the volume serial the
+// fingerprint is made of, read once per run.
+var machineHardware = sync.OnceValues(func() ([]fingerprintAdapter, uint32) {
+	var defaultIndex uint32
+	if err := windows.GetBestInterfaceEx(&windows.SockaddrInet4{Addr: defaultRouteProbe}, &defaultIndex); err != nil {
+		slog.Debug("unable to find the default route's adapter for the machine fingerprint", "error", err)
+	}
+	var adapters []fingerprintAdapter
+	if interfaces, err := net.Interfaces(); err != nil {
+		slog.Debug("unable to list network adapters for the machine fingerprint", "error", err)
+	} else {
+		for _, iface := range interfaces {
+			if iface.Flags&net.FlagLoopback == 0 {
+				adapters = append(adapters, fingerprintAdapter{
+					MAC:          iface.HardwareAddr,
+					Up:           iface.Flags&net.FlagUp != 0,
+					DefaultRoute: defaultIndex != 0 && uint32(iface.Index) == defaultIndex,
+				})
+			}
+		}
+	}
+	serial, err := systemVolumeSerial()
+	if err != nil {
+		slog.Debug("unable to read the volume serial for the machine fingerprint", "error", err)
+	}
+	return adapters, serial
+})
+
+// hardwareFingerprint returns the fingerprint of this machine for the node
+// ID, empty if neither a MAC address nor the volume serial can be read.
+func hardwareFingerprint(nodeID string) string {
+	adapters, serial := machineHardware()
+	return fingerprintFrom(nodeID, adapters, serial)
+}
+
+// fingerprintFrom hashes the MAC address of the most stable adapter with the
+// volume serial, keyed with the node ID. The adapter that is up and carries
+// the default route is preferred, then any adapter that is up, and the
+// lowest address breaks ties, so a USB or dock adapter appearing doesn't
+// change the fingerprint. Locally administered addresses are left out, as
+// virtual adapters and MAC randomization make them up.
+func fingerprintFrom(nodeID string, adapters []fingerprintAdapter, volumeSerial uint32) string {
+	rank := func(a fingerprintAdapter) int {
+		switch {
+		case a.Up && a.DefaultRoute:
+			return 2
+		case a.Up:
+			return 1
+		}
+		return 0
+	}
+	var best *fingerprintAdapter
+	for i, candidate := range adapters {
+		mac := candidate.MAC
+		if len(mac) != 6 || mac[0]&0x02 != 0 || bytes.Equal(mac, make(net.HardwareAddr, 6)) {
+			continue
+		}
+		if best == nil || rank(candidate) > rank(*best) ||
+			rank(candidate) == rank(*best) && bytes.Compare(mac, best.MAC) < 0 {
+			best = &adapters[i]
+		}
+	}
+	var mac net.HardwareAddr
+	if best != nil {
+		mac = best.MAC
+	}
+	if mac == nil && volumeSerial == 0 {
+		return ""
+	}
+	h := hmac.New(sha256.New, []byte(nodeID))
+	fmt.Fprintf(h, "reai-machine:%s:%08x", mac, volumeSerial)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// systemVolumeSerial returns the serial number of the Windows volume.
+func systemVolumeSerial() (uint32, error) {
+	drive := os.Getenv("SystemDrive")
+	if drive == "" {
+		drive = "C:"
+	}
+	root, err := windows.UTF16PtrFromString(drive + `\`)
+	if err != nil {
+		return 0, err
+	}
+	var serial uint32
+	if err := windows.GetVolumeInformation(root, nil, 0, &serial, nil, nil, nil, 0); err != nil {
+		return 0, err
+	}
+	return serial, nil
+}
+
+// handleDuplicateNode warns that another machine runs with this node ID,
+// once per run, and gives this machine a new node ID if the warning is
+// clicked.
+func handleDuplicateNode() {
+	if !duplicateWarned.CompareAndSwap(false, true) {
+		return
+	}
+	slog.Warn("Another machine reports the same node ID", "id", store.GetID())
+	click := make(chan struct{}, 1)
+	if !notifyAction(commontray.NotifyWarning, "This node ID is also used by another machine",
+		"This machine is likely a copy of another one, like a cloned VM. Click here to give this machine its own node ID", click) {
+		return
+	}
+	select {
+	case <-click:
+	case <-time.After(duplicateNodeClickTime):
+		return
+	}
+	if !confirm("Give this machine a new node ID",
+		"This machine will contribute as a new node, and the contribution history stays with the other machine. "+
+			"Only do this on the copy. Continue?") {
+		return
+	}
+	id := uuid.NewString()
+	store.ImportNode(id, store.GetSettings())
+	slog.Info("Node ID replaced after duplicate was detected", "id", id)
+	restartRunningNode() // The container name includes the node ID
+	notify(commontray.NotifyInfo, "New node ID", "This machine now contributes as its own node")
+}
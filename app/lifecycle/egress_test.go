@@ -0,0 +1,42 @@
+//go:build windows && unit_test
+
+package lifecycle
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestInitialPeerHosts(t *testing.T) {
+	peers := "/dns4/sociallyshaped.net/tcp/8788/p2p/QmTUpY86, /ip4/203.0.113.7/tcp/31337/p2p/QmX /p2p-circuit"
+	expected := []string{"sociallyshaped.net", "203.0.113.7"}
+	if got := initialPeerHosts(peers); !slices.Equal(got, expected) {
+		t.Errorf("initialPeerHosts() = %v, expected %v", got, expected)
+	}
+}
+
+func TestResolveEgressAllowlistLiterals(t *testing.T) {
+	got := resolveEgressAllowlist(context.Background(), []string{"10.0.0.0/8", "203.0.113.7", "2001:db8::1", "203.0.113.7"})
+	expected := []string{"10.0.0.0/8", "203.0.113.7"}
+	if !slices.Equal(got, expected) {
+		t.Errorf("resolveEgressAllowlist() = %v, expected %v", got, expected)
+	}
+}
+
+func TestBuildEgressRuleset(t *testing.T) {
+	ruleset := buildEgressRuleset([]string{"10.0.0.0/8", "203.0.113.7"})
+	for _, expected := range []string{
+		"delete table inet " + egressTableName,
+		"elements = { 10.0.0.0/8, 203.0.113.7 }",
+		"ip saddr " + egressNetworkSubnet + " counter drop",
+	} {
+		if !strings.Contains(ruleset, expected) {
+			t.Errorf("expected %q in ruleset:\n%s", expected, ruleset)
+		}
+	}
+	if strings.Contains(buildEgressRuleset(nil), "elements") {
+		t.Error("expected no elements for an empty allowlist")
+	}
+}
@@ -0,0 +1,154 @@
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// With restrict_egress the container runs on its own Podman network instead
+// of the machine's, and an nftables table in the Podman machine only lets it
+// open connections to the swarm's bootstrap peers, HuggingFace and the hosts
+// of egress_allowlist. Connections other peers open to the node are still
+// answered. Hostnames are resolved on every start, as CDN addresses change,
+// and the rules are reapplied then since they don't survive a machine restart.
+
+const (
+	egressNetworkName   = "reai-egress"
+	egressNetworkSubnet = "10.89.77.0/24"
+	egressTableName     = "reai_egress"
+)
+
+// defaultEgressAllowlist are the hosts every node needs, besides the swarm
+// bootstrap peers from initial_peers.
+var defaultEgressAllowlist = []string{
+	"huggingface.co",
+	"cdn-lfs.huggingface.co",
+	"cdn-lfs.hf.co",
+	"cdn-lfs-us-1.hf.co",
+	"cas-bridge.xethub.hf.co",
+	"sociallyshaped.net",
+}
+
+// networkArgs returns the podman run arguments for the network of a
+// container serving on port, setting up the egress restriction if enabled.
+func networkArgs(ctx context.Context, port uint64) ([]string, error) {
+	cfg := currentAppConfig()
+	if !cfg.RestrictEgress {
+		return []string{"--network=host"}, nil
+	}
+	if err := ensureEgressNetwork(ctx); err != nil {
+		return nil, err
+	}
+
+	hosts := slices.Concat(defaultEgressAllowlist, initialPeerHosts(cfg.InitialPeers), cfg.EgressAllowlist)
+	allowed := resolveEgressAllowlist(ctx, hosts)
+	if err := applyEgressRules(ctx, buildEgressRuleset(allowed)); err != nil {
+		return nil, err
+	}
+	slog.Info("Container egress restricted", "network", egressNetworkName, "hosts", hosts, "addresses", len(allowed))
+
+	published := strconv.FormatUint(port, 10)
+	return []string{
+		"--network=" + egressNetworkName,
+		"--publish=" + published + ":" + published + "/tcp",
+		"--publish=" + published + ":" + published + "/udp",
+	}, nil
+}
+
+func ensureEgressNetwork(ctx context.Context) error {
+	if err := runHelper(podmanCommand(ctx, "network", "exists", egressNetworkName)); err == nil {
+		return nil
+	}
+	slog.Info("Creating egress restricted network", "name", egressNetworkName, "subnet", egressNetworkSubnet)
+	output, err := helperCombinedOutput(podmanCommand(ctx, "network", "create",
+		"--subnet="+egressNetworkSubnet,
+		"--label", cacheVolumeAppLabel,
+		egressNetworkName,
+	))
+	if err != nil {
+		return fmt.Errorf("failed to create network %s: %w", egressNetworkName, podmanError(err, output))
+	}
+	return nil
+}
+
+// initialPeerHosts returns the hosts of multiaddrs like
+// /dns4/example.net/tcp/8788/p2p/Qm... or /ip4/1.2.3.4/tcp/8788/p2p/Qm...
+func initialPeerHosts(initialPeers string) []string {
+	var hosts []string
+	for _, addr := range strings.Fields(strings.ReplaceAll(initialPeers, ",", " ")) {
+		parts := strings.Split(strings.Trim(addr, "/"), "/")
+		if len(parts) < 2 {
+			continue
+		}
+		switch parts[0] {
+		case "dns", "dns4", "dns6", "ip4":
+			hosts = append(hosts, parts[1])
+		}
+	}
+	return hosts
+}
+
+// resolveEgressAllowlist returns the IPv4 addresses and networks of entries,
+// which are hostnames, addresses or CIDR networks. Hosts that don't resolve
+// are logged and skipped.
+func resolveEgressAllowlist(ctx context.Context, entries []string) []string {
+	var allowed []string
+	for _, entry := range entries {
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			if network.IP.To4() != nil {
+				allowed = append(allowed, network.String())
+			}
+			continue
+		}
+		if ip := net.ParseIP(entry); ip != nil {
+			if ip.To4() != nil {
+				allowed = append(allowed, ip.String())
+			}
+			continue
+		}
+		ips, err := net.DefaultResolver.LookupIP(ctx, "ip4", entry)
+		if err != nil {
+			slog.Warn("Unable to resolve egress allowlist host", "host", entry, "error", err)
+			continue
+		}
+		for _, ip := range ips {
+			allowed = append(allowed, ip.String())
+		}
+	}
+	slices.Sort(allowed)
+	return slices.Compact(allowed)
+}
+
+// buildEgressRuleset returns the nftables script replacing the egress table.
+func buildEgressRuleset(allowed []string) string {
+	var b strings.Builder
+	// Creating the table first makes the delete succeed on the first run
+	fmt.Fprintf(&b, "table inet %s\ndelete table inet %s\n", egressTableName, egressTableName)
+	fmt.Fprintf(&b, "table inet %s {\n", egressTableName)
+	b.WriteString("\tset allowed {\n\t\ttype ipv4_addr\n\t\tflags interval\n")
+	if len(allowed) > 0 {
+		fmt.Fprintf(&b, "\t\telements = { %s }\n", strings.Join(allowed, ", "))
+	}
+	b.WriteString("\t}\n")
+	b.WriteString("\tchain forward {\n\t\ttype filter hook forward priority filter - 10; policy accept;\n")
+	fmt.Fprintf(&b, "\t\tip saddr %s ct state established,related accept\n", egressNetworkSubnet)
+	fmt.Fprintf(&b, "\t\tip saddr %s ip daddr @allowed accept\n", egressNetworkSubnet)
+	fmt.Fprintf(&b, "\t\tip saddr %s counter drop\n", egressNetworkSubnet)
+	b.WriteString("\t}\n}\n")
+	return b.String()
+}
+
+// applyEgressRules loads an nftables script in the Podman machine.
+func applyEgressRules(ctx context.Context, ruleset string) error {
+	cmd := helperCommand(ctx, "podman", "machine", "ssh", "sudo nft -f -")
+	cmd.Stdin = strings.NewReader(ruleset)
+	if output, err := helperCombinedOutput(cmd); err != nil {
+		return fmt.Errorf("failed to apply egress rules in the Podman machine: %w", podmanError(err, output))
+	}
+	return nil
+}
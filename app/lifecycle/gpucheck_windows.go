@@ -0,0 +1,128 @@
+package lifecycle
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/ReEnvision-AI/systray/app/tray/commontray"
+)
+
+// GPU passthrough can break silently while the node runs, typically when
+// Windows updates the graphics driver underneath the Podman machine. The
+// container is checked for the GPU every GPUCheckInterval, and right away
+// when the driver version changes. If the GPU is gone the node is marked
+// degraded and the user is offered a restart, which regenerates the CDI spec.
+
+var (
+	GPUCheckInterval       = 3 * time.Hour
+	GPUDriverCheckInterval = 10 * time.Minute
+)
+
+const (
+	gpuCheckTimeout     = time.Minute
+	gpuDegradedText     = "Running (GPU unavailable)"
+	gpuRestartClickTime = 5 * time.Minute // How long the notification's restart action is waited for
+)
+
+// gpuDegraded is set while the running container has lost the GPU, and
+// cleared on every state change.
+var gpuDegraded atomic.Bool
+
+// StartGPUCheck checks the GPU of the running container until ctx is
+// cancelled.
+func StartGPUCheck(ctx context.Context) {
+	go func() {
+		var driver string
+		lastCheck := time.Now()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(GPUDriverCheckInterval):
+			}
+			if !gpuCheckDue() {
+				continue
+			}
+
+			current := nvidiaDriverVersion(ctx)
+			changed := driver != "" && current != "" && current != driver
+			if current != "" {
+				driver = current
+			}
+			if changed {
+				slog.Info("Nvidia driver version changed", "version", current)
+			} else if time.Since(lastCheck) < GPUCheckInterval {
+				continue
+			}
+			lastCheck = time.Now()
+			checkContainerGPU(ctx)
+		}
+	}()
+}
+
+// gpuCheckDue reports whether a GPU node is running.
+func gpuCheckDue() bool {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	return currentState == StateRunning && currentAppConfig().UseGPU
+}
+
+// nvidiaDriverVersion returns the driver version seen by Windows, empty if
+// unknown.
+func nvidiaDriverVersion(ctx context.Context) string {
+	ctx, cancel := context.WithTimeout(ctx, gpuCheckTimeout)
+	defer cancel()
+	output, err := helperOutput(helperCommand(ctx, "nvidia-smi", "--query-gpu=driver_version", "--format=csv,noheader"))
+	if err != nil {
+		slog.Debug("failed to query Nvidia driver version", "error", err)
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// checkContainerGPU runs nvidia-smi in the container and updates the
+// degraded state.
+func checkContainerGPU(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, gpuCheckTimeout)
+	defer cancel()
+	output, err := helperCombinedOutput(podmanCommand(ctx, "exec", currentAppConfig().ContainerName, "nvidia-smi", "-L"))
+	if ctx.Err() != nil || !gpuCheckDue() {
+		return // Stopped meanwhile, or Podman is too busy to tell
+	}
+	if err == nil {
+		if gpuDegraded.CompareAndSwap(true, false) {
+			slog.Info("Container GPU is available again")
+			setStatusText(StateRunning, "")
+		}
+		return
+	}
+
+	slog.Warn("Container lost access to the GPU", "error", podmanError(err, output))
+	if !gpuDegraded.CompareAndSwap(false, true) {
+		return
+	}
+	setStatusText(StateRunning, gpuDegradedText)
+	go offerGPURestart()
+}
+
+// offerGPURestart notifies that the GPU is gone and restarts the node if the
+// notification is clicked.
+func offerGPURestart() {
+	restart := make(chan struct{}, 1)
+	if !notifyAction(commontray.NotifyError, "The node lost access to the GPU",
+		"This usually happens after a graphics driver update. Click here to restart the node", restart) {
+		return
+	}
+	select {
+	case <-restart:
+		if !gpuDegraded.Load() {
+			return
+		}
+		slog.Info("Restarting node to restore GPU access")
+		restartNode()
+	case <-time.After(gpuRestartClickTime):
+	}
+}
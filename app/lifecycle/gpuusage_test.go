@@ -0,0 +1,32 @@
+//go:build windows && unit_test
+
+package lifecycle
+
+import "testing"
+
+func TestParseGPUUsage(t *testing.T) {
+	usage, err := parseGPUUsage("87, 10240, 24576\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(usage) != 1 || usage[0] != (gpuUsage{Utilization: 87, MemoryUsed: 10 << 30, MemoryTotal: 24 << 30}) {
+		t.Errorf("unexpected usage %+v", usage)
+	}
+	if got, want := gpuUsageText(usage), "GPU: 87%, VRAM 10.0 GiB of 24.0 GiB"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	usage, err = parseGPUUsage("87, 10240, 24576\n3, 512, 8192\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := gpuUsageText(usage), "GPU 0: 87%, VRAM 10.0 GiB of 24.0 GiB; GPU 1: 3%, VRAM 512.0 MiB of 8.0 GiB"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	for _, output := range []string{"", "No devices were found", "[N/A], 512, 8192"} {
+		if _, err := parseGPUUsage(output); err == nil {
+			t.Errorf("expected an error parsing %q", output)
+		}
+	}
+}
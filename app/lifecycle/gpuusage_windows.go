@@ -0,0 +1,115 @@
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ReEnvision-AI/systray/app/format"
+)
+
+// While a GPU node runs, the utilization and memory use of the GPU are shown
+// below the status in the tray menu, so users can tell the node actually
+// uses their GPU. nvidia-smi is polled on the Windows side, as it sees the
+// memory the container allocated like any other.
+
+// GPUUsageInterval is how often GPU usage is polled while the node runs.
+var GPUUsageInterval = 15 * time.Second
+
+const gpuUsageTimeout = 10 * time.Second
+
+// gpuUsage is the usage of a GPU reported by nvidia-smi.
+type gpuUsage struct {
+	Utilization int   // Percent
+	MemoryUsed  int64 // Bytes
+	MemoryTotal int64 // Bytes
+}
+
+// StartGPUUsage shows the GPU usage of the running node until ctx is
+// cancelled.
+func StartGPUUsage(ctx context.Context) {
+	go func() {
+		shown := ""
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(GPUUsageInterval):
+			}
+			text := ""
+			if gpuCheckDue() {
+				text = queryGPUUsage(ctx)
+			}
+			if text == shown {
+				continue
+			}
+			shown = text
+			if err := t.SetGPUStatus(text); err != nil {
+				slog.Debug("failed to show GPU usage", "error", err)
+			}
+		}
+	}()
+}
+
+// queryGPUUsage returns the text describing the GPU usage, empty if it
+// can't be told.
+func queryGPUUsage(ctx context.Context) string {
+	ctx, cancel := context.WithTimeout(ctx, gpuUsageTimeout)
+	defer cancel()
+	output, err := helperOutput(helperCommand(ctx, "nvidia-smi", "--query-gpu=utilization.gpu,memory.used,memory.total", "--format=csv,noheader,nounits"))
+	if err != nil {
+		slog.Debug("failed to query GPU usage", "error", err)
+		return ""
+	}
+	usage, err := parseGPUUsage(string(output))
+	if err != nil {
+		slog.Debug("failed to parse GPU usage", "error", err)
+		return ""
+	}
+	return gpuUsageText(usage)
+}
+
+// parseGPUUsage parses the CSV output of nvidia-smi querying
+// utilization.gpu, memory.used and memory.total without units, one line per
+// GPU with memory in MiB.
+func parseGPUUsage(output string) ([]gpuUsage, error) {
+	var usage []gpuUsage
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fields := strings.Split(line, ",")
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("unexpected nvidia-smi output %q", line)
+		}
+		var values [3]int64
+		for i, field := range fields {
+			value, err := strconv.ParseInt(strings.TrimSpace(field), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("unexpected nvidia-smi output %q", line)
+			}
+			values[i] = value
+		}
+		usage = append(usage, gpuUsage{Utilization: int(values[0]), MemoryUsed: values[1] << 20, MemoryTotal: values[2] << 20})
+	}
+	if len(usage) == 0 {
+		return nil, fmt.Errorf("nvidia-smi found no GPU")
+	}
+	return usage, nil
+}
+
+// gpuUsageText describes the usage of the GPUs for the tray menu.
+func gpuUsageText(usage []gpuUsage) string {
+	parts := make([]string, len(usage))
+	for i, gpu := range usage {
+		name := "GPU"
+		if len(usage) > 1 {
+			name = fmt.Sprintf("GPU %d", i)
+		}
+		parts[i] = fmt.Sprintf("%s: %d%%, VRAM %s of %s", name, gpu.Utilization, format.Bytes(gpu.MemoryUsed), format.Bytes(gpu.MemoryTotal))
+	}
+	return strings.Join(parts, "; ")
+}
@@ -0,0 +1,52 @@
+//go:build windows && unit_test
+
+package lifecycle
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHealthCheckDefaults(t *testing.T) {
+	savedPort := Port
+	defer func() { Port = savedPort }()
+	Port = 31330
+
+	var cfg HealthCheck
+	if cfg.interval() != defaultHealthInterval || cfg.failures() != defaultHealthFailures {
+		t.Errorf("unexpected defaults %v, %d", cfg.interval(), cfg.failures())
+	}
+	if !strings.Contains(cfg.command(), "31330") {
+		t.Errorf("expected the default probe to connect to the node port, got %q", cfg.command())
+	}
+
+	cfg = HealthCheck{IntervalSeconds: 30, Failures: 5, Command: "curl -fs localhost:8080/health"}
+	if cfg.interval() != 30*time.Second || cfg.failures() != 5 || cfg.command() != cfg.Command {
+		t.Errorf("expected the configured values, got %v, %d, %q", cfg.interval(), cfg.failures(), cfg.command())
+	}
+}
+
+func TestRecordHealthProbe(t *testing.T) {
+	mt := setupMockTray()
+	defer resetState()
+	SetState(StateRunning)
+
+	probeErr := errors.New("exit status 1")
+	failures := recordHealthProbe(probeErr, 0, 3)
+	failures = recordHealthProbe(probeErr, failures, 3)
+	if failures != 2 {
+		t.Errorf("expected 2 failures, got %d", failures)
+	}
+	if mt.statusText != unhealthyText {
+		t.Errorf("expected status %q, got %q", unhealthyText, mt.statusText)
+	}
+
+	if failures := recordHealthProbe(nil, failures, 3); failures != 0 {
+		t.Errorf("expected a successful probe to reset the failures, got %d", failures)
+	}
+	if mt.statusText != "Running" {
+		t.Errorf("expected the running status to be restored, got %q", mt.statusText)
+	}
+}
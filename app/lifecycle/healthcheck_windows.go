@@ -0,0 +1,140 @@
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/ReEnvision-AI/systray/app/tray/commontray"
+)
+
+// A hung server keeps its container running, so the node is probed every
+// health check interval once it had time to load its blocks. The probe runs
+// in the container, by default connecting to the node port. While probes
+// fail the node shows as unhealthy, and after Failures in a row the node is
+// restarted.
+
+// HealthCheck configures the probing of the running node.
+type HealthCheck struct {
+	IntervalSeconds int    `json:"interval_seconds"` // 0 for defaultHealthInterval, negative disables the health check
+	Failures        int    `json:"failures"`         // Consecutive failed probes before restarting, 0 for defaultHealthFailures
+	Command         string `json:"command"`          // Run in the container with sh -c, empty to probe the node port
+}
+
+const (
+	defaultHealthInterval = time.Minute
+	defaultHealthFailures = 3
+	healthProbeTimeout    = 30 * time.Second
+	healthStartupGrace    = 10 * time.Minute // Loading blocks can take this long before the port opens
+	unhealthyText         = "Running (Unhealthy)"
+)
+
+func (h HealthCheck) interval() time.Duration {
+	if h.IntervalSeconds > 0 {
+		return time.Duration(h.IntervalSeconds) * time.Second
+	}
+	return defaultHealthInterval
+}
+
+func (h HealthCheck) failures() int {
+	if h.Failures > 0 {
+		return h.Failures
+	}
+	return defaultHealthFailures
+}
+
+func (h HealthCheck) command() string {
+	return h.commandFor(Port)
+}
+
+// commandFor returns the probe of a node serving on port.
+func (h HealthCheck) commandFor(port uint64) string {
+	if h.Command != "" {
+		return h.Command
+	}
+	return fmt.Sprintf(`python3 -c "import socket; socket.create_connection(('127.0.0.1', %d), 5)"`, port)
+}
+
+// StartHealthCheck probes the running node until ctx is cancelled.
+func StartHealthCheck(ctx context.Context) {
+	go func() {
+		failures := 0
+		for {
+			// The config is only loaded once the node starts
+			cfg := currentAppConfig().HealthCheck
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(cfg.interval()):
+			}
+
+			since, ok := healthCheckDue(cfg)
+			if !ok || time.Since(since) < healthStartupGrace {
+				failures = 0
+				continue
+			}
+			failures = recordHealthProbe(probeHealth(ctx, cfg), failures, cfg.failures())
+		}
+	}()
+}
+
+// healthCheckDue returns when the node started running, if it is running
+// and the health check is enabled.
+func healthCheckDue(cfg HealthCheck) (time.Time, bool) {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	return runningSince, currentState == StateRunning && cfg.IntervalSeconds >= 0 && !runningSince.IsZero()
+}
+
+// probeHealth runs the probe in the container.
+func probeHealth(ctx context.Context, cfg HealthCheck) error {
+	return probeContainer(ctx, currentAppConfig().ContainerName, cfg.command())
+}
+
+// probeContainer runs the probe command in the named container.
+func probeContainer(ctx context.Context, name, command string) error {
+	ctx, cancel := context.WithTimeout(ctx, healthProbeTimeout)
+	defer cancel()
+	output, err := helperCombinedOutput(podmanCommand(ctx, "exec", name, "sh", "-c", command))
+	if err != nil {
+		return podmanError(err, output)
+	}
+	return nil
+}
+
+// recordHealthProbe updates the status after a probe and restarts the node
+// once maxFailures probes failed in a row. Returns the new failure count.
+func recordHealthProbe(err error, failures, maxFailures int) int {
+	if err == nil {
+		if failures > 0 {
+			slog.Info("Node is healthy again", "failed_probes", failures)
+			nodeMetrics.Set("node.healthy", 1)
+			setStatusText(StateRunning, runningStatusText())
+		}
+		return 0
+	}
+
+	failures++
+	slog.Warn("Node health probe failed", "failures", failures, "max_failures", maxFailures, "error", err)
+	nodeMetrics.Set("node.healthy", 0)
+	setStatusText(StateRunning, unhealthyText)
+	if failures < maxFailures {
+		return failures
+	}
+
+	slog.Error("Node is unhealthy, restarting it")
+	nodeMetrics.Add("node.health_restarts", 1)
+	notify(commontray.NotifyWarning, "Restarting your node", "The node stopped responding and is being restarted")
+	go restartNode()
+	return 0
+}
+
+// runningStatusText is the status text of a running node without health
+// problems, empty for the default.
+func runningStatusText() string {
+	if gpuDegraded.Load() {
+		return gpuDegradedText
+	}
+	return ""
+}
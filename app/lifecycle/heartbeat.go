@@ -0,0 +1,99 @@
+package lifecycle
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"time"
+
+	"github.com/ReEnvision-AI/systray/app/auth"
+	"github.com/ReEnvision-AI/systray/app/heartbeat"
+	"github.com/ReEnvision-AI/systray/app/store"
+	"github.com/ReEnvision-AI/systray/version"
+)
+
+const heartbeatTimeout = 30 * time.Second
+
+// StartHeartbeat reports the node status to the configured heartbeat backend
+// while the node is running outside anonymous mode and the network is up,
+// until ctx is cancelled.
+func StartHeartbeat(ctx context.Context) {
+	go func() {
+		for {
+			interval := sendHeartbeat(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(interval):
+			}
+		}
+	}()
+}
+
+// sendHeartbeat sends one heartbeat if the node is running and returns the
+// time until the next one.
+func sendHeartbeat(ctx context.Context) time.Duration {
+	stateMu.Lock()
+	state, since := currentState, runningSince
+	stateMu.Unlock()
+	// The config is only loaded once the node starts
+	cfg := currentAppConfig()
+	if state != StateRunning || anonymousMode() || networkOffline.Load() {
+		return cfg.Heartbeat.Interval()
+	}
+
+	backend, err := newHeartbeatBackend(cfg)
+	if err != nil {
+		slog.Warn("Heartbeat is not configured", "error", err)
+		return cfg.Heartbeat.Interval()
+	}
+
+	id := store.GetID()
+	beat := heartbeat.Beat{
+		NodeID:    id,
+		State:     hookStateName(state),
+		SentAt:    time.Now().UTC(),
+		Operation: operationID(),
+		Summaries: unsyncedSummaries(time.Now()),
+	}
+	if org := store.GetOrganization(); org != nil {
+		beat.OrganizationID = org.ID
+	}
+	if !since.IsZero() {
+		beat.Uptime = int64(time.Since(since) / time.Second)
+	}
+	if telemetryEnabled() {
+		beat.MachineFingerprint = hardwareFingerprint(id)
+	}
+	if telemetryEnabled() && !meteredLimited() {
+		beat.Details = &heartbeat.Details{
+			Version: version.Version,
+			Channel: version.Channel,
+			OS:      runtime.GOOS,
+			Arch:    runtime.GOARCH,
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, heartbeatTimeout)
+	defer cancel()
+	reply, err := backend.Send(ctx, beat)
+	if err != nil {
+		reportFailure(failureHeartbeat, err)
+	} else {
+		reportRecovery(failureHeartbeat)
+		markSummariesSynced(beat.Summaries)
+		if reply.DuplicateNode {
+			go handleDuplicateNode()
+		}
+	}
+	return cfg.Heartbeat.Interval()
+}
+
+// newHeartbeatBackend returns the heartbeat backend cfg selects.
+func newHeartbeatBackend(cfg AppConfig) (heartbeat.Backend, error) {
+	var supabase *auth.Client
+	if cfg.SupabaseURL != "" && auth.CheckAnonKey(cfg.SupabaseAnonKey) == nil {
+		supabase = auth.NewClient(cfg.SupabaseURL, cfg.SupabaseAnonKey)
+	}
+	return heartbeat.New(cfg.Heartbeat, supabase, userAgent())
+}
@@ -0,0 +1,31 @@
+//go:build windows && unit_test
+
+package lifecycle
+
+import (
+	"testing"
+
+	"golang.org/x/sys/windows"
+)
+
+func TestHelperPriority(t *testing.T) {
+	saved := appConfig
+	defer func() { appConfig = saved }()
+
+	// Helpers may run before the config is loaded
+	appConfig = AppConfig{}
+	if got := helperPriorityClass(); got != windows.BELOW_NORMAL_PRIORITY_CLASS {
+		t.Errorf("expected below normal priority by default, got %#x", got)
+	}
+	if got := helperIOPriority(); got != 1 {
+		t.Errorf("expected low IO priority by default, got %d", got)
+	}
+
+	appConfig = AppConfig{HelperPriority: helperPriorityIdle, HelperIOPriority: helperIOPriorityVeryLow}
+	if got := helperPriorityClass(); got != windows.IDLE_PRIORITY_CLASS {
+		t.Errorf("expected idle priority, got %#x", got)
+	}
+	if got := helperIOPriority(); got != 0 {
+		t.Errorf("expected very low IO priority, got %d", got)
+	}
+}
@@ -0,0 +1,195 @@
+package lifecycle
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/ReEnvision-AI/systray/app/procs"
+	"golang.org/x/sys/windows"
+)
+
+// Helper processes (the podman CLI, nvidia-smi, the installer) run with a
+// lower CPU and IO priority than the desktop, so maintenance work like image
+// pulls and volume scans never makes the foreground stutter. The priority
+// class is set at creation and inherited by the helper's own children, the IO
+// priority right after the process starts along with the assignment to the
+// helper job, which is why helpers are run through the functions below
+// instead of the exec.Cmd methods.
+//
+// Helpers are console programs, and are created without a console so none
+// flashes on the desktop. Helpers run to completion are killed after
+// helperTimeout unless their context ends sooner, so a hung podman command
+// can't stall the app. Ones running until they are cancelled, like the
+// node's container, are built with persistentHelperCommand instead.
+//
+// Started helpers are tracked in helperProcs until they are waited for, to
+// list them in diagnostics and kill any still running when the app quits.
+// The helper job kills them too if the app crashes.
+
+// Values for AppConfig.HelperPriority
+const (
+	helperPriorityBelowNormal = "below_normal" // The default
+	helperPriorityIdle        = "idle"
+	helperPriorityNormal      = "normal"
+)
+
+// Values for AppConfig.HelperIOPriority, named after the Windows IO priority
+// hints
+const (
+	helperIOPriorityLow     = "low" // The default
+	helperIOPriorityVeryLow = "very_low"
+	helperIOPriorityNormal  = "normal"
+)
+
+var helperProcs = procs.NewRegistry()
+
+const (
+	helperTimeout   = 10 * time.Minute
+	helperWaitDelay = 5 * time.Second // For pipes held open by processes a helper left behind
+)
+
+// helperCommand builds a hidden command running at the helper priority, to
+// run to completion within helperTimeout.
+func helperCommand(ctx context.Context, name string, args ...string) *exec.Cmd {
+	if _, ok := ctx.Deadline(); !ok {
+		// Not WithTimeout, the command outlives this function and the timer
+		// releases the context once it fires
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		time.AfterFunc(helperTimeout, cancel)
+	}
+	cmd := persistentHelperCommand(ctx, name, args...)
+	cmd.WaitDelay = helperWaitDelay
+	return cmd
+}
+
+// persistentHelperCommand builds a hidden command running at the helper
+// priority until ctx is cancelled.
+func persistentHelperCommand(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true, CreationFlags: windows.CREATE_NO_WINDOW | helperPriorityClass()}
+	return cmd
+}
+
+func helperPriorityClass() uint32 {
+	switch currentAppConfig().HelperPriority {
+	case helperPriorityIdle:
+		return windows.IDLE_PRIORITY_CLASS
+	case helperPriorityNormal:
+		return windows.NORMAL_PRIORITY_CLASS
+	default:
+		return windows.BELOW_NORMAL_PRIORITY_CLASS
+	}
+}
+
+// helperIOPriority returns the IO_PRIORITY_HINT of helpers.
+func helperIOPriority() uint32 {
+	switch currentAppConfig().HelperIOPriority {
+	case helperIOPriorityVeryLow:
+		return 0
+	case helperIOPriorityNormal:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// startHelper starts cmd in the helper job and lowers its IO priority. The
+// caller calls helperProcs.Done(cmd) once it waited for cmd.
+func startHelper(cmd *exec.Cmd) error {
+	return startHelperProcess(cmd, true)
+}
+
+func startHelperProcess(cmd *exec.Cmd, inJob bool) error {
+	if inJob {
+		if cmd.SysProcAttr == nil {
+			cmd.SysProcAttr = &syscall.SysProcAttr{}
+		}
+		cmd.SysProcAttr.CreationFlags |= windows.CREATE_SUSPENDED
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	helperProcs.Track(cmd)
+	if inJob {
+		if err := assignToHelperJob(cmd.Process.Pid); err != nil {
+			slog.Debug("failed to assign helper to job", "command", cmd.Path, "error", err)
+		}
+		if err := resumeProcess(cmd.Process.Pid); err != nil {
+			cmd.Process.Kill() //nolint:errcheck
+			cmd.Wait()         //nolint:errcheck
+			helperProcs.Done(cmd)
+			return fmt.Errorf("failed to resume helper: %w", err)
+		}
+	}
+	if err := setIOPriority(cmd.Process.Pid, helperIOPriority()); err != nil {
+		// Only a missed optimization, and helpers often exit this quickly
+		slog.Debug("failed to set helper IO priority", "command", cmd.Path, "error", err)
+	}
+	return nil
+}
+
+// runHelper is exec.Cmd.Run for helpers.
+func runHelper(cmd *exec.Cmd) error {
+	if err := startHelper(cmd); err != nil {
+		return err
+	}
+	defer helperProcs.Done(cmd)
+	return cmd.Wait()
+}
+
+// helperOutput is exec.Cmd.Output for helpers, including the stderr of an
+// *exec.ExitError.
+func helperOutput(cmd *exec.Cmd) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	captureStderr := cmd.Stderr == nil
+	if captureStderr {
+		cmd.Stderr = &stderr
+	}
+	err := runHelper(cmd)
+	var exitErr *exec.ExitError
+	if captureStderr && errors.As(err, &exitErr) {
+		exitErr.Stderr = stderr.Bytes()
+	}
+	return stdout.Bytes(), err
+}
+
+// helperCombinedOutput is exec.Cmd.CombinedOutput for helpers.
+func helperCombinedOutput(cmd *exec.Cmd) ([]byte, error) {
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	err := runHelper(cmd)
+	return output.Bytes(), err
+}
+
+// machineHelperCombinedOutput is helperCombinedOutput for podman machine
+// start, which runs outside the helper job, see job_windows.go.
+func machineHelperCombinedOutput(cmd *exec.Cmd) ([]byte, error) {
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := startHelperProcess(cmd, false); err != nil {
+		return nil, err
+	}
+	defer helperProcs.Done(cmd)
+	err := cmd.Wait()
+	return output.Bytes(), err
+}
+
+func setIOPriority(pid int, priority uint32) error {
+	process, err := windows.OpenProcess(windows.PROCESS_SET_INFORMATION, false, uint32(pid))
+	if err != nil {
+		return err
+	}
+	defer windows.CloseHandle(process)
+	return windows.NtSetInformationProcess(process, windows.ProcessIoPriority, unsafe.Pointer(&priority), uint32(unsafe.Sizeof(priority)))
+}
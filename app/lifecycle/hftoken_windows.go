@@ -0,0 +1,109 @@
+package lifecycle
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ReEnvision-AI/systray/app/secrets"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+var (
+	HFWhoAmIURL          = "https://huggingface.co/api/whoami-v2"
+	hfTokenCheckTimeout  = 15 * time.Second
+	maxHFTokenAttempts   = 3
+	errInvalidHFToken    = errors.New("HuggingFace rejected the token")
+	errHFTokenNotEntered = errors.New("no HuggingFace token was entered")
+)
+
+// promptForHFToken asks the user for a HuggingFace access token, validates it
+// and stores it in the secret store. Returns the token.
+func promptForHFToken() (string, error) {
+	message := "ReEnvision AI needs a HuggingFace access token to download models.\n\n" +
+		"Create a read token at https://huggingface.co/settings/tokens and paste it as the password."
+	for attempt := 1; attempt <= maxHFTokenAttempts; attempt++ {
+		token, ok := promptForSecret("ReEnvision AI - HuggingFace token", message, "HuggingFace")
+		if !ok {
+			return "", errHFTokenNotEntered
+		}
+		token = strings.TrimSpace(token)
+		if token == "" {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), hfTokenCheckTimeout)
+		user, err := validateHFToken(ctx, currentEndpoints().HFWhoAmI, token)
+		cancel()
+		switch {
+		case err == nil:
+			slog.Info("HuggingFace token validated", "user", user)
+		case errors.Is(err, errInvalidHFToken):
+			slog.Warn("HuggingFace token rejected", "attempt", attempt)
+			message = "HuggingFace rejected that token. Check that it was copied completely and try again."
+			continue
+		default:
+			// Don't block onboarding if HuggingFace can't be reached right now
+			slog.Warn("Unable to validate HuggingFace token, storing it anyway", "error", err)
+		}
+
+		if err := storeHFToken(token); err != nil {
+			return "", err
+		}
+		return token, nil
+	}
+	return "", fmt.Errorf("no valid HuggingFace token after %d attempts", maxHFTokenAttempts)
+}
+
+// validateHFToken checks the token against the HuggingFace whoami API and
+// returns the account name. Returns errInvalidHFToken if it was rejected.
+func validateHFToken(ctx context.Context, whoAmIURL, token string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, whoAmIURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("User-Agent", userAgent())
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return "", errInvalidHFToken
+	default:
+		return "", fmt.Errorf("unexpected status from HuggingFace: %d", resp.StatusCode)
+	}
+
+	var whoami struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&whoami); err != nil {
+		return "", fmt.Errorf("malformed whoami response: %w", err)
+	}
+	return whoami.Name, nil
+}
+
+// storeHFToken saves the token UTF-16LE encoded, the same way the Credential
+// Manager UI and cmdkey store it, so either can be used to set it.
+func storeHFToken(token string) error {
+	utf16leEncoder := unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM).NewEncoder()
+	blob, _, err := transform.Bytes(utf16leEncoder, []byte(token))
+	if err != nil {
+		return fmt.Errorf("error encoding token to UTF-16LE: %w", err)
+	}
+	if err := secrets.Default().Set(hfTokenCredentialTarget, blob); err != nil {
+		return fmt.Errorf("failed to store HuggingFace token: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,268 @@
+package lifecycle
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ReEnvision-AI/systray/app/heartbeat"
+	"github.com/ReEnvision-AI/systray/app/store"
+)
+
+// The time the node spends running is rolled up per local day, with the
+// number of starts and errors, in history.json next to the store. Today's
+// total is shown in the status popup, and finished days the backend hasn't
+// seen yet are sent with the next heartbeat.
+
+const (
+	historyFileName      = "history.json"
+	historyRetentionDays = 90
+	historySaveInterval  = time.Minute
+	historyDateFormat    = "2006-01-02"
+)
+
+// DaySummary is the rollup of one local day.
+type DaySummary struct {
+	Date           string `json:"date"` // In historyDateFormat
+	RunningSeconds int64  `json:"running_seconds"`
+	Starts         int    `json:"starts"`
+	Errors         int    `json:"errors"`
+	Synced         bool   `json:"synced"` // Sent to the backend, only once the day is over
+}
+
+var (
+	historyMu     sync.Mutex
+	history       []DaySummary // Oldest first
+	historyLoaded bool
+	accruedUntil  time.Time // Running time is counted up to here, zero unless running
+)
+
+func historyPath() string {
+	return filepath.Join(AppDataDir, historyFileName)
+}
+
+// loadHistory reads the history file once. Must be called with historyMu
+// held.
+func loadHistory() {
+	if historyLoaded {
+		return
+	}
+	historyLoaded = true
+	data, err := os.ReadFile(historyPath())
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			slog.Warn("failed to read contribution history", "error", err)
+		}
+		return
+	}
+	if err := json.Unmarshal(data, &history); err != nil {
+		slog.Warn("failed to parse contribution history, starting over", "error", err)
+		history = nil
+	}
+}
+
+// saveHistory writes the history file. Must be called with historyMu held.
+func saveHistory() {
+	if localDataWiped.Load() {
+		return
+	}
+	data, err := json.Marshal(history)
+	if err != nil {
+		slog.Warn("failed to encode contribution history", "error", err)
+		return
+	}
+	tmp := historyPath() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		slog.Warn("failed to save contribution history", "error", err)
+		return
+	}
+	if err := os.Rename(tmp, historyPath()); err != nil {
+		slog.Warn("failed to save contribution history", "error", err)
+	}
+}
+
+// historyDay returns the summary of the local day of t, adding it and
+// dropping days past historyRetentionDays if needed. Must be called with
+// historyMu held.
+func historyDay(t time.Time) *DaySummary {
+	date := t.Format(historyDateFormat)
+	if n := len(history); n > 0 && history[n-1].Date == date {
+		return &history[n-1]
+	}
+	for i := range history {
+		if history[i].Date == date {
+			return &history[i]
+		}
+	}
+	history = append(history, DaySummary{Date: date})
+	cutoff := t.AddDate(0, 0, -historyRetentionDays).Format(historyDateFormat)
+	for len(history) > 0 && history[0].Date < cutoff {
+		store.AddContributionBeforeHistory(history[0].RunningSeconds)
+		history = history[1:]
+	}
+	return &history[len(history)-1]
+}
+
+// accrueRunning adds the running time up to now, split at local midnight.
+// Whole seconds are counted so nothing is lost to rounding. Must be called
+// with historyMu held.
+func accrueRunning(now time.Time) {
+	for !accruedUntil.IsZero() && now.Sub(accruedUntil) >= time.Second {
+		y, m, d := accruedUntil.Date()
+		end := time.Date(y, m, d+1, 0, 0, 0, 0, accruedUntil.Location())
+		if now.Before(end) {
+			end = now
+		}
+		seconds := int64(end.Sub(accruedUntil) / time.Second)
+		if seconds == 0 {
+			seconds = 1 // Less than a second to midnight
+		}
+		historyDay(accruedUntil).RunningSeconds += seconds
+		accruedUntil = accruedUntil.Add(time.Duration(seconds) * time.Second)
+	}
+}
+
+// recordHistoryState updates the history when the app enters state.
+func recordHistoryState(state AppState, now time.Time) {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	loadHistory()
+	accrueRunning(now)
+
+	switch state {
+	case StateRunning:
+		if accruedUntil.IsZero() {
+			accruedUntil = now
+		}
+	case StateStarting:
+		historyDay(now).Starts++
+		accruedUntil = time.Time{}
+	case StateError:
+		historyDay(now).Errors++
+		accruedUntil = time.Time{}
+	default:
+		accruedUntil = time.Time{}
+	}
+	saveHistory()
+}
+
+// contributedToday returns the time the node ran today.
+func contributedToday(now time.Time) time.Duration {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	loadHistory()
+	accrueRunning(now)
+	return time.Duration(historyDay(now).RunningSeconds) * time.Second
+}
+
+// contributedTotal returns the time the node ran since it was created.
+func contributedTotal(now time.Time) time.Duration {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	loadHistory()
+	accrueRunning(now)
+	seconds := store.GetContributionBeforeHistory()
+	for _, day := range history {
+		seconds += day.RunningSeconds
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// historySnapshot returns a copy of the history, oldest first.
+func historySnapshot(now time.Time) []DaySummary {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	loadHistory()
+	accrueRunning(now)
+	return slices.Clone(history)
+}
+
+// StartHistory saves the running time every historySaveInterval, so little
+// is lost if the app is killed, and refreshes today's total in the tray.
+func StartHistory(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(historySaveInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				historyMu.Lock()
+				accrueRunning(time.Now())
+				saveHistory()
+				historyMu.Unlock()
+				return
+			case <-ticker.C:
+			}
+
+			historyMu.Lock()
+			running := !accruedUntil.IsZero()
+			accrueRunning(time.Now())
+			if running {
+				saveHistory()
+			}
+			historyMu.Unlock()
+
+			if running {
+				stateMu.Lock()
+				state := currentState
+				stateMu.Unlock()
+				t.SetStatusInfo(statusInfo(state))
+			}
+		}
+	}()
+}
+
+// unsyncedSummaries returns the finished days not yet sent to the backend.
+func unsyncedSummaries(now time.Time) []heartbeat.DaySummary {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	loadHistory()
+	today := now.Format(historyDateFormat)
+	var summaries []heartbeat.DaySummary
+	for _, day := range history {
+		if !day.Synced && day.Date < today {
+			summaries = append(summaries, heartbeat.DaySummary{
+				Date:           day.Date,
+				RunningSeconds: day.RunningSeconds,
+				Starts:         day.Starts,
+				Errors:         day.Errors,
+			})
+		}
+	}
+	return summaries
+}
+
+// markSummariesSynced records that the backend received summaries.
+func markSummariesSynced(summaries []heartbeat.DaySummary) {
+	if len(summaries) == 0 {
+		return
+	}
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	for _, summary := range summaries {
+		for i := range history {
+			if history[i].Date == summary.Date {
+				history[i].Synced = true
+			}
+		}
+	}
+	saveHistory()
+}
+
+// removeHistory deletes the history file and forgets the loaded history.
+func removeHistory() error {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	history = nil
+	accruedUntil = time.Time{}
+	if err := os.Remove(historyPath()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+//go:build windows && unit_test
+
+package lifecycle
+
+import (
+	"testing"
+	"time"
+)
+
+func useTempHistory(t *testing.T) {
+	t.Helper()
+	saved := AppDataDir
+	AppDataDir = t.TempDir()
+	resetHistory := func() {
+		historyMu.Lock()
+		history, historyLoaded, accruedUntil = nil, false, time.Time{}
+		historyMu.Unlock()
+	}
+	resetHistory()
+	t.Cleanup(func() {
+		resetHistory()
+		AppDataDir = saved
+	})
+}
+
+func TestHistoryAcrossMidnight(t *testing.T) {
+	useTempHistory(t)
+	start := time.Date(2026, 3, 1, 23, 0, 0, 0, time.Local)
+
+	recordHistoryState(StateStarting, start)
+	recordHistoryState(StateRunning, start)
+	recordHistoryState(StateError, start.Add(90*time.Minute))
+
+	historyMu.Lock()
+	days := append([]DaySummary(nil), history...)
+	historyMu.Unlock()
+	if len(days) != 2 {
+		t.Fatalf("expected 2 days, got %+v", days)
+	}
+	if days[0].RunningSeconds != 3600 || days[0].Starts != 1 {
+		t.Errorf("unexpected first day %+v", days[0])
+	}
+	if days[1].RunningSeconds != 1800 || days[1].Errors != 1 {
+		t.Errorf("unexpected second day %+v", days[1])
+	}
+
+	// Reloaded from disk
+	historyMu.Lock()
+	history, historyLoaded = nil, false
+	historyMu.Unlock()
+	if got := contributedToday(start.Add(2 * time.Hour)); got != 30*time.Minute {
+		t.Errorf("expected 30 min contributed, got %v", got)
+	}
+}
+
+func TestContributedTodayWhileRunning(t *testing.T) {
+	useTempHistory(t)
+	start := time.Date(2026, 3, 2, 8, 0, 0, 0, time.Local)
+
+	recordHistoryState(StateRunning, start)
+	if got := contributedToday(start.Add(6*time.Hour + 13*time.Minute)); got != 6*time.Hour+13*time.Minute {
+		t.Errorf("expected 6h13m contributed, got %v", got)
+	}
+}
+
+func TestUnsyncedSummaries(t *testing.T) {
+	useTempHistory(t)
+	day := time.Date(2026, 3, 3, 10, 0, 0, 0, time.Local)
+
+	recordHistoryState(StateRunning, day)
+	recordHistoryState(StateStopped, day.Add(time.Hour))
+	if summaries := unsyncedSummaries(day.Add(2 * time.Hour)); len(summaries) != 0 {
+		t.Errorf("expected today to be held back, got %+v", summaries)
+	}
+
+	tomorrow := day.AddDate(0, 0, 1)
+	summaries := unsyncedSummaries(tomorrow)
+	if len(summaries) != 1 || summaries[0].RunningSeconds != 3600 {
+		t.Fatalf("expected yesterday's summary, got %+v", summaries)
+	}
+	markSummariesSynced(summaries)
+	if summaries := unsyncedSummaries(tomorrow); len(summaries) != 0 {
+		t.Errorf("expected no summaries after syncing, got %+v", summaries)
+	}
+}
+
+func TestHistoryRetention(t *testing.T) {
+	useTempHistory(t)
+	day := time.Date(2026, 1, 1, 12, 0, 0, 0, time.Local)
+
+	recordHistoryState(StateStarting, day)
+	recordHistoryState(StateStarting, day.AddDate(0, 0, historyRetentionDays+1))
+
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	if len(history) != 1 || history[0].Date != day.AddDate(0, 0, historyRetentionDays+1).Format(historyDateFormat) {
+		t.Errorf("expected old days to be dropped, got %+v", history)
+	}
+}
@@ -0,0 +1,51 @@
+//go:build windows && unit_test
+
+package lifecycle
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestHookCommand(t *testing.T) {
+	hooks := Hooks{OnStart: "start.cmd", OnStop: "stop.cmd", OnError: "error.cmd"}
+	tests := []struct {
+		state    AppState
+		expected string
+	}{
+		{StateRunning, "start.cmd"},
+		{StateStopped, "stop.cmd"},
+		{StateError, "error.cmd"},
+		{StateStarting, ""},
+		{StateStopping, ""},
+	}
+	for _, test := range tests {
+		if got := hooks.command(test.state); got != test.expected {
+			t.Errorf("command(%s) = %q, expected %q", test.state, got, test.expected)
+		}
+	}
+}
+
+func TestHookTimeout(t *testing.T) {
+	if got := (Hooks{}).timeout(); got != defaultHookTimeout {
+		t.Errorf("expected default timeout %v, got %v", defaultHookTimeout, got)
+	}
+	if got := (Hooks{TimeoutSeconds: 5}).timeout(); got != 5*time.Second {
+		t.Errorf("expected 5s timeout, got %v", got)
+	}
+}
+
+func TestHookEnv(t *testing.T) {
+	env := hookEnv(StateRunning, StateError, errors.New("exit status 1"))
+	for _, expected := range []string{
+		"REAI_STATE=error",
+		"REAI_PREVIOUS_STATE=running",
+		"REAI_ERROR=exit status 1",
+	} {
+		if !slices.Contains(env, expected) {
+			t.Errorf("expected %q in hook environment", expected)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ReEnvision-AI/systray/app/store"
+)
+
+const (
+	defaultHookTimeout = 30 * time.Second
+	maxHookOutputLog   = 4096 // Bytes of hook output kept in the log
+)
+
+// command returns the hook to run when entering state, or "" if none is
+// configured.
+func (h Hooks) command(state AppState) string {
+	switch state {
+	case StateRunning:
+		return h.OnStart
+	case StateStopped:
+		return h.OnStop
+	case StateError:
+		return h.OnError
+	}
+	return ""
+}
+
+func (h Hooks) timeout() time.Duration {
+	if h.TimeoutSeconds > 0 {
+		return time.Duration(h.TimeoutSeconds) * time.Second
+	}
+	return defaultHookTimeout
+}
+
+// hookStateName returns the stable name of a state passed to hooks, as
+// AppState.String is meant for display.
+func hookStateName(state AppState) string {
+	switch state {
+	case StateStopped:
+		return "stopped"
+	case StateStarting:
+		return "starting"
+	case StateLoading:
+		return "loading"
+	case StateRunning:
+		return "running"
+	case StateStopping:
+		return "stopping"
+	case StateThankyou:
+		return "thankyou"
+	case StatePaused:
+		return "paused"
+	case StateError:
+		return "error"
+	}
+	return "unknown"
+}
+
+// hookEnv returns the app's environment with the details of the transition
+// added for the hook.
+func hookEnv(previous, state AppState, stateErr error) []string {
+	errText := ""
+	if stateErr != nil {
+		errText = stateErr.Error()
+	}
+	return append(os.Environ(),
+		"REAI_STATE="+hookStateName(state),
+		"REAI_PREVIOUS_STATE="+hookStateName(previous),
+		"REAI_ERROR="+errText,
+		"REAI_NODE_ID="+store.GetID(),
+		"REAI_CONTAINER_NAME="+currentAppConfig().ContainerName,
+		"REAI_PORT="+strconv.FormatUint(Port, 10),
+		"REAI_OPERATION_ID="+operationID(),
+	)
+}
+
+// runHook runs a hook command line through cmd.exe, killing it after timeout,
+// and logs its output. Hooks can't affect the node, failures are only logged.
+func runHook(commandLine string, timeout time.Duration, env []string) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	shell := os.Getenv("ComSpec")
+	if shell == "" {
+		shell = "cmd.exe"
+	}
+	cmd := helperCommand(ctx, shell)
+	// Pass the command line verbatim so quoting works the way it does in a
+	// console, rather than being escaped as a single argument
+	cmd.SysProcAttr.CmdLine = syscall.EscapeArg(shell) + ` /d /s /c "` + commandLine + `"`
+	cmd.Env = env
+
+	slog.Info("Running hook", "command", commandLine)
+	start := time.Now()
+	output, err := helperCombinedOutput(cmd)
+	out := strings.TrimSpace(string(output))
+	if len(out) > maxHookOutputLog {
+		out = out[:maxHookOutputLog] + "..."
+	}
+
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		slog.Warn("Hook timed out", "command", commandLine, "timeout", timeout, "output", out)
+	case err != nil:
+		slog.Warn("Hook failed", "command", commandLine, "error", err, "output", out)
+	default:
+		slog.Info("Hook finished", "command", commandLine, "duration", time.Since(start).Round(time.Millisecond), "output", out)
+	}
+}
@@ -0,0 +1,25 @@
+//go:build windows && unit_test
+
+package lifecycle
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFingerprintOf(t *testing.T) {
+	guid := "3F2504E0-4F89-11D3-9A0C-0305E82C3301"
+	fingerprint := fingerprintOf(guid)
+	if len(fingerprint) != 64 {
+		t.Errorf("expected a hex SHA-256, got %q", fingerprint)
+	}
+	if strings.Contains(strings.ToLower(fingerprint), strings.ToLower(guid[:8])) {
+		t.Errorf("fingerprint %q reveals the machine GUID", fingerprint)
+	}
+	if other := fingerprintOf(" " + strings.ToLower(guid) + "\n"); other != fingerprint {
+		t.Errorf("expected the same fingerprint regardless of case and spaces, got %q and %q", fingerprint, other)
+	}
+	if other := fingerprintOf("3F2504E0-4F89-11D3-9A0C-0305E82C3302"); other == fingerprint {
+		t.Error("expected different machines to have different fingerprints")
+	}
+}
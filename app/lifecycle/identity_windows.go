@@ -0,0 +1,210 @@
+package lifecycle
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ReEnvision-AI/systray/app/auth"
+	"github.com/ReEnvision-AI/systray/app/store"
+	"github.com/ReEnvision-AI/systray/app/tray/commontray"
+	"golang.org/x/sys/windows/registry"
+)
+
+// The node ID is registered with the backend, tied to the signed in account
+// and a fingerprint of the machine, once the node runs. When the store is
+// lost, e.g. after reinstalling or the store failing to parse without a
+// known good copy, the backend is asked for the ID this machine had, and the
+// user is offered to restore it so the node keeps its contribution history.
+
+// Backend endpoints for node identities, relative to the Supabase URL
+var (
+	NodeIdentityRegisterPath = "/functions/v1/register-node-identity"
+	NodeIdentityPath         = "/functions/v1/node-identity"
+)
+
+const (
+	identityRequestTimeout   = 30 * time.Second
+	identityRestoreClickTime = 30 * time.Minute
+)
+
+var (
+	identityMu   sync.Mutex
+	registeredID string // Node ID last registered with the backend
+)
+
+// machineFingerprint identifies this machine to the backend without
+// revealing its Windows machine GUID.
+func machineFingerprint() (string, error) {
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE, `SOFTWARE\Microsoft\Cryptography`, registry.QUERY_VALUE|registry.WOW64_64KEY)
+	if err != nil {
+		return "", fmt.Errorf("unable to read machine GUID: %w", err)
+	}
+	defer key.Close()
+	guid, _, err := key.GetStringValue("MachineGuid")
+	if err != nil {
+		return "", fmt.Errorf("unable to read machine GUID: %w", err)
+	}
+	return fingerprintOf(guid), nil
+}
+
+func fingerprintOf(machineGUID string) string {
+	sum := sha256.Sum256([]byte("reai-node-identity:" + strings.ToLower(strings.TrimSpace(machineGUID))))
+	return hex.EncodeToString(sum[:])
+}
+
+// registerNodeIdentity registers the node ID with the backend, unless it
+// already was this run. Nodes in anonymous mode aren't tied to the account.
+func registerNodeIdentity() {
+	id := store.GetID()
+	identityMu.Lock()
+	defer identityMu.Unlock()
+	if store.GetAnonymousMode() || registeredID == id {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), identityRequestTimeout)
+	defer cancel()
+	client, s, err := backendSession(ctx, storedSession)
+	if err != nil {
+		slog.Debug("node identity not registered", "error", err)
+		return
+	}
+	fingerprint, err := machineFingerprint()
+	if err != nil {
+		slog.Warn("Failed to register node identity", "error", err)
+		return
+	}
+	body, err := json.Marshal(map[string]string{"node_id": id, "machine_fingerprint": fingerprint})
+	if err != nil {
+		return
+	}
+	req, err := client.NewRequest(ctx, http.MethodPost, NodeIdentityRegisterPath, bytes.NewReader(body), s)
+	if err != nil {
+		slog.Warn("Failed to register node identity", "error", err)
+		return
+	}
+	if err := client.Do(req, nil); err != nil {
+		slog.Warn("Failed to register node identity", "error", err)
+		return
+	}
+	registeredID = id
+	slog.Debug("node identity registered", "id", id)
+}
+
+// previousNodeIdentity returns the node ID registered for this machine and
+// account, empty if there is none.
+func previousNodeIdentity(ctx context.Context, client *auth.Client, s *auth.Session) (string, error) {
+	fingerprint, err := machineFingerprint()
+	if err != nil {
+		return "", err
+	}
+	body, err := json.Marshal(map[string]string{"machine_fingerprint": fingerprint})
+	if err != nil {
+		return "", err
+	}
+	req, err := client.NewRequest(ctx, http.MethodPost, NodeIdentityPath, bytes.NewReader(body), s)
+	if err != nil {
+		return "", err
+	}
+	var identity struct {
+		NodeID string `json:"node_id"`
+	}
+	if err := client.Do(req, &identity); err != nil {
+		var apiErr *auth.APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+			return "", nil
+		}
+		return "", err
+	}
+	return identity.NodeID, nil
+}
+
+// checkNodeIdentity offers to restore the previous node identity of this
+// machine if the store was created when the app started. Without a session
+// the backend can't be asked before the user signs in, so that's only
+// offered when the store surely lost an ID, as it failed to parse.
+func checkNodeIdentity() {
+	if !store.Created() || store.GetAnonymousMode() {
+		return
+	}
+	if _, _, err := store.KnownGoodID(); store.Damaged() && err == nil {
+		return // Offered by checkKnownGoodStore
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), identityRequestTimeout)
+	defer cancel()
+	client, s, err := backendSession(ctx, storedSession)
+	switch {
+	case err == nil:
+		id, err := previousNodeIdentity(ctx, client, s)
+		if err != nil {
+			slog.Warn("Failed to look up previous node identity", "error", err)
+			return
+		}
+		if id == "" || id == store.GetID() {
+			return
+		}
+		slog.Info("Found a previous node identity for this machine", "id", id, "current_id", store.GetID())
+	case errors.Is(err, auth.ErrNoSession) && store.Damaged():
+	default:
+		return
+	}
+	offerIdentityRestore()
+}
+
+// offerIdentityRestore notifies that the node runs with a new ID and
+// restores the previous one if the notification is clicked.
+func offerIdentityRestore() {
+	click := make(chan struct{}, 1)
+	if !notifyAction(commontray.NotifyWarning, "Restore my previous node identity",
+		"The node now runs with a new ID. Click here to sign in and continue with the ID and contribution history this machine had", click) {
+		return
+	}
+	select {
+	case <-click:
+	case <-time.After(identityRestoreClickTime):
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), DataRequestTimeout)
+	defer cancel()
+	id, err := restoreNodeIdentity(ctx)
+	switch {
+	case errors.Is(err, auth.ErrNoSession):
+	case err != nil:
+		slog.Error("Failed to restore previous node identity", "error", err)
+		notify(commontray.NotifyError, "Unable to restore your previous node identity", "Open the logs from the tray menu for details")
+	case id == "":
+		notify(commontray.NotifyInfo, "No previous node identity found", "This machine has no other node registered to your account")
+	default:
+		notify(commontray.NotifyInfo, "Node identity restored", "Your node continues with its contribution history")
+	}
+}
+
+// restoreNodeIdentity takes over the node ID the backend has for this
+// machine, prompting to sign in if needed. Returns the restored ID, empty if
+// there was none to restore.
+func restoreNodeIdentity(ctx context.Context) (string, error) {
+	client, s, err := backendSession(ctx, getSession)
+	if err != nil {
+		return "", err
+	}
+	id, err := previousNodeIdentity(ctx, client, s)
+	if err != nil || id == "" || id == store.GetID() {
+		return "", err
+	}
+	slog.Info("Restoring previous node identity", "id", id)
+	store.ImportNode(id, store.GetSettings())
+	restartRunningNode() // The container name includes the node ID
+	return id, nil
+}
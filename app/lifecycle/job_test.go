@@ -0,0 +1,39 @@
+//go:build windows && unit_test
+
+package lifecycle
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestHelperJob(t *testing.T) {
+	job, err := getHelperJob()
+	if err != nil {
+		t.Fatalf("failed to create helper job: %v", err)
+	}
+	if again, _ := getHelperJob(); again != job {
+		t.Error("expected the helper job to be created once")
+	}
+
+	cmd := exec.Command("cmd.exe", "/c", "ping -n 3 127.0.0.1 >nul")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Wait()
+	if err := assignToHelperJob(cmd.Process.Pid); err != nil {
+		t.Fatalf("failed to assign process to job: %v", err)
+	}
+}
+
+func TestRunHelperResumesSuspendedProcess(t *testing.T) {
+	// Helpers are created suspended, a helper that is never resumed would
+	// hang until the timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := runHelper(helperCommand(ctx, "cmd.exe", "/c", "exit 0")); err != nil {
+		t.Fatalf("helper failed: %v", err)
+	}
+}
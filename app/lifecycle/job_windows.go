@@ -0,0 +1,82 @@
+package lifecycle
+
+import (
+	"fmt"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// Helpers are assigned to a Job object that kills them when its last handle
+// closes. The handle is never closed explicitly, so Windows closes it when
+// the app exits for any reason, including a crash or being killed from Task
+// Manager, and the podman processes don't linger as orphaned consoles.
+// Helpers are created suspended and only resumed once they are in the job,
+// so the children a helper starts join the job too.
+//
+// podman machine start is the exception. The machine's host processes it
+// leaves running must outlive the app, so it is never assigned to the job.
+
+var (
+	helperJob     windows.Handle
+	helperJobErr  error
+	helperJobOnce sync.Once
+
+	ntdll            = windows.NewLazySystemDLL("ntdll.dll")
+	pNtResumeProcess = ntdll.NewProc("NtResumeProcess")
+)
+
+// getHelperJob returns the kill-on-close job, creating it on first use.
+func getHelperJob() (windows.Handle, error) {
+	helperJobOnce.Do(func() {
+		helperJob, helperJobErr = createKillOnCloseJob()
+	})
+	return helperJob, helperJobErr
+}
+
+func createKillOnCloseJob() (windows.Handle, error) {
+	job, err := windows.CreateJobObject(nil, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create job object: %w", err)
+	}
+	info := windows.JOBOBJECT_EXTENDED_LIMIT_INFORMATION{
+		BasicLimitInformation: windows.JOBOBJECT_BASIC_LIMIT_INFORMATION{
+			LimitFlags: windows.JOB_OBJECT_LIMIT_KILL_ON_JOB_CLOSE,
+		},
+	}
+	if _, err := windows.SetInformationJobObject(job, windows.JobObjectExtendedLimitInformation,
+		uintptr(unsafe.Pointer(&info)), uint32(unsafe.Sizeof(info))); err != nil {
+		windows.CloseHandle(job)
+		return 0, fmt.Errorf("failed to configure job object: %w", err)
+	}
+	return job, nil
+}
+
+// assignToHelperJob adds a started process to the helper job. A helper that
+// already exited can't be assigned, which is harmless.
+func assignToHelperJob(pid int) error {
+	job, err := getHelperJob()
+	if err != nil {
+		return err
+	}
+	process, err := windows.OpenProcess(windows.PROCESS_SET_QUOTA|windows.PROCESS_TERMINATE, false, uint32(pid))
+	if err != nil {
+		return err
+	}
+	defer windows.CloseHandle(process)
+	return windows.AssignProcessToJobObject(job, process)
+}
+
+// resumeProcess resumes a process created with CREATE_SUSPENDED.
+func resumeProcess(pid int) error {
+	process, err := windows.OpenProcess(windows.PROCESS_SUSPEND_RESUME, false, uint32(pid))
+	if err != nil {
+		return err
+	}
+	defer windows.CloseHandle(process)
+	if status, _, _ := pNtResumeProcess.Call(uintptr(process)); status != 0 {
+		return windows.NTStatus(status)
+	}
+	return nil
+}
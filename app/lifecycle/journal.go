@@ -0,0 +1,119 @@
+package lifecycle
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// The journal records every state change of the node, one JSON object per
+// line in journal.jsonl next to the store. When it grows past
+// journalMaxSize it is moved to journal-1.jsonl, replacing the previous one,
+// so about two files worth of events are kept.
+
+const (
+	journalFileName    = "journal.jsonl"
+	journalOldFileName = "journal-1.jsonl"
+	journalMaxSize     = 1 << 20
+)
+
+// JournalEvent is a state change of the node.
+type JournalEvent struct {
+	Time      time.Time `json:"time"`
+	State     string    `json:"state"`
+	Previous  string    `json:"previous"`
+	Operation string    `json:"operation,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+var journalMu sync.Mutex
+
+func journalPaths() (current, old string) {
+	return filepath.Join(AppDataDir, journalFileName), filepath.Join(AppDataDir, journalOldFileName)
+}
+
+// recordJournalEvent appends a state change to the journal.
+func recordJournalEvent(previous, state AppState, stateErr error, now time.Time) {
+	if localDataWiped.Load() {
+		return
+	}
+	event := JournalEvent{
+		Time:      now.UTC(),
+		State:     hookStateName(state),
+		Previous:  hookStateName(previous),
+		Operation: operationID(),
+	}
+	if stateErr != nil {
+		event.Error = stateErr.Error()
+	}
+	line, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+
+	journalMu.Lock()
+	defer journalMu.Unlock()
+	current, old := journalPaths()
+	if info, err := os.Stat(current); err == nil && info.Size() >= journalMaxSize {
+		if err := os.Rename(current, old); err != nil {
+			slog.Warn("failed to rotate journal", "error", err)
+		}
+	}
+	f, err := os.OpenFile(current, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		slog.Warn("failed to open journal", "error", err)
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		slog.Warn("failed to write journal", "error", err)
+	}
+}
+
+// readJournal returns the journal events, oldest first. Lines that can't be
+// parsed, e.g. cut short by a crash, are skipped.
+func readJournal() ([]JournalEvent, error) {
+	journalMu.Lock()
+	defer journalMu.Unlock()
+	current, old := journalPaths()
+	var events []JournalEvent
+	for _, path := range []string{old, current} {
+		f, err := os.Open(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			var event JournalEvent
+			if json.Unmarshal(scanner.Bytes(), &event) == nil {
+				events = append(events, event)
+			}
+		}
+		err = scanner.Err()
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return events, nil
+}
+
+// removeJournal deletes the journal files.
+func removeJournal() error {
+	journalMu.Lock()
+	defer journalMu.Unlock()
+	current, old := journalPaths()
+	for _, path := range []string{current, old} {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
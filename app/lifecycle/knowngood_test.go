@@ -0,0 +1,39 @@
+//go:build windows && unit_test
+
+package lifecycle
+
+import (
+	"os"
+	"testing"
+)
+
+func TestRestoreKnownGoodConfig(t *testing.T) {
+	t.Setenv("LOCALAPPDATA", t.TempDir())
+	configFile, err := configFilePath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	good := `{"container_name": "reai", "container_image": "node:1", "model_name": "org/model"}`
+	if err := os.WriteFile(configFile, []byte(good), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	saveKnownGoodSettings()
+	if _, err := knownGoodConfigTime(); err != nil {
+		t.Fatalf("expected a known good config, got %v", err)
+	}
+
+	broken := `{"container_name": "reai",`
+	if err := os.WriteFile(configFile, []byte(broken), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	saveKnownGoodSettings() // An invalid config isn't kept
+	if err := restoreKnownGoodConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(configFile); err != nil || string(data) != good {
+		t.Errorf("expected the known good config to be restored, got %q, %v", data, err)
+	}
+	if data, err := os.ReadFile(configFile + ".damaged"); err != nil || string(data) != broken {
+		t.Errorf("expected the invalid config to be kept aside, got %q, %v", data, err)
+	}
+}
@@ -0,0 +1,145 @@
+package lifecycle
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/ReEnvision-AI/systray/app/store"
+	"github.com/ReEnvision-AI/systray/app/tray/commontray"
+)
+
+// Copies of the store and config.json are kept from the last time the node
+// ran, as known good settings. When either fails to parse, e.g. after a bad
+// manual edit or the app crashing while saving, the user is offered to
+// restore them, instead of the node running with a new ID and losing its
+// contribution history, or not starting at all.
+
+const settingsRestoreClickTime = 30 * time.Minute
+
+// errConfigInvalid marks config.json failing to parse or validate.
+var errConfigInvalid = errors.New("config.json is invalid")
+
+// saveKnownGoodSettings keeps the store and config.json, once the node runs
+// with them.
+func saveKnownGoodSettings() {
+	if err := store.SaveKnownGood(); err != nil {
+		slog.Warn("Failed to keep known good store", "error", err)
+	}
+	configFile, err := configFilePath()
+	if err != nil {
+		return
+	}
+	if _, err := readConfigFile(configFile); err != nil {
+		return // Changed since the node started
+	}
+	data, err := os.ReadFile(configFile)
+	if err != nil {
+		slog.Warn("Failed to keep known good config", "error", err)
+		return
+	}
+	tmp := configFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		os.Remove(tmp)
+		slog.Warn("Failed to keep known good config", "error", err)
+		return
+	}
+	if err := os.Rename(tmp, configFile+store.KnownGoodSuffix); err != nil {
+		os.Remove(tmp)
+		slog.Warn("Failed to keep known good config", "error", err)
+	}
+}
+
+// restoreKnownGoodConfig replaces config.json with its known good copy,
+// keeping the invalid one aside.
+func restoreKnownGoodConfig() error {
+	configFile, err := configFilePath()
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(configFile + store.KnownGoodSuffix)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(configFile, configFile+store.DamagedSuffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return os.WriteFile(configFile, data, 0o644)
+}
+
+// knownGoodConfigTime returns when the known good copy of config.json was
+// saved.
+func knownGoodConfigTime() (time.Time, error) {
+	configFile, err := configFilePath()
+	if err != nil {
+		return time.Time{}, err
+	}
+	info, err := os.Stat(configFile + store.KnownGoodSuffix)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return info.ModTime(), nil
+}
+
+// checkKnownGoodStore offers to restore the known good store if the store
+// failed to parse when the app started.
+func checkKnownGoodStore() {
+	if !store.Damaged() {
+		return
+	}
+	id, saved, err := store.KnownGoodID()
+	if err != nil {
+		slog.Warn("Store was damaged and no known good copy can be restored", "error", err)
+		return
+	}
+	slog.Warn("Store was damaged, running with a new node ID", "id", store.GetID(), "known_good_id", id)
+	go offerSettingsRestore("Your settings couldn't be read",
+		fmt.Sprintf("The node now runs with a new ID. Click here to restore the settings from %s and keep the node's contribution history", saved.Format(time.DateTime)),
+		func() error {
+			if err := store.RestoreKnownGood(); err != nil {
+				return err
+			}
+			restartRunningNode() // The container name includes the node ID
+			return nil
+		})
+}
+
+// offerConfigRestore offers to restore the known good config.json after the
+// node failed to start as config.json is invalid. Returns false if there is
+// no copy to restore.
+func offerConfigRestore() bool {
+	saved, err := knownGoodConfigTime()
+	if err != nil {
+		return false
+	}
+	go offerSettingsRestore("ReEnvision AI failed to start: config.json is invalid",
+		fmt.Sprintf("Click here to restore the settings from %s and start the node", saved.Format(time.DateTime)),
+		func() error {
+			if err := restoreKnownGoodConfig(); err != nil {
+				return err
+			}
+			handleStartRequest()
+			return nil
+		})
+	return true
+}
+
+// offerSettingsRestore notifies that settings failed to parse and calls
+// restore if the notification is clicked.
+func offerSettingsRestore(title, message string, restore func() error) {
+	click := make(chan struct{}, 1)
+	if !notifyAction(commontray.NotifyError, title, message, click) {
+		return
+	}
+	select {
+	case <-click:
+		slog.Info("Restoring previous settings")
+		if err := restore(); err != nil {
+			slog.Error("Failed to restore previous settings", "error", err)
+			notify(commontray.NotifyError, "Unable to restore previous settings", "Open the logs from the tray menu for details")
+		}
+	case <-time.After(settingsRestoreClickTime):
+	}
+}
@@ -2,10 +2,12 @@ package lifecycle
 
 import (
 	"context"
+	"errors"
 	"log"
 	"log/slog"
 	"os"
 	"os/signal"
+	"slices"
 	"sync"
 	"syscall"
 	"time"
@@ -14,6 +16,7 @@ import (
 	"github.com/ReEnvision-AI/systray/app/store"
 	"github.com/ReEnvision-AI/systray/app/tray"
 	"github.com/ReEnvision-AI/systray/app/tray/commontray"
+	"github.com/ReEnvision-AI/systray/version"
 )
 
 type AppState int
@@ -25,6 +28,17 @@ const (
 	StateStopping
 	StateThankyou
 	StateError
+	StateLoading // The container runs but the server isn't ready yet
+	StatePaused  // The container is frozen with `podman pause`
+)
+
+// AutostartFlag is passed by the Startup folder shortcut created by the installer,
+// and by the Run entry added when the user turns Start with Windows back on
+const AutostartFlag = "--autostart"
+
+const (
+	statusRefreshInterval = 30 * time.Second       // Uptime and contribution shown in the tray
+	restartPollInterval   = 100 * time.Millisecond // While a restart waits for a cancelled start
 )
 
 var (
@@ -32,6 +46,15 @@ var (
 	stateMu      sync.Mutex
 	t            commontray.ReaiTray
 
+	// Start cancellation, both guarded by stateMu
+	startCancel context.CancelFunc // Cancels the in-flight start request, nil when not starting
+	stopQueued  bool               // A stop was requested while the container was starting
+	restartMu   sync.Mutex         // Held while the node restarts, so restarts don't interleave
+
+	runningSince time.Time // When the container last entered StateRunning, guarded by stateMu
+	lastError    error     // Why the app entered StateError, for the on_error hook, guarded by stateMu
+	statusText   string    // The status text the tray shows, guarded by stateMu
+
 	// Sleep/resume state tracking
 	wasRunningBeforeSleep bool
 	sleepStateMu          sync.Mutex
@@ -55,25 +78,45 @@ func (s AppState) String() string {
 		return "Please restart ReEnvision AI"
 	case StateThankyou:
 		return "Thank you!"
+	case StateLoading:
+		return "Loading model..."
+	case StatePaused:
+		return "Paused"
 	default:
 		return "Unknown"
 	}
 }
 
 func Run() {
+	prepareDataDir()
 	InitLogging()
-	slog.Info("ReEnvision AI app starting")
+	slog.Info("ReEnvision AI app starting", "version", version.Version, "commit", version.Commit, "build_date", version.BuildDate, "channel", version.Channel)
+	logDataDir()
+	if uninstalling() {
+		handleUninstall()
+		CloseLogging()
+		return
+	}
+	if autostartDisabled() {
+		slog.Info("Launched at login with autostart turned off, exiting")
+		CloseLogging()
+		return
+	}
+	loadBackendConfig()
 
 	updaterCtx, updaterCancel := context.WithCancel(context.Background())
 	var updaterDone chan int
 
 	var err error
-	t, err = tray.NewTray()
+	t, err = tray.NewTray(commontray.Options{AdvancedSubmenu: store.GetAdvancedSubmenu()})
 	if err != nil {
 		log.Fatalf("Failed to start: %s", err)
 	}
 
 	callbacks := t.GetCallbacks()
+	watchDiskFull()
+	checkKnownGoodStore()
+	go checkNodeIdentity()
 
 	// Initialize sleep detection
 	sleepChan, wakeChan, err = power.StartSleepDetection()
@@ -99,17 +142,81 @@ func Run() {
 				err := DoUpgrade(updaterCancel, updaterDone)
 				if err != nil {
 					slog.Warn("upgrade attempt failed", "error", err)
+					checkAntivirus(err)
 				}
+			case <-callbacks.ReleaseNotes:
+				go handleShowReleaseNotes(callbacks.Update)
 			case <-callbacks.ShowLogs:
 				ShowLogs()
+			case <-callbacks.ShowLiveLogs:
+				go handleShowLiveLogs()
+			case <-callbacks.CopyDiagnostics:
+				go handleCopyDiagnostics()
+			case <-callbacks.SupportBundle:
+				go handleSupportBundle()
 			case <-callbacks.StartContainer:
-				// Start the container
+				// Start the container. Run it in the background so a stop
+				// request can still be received while we are starting.
 				slog.Info("Starting container")
-				handleStartRequest()
+				resetCrashRestarts()
+				cancelTimedPause()
+				go handleStartRequest()
 			case <-callbacks.StopContainer:
 				// Stop the container
 				slog.Info("Stopping container")
+				cancelTimedPause()
 				handleStopRequest()
+			case <-callbacks.Restart:
+				// Starting again takes a while, don't block other callbacks
+				cancelTimedPause()
+				go handleRestartRequest()
+			case <-callbacks.Pause:
+				go handlePauseRequest()
+			case <-callbacks.Resume:
+				cancelTimedPause()
+				go handleResumeRequest()
+			case choice := <-callbacks.PauseFor:
+				go handlePauseFor(choice)
+			case <-callbacks.RecreateCache:
+				// Asks for confirmation, don't block other callbacks
+				go handleRecreateCacheVolume()
+			case <-callbacks.ToggleQuiet:
+				handleToggleQuietMode()
+			case <-callbacks.ToggleAutostart:
+				handleToggleAutostart()
+			case <-callbacks.ToggleAutoRun:
+				go handleToggleAutoStartContainer()
+			case <-callbacks.StartupNotice:
+				handleToggleStartupNotice()
+			case <-callbacks.GroupAdvanced:
+				handleToggleAdvancedSubmenu()
+			case <-callbacks.ToggleTelemetry:
+				handleToggleTelemetry()
+			case <-callbacks.ExportData:
+				// Prompts for sign in and downloads, don't block other callbacks
+				go handleExportData()
+			case <-callbacks.DeleteData:
+				go handleDeleteData()
+			case <-callbacks.ShowAPIToken:
+				go handleShowAPIToken()
+			case <-callbacks.ShowAbout:
+				go handleShowAbout()
+			case <-callbacks.SupportAccess:
+				go handleSupportAccess()
+			case <-callbacks.MoveNode:
+				go handleMoveNode()
+			case <-callbacks.ImportNode:
+				go handleImportNode()
+			case <-callbacks.JoinOrg:
+				go handleJoinOrg()
+			case <-callbacks.ExportStats:
+				go handleExportStats()
+			case <-callbacks.Settings:
+				go handleSettings()
+			case <-callbacks.ToggleAnonymous:
+				go handleToggleAnonymousMode()
+			case name := <-callbacks.SelectProfile:
+				go handleSelectProfile(name)
 			case <-callbacks.DoFirstUse:
 				err := GetStarted()
 				if err != nil {
@@ -125,8 +232,32 @@ func Run() {
 		}
 	}()
 
+	if err := t.SetQuietMode(store.GetQuietMode()); err != nil {
+		slog.Warn("failed to apply quiet mode to tray", "error", err)
+	}
+	if err := t.SetAutostart(store.GetAutostart()); err != nil {
+		slog.Warn("failed to apply autostart to tray", "error", err)
+	}
+	autoStart := autoStartContainer()
+	if err := t.SetAutoRun(autoStart); err != nil {
+		slog.Warn("failed to apply auto start of the node to tray", "error", err)
+	}
+	if err := t.SetStartupNotice(store.GetStartupNotice()); err != nil {
+		slog.Warn("failed to apply startup notification preference to tray", "error", err)
+	}
+	if err := t.SetAdvancedSubmenu(store.GetAdvancedSubmenu()); err != nil {
+		slog.Warn("failed to apply advanced submenu preference to tray", "error", err)
+	}
+	if err := t.SetTelemetryEnabled(store.GetTelemetryEnabled()); err != nil {
+		slog.Warn("failed to apply telemetry preference to tray", "error", err)
+	}
+	if err := t.SetAnonymousMode(store.GetAnonymousMode()); err != nil {
+		slog.Warn("failed to apply anonymous mode to tray", "error", err)
+	}
+	refreshProfiles()
+
 	// Are we first use?
-	if !store.GetFirstTimeRun() {
+	if !store.GetFirstTimeRun() && !store.GetQuietMode() {
 		slog.Debug("First time run")
 		err = t.DisplayFirstUseNotification()
 		if err != nil {
@@ -138,8 +269,33 @@ func Run() {
 	}
 
 	StartBackgroundUpdaterChecker(updaterCtx, t.UpdateAvailable)
-
-	handleStartRequest()
+	StartAnnouncementChecker(updaterCtx)
+	StartHeartbeat(updaterCtx)
+	StartGPUCheck(updaterCtx)
+	StartGPUUsage(updaterCtx)
+	StartHealthCheck(updaterCtx)
+	StartMetricsExport(updaterCtx)
+	StartHistory(updaterCtx)
+	StartMilestoneCheck(updaterCtx)
+	StartOrgSync(updaterCtx)
+	StartSessionRefresh(updaterCtx)
+	StartConfigWatch(updaterCtx)
+	StartNetworkWatch(updaterCtx)
+	StartImageUpdateCheck(updaterCtx)
+	StartStatusRefresh(updaterCtx)
+	StartControlAPI(updaterCtx, callbacks)
+	go checkTrayOverflow()
+
+	if launchedAtLogin() && store.GetStartupNotice() && autoStart {
+		showStartupNotice()
+	}
+	switch {
+	case resumeTimedPause():
+	case !autoStart:
+		slog.Info("Not starting the node, auto start is turned off")
+	default:
+		go handleStartRequest()
+	}
 
 	t.Run()
 
@@ -153,34 +309,295 @@ func Run() {
 	CloseLogging()
 }
 
+// statusInfo describes the node for the tray's status popup. runningSince is
+// reset whenever the container enters the running state.
+func statusInfo(state AppState) commontray.StatusInfo {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	if state != StateRunning {
+		runningSince = time.Time{}
+	} else if runningSince.IsZero() {
+		runningSince = time.Now()
+	}
+	return commontray.StatusInfo{
+		State:        state.String(),
+		RunningSince: runningSince,
+		Contributed:  contributedToday(time.Now()),
+		CanStart:     state == StateStopped || state == StateError,
+		CanStop:      state == StateStarting || state == StateLoading || state == StateRunning || state == StatePaused,
+		Anonymous:    anonymousMode(),
+		Throughput:   currentServerProgress().throughputText(),
+		Progress:     currentServerProgress().summary(),
+	}
+}
+
+// launchedAtLogin reports whether the app was started by the Startup folder
+// shortcut rather than by the user.
+func launchedAtLogin() bool {
+	return slices.Contains(os.Args[1:], AutostartFlag)
+}
+
+// trayTooltip returns the tray tooltip showing text as the status.
+func trayTooltip(text string) string {
+	if anonymousMode() {
+		text += " (anonymous, not credited)"
+	}
+	stateMu.Lock()
+	since := runningSince
+	stateMu.Unlock()
+	var uptime time.Duration
+	if !since.IsZero() {
+		uptime = time.Since(since)
+	}
+	if stats := contributionText(uptime, currentServerProgress()); stats != "" {
+		text += "\n" + stats
+	}
+	return commontray.Tooltip + ": " + text
+}
+
+// refreshTrayStatus shows the latest progress and contribution of the node
+// in the tray status, tooltip and status popup.
+func refreshTrayStatus() {
+	stateMu.Lock()
+	state, text := currentState, statusText
+	stateMu.Unlock()
+	t.ChangeStatusText(currentServerProgress().statusLine(text))
+	t.SetTooltip(trayTooltip(text))
+	t.SetStatusInfo(statusInfo(state))
+}
+
+// StartStatusRefresh refreshes the uptime and contribution the tray shows
+// while the node runs, until ctx is cancelled.
+func StartStatusRefresh(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(statusRefreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			stateMu.Lock()
+			running := currentState == StateRunning
+			stateMu.Unlock()
+			if running {
+				refreshTrayStatus()
+			}
+		}
+	}()
+}
+
+// setStatusText replaces the tray status text while the app is in state, or
+// restores the text of the state if text is empty.
+func setStatusText(state AppState, text string) {
+	if text == "" {
+		text = state.String()
+	}
+	stateMu.Lock()
+	current := currentState
+	if current == state {
+		statusText = text
+	}
+	stateMu.Unlock()
+	if current != state {
+		return
+	}
+	t.ChangeStatusText(currentServerProgress().statusLine(text))
+	t.SetTooltip(trayTooltip(text))
+}
+
 func SetState(newState AppState) {
 	stateMu.Lock()
-	currentState = newState
+	previous, stateErr := changeStateLocked(newState)
 	stateMu.Unlock()
-	t.ChangeStatusText(newState.String())
+	applyState(previous, newState, stateErr)
+}
+
+// changeStateLocked sets the state with stateMu held, returning the previous
+// state and the error of an error state. applyState has to follow once
+// stateMu is released.
+func changeStateLocked(newState AppState) (previous AppState, stateErr error) {
+	previous = currentState
+	currentState = newState
+	statusText = newState.String()
+	if newState == StateError {
+		stateErr = lastError
+	}
+	lastError = nil
+	return previous, stateErr
+}
+
+// applyState shows a state changed from previous in the tray, and records
+// and announces the change.
+func applyState(previous, newState AppState, stateErr error) {
+	if previous != newState {
+		gpuDegraded.Store(false)
+		recordStateMetrics(newState)
+		recordHistoryState(newState, time.Now())
+		recordJournalEvent(previous, newState, stateErr, time.Now())
+	}
+	t.ChangeStatusText(currentServerProgress().statusLine(newState.String()))
+	t.SetTooltip(trayTooltip(newState.String()))
+	t.SetStatusInfo(statusInfo(newState))
+	t.SetIconState(trayIconState(newState))
 
 	switch newState {
 	case StateStopping, StateStopped, StateError:
 		t.SetStopped()
-	case StateStarting, StateRunning:
+		t.ShowStartingBadge(false)
+	case StateStarting:
+		t.SetStarting()
+	case StateLoading:
+		t.SetStarted()
+	case StateRunning:
 		t.SetStarted()
+		t.ShowStartingBadge(false)
+	case StatePaused:
+		t.SetPaused()
+		t.ShowStartingBadge(false)
+	}
+
+	if previous != newState {
+		hooks := currentAppConfig().Hooks
+		if hook := hooks.command(newState); hook != "" {
+			go runHook(hook, hooks.timeout(), hookEnv(previous, newState, stateErr))
+		}
+		notifyStateChange(previous, newState, stateErr)
+	}
+}
+
+// trayIconState returns the tray icon showing state.
+func trayIconState(state AppState) commontray.IconState {
+	switch state {
+	case StateStarting, StateLoading, StateStopping:
+		return commontray.IconStarting
+	case StateRunning:
+		return commontray.IconRunning
+	case StateError:
+		return commontray.IconError
 	}
+	return commontray.IconStopped
+}
+
+// setErrorState moves to StateError, recording err for the on_error hook.
+func setErrorState(err error) {
+	stateMu.Lock()
+	lastError = err
+	stateMu.Unlock()
+	SetState(StateError)
 }
 
 func handleStartRequest() {
-	SetState(StateStarting)
+	if deferStartWhileOffline() {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	ctx := context.Background()
+	stateMu.Lock()
+	if currentState == StateStarting || currentState == StateLoading || currentState == StateRunning || currentState == StatePaused {
+		slog.Info("Container is already starting or running, ignoring start request", "state", currentState)
+		stateMu.Unlock()
+		return
+	}
+	if currentState == StateStopping {
+		// The stop would move to Stopped while the new container runs
+		slog.Info("Container is stopping, ignoring start request")
+		stateMu.Unlock()
+		return
+	}
+	startCancel = cancel
+	stopQueued = false
+	// Entering StateStarting in the same critical section as the check, so
+	// a second start request can't pass it meanwhile
+	previous, stateErr := changeStateLocked(StateStarting)
+	stateMu.Unlock()
+
+	beginOperation(opStart)
+	applyState(previous, StateStarting, stateErr)
 
 	err := StartContainer(ctx)
+
+	stateMu.Lock()
+	cancelled := stopQueued
+	startCancel = nil
+	stopQueued = false
+	stateMu.Unlock()
+
+	if cancelled {
+		slog.Info("Start was cancelled by a stop request")
+		if err == nil {
+			// The container may have been launched before the cancellation was noticed
+			stopCtx, stopCancel := context.WithTimeout(context.Background(), podmanStopTimeout)
+			defer stopCancel()
+			if err := StopContainer(stopCtx); err != nil {
+				slog.Warn("Failed to stop container after cancelled start", "error", err)
+			}
+		}
+		SetState(StateStopped)
+		return
+	}
+
+	if errors.Is(err, errDiskFull) {
+		slog.Info("Not starting while the disk is full")
+		SetState(StateStopped)
+		showDiskFull()
+		return
+	}
+	if errors.Is(err, errVPNConnected) {
+		slog.Info("Not starting while a VPN is connected", "error", err)
+		SetState(StateStopped)
+		notify(commontray.NotifyInfo, "Node paused", "A VPN is connected. The node starts once it disconnects")
+		return
+	}
 	if err != nil {
 		slog.Error("Failed to start container", "error", err)
-		SetState(StateError)
+		setErrorState(err)
+		continueCrashRestarts()
+		if errors.Is(err, errMeteredImageMissing) {
+			notify(commontray.NotifyError, "ReEnvision AI failed to start: metered connection",
+				"The node has to download its image first. Connect to an unmetered network, or set metered_policy to \"ignore\" in config.json")
+			return
+		}
+		if errors.Is(err, errConfigInvalid) && offerConfigRestore() {
+			return
+		}
+		if checkAntivirus(err) {
+			return
+		}
+		var podmanErr *PodmanError
+		if errors.As(err, &podmanErr) {
+			notify(commontray.NotifyError, "ReEnvision AI failed to start: "+podmanErr.Kind.Error(), podmanErr.Remedy)
+			return
+		}
+		notify(commontray.NotifyError, "ReEnvision AI failed to start", "Open the logs from the tray menu for details")
 		return
 	}
 }
 
+// cancelStartRequest records a stop request issued while the container is
+// starting and cancels the start context. The start request notices the
+// cancellation at its next safe point and transitions to Stopped.
+// Returns false if no start is in progress.
+func cancelStartRequest() bool {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	if currentState != StateStarting || startCancel == nil {
+		return false
+	}
+	slog.Info("Stop requested while starting, cancelling start")
+	stopQueued = true
+	startCancel()
+	return true
+}
+
 func handleStopRequest() {
+	if cancelStartRequest() {
+		return
+	}
+
+	beginOperation(opStop)
 	SetState(StateStopping)
 	ctx, cancel := context.WithTimeout(context.Background(), podmanStopTimeout)
 	defer cancel()
@@ -196,6 +613,54 @@ func handleStopRequest() {
 	}
 }
 
+// handleRestartRequest stops the node and starts it again, as asked from the
+// tray. A start in progress is cancelled and started over, and a stopped node
+// is started.
+func handleRestartRequest() {
+	slog.Info("Restarting container")
+	resetCrashRestarts()
+	restartNode()
+}
+
+// restartNode stops the node, waiting for a cancelled start to wind down,
+// and starts it again.
+func restartNode() {
+	restartMu.Lock()
+	defer restartMu.Unlock()
+
+	stateMu.Lock()
+	state := currentState
+	stateMu.Unlock()
+	switch state {
+	case StateStarting, StateLoading, StateRunning, StatePaused:
+		handleStopRequest()
+	}
+	// A cancelled start, or a stop from the tray, moves to Stopped by itself
+	if !awaitStopped(podmanStopTimeout) {
+		slog.Warn("Node didn't stop in time, not starting it again")
+		return
+	}
+	handleStartRequest()
+}
+
+// awaitStopped waits up to timeout for the node to be neither starting nor
+// stopping. Reports whether it is.
+func awaitStopped(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		stateMu.Lock()
+		state := currentState
+		stateMu.Unlock()
+		if state != StateStarting && state != StateStopping {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(restartPollInterval)
+	}
+}
+
 func handleQuit() {
 	slog.Info("Quitting..")
 
@@ -207,8 +672,13 @@ func handleQuit() {
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), podmanStopTimeout+5*time.Second) // Give a bit extra time
 	defer cancel()
 
+	// A start in progress could still launch the container after the stop
+	if cancelStartRequest() && !awaitStopped(podmanStopTimeout) {
+		slog.Warn("Cancelled start didn't finish in time, stopping the container anyway")
+	}
+
 	stateMu.Lock()
-	shouldStop := currentState == StateRunning || currentState == StateStarting
+	shouldStop := currentState == StateRunning || currentState == StateLoading || currentState == StateStarting || currentState == StatePaused
 	stateMu.Unlock()
 
 	if shouldStop {
@@ -219,6 +689,9 @@ func handleQuit() {
 			slog.Error("Error during shutdown stop", "error", err)
 		}
 	}
+	if killed := helperProcs.KillAll(); killed > 0 {
+		slog.Warn("Killed helper processes still running", "count", killed)
+	}
 
 	t.Quit()
 
@@ -250,7 +723,7 @@ func handleSleepEvent() {
 
 	// Check if container is currently running
 	stateMu.Lock()
-	containerIsRunning := currentState == StateRunning
+	containerIsRunning := currentState == StateRunning || currentState == StateLoading
 	stateMu.Unlock()
 
 	if containerIsRunning {
@@ -281,26 +754,37 @@ func handleWakeEvent() {
 	if wasRunningBeforeSleep {
 		slog.Info("Container was running before sleep, attempting to restart")
 
-		// Check current state first
-		stateMu.Lock()
-		currentStateValue := currentState
-		stateMu.Unlock()
-
-		// Always restart the container if it was running before sleep, as the process
+		// Restart the container if it was running before sleep, as the process
 		// might be in an inconsistent state after sleep
-		slog.Info("Restarting container after sleep", "previous_state", currentStateValue)
+		policy := currentAppConfig().WakeRestart
+		slog.Info("Restarting container after sleep", "policy", policy)
 		go func() {
-			// Add a small delay to ensure system is fully awake
-			time.Sleep(3 * time.Second)
+			if !confirmWakeRestart(policy) {
+				slog.Info("Not restarting container after sleep", "policy", policy)
+				return
+			}
+			// Wait until the network and Podman resumed
+			awaitWakeReady(context.Background())
+
+			shutdownMu.Lock()
+			shuttingDown := isShuttingDown
+			shutdownMu.Unlock()
+			if shuttingDown {
+				return
+			}
 
 			// Force stop first if the container appears to be running
-			if currentStateValue == StateRunning || currentStateValue == StateStarting {
+			stateMu.Lock()
+			currentStateValue := currentState
+			stateMu.Unlock()
+			if currentStateValue == StateRunning || currentStateValue == StateLoading || currentStateValue == StateStarting {
 				slog.Info("Stopping potentially inconsistent container before restart")
 				handleStopRequest()
 				// Give it a moment to stop
 				time.Sleep(2 * time.Second)
 			}
 
+			refreshSessionAfterWake()
 			slog.Info("Starting container after sleep")
 			handleStartRequest()
 		}()
@@ -309,5 +793,6 @@ func handleWakeEvent() {
 		wasRunningBeforeSleep = false
 	} else {
 		slog.Info("Container was not running before sleep, no restart needed")
+		go refreshSessionAfterWake()
 	}
 }
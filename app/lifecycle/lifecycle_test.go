@@ -3,6 +3,7 @@
 package lifecycle
 
 import (
+	"context"
 	"sync"
 	"testing"
 	"time"
@@ -12,9 +13,12 @@ import (
 
 // Mock tray implementation for testing
 type mockTray struct {
-	statusText string
-	started    bool
-	callbacks  commontray.Callbacks
+	statusText    string
+	tooltip       string
+	started       bool
+	callbacks     commontray.Callbacks
+	notifications []string // Titles of the notifications shown
+	iconState     commontray.IconState
 }
 
 func (m *mockTray) Run()                               {}
@@ -31,15 +35,73 @@ func (m *mockTray) SetStarted() error   { m.started = true; return nil }
 func (m *mockTray) SetStopped() error   { m.started = false; return nil }
 func (m *mockTray) DisplayFirstUseNotification() error { return nil }
 
+func (m *mockTray) SetTooltip(text string) error {
+	m.tooltip = text
+	return nil
+}
+func (m *mockTray) SetStatusInfo(info commontray.StatusInfo) error { return nil }
+func (m *mockTray) SetStarting() error                             { m.started = true; return nil }
+func (m *mockTray) ShowStartingBadge(show bool) error              { return nil }
+func (m *mockTray) SetIconState(state commontray.IconState) error  { m.iconState = state; return nil }
+func (m *mockTray) SetPaused() error                               { return nil }
+func (m *mockTray) DisplayNotification(title, message string, level commontray.NotificationLevel) error {
+	return nil
+}
+func (m *mockTray) DisplayActionNotification(title, message string, level commontray.NotificationLevel, action chan struct{}) error {
+	m.notifications = append(m.notifications, title)
+	return nil
+}
+func (m *mockTray) IconHidden() (bool, error)                       { return false, nil }
+func (m *mockTray) CanPinIcon() bool                                { return false }
+func (m *mockTray) PinIcon() error                                  { return nil }
+func (m *mockTray) SetQuietMode(quiet bool) error                   { return nil }
+func (m *mockTray) SetTelemetryEnabled(enabled bool) error          { return nil }
+func (m *mockTray) SetAnonymousMode(anonymous bool) error           { return nil }
+func (m *mockTray) SetAutostart(enabled bool) error                 { return nil }
+func (m *mockTray) SetAutoRun(enabled bool) error                   { return nil }
+func (m *mockTray) SetStartupNotice(enabled bool) error             { return nil }
+func (m *mockTray) SetAdvancedSubmenu(enabled bool) error           { return nil }
+func (m *mockTray) SetProfiles(names []string, active string) error { return nil }
+func (m *mockTray) SetSupportAccess(remaining time.Duration) error  { return nil }
+func (m *mockTray) SetAnnouncement(text string) error               { return nil }
+func (m *mockTray) SetGPUStatus(text string) error                  { return nil }
+
 func setupMockTray() *mockTray {
 	mt := &mockTray{
 		callbacks: commontray.Callbacks{
-			Quit:           make(chan struct{}, 1),
-			Update:         make(chan struct{}, 1),
-			DoFirstUse:     make(chan struct{}, 1),
-			ShowLogs:       make(chan struct{}, 1),
-			StartContainer: make(chan struct{}, 1),
-			StopContainer:  make(chan struct{}, 1),
+			Quit:            make(chan struct{}, 1),
+			Update:          make(chan struct{}, 1),
+			ReleaseNotes:    make(chan struct{}, 1),
+			DoFirstUse:      make(chan struct{}, 1),
+			ShowLogs:        make(chan struct{}, 1),
+			ShowLiveLogs:    make(chan struct{}, 1),
+			CopyDiagnostics: make(chan struct{}, 1),
+			SupportBundle:   make(chan struct{}, 1),
+			StartContainer:  make(chan struct{}, 1),
+			StopContainer:   make(chan struct{}, 1),
+			Restart:         make(chan struct{}, 1),
+			Pause:           make(chan struct{}, 1),
+			Resume:          make(chan struct{}, 1),
+			PauseFor:        make(chan string, 1),
+			ToggleQuiet:     make(chan struct{}, 1),
+			ToggleAutostart: make(chan struct{}, 1),
+			ToggleAutoRun:   make(chan struct{}, 1),
+			StartupNotice:   make(chan struct{}, 1),
+			GroupAdvanced:   make(chan struct{}, 1),
+			RecreateCache:   make(chan struct{}, 1),
+			ToggleTelemetry: make(chan struct{}, 1),
+			ExportData:      make(chan struct{}, 1),
+			DeleteData:      make(chan struct{}, 1),
+			ShowAPIToken:    make(chan struct{}, 1),
+			ShowAbout:       make(chan struct{}, 1),
+			SupportAccess:   make(chan struct{}, 1),
+			MoveNode:        make(chan struct{}, 1),
+			ImportNode:      make(chan struct{}, 1),
+			JoinOrg:         make(chan struct{}, 1),
+			ExportStats:     make(chan struct{}, 1),
+			Settings:        make(chan struct{}, 1),
+			ToggleAnonymous: make(chan struct{}, 1),
+			SelectProfile:   make(chan string, 1),
 		},
 	}
 	t = mt // Set the global tray variable
@@ -57,19 +119,22 @@ func resetState() {
 }
 
 func TestSetState(t *testing.T) {
-	setupMockTray()
+	mt := setupMockTray()
 	defer resetState()
 
 	tests := []struct {
 		state    AppState
 		expected string
+		icon     commontray.IconState
 	}{
-		{StateStopped, "Stopped"},
-		{StateStarting, "Starting..."},
-		{StateRunning, "Running"},
-		{StateStopping, "Stopping..."},
-		{StateError, "Please restart ReEnvision AI"},
-		{StateThankyou, "Thank you!"},
+		{StateStopped, "Stopped", commontray.IconStopped},
+		{StateStarting, "Starting...", commontray.IconStarting},
+		{StateLoading, "Loading model...", commontray.IconStarting},
+		{StateRunning, "Running", commontray.IconRunning},
+		{StateStopping, "Stopping...", commontray.IconStarting},
+		{StatePaused, "Paused", commontray.IconStopped},
+		{StateError, "Please restart ReEnvision AI", commontray.IconError},
+		{StateThankyou, "Thank you!", commontray.IconStopped},
 	}
 
 	for _, test := range tests {
@@ -80,6 +145,9 @@ func TestSetState(t *testing.T) {
 			t.Errorf("Expected state %d, got %d", test.state, currentState)
 		}
 		stateMu.Unlock()
+		if mt.iconState != test.icon {
+			t.Errorf("Expected icon %d for state %d, got %d", test.icon, test.state, mt.iconState)
+		}
 
 		// Check if tray status text was updated
 		// Note: mockTray implementation would need to be enhanced to verify this
@@ -193,6 +261,79 @@ func TestHandleWakeEventInInvalidStates(testT *testing.T) {
 	}
 }
 
+func TestCancelStartRequest(t *testing.T) {
+	setupMockTray()
+	defer resetState()
+
+	// Nothing to cancel when stopped
+	SetState(StateStopped)
+	if cancelStartRequest() {
+		t.Error("Expected cancelStartRequest to return false when not starting")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stateMu.Lock()
+	startCancel = cancel
+	stateMu.Unlock()
+	SetState(StateStarting)
+
+	if !cancelStartRequest() {
+		t.Fatal("Expected cancelStartRequest to return true while starting")
+	}
+	if ctx.Err() == nil {
+		t.Error("Expected start context to be cancelled")
+	}
+
+	stateMu.Lock()
+	if !stopQueued {
+		t.Error("Expected stop to be queued")
+	}
+	startCancel = nil
+	stopQueued = false
+	stateMu.Unlock()
+}
+
+func TestStartWhileStopping(t *testing.T) {
+	setupMockTray()
+	defer resetState()
+
+	SetState(StateRunning)
+	SetState(StateStopping)
+	handleStartRequest()
+	stateMu.Lock()
+	state := currentState
+	stateMu.Unlock()
+	if state != StateStopping {
+		t.Errorf("Expected the start to be ignored while stopping, got %v", state)
+	}
+}
+
+func TestAwaitStopped(t *testing.T) {
+	setupMockTray()
+	defer resetState()
+
+	SetState(StateStopped)
+	if !awaitStopped(0) {
+		t.Error("Expected a stopped node to count as stopped")
+	}
+
+	// A cancelled start moves to Stopped once it notices
+	SetState(StateStarting)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		SetState(StateStopped)
+	}()
+	if !awaitStopped(5 * time.Second) {
+		t.Error("Expected awaitStopped to return once the start was cancelled")
+	}
+
+	SetState(StateStopping)
+	if awaitStopped(50 * time.Millisecond) {
+		t.Error("Expected awaitStopped to time out while stopping")
+	}
+}
+
 func TestConcurrentSleepWakeEvents(t *testing.T) {
 	setupMockTray()
 	defer resetState()
@@ -272,6 +413,8 @@ func TestAppStateString(t *testing.T) {
 		{StateStopping, "Stopping..."},
 		{StateError, "Please restart ReEnvision AI"},
 		{StateThankyou, "Thank you!"},
+		{StateLoading, "Loading model..."},
+		{StatePaused, "Paused"},
 		{AppState(999), "Unknown"}, // Test unknown state
 	}
 
@@ -283,6 +426,31 @@ func TestAppStateString(t *testing.T) {
 	}
 }
 
+func TestSetStatusText(t *testing.T) {
+	mt := setupMockTray()
+	defer resetState()
+
+	SetState(StateRunning)
+	setStatusText(StateRunning, gpuDegradedText)
+	if mt.statusText != gpuDegradedText {
+		t.Errorf("Expected status %q, got %q", gpuDegradedText, mt.statusText)
+	}
+	setStatusText(StateStarting, "Installing")
+	if mt.statusText != gpuDegradedText {
+		t.Errorf("Expected text for another state to be ignored, got %q", mt.statusText)
+	}
+	setStatusText(StateRunning, "")
+	if mt.statusText != "Running" {
+		t.Errorf("Expected the state text to be restored, got %q", mt.statusText)
+	}
+
+	gpuDegraded.Store(true)
+	SetState(StateStopped)
+	if gpuDegraded.Load() {
+		t.Error("Expected a state change to clear the GPU degraded flag")
+	}
+}
+
 func TestPowerManagementIntegration(t *testing.T) {
 	setupMockTray()
 	defer resetState()
@@ -356,4 +524,4 @@ func BenchmarkHandleWakeEvent(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		go handleWakeEvent()
 	}
-}
\ No newline at end of file
+}
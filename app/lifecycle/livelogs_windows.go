@@ -0,0 +1,259 @@
+package lifecycle
+
+import (
+	"log/slog"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// The live log viewer shows the latest container output from containerLog,
+// or the app's own log from recentLog, adding lines as they are printed.
+// Lines can be filtered by level and searched for, and the full log is a
+// click away.
+
+// Control IDs of the live log viewer
+const (
+	liveLogLevelID  = 301
+	liveLogSearchID = 302
+	liveLogTextID   = 303
+	liveLogOpenID   = 304
+	liveLogSourceID = 305
+)
+
+const (
+	liveLogTimerID       = 1
+	liveLogRefreshMillis = 500
+	maxLogSearchLength   = 256
+)
+
+// Window styles and messages of the live log viewer's controls
+const (
+	WS_VSCROLL       = 0x00200000
+	ES_MULTILINE     = 0x4
+	ES_AUTOVSCROLL   = 0x40
+	ES_READONLY      = 0x800
+	CBS_DROPDOWNLIST = 0x3
+	WM_TIMER         = 0x0113
+	EM_SETSEL        = 0x00B1
+	EM_SCROLLCARET   = 0x00B7
+	EM_REPLACESEL    = 0x00C2
+	EM_SETLIMITTEXT  = 0x00C5
+	EN_CHANGE        = 0x0300
+	CB_ADDSTRING     = 0x0143
+	CB_GETCURSEL     = 0x0147
+	CB_SETCURSEL     = 0x014E
+	CBN_SELCHANGE    = 1
+)
+
+const (
+	liveLogEmptyText   = "The node hasn't printed anything yet."
+	liveLogNoMatchText = "No lines match the filter."
+)
+
+// liveLogSources are the logs the viewer shows, in the order of the combo
+// box.
+var liveLogSources = []struct {
+	title string
+	log   *lineRing
+}{
+	{"Node output", containerLog},
+	{"App log", recentLog},
+}
+
+// liveLogLevels are the choices of the level filter, in the order of the
+// combo box.
+var liveLogLevels = []struct {
+	title string
+	level logLevel
+}{
+	{"All lines", levelDebug},
+	{"Info and above", levelInfo},
+	{"Warnings and errors", levelWarning},
+	{"Errors only", levelError},
+}
+
+var (
+	pSetTimer             = user32.NewProc("SetTimer")
+	pKillTimer            = user32.NewProc("KillTimer")
+	pGetWindowTextLength  = user32.NewProc("GetWindowTextLengthW")
+	liveLogDialogMu       sync.Mutex
+	activeLiveLogDialog   *liveLogDialog // The open viewer, guarded by liveLogDialogMu
+	liveLogDialogCallback = sync.OnceValue(func() uintptr { return windows.NewCallback(liveLogDialogProc) })
+)
+
+type liveLogDialog struct {
+	source *lineRing
+	filter logFilter
+	next   uint64 // Seq of the first line not shown yet
+	shown  int    // Lines in the text box
+}
+
+// handleShowLiveLogs shows the live log viewer until the user closes it.
+func handleShowLiveLogs() {
+	if !liveLogDialogMu.TryLock() {
+		return // Already open
+	}
+	defer liveLogDialogMu.Unlock()
+
+	activeLiveLogDialog = &liveLogDialog{source: containerLog}
+	defer func() { activeLiveLogDialog = nil }()
+	if err := runDialog(liveLogDialogTemplate(), liveLogDialogCallback()); err != nil {
+		slog.Warn("failed to show live logs", "error", err)
+	}
+}
+
+func liveLogDialogTemplate() []uint16 {
+	return buildDialogTemplate("ReEnvision AI live logs", 420, 270, []dialogItem{
+		{class: dialogStatic, style: dialogChild, x: 7, y: 9, cx: 22, cy: 9, id: dialogLabelID, title: "Sho&w:"},
+		{class: dialogComboBox, style: dialogChild | WS_TABSTOP | WS_VSCROLL | CBS_DROPDOWNLIST, x: 30, y: 7, cx: 70, cy: 60, id: liveLogSourceID},
+		{class: dialogStatic, style: dialogChild, x: 108, y: 9, cx: 24, cy: 9, id: dialogLabelID, title: "&Level:"},
+		{class: dialogComboBox, style: dialogChild | WS_TABSTOP | WS_VSCROLL | CBS_DROPDOWNLIST, x: 133, y: 7, cx: 90, cy: 80, id: liveLogLevelID},
+		{class: dialogStatic, style: dialogChild, x: 231, y: 9, cx: 30, cy: 9, id: dialogLabelID, title: "&Search:"},
+		{class: dialogEdit, style: dialogChild | WS_TABSTOP | ES_AUTOHSCROLL, exStyle: WS_EX_CLIENTEDGE, x: 262, y: 7, cx: 151, cy: 13, id: liveLogSearchID},
+		{class: dialogEdit, style: dialogChild | WS_TABSTOP | WS_VSCROLL | ES_MULTILINE | ES_AUTOVSCROLL | ES_READONLY, exStyle: WS_EX_CLIENTEDGE, x: 7, y: 26, cx: 406, cy: 220, id: liveLogTextID},
+		{class: dialogButton, style: dialogChild | WS_TABSTOP, x: 7, y: 251, cx: 70, cy: 14, id: liveLogOpenID, title: "&Open log folder"},
+		{class: dialogButton, style: dialogChild | WS_TABSTOP | BS_DEFPUSHBUTTON, x: 363, y: 251, cx: 50, cy: 14, id: IDCANCEL, title: "Close"},
+	})
+}
+
+func liveLogDialogProc(hwnd, msg, wParam, lParam uintptr) uintptr {
+	dlg := activeLiveLogDialog
+	if dlg == nil {
+		return dialogDefault
+	}
+
+	switch msg {
+	case WM_INITDIALOG:
+		sources := make([]string, len(liveLogSources))
+		for i, source := range liveLogSources {
+			sources[i] = source.title
+		}
+		fillComboBox(dlgItem(hwnd, liveLogSourceID), sources)
+		levels := make([]string, len(liveLogLevels))
+		for i, choice := range liveLogLevels {
+			levels[i] = choice.title
+		}
+		fillComboBox(dlgItem(hwnd, liveLogLevelID), levels)
+		// No limit to the text instead of 32K characters
+		pSendMessage.Call(dlgItem(hwnd, liveLogTextID), EM_SETLIMITTEXT, 0, 0) //nolint:errcheck
+		dlg.update(hwnd, true)
+		pSetTimer.Call(hwnd, liveLogTimerID, liveLogRefreshMillis, 0) //nolint:errcheck
+
+		return 1 // Focus the first control
+
+	case WM_TIMER:
+		dlg.update(hwnd, false)
+		return dialogHandled
+
+	case WM_COMMAND:
+		switch id, code := uint16(wParam), wParam>>16&0xFFFF; {
+		case id == liveLogSourceID && code == CBN_SELCHANGE:
+			i, _, _ := pSendMessage.Call(dlgItem(hwnd, liveLogSourceID), CB_GETCURSEL, 0, 0)
+			if int(i) >= 0 && int(i) < len(liveLogSources) {
+				dlg.source = liveLogSources[i].log
+				dlg.update(hwnd, true)
+			}
+		case id == liveLogLevelID && code == CBN_SELCHANGE:
+			i, _, _ := pSendMessage.Call(dlgItem(hwnd, liveLogLevelID), CB_GETCURSEL, 0, 0)
+			if int(i) >= 0 && int(i) < len(liveLogLevels) {
+				dlg.filter.MinLevel = liveLogLevels[i].level
+				dlg.update(hwnd, true)
+			}
+		case id == liveLogSearchID && code == EN_CHANGE:
+			dlg.filter.Search = strings.TrimSpace(getDlgItemText(hwnd, liveLogSearchID, maxLogSearchLength))
+			dlg.update(hwnd, true)
+		case id == liveLogOpenID && code == BN_CLICKED:
+			ShowLogs()
+		case id == IDCANCEL:
+			pKillTimer.Call(hwnd, liveLogTimerID) //nolint:errcheck
+			pEndDialog.Call(hwnd, IDCANCEL)       //nolint:errcheck
+		default:
+			return dialogDefault
+		}
+		return dialogHandled
+	}
+	return dialogDefault
+}
+
+// update adds the lines printed since the last update to the text box, or
+// shows all kept lines again if rebuild is set, like when the filter
+// changed.
+func (d *liveLogDialog) update(hwnd uintptr, rebuild bool) {
+	from := d.next
+	if rebuild {
+		from = 0
+	}
+	lines, next := d.source.since(from)
+	if !rebuild && next == d.next {
+		return
+	}
+	d.next = next
+	text, count := renderLogLines(lines, d.filter)
+	if !rebuild && d.shown+count > d.source.size {
+		// Older lines left the buffer, keep the text box from growing
+		lines, d.next = d.source.since(0)
+		text, count = renderLogLines(lines, d.filter)
+		rebuild = true
+	}
+
+	edit := dlgItem(hwnd, liveLogTextID)
+	if rebuild {
+		d.shown = count
+		if count == 0 {
+			text = liveLogEmptyText
+			if len(lines) > 0 {
+				text = liveLogNoMatchText
+			}
+		}
+		setDlgItemText(hwnd, liveLogTextID, text)
+	} else if count > 0 {
+		if d.shown == 0 {
+			setDlgItemText(hwnd, liveLogTextID, "") // Replace the placeholder
+		}
+		d.shown += count
+		textPtr, err := windows.UTF16PtrFromString(text)
+		if err != nil {
+			return
+		}
+		length, _, _ := pGetWindowTextLength.Call(edit)
+		pSendMessage.Call(edit, EM_SETSEL, length, length)                          //nolint:errcheck
+		pSendMessage.Call(edit, EM_REPLACESEL, 0, uintptr(unsafe.Pointer(textPtr))) //nolint:errcheck
+	}
+	// Follow the latest lines
+	length, _, _ := pGetWindowTextLength.Call(edit)
+	pSendMessage.Call(edit, EM_SETSEL, length, length) //nolint:errcheck
+	pSendMessage.Call(edit, EM_SCROLLCARET, 0, 0)      //nolint:errcheck
+}
+
+// fillComboBox adds the choices to a combo box and selects the first.
+func fillComboBox(combo uintptr, choices []string) {
+	for _, choice := range choices {
+		title, err := windows.UTF16PtrFromString(choice)
+		if err != nil {
+			continue
+		}
+		pSendMessage.Call(combo, CB_ADDSTRING, 0, uintptr(unsafe.Pointer(title))) //nolint:errcheck
+	}
+	pSendMessage.Call(combo, CB_SETCURSEL, 0, 0) //nolint:errcheck
+}
+
+// renderLogLines returns the text of the lines the filter matches, one per
+// line, and how many there are.
+func renderLogLines(lines []logLine, filter logFilter) (string, int) {
+	var b strings.Builder
+	count := 0
+	for _, line := range lines {
+		if !filter.matches(line) {
+			continue
+		}
+		b.WriteString(line.Time.Format("15:04:05"))
+		b.WriteString("  ")
+		b.WriteString(line.Text)
+		b.WriteString("\r\n")
+		count++
+	}
+	return b.String(), count
+}
@@ -1,17 +1,46 @@
 package lifecycle
 
 import (
+	"context"
+	"errors"
 	"log/slog"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 )
 
-var logFile *os.File
+var (
+	logFile  *os.File
+	asyncLog *asyncWriter // Writes appLog, nil if the log file couldn't be opened
+)
+
+const recentLogLines = 5000 // Lines of the app log kept in memory
+
+// recentLog keeps the latest lines of the app log, for the log viewer and
+// diagnostics, even while the log file can't be written.
+var recentLog = &lineRing{size: recentLogLines}
 
 func InitLogging() {
 	level := slog.LevelInfo
+	replaceSource := func(_ []string, attr slog.Attr) slog.Attr {
+		if attr.Key == slog.SourceKey {
+			source := attr.Value.Any().(*slog.Source)
+			source.File = filepath.Base(source.File)
+		}
+		return attr
+	}
+	recentHandler := slog.NewTextHandler(redactingWriter{recentLogWriter{}}, &slog.HandlerOptions{
+		Level:     level,
+		AddSource: true,
+		ReplaceAttr: func(groups []string, attr slog.Attr) slog.Attr {
+			if len(groups) == 0 && attr.Key == slog.TimeKey {
+				return slog.Attr{} // Kept with the line
+			}
+			return replaceSource(groups, attr)
+		},
+	})
 
 	var err error
 
@@ -19,28 +48,105 @@ func InitLogging() {
 	logFile, err = os.OpenFile(AppLogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0755)
 	if err != nil {
 		slog.Error("failed to create log", "error", err)
+		slog.SetDefault(slog.New(operationHandler{recentHandler}))
 		return
 	}
 	// logFile is closed on shutdown by CloseLogging
-	handler := slog.NewTextHandler(logFile, &slog.HandlerOptions{
-		Level:     level,
-		AddSource: true,
-		ReplaceAttr: func(_ []string, attr slog.Attr) slog.Attr {
-			if attr.Key == slog.SourceKey {
-				source := attr.Value.Any().(*slog.Source)
-				source.File = filepath.Base(source.File)
-			}
-			return attr
-		},
+	appLog = &logWriter{file: logFile}
+	asyncLog = newAsyncWriter(appLog, logQueueSize)
+	handler := slog.NewTextHandler(redactingWriter{asyncLog}, &slog.HandlerOptions{
+		Level:       level,
+		AddSource:   true,
+		ReplaceAttr: replaceSource,
 	})
 
-	slog.SetDefault(slog.New(handler))
+	slog.SetDefault(slog.New(operationHandler{multiHandler{handler, recentHandler}}))
 
 	slog.Info("ReEnvision AI logging starting")
 
 }
 
+// GetRecentLogs returns the latest lines of the app log, oldest first, each
+// starting with its time.
+func GetRecentLogs() []string {
+	lines, _ := recentLog.since(0)
+	logs := make([]string, len(lines))
+	for i, line := range lines {
+		logs[i] = line.Time.Format(time.DateTime) + " " + line.Text
+	}
+	return logs
+}
+
+// recentLogWriter adds the records of a text handler to recentLog.
+type recentLogWriter struct{}
+
+func (recentLogWriter) Write(p []byte) (int, error) {
+	text := strings.TrimRight(string(p), "\r\n")
+	recentLog.addLevel("app", text, recordLevel(text), time.Now())
+	return len(p), nil
+}
+
+// recordLevel returns the level of a record written by a text handler.
+func recordLevel(text string) logLevel {
+	i := strings.Index(text, "level=")
+	if i < 0 {
+		return levelInfo
+	}
+	switch level := text[i+len("level="):]; {
+	case strings.HasPrefix(level, "DEBUG"):
+		return levelDebug
+	case strings.HasPrefix(level, "WARN"):
+		return levelWarning
+	case strings.HasPrefix(level, "ERROR"):
+		return levelError
+	}
+	return levelInfo
+}
+
+// multiHandler passes log records to each of its handlers.
+type multiHandler []slog.Handler
+
+func (h multiHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, handler := range h {
+		if handler.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h multiHandler) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for _, handler := range h {
+		if handler.Enabled(ctx, r.Level) {
+			if err := handler.Handle(ctx, r.Clone()); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (h multiHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make(multiHandler, len(h))
+	for i, handler := range h {
+		handlers[i] = handler.WithAttrs(attrs)
+	}
+	return handlers
+}
+
+func (h multiHandler) WithGroup(name string) slog.Handler {
+	handlers := make(multiHandler, len(h))
+	for i, handler := range h {
+		handlers[i] = handler.WithGroup(name)
+	}
+	return handlers
+}
+
 func CloseLogging() {
+	if asyncLog != nil {
+		asyncLog.close(logFlushTimeout)
+	}
 	if logFile != nil {
 		logFile.Close()
 	}
@@ -0,0 +1,81 @@
+//go:build windows && unit_test
+
+package lifecycle
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ReEnvision-AI/systray/app/procs"
+)
+
+func TestMultiHandler(t *testing.T) {
+	var info, debug bytes.Buffer
+	logger := slog.New(multiHandler{
+		slog.NewTextHandler(&info, &slog.HandlerOptions{Level: slog.LevelInfo}),
+		slog.NewTextHandler(&debug, &slog.HandlerOptions{Level: slog.LevelDebug}),
+	}).With("component", "test")
+
+	logger.Debug("details")
+	logger.Info("started", "id", 1)
+
+	if strings.Contains(info.String(), "details") || !strings.Contains(info.String(), "msg=started id=1 component=test") {
+		t.Errorf("unexpected info output %q", info.String())
+	}
+	if !strings.Contains(debug.String(), "msg=details component=test") || !strings.Contains(debug.String(), "msg=started") {
+		t.Errorf("unexpected debug output %q", debug.String())
+	}
+}
+
+func TestRecentLog(t *testing.T) {
+	defer func(saved *lineRing) { recentLog = saved }(recentLog)
+	recentLog = &lineRing{size: 10}
+
+	logger := slog.New(slog.NewTextHandler(recentLogWriter{}, nil))
+	logger.Warn("disk almost full", "error", "none")
+	logger.Info("request failed", "error", "timeout")
+
+	lines, _ := recentLog.since(0)
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d", len(lines))
+	}
+	if lines[0].Level != levelWarning || lines[1].Level != levelInfo {
+		t.Errorf("expected the levels of the records, got %v and %v", lines[0].Level, lines[1].Level)
+	}
+	if strings.HasSuffix(lines[1].Text, "\n") {
+		t.Errorf("expected the line without its newline, got %q", lines[1].Text)
+	}
+
+	logs := GetRecentLogs()
+	if len(logs) != 2 || !strings.Contains(logs[1], `msg="request failed"`) {
+		t.Fatalf("unexpected recent logs %q", logs)
+	}
+	if _, err := time.ParseInLocation(time.DateTime, logs[0][:len(time.DateTime)], time.Local); err != nil {
+		t.Errorf("expected recent logs to start with their time, got %q", logs[0])
+	}
+}
+
+func TestDiagnosticsText(t *testing.T) {
+	now := time.Date(2024, 5, 6, 12, 0, 0, 0, time.UTC)
+	appLines := make([]string, diagnosticsAppLines+10)
+	for i := range appLines {
+		appLines[i] = "app line"
+	}
+	appLines[len(appLines)-1] = "latest app line"
+	nodeLines := []logLine{{Time: now, Text: "Server is ready"}}
+
+	helpers := []procs.Process{{PID: 4242, Command: "podman machine start", Started: now.Add(-12 * time.Second), Running: true}}
+
+	text := diagnosticsText("node-1", StateRunning, now, helpers, nil, appLines, nodeLines)
+	for _, want := range []string{"Node ID: node-1\r\n", "State: Running\r\n", "4242  podman machine start  (running for 12 s)\r\n", "latest app line\r\n", "12:00:00  Server is ready\r\n"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("expected diagnostics to contain %q", want)
+		}
+	}
+	if got := strings.Count(text, "app line\r\n"); got != diagnosticsAppLines {
+		t.Errorf("expected the latest %d app lines, got %d", diagnosticsAppLines, got)
+	}
+}
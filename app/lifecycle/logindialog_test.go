@@ -0,0 +1,36 @@
+//go:build windows && unit_test
+
+package lifecycle
+
+import "testing"
+
+func TestValidateCredentials(t *testing.T) {
+	tests := []struct {
+		email, password string
+		field           uint16
+	}{
+		{"", "someone@example.com", loginEmailID},
+		{"", "secret", loginEmailID},
+		{"someone", "secret", loginEmailID},
+		{"some one@example.com", "secret", loginEmailID},
+		{"someone@example.com", "", loginPasswordID},
+		{"someone@example.com", "secret", 0},
+	}
+	for _, tt := range tests {
+		problem, field := validateCredentials(tt.email, tt.password)
+		if field != tt.field || (problem == "") != (tt.field == 0) {
+			t.Errorf("validateCredentials(%q, %q) = %q, %d, want field %d", tt.email, tt.password, problem, field, tt.field)
+		}
+	}
+}
+
+func TestLoginDialogTemplate(t *testing.T) {
+	template := loginDialogTemplate("ReEnvision AI")
+	if items := int(template[4]); items != 10 {
+		t.Errorf("expected 10 controls, got %d", items)
+	}
+	// The last item ends with its title and an empty creation data word
+	if template[len(template)-1] != 0 || template[len(template)-2] != 0 {
+		t.Errorf("template doesn't end with a terminated item: %v", template[len(template)-4:])
+	}
+}
@@ -0,0 +1,181 @@
+package lifecycle
+
+import (
+	"log/slog"
+	"strings"
+	"sync"
+
+	"golang.org/x/sys/windows"
+)
+
+// The sign in dialog asks for the email and password in labelled fields, as
+// the generic Windows credential dialog asks for a "user name" and users
+// typed their email into the wrong field. It can reveal the password, has
+// the reset email sent for a forgotten password and shows what is wrong below
+// the fields instead of in a separate message box. It is built from an
+// in-memory template so it needs no resources.
+
+// ForgotPasswordURL is where users reset their account password when the
+// app can't send the reset email.
+var ForgotPasswordURL = "https://sociallyshaped.net/reset-password"
+
+// loginAction is how the sign in dialog was closed.
+type loginAction int
+
+const (
+	loginCancelled loginAction = iota
+	loginSubmitted
+	loginResetPassword // Send the password reset email to the entered address
+)
+
+// Control IDs of the sign in dialog
+const (
+	loginMessageID      = 100
+	loginEmailID        = 101
+	loginPasswordID     = 102
+	loginShowPasswordID = 103
+	loginForgotID       = 104
+	loginErrorID        = 105
+)
+
+const (
+	maxEmailLength    = 512
+	maxPasswordLength = 256
+)
+
+var (
+	loginDialogMu       sync.Mutex
+	activeLoginDialog   *loginDialog // The open dialog, guarded by loginDialogMu
+	loginDialogCallback = sync.OnceValue(func() uintptr { return windows.NewCallback(loginDialogProc) })
+)
+
+type loginDialog struct {
+	message      string
+	errText      string
+	email        string
+	password     string
+	action       loginAction
+	passwordChar uintptr // Mask of the password field, restored when hiding it again
+}
+
+func loginDialogTemplate(caption string) []uint16 {
+	return buildDialogTemplate(caption, 240, 150, []dialogItem{
+		{class: dialogStatic, style: dialogChild | SS_NOPREFIX, x: 7, y: 7, cx: 226, cy: 20, id: loginMessageID},
+		{class: dialogStatic, style: dialogChild, x: 7, y: 30, cx: 226, cy: 9, id: dialogLabelID, title: "&Email:"},
+		{class: dialogEdit, style: dialogChild | WS_TABSTOP | ES_AUTOHSCROLL, exStyle: WS_EX_CLIENTEDGE, x: 7, y: 40, cx: 226, cy: 13, id: loginEmailID},
+		{class: dialogStatic, style: dialogChild, x: 7, y: 58, cx: 226, cy: 9, id: dialogLabelID, title: "&Password:"},
+		{class: dialogEdit, style: dialogChild | WS_TABSTOP | ES_AUTOHSCROLL | ES_PASSWORD, exStyle: WS_EX_CLIENTEDGE, x: 7, y: 68, cx: 226, cy: 13, id: loginPasswordID},
+		{class: dialogButton, style: dialogChild | WS_TABSTOP | BS_AUTOCHECKBOX, x: 7, y: 86, cx: 100, cy: 10, id: loginShowPasswordID, title: "&Show password"},
+		{class: dialogButton, style: dialogChild | WS_TABSTOP, x: 150, y: 84, cx: 83, cy: 14, id: loginForgotID, title: "&Forgot password?"},
+		{class: dialogStatic, style: dialogChild | SS_NOPREFIX, x: 7, y: 104, cx: 226, cy: 18, id: loginErrorID},
+		{class: dialogButton, style: dialogChild | WS_TABSTOP | BS_DEFPUSHBUTTON, x: 129, y: 129, cx: 50, cy: 14, id: IDOK, title: "Sign in"},
+		{class: dialogButton, style: dialogChild | WS_TABSTOP, x: 183, y: 129, cx: 50, cy: 14, id: IDCANCEL, title: "Cancel"},
+	})
+}
+
+// validateCredentials returns what is wrong with the entered credentials and
+// the ID of the field to fix, or "" if they can be sent.
+func validateCredentials(email, password string) (string, uint16) {
+	switch {
+	case email == "" && strings.Contains(password, "@"):
+		return "The email address seems to be in the password field. Enter it in the email field.", loginEmailID
+	case email == "":
+		return "Enter the email address of your account.", loginEmailID
+	case !strings.Contains(email, "@") || strings.ContainsAny(email, " \t"):
+		return "Enter a valid email address, such as name@example.com.", loginEmailID
+	case password == "":
+		return "Enter your password.", loginPasswordID
+	}
+	return "", 0
+}
+
+// showLoginDialog shows the sign in dialog with email prefilled and errText
+// below the fields, and returns the entered credentials and how the dialog
+// was closed.
+func showLoginDialog(caption, message, email, errText string) (string, string, loginAction) {
+	loginDialogMu.Lock()
+	defer loginDialogMu.Unlock()
+	dlg := &loginDialog{message: message, errText: errText, email: email}
+	activeLoginDialog = dlg
+	defer func() { activeLoginDialog = nil }()
+
+	if err := runDialog(loginDialogTemplate(caption), loginDialogCallback()); err != nil {
+		slog.Warn("failed to show sign in dialog", "error", err)
+		return "", "", loginCancelled
+	}
+	return dlg.email, dlg.password, dlg.action
+}
+
+func loginDialogProc(hwnd, msg, wParam, lParam uintptr) uintptr {
+	const (
+		EM_SETPASSWORDCHAR = 0x00CC
+		EM_GETPASSWORDCHAR = 0x00D2
+	)
+	dlg := activeLoginDialog
+	if dlg == nil {
+		return dialogDefault
+	}
+	item := func(id uint16) uintptr { return dlgItem(hwnd, id) }
+
+	switch msg {
+	case WM_INITDIALOG:
+		setDlgItemText(hwnd, loginMessageID, dlg.message)
+		setDlgItemText(hwnd, loginEmailID, dlg.email)
+		setDlgItemText(hwnd, loginErrorID, dlg.errText)
+		dlg.passwordChar, _, _ = pSendMessage.Call(item(loginPasswordID), EM_GETPASSWORDCHAR, 0, 0)
+		focus := uint16(loginEmailID)
+		if dlg.email != "" {
+			focus = loginPasswordID
+		}
+		pSetFocus.Call(item(focus)) //nolint:errcheck
+		return dialogFocusSet
+
+	case WM_CTLCOLORSTATIC:
+		if lParam != item(loginErrorID) {
+			return dialogDefault
+		}
+		return paintErrorText(wParam)
+
+	case WM_COMMAND:
+		if wParam>>16&0xFFFF != BN_CLICKED {
+			return dialogDefault
+		}
+		switch uint16(wParam) {
+		case IDOK:
+			// Pasted addresses often carry surrounding whitespace
+			email := strings.TrimSpace(getDlgItemText(hwnd, loginEmailID, maxEmailLength))
+			password := getDlgItemText(hwnd, loginPasswordID, maxPasswordLength)
+			if problem, field := validateCredentials(email, password); problem != "" {
+				setDlgItemText(hwnd, loginErrorID, problem)
+				pSetFocus.Call(item(field)) //nolint:errcheck
+				return dialogHandled
+			}
+			dlg.email, dlg.password, dlg.action = email, password, loginSubmitted
+			pEndDialog.Call(hwnd, IDOK) //nolint:errcheck
+		case IDCANCEL:
+			pEndDialog.Call(hwnd, IDCANCEL) //nolint:errcheck
+		case loginShowPasswordID:
+			mask := dlg.passwordChar
+			if checked, _, _ := pIsDlgButtonChecked.Call(hwnd, loginShowPasswordID); checked != 0 {
+				mask = 0
+			}
+			password := item(loginPasswordID)
+			pSendMessage.Call(password, EM_SETPASSWORDCHAR, mask, 0) //nolint:errcheck
+			pInvalidateRect.Call(password, 0, 1)                     //nolint:errcheck
+			pSetFocus.Call(password)                                 //nolint:errcheck
+		case loginForgotID:
+			email := strings.TrimSpace(getDlgItemText(hwnd, loginEmailID, maxEmailLength))
+			if problem, field := validateCredentials(email, "-"); problem != "" {
+				setDlgItemText(hwnd, loginErrorID, "To reset your password, "+strings.ToLower(problem[:1])+problem[1:])
+				pSetFocus.Call(item(field)) //nolint:errcheck
+				return dialogHandled
+			}
+			dlg.email, dlg.action = email, loginResetPassword
+			pEndDialog.Call(hwnd, IDOK) //nolint:errcheck
+		default:
+			return dialogDefault
+		}
+		return dialogHandled
+	}
+	return dialogDefault
+}
@@ -0,0 +1,41 @@
+//go:build windows && unit_test
+
+package lifecycle
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseUptime(t *testing.T) {
+	uptime, err := parseUptime("350735.5 234388.90\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := 350735500 * time.Millisecond; uptime != expected {
+		t.Errorf("expected %v, got %v", expected, uptime)
+	}
+	for _, invalid := range []string{"", "abc 1.0"} {
+		if _, err := parseUptime(invalid); err == nil {
+			t.Errorf("expected an error for %q", invalid)
+		}
+	}
+}
+
+func TestAllowMachineRestart(t *testing.T) {
+	machineRestarts = nil
+	defer func() { machineRestarts = nil }()
+
+	now := time.Now()
+	for i := range maxMachineRestarts {
+		if !allowMachineRestart(now.Add(time.Duration(i) * time.Minute)) {
+			t.Fatalf("expected restart %d to be allowed", i+1)
+		}
+	}
+	if allowMachineRestart(now.Add(10 * time.Minute)) {
+		t.Error("expected restarts to be limited")
+	}
+	if !allowMachineRestart(now.Add(machineRestartWindow + time.Minute)) {
+		t.Error("expected a restart once the earlier ones left the window")
+	}
+}
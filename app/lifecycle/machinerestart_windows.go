@@ -0,0 +1,131 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ReEnvision-AI/systray/app/tray/commontray"
+)
+
+// Windows Update and WSL updates restart the Podman machine underneath a
+// running node, which ends `podman run` with a connection reset. When the
+// container exits unexpectedly, the machine's boot time is compared with the
+// one recorded at start, and if the machine restarted or can't be reached
+// the node is started again instead of being left in the error state. At
+// most maxMachineRestarts restarts are made per machineRestartWindow so a
+// machine that keeps crashing still ends in the error state.
+
+const (
+	maxMachineRestarts   = 3
+	machineRestartWindow = time.Hour
+	machineBootTolerance = time.Minute // Uptime is read at a slightly different time than the clock
+	machineCheckTimeout  = 30 * time.Second
+)
+
+var (
+	machineRestartMu sync.Mutex
+	machineBootedAt  time.Time   // Boot time of the machine when the container started, zero if unknown
+	machineRestarts  []time.Time // Recent automatic restarts
+)
+
+// recordMachineBoot remembers the boot time of the machine the container is
+// started on.
+func recordMachineBoot(ctx context.Context) {
+	bootedAt, err := machineBootTime(ctx)
+	if err != nil {
+		slog.Debug("failed to read Podman machine boot time", "error", err)
+	}
+	machineRestartMu.Lock()
+	machineBootedAt = bootedAt
+	machineRestartMu.Unlock()
+}
+
+// machineBootTime returns when the Podman machine booted.
+func machineBootTime(ctx context.Context) (time.Time, error) {
+	ctx, cancel := context.WithTimeout(ctx, machineCheckTimeout)
+	defer cancel()
+	output, err := helperOutput(helperCommand(ctx, "podman", "machine", "ssh", "cat /proc/uptime"))
+	if err != nil {
+		return time.Time{}, podmanError(err, output)
+	}
+	uptime, err := parseUptime(string(output))
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Now().Add(-uptime), nil
+}
+
+// parseUptime parses /proc/uptime, e.g. "350735.47 234388.90".
+func parseUptime(content string) (time.Duration, error) {
+	fields := strings.Fields(content)
+	if len(fields) == 0 {
+		return 0, errors.New("empty uptime")
+	}
+	seconds, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid uptime %q: %w", fields[0], err)
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// machineRestarted reports whether the container exited because the Podman
+// machine restarted or went away.
+func machineRestarted() bool {
+	machineRestartMu.Lock()
+	bootedAt := machineBootedAt
+	machineRestartMu.Unlock()
+
+	if bootedAt.IsZero() {
+		return false // The machine couldn't be checked at start either
+	}
+	current, err := machineBootTime(context.Background())
+	if err != nil {
+		// Reachable at start but not now, so it is going down or coming back up
+		slog.Info("Podman machine is unreachable after the container exited", "error", err)
+		return true
+	}
+	return current.Sub(bootedAt) > machineBootTolerance
+}
+
+// allowMachineRestart records an automatic restart, unless too many were made
+// recently.
+func allowMachineRestart(now time.Time) bool {
+	machineRestartMu.Lock()
+	defer machineRestartMu.Unlock()
+	recent := machineRestarts[:0]
+	for _, at := range machineRestarts {
+		if now.Sub(at) < machineRestartWindow {
+			recent = append(recent, at)
+		}
+	}
+	machineRestarts = recent
+	if len(machineRestarts) >= maxMachineRestarts {
+		return false
+	}
+	machineRestarts = append(machineRestarts, now)
+	return true
+}
+
+// restartAfterMachineRestart starts the node again if the container exited
+// because the Podman machine restarted. Returns false if the exit should be
+// treated as an error.
+func restartAfterMachineRestart() bool {
+	if !machineRestarted() {
+		return false
+	}
+	if !allowMachineRestart(time.Now()) {
+		slog.Warn("Podman machine restarted again, not restarting the node", "max_restarts", maxMachineRestarts, "window", machineRestartWindow)
+		return false
+	}
+	slog.Info("Podman machine restarted underneath the node, starting it again")
+	SetState(StateStopped)
+	notify(commontray.NotifyInfo, "Restarting your node", "Podman was restarted, likely by an update. Your node is starting again")
+	go handleStartRequest()
+	return true
+}
@@ -0,0 +1,42 @@
+//go:build windows && unit_test
+
+package lifecycle
+
+import "testing"
+
+func TestValidateMachineSetup(t *testing.T) {
+	valid := []MachineSetupStep{
+		{Name: "swappiness", Command: "sudo sysctl -w vm.swappiness=10"},
+		{Name: "hugepages", Command: "sudo sysctl -w vm.nr_hugepages=0", Always: true},
+	}
+	if err := validateMachineSetup(valid); err != nil {
+		t.Errorf("expected valid steps, got %v", err)
+	}
+
+	invalid := map[string][]MachineSetupStep{
+		"no name":    {{Command: "true"}},
+		"reserved":   {{Name: nvidiaCDIStepName, Command: "true"}},
+		"duplicate":  {{Name: "a", Command: "true"}, {Name: "a", Command: "false"}},
+		"no command": {{Name: "a", Command: " "}},
+	}
+	for name, steps := range invalid {
+		if err := validateMachineSetup(steps); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestMachineSetupKey(t *testing.T) {
+	step := MachineSetupStep{Name: "swappiness", Command: "sudo sysctl -w vm.swappiness=10"}
+	key := machineSetupKey(step, "2025-01-01 ID=fedora VERSION_ID=41")
+	if key != machineSetupKey(step, "2025-01-01 ID=fedora VERSION_ID=41") {
+		t.Error("expected a stable key")
+	}
+	if key == machineSetupKey(step, "2025-01-01 ID=fedora VERSION_ID=42") {
+		t.Error("expected a new key for a new machine version")
+	}
+	step.Command = "sudo sysctl -w vm.swappiness=20"
+	if key == machineSetupKey(step, "2025-01-01 ID=fedora VERSION_ID=41") {
+		t.Error("expected a new key for a changed command")
+	}
+}
@@ -0,0 +1,114 @@
+package lifecycle
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/ReEnvision-AI/systray/app/store"
+)
+
+// Machine setup steps are shell commands run in the Podman machine with
+// `podman machine ssh` before the node starts, such as sysctl tweaks or
+// installing nvidia-ctk. The steps of machine_setup run once per machine
+// version, recorded in the store, and again when their command changes or
+// the machine is recreated or upgraded. The Nvidia CDI spec is generated by
+// a built-in step that runs on every start, as it follows the Windows driver.
+
+// MachineSetupStep is a command run in the Podman machine.
+type MachineSetupStep struct {
+	Name    string `json:"name"`
+	Command string `json:"command"` // Run as the machine's default user, prefix with sudo for root
+	Always  bool   `json:"always"`  // Run on every start instead of once per machine version
+}
+
+const (
+	nvidiaCDIStepName   = "nvidia-cdi" // Reserved for the built-in step
+	machineSetupTimeout = 10 * time.Minute
+)
+
+// setupMachine runs the configured machine setup steps that are due.
+func setupMachine(ctx context.Context) error {
+	if len(currentAppConfig().MachineSetup) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, machineSetupTimeout)
+	defer cancel()
+
+	version, err := machineVersion(ctx)
+	if err != nil {
+		return err
+	}
+	for _, step := range currentAppConfig().MachineSetup {
+		if err := runMachineSetupStep(ctx, step, version); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runMachineSetupStep runs step unless it already ran on this machine
+// version.
+func runMachineSetupStep(ctx context.Context, step MachineSetupStep, version string) error {
+	done := machineSetupKey(step, version)
+	if !step.Always && store.GetMachineSetup(step.Name) == done {
+		slog.Debug("Machine setup step already done", "step", step.Name)
+		return nil
+	}
+
+	slog.Info("Running machine setup step", "step", step.Name, "command", step.Command)
+	start := time.Now()
+	output, err := helperCombinedOutput(helperCommand(ctx, "podman", "machine", "ssh", step.Command))
+	if err != nil {
+		return fmt.Errorf("machine setup step %s failed: %w", step.Name, podmanError(err, output))
+	}
+	slog.Info("Machine setup step done", "step", step.Name, "duration", time.Since(start), "output", strings.TrimSpace(string(output)))
+	if !step.Always {
+		store.SetMachineSetup(step.Name, done)
+	}
+	return nil
+}
+
+// machineVersion identifies the Podman machine by its creation time and OS
+// release, which change when it is recreated or its image is upgraded.
+func machineVersion(ctx context.Context) (string, error) {
+	created, err := helperOutput(helperCommand(ctx, "podman", "machine", "inspect", "--format", "{{.Created}}"))
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect Podman machine: %w", podmanError(err, created))
+	}
+	release, err := helperOutput(helperCommand(ctx, "podman", "machine", "ssh", "grep -E '^(ID|VERSION_ID)=' /etc/os-release"))
+	if err != nil {
+		return "", fmt.Errorf("failed to read Podman machine OS release: %w", podmanError(err, release))
+	}
+	return strings.Join(append(strings.Fields(string(created)), strings.Fields(string(release))...), " "), nil
+}
+
+// machineSetupKey is recorded in the store once step ran on a machine
+// version, including a hash of the command so edits run again.
+func machineSetupKey(step MachineSetupStep, version string) string {
+	sum := sha256.Sum256([]byte(step.Command))
+	return version + " " + hex.EncodeToString(sum[:6])
+}
+
+// validateMachineSetup checks the machine_setup config.
+func validateMachineSetup(steps []MachineSetupStep) error {
+	names := map[string]bool{}
+	for i, step := range steps {
+		switch {
+		case step.Name == "":
+			return fmt.Errorf("step %d has no name", i+1)
+		case step.Name == nvidiaCDIStepName:
+			return fmt.Errorf("step name %q is reserved", step.Name)
+		case names[step.Name]:
+			return fmt.Errorf("step name %q is used twice", step.Name)
+		case strings.TrimSpace(step.Command) == "":
+			return fmt.Errorf("step %s has no command", step.Name)
+		}
+		names[step.Name] = true
+	}
+	return nil
+}
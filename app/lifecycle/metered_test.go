@@ -0,0 +1,69 @@
+//go:build windows && unit_test
+
+package lifecycle
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestIsMeteredCost(t *testing.T) {
+	for cost, want := range map[uint32]bool{
+		0:                                    false, // Unknown
+		nlmCostUnrestricted:                  false,
+		nlmCostFixed:                         true,
+		nlmCostVariable:                      true,
+		nlmCostUnrestricted | nlmCostRoaming: true,
+		nlmCostFixed | nlmCostOverDataLimit:  true,
+		nlmCostUnrestricted | nlmCostFixed:   false,
+	} {
+		if got := isMeteredCost(cost); got != want {
+			t.Errorf("cost %#x: expected metered %v, got %v", cost, want, got)
+		}
+	}
+}
+
+func TestMeteredPullArgs(t *testing.T) {
+	defer connectionMetered.Store(false)
+	saved := appConfig
+	defer func() { appConfig = saved }()
+
+	if args := pullArgs(); !slices.Contains(args, "--pull=newer") {
+		t.Errorf("expected pulls on an unmetered connection, got %v", args)
+	}
+	connectionMetered.Store(true)
+	appConfig.MeteredPolicy = meteredPolicyLimit
+	if args := pullArgs(); !slices.Contains(args, "--pull=never") || !slices.Contains(args, "--env=HF_HUB_OFFLINE=1") {
+		t.Errorf("expected no downloads on a metered connection, got %v", args)
+	}
+	appConfig.MeteredPolicy = meteredPolicyIgnore
+	if args := pullArgs(); !slices.Contains(args, "--pull=newer") {
+		t.Errorf("expected pulls when ignoring metered connections, got %v", args)
+	}
+}
+
+func TestMeteredPolicyConfig(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), configFileName)
+	for content, want := range map[string]string{
+		`{"container_name": "reai", "container_image": "node:1", "model_name": "org/model"}`:                             meteredPolicyLimit,
+		`{"container_name": "reai", "container_image": "node:1", "model_name": "org/model", "metered_policy": "ignore"}`: meteredPolicyIgnore,
+		`{"container_name": "reai", "container_image": "node:1", "model_name": "org/model", "metered_policy": "pause"}`:  "",
+	} {
+		if err := os.WriteFile(configFile, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := readConfigFile(configFile)
+		if want == "" {
+			if err == nil || !strings.Contains(err.Error(), "metered_policy") {
+				t.Errorf("expected an invalid metered_policy error, got %v", err)
+			}
+			continue
+		}
+		if err != nil || cfg.MeteredPolicy != want {
+			t.Errorf("expected metered_policy %q, got %q, %v", want, cfg.MeteredPolicy, err)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+
+	"github.com/ReEnvision-AI/systray/app/tray/commontray"
+	"golang.org/x/sys/windows"
+)
+
+// On a connection Windows considers metered, like a phone hotspot, the node
+// doesn't download anything large: the container image isn't pulled, the
+// server only uses models already in the cache volume, updates aren't
+// downloaded and heartbeats leave out their details. A node whose image
+// isn't downloaded yet can't start until the connection is unmetered.
+// metered_policy "ignore" in config.json turns this off.
+
+// Values for AppConfig.MeteredPolicy
+const (
+	meteredPolicyLimit  = "limit" // The default
+	meteredPolicyIgnore = "ignore"
+)
+
+var errMeteredImageMissing = errors.New("the container image isn't downloaded and the connection is metered")
+
+// NLM_CONNECTION_COST flags
+const (
+	nlmCostUnrestricted  = 0x1
+	nlmCostFixed         = 0x2
+	nlmCostVariable      = 0x4
+	nlmCostOverDataLimit = 0x10000
+	nlmCostRoaming       = 0x40000
+)
+
+var (
+	ole32             = windows.NewLazySystemDLL("ole32.dll")
+	pCoCreateInstance = ole32.NewProc("CoCreateInstance")
+
+	clsidNetworkListManager = windows.GUID{Data1: 0xDCB00C01, Data2: 0x570F, Data3: 0x4A9B, Data4: [8]byte{0x8D, 0x69, 0x19, 0x9F, 0xDB, 0xA5, 0x72, 0x3B}}
+	iidNetworkCostManager   = windows.GUID{Data1: 0xDCB00008, Data2: 0x570F, Data3: 0x4A9B, Data4: [8]byte{0x8D, 0x69, 0x19, 0x9F, 0xDB, 0xA5, 0x72, 0x3B}}
+)
+
+var connectionMetered atomic.Bool
+
+// networkCostManager is the INetworkCostManager COM interface.
+type networkCostManager struct {
+	vtbl *struct {
+		QueryInterface          uintptr
+		AddRef                  uintptr
+		Release                 uintptr
+		GetCost                 uintptr
+		GetDataPlanStatus       uintptr
+		SetDestinationAddresses uintptr
+	}
+}
+
+// connectionCost returns the NLM_CONNECTION_COST of the internet connection.
+func connectionCost() (uint32, error) {
+	const (
+		CLSCTX_ALL         = 0x17
+		RPC_E_CHANGED_MODE = syscall.Errno(0x80010106)
+	)
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	switch err := windows.CoInitializeEx(0, windows.COINIT_MULTITHREADED); {
+	case err == nil, err == syscall.Errno(1): // S_FALSE, already initialized
+		defer windows.CoUninitialize()
+	case err == RPC_E_CHANGED_MODE: // Initialized otherwise, which is fine
+	default:
+		return 0, fmt.Errorf("unable to initialize COM: %w", err)
+	}
+
+	var manager *networkCostManager
+	hr, _, _ := pCoCreateInstance.Call(
+		uintptr(unsafe.Pointer(&clsidNetworkListManager)),
+		0,
+		CLSCTX_ALL,
+		uintptr(unsafe.Pointer(&iidNetworkCostManager)),
+		uintptr(unsafe.Pointer(&manager)),
+	)
+	if hr != 0 {
+		return 0, fmt.Errorf("unable to create network cost manager: %w", windows.Errno(hr))
+	}
+	defer syscall.SyscallN(manager.vtbl.Release, uintptr(unsafe.Pointer(manager))) //nolint:errcheck
+
+	var cost uint32
+	hr, _, _ = syscall.SyscallN(manager.vtbl.GetCost, uintptr(unsafe.Pointer(manager)), uintptr(unsafe.Pointer(&cost)), 0)
+	if hr != 0 {
+		return 0, fmt.Errorf("unable to get connection cost: %w", windows.Errno(hr))
+	}
+	return cost, nil
+}
+
+// isMeteredCost reports whether a connection of the cost is metered.
+func isMeteredCost(cost uint32) bool {
+	if cost&(nlmCostOverDataLimit|nlmCostRoaming) != 0 {
+		return true
+	}
+	return cost&(nlmCostFixed|nlmCostVariable) != 0 && cost&nlmCostUnrestricted == 0
+}
+
+// refreshMetered checks whether the connection is metered and warns when a
+// connection became metered while the node runs.
+func refreshMetered() {
+	cost, err := connectionCost()
+	if err != nil {
+		slog.Debug("unable to check for a metered connection", "error", err)
+		return
+	}
+	metered := isMeteredCost(cost)
+	if connectionMetered.Swap(metered) == metered {
+		return
+	}
+	slog.Info("Connection cost changed", "metered", metered, "cost", fmt.Sprintf("%#x", cost))
+	if !metered || currentAppConfig().MeteredPolicy == meteredPolicyIgnore {
+		return
+	}
+	stateMu.Lock()
+	running := currentState == StateRunning || currentState == StateLoading
+	stateMu.Unlock()
+	if running { // A start checks itself
+		notify(commontray.NotifyWarning, "Metered connection", "The node keeps running but won't download images, models or updates until the connection is unmetered")
+	}
+}
+
+// meteredLimited reports whether downloads are limited as the connection
+// is metered.
+func meteredLimited() bool {
+	return connectionMetered.Load() && currentAppConfig().MeteredPolicy != meteredPolicyIgnore
+}
+
+// checkMeteredStart returns errMeteredImageMissing if the node can't start
+// on a metered connection without pulling its image.
+func checkMeteredStart(ctx context.Context) error {
+	if !meteredLimited() {
+		return nil
+	}
+	if err := runHelper(podmanCommand(ctx, "image", "exists", currentAppConfig().ContainerImage)); err != nil {
+		return errMeteredImageMissing
+	}
+	slog.Info("Metered connection, starting without downloads")
+	notify(commontray.NotifyWarning, "Metered connection", "The node starts without downloading images or models. Models that aren't cached fail to load")
+	return nil
+}
+
+// pullArgs returns the podman run arguments for pulling the image and
+// downloading models.
+func pullArgs() []string {
+	if meteredLimited() {
+		return []string{"--pull=never", "--env=HF_HUB_OFFLINE=1"}
+	}
+	return []string{"--pull=newer"} // Pulls newer image even if same version
+}
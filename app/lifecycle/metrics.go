@@ -0,0 +1,77 @@
+package lifecycle
+
+import (
+	"context"
+	"log/slog"
+	"reflect"
+	"time"
+
+	"github.com/ReEnvision-AI/systray/app/metrics"
+	"github.com/ReEnvision-AI/systray/app/store"
+	"github.com/ReEnvision-AI/systray/version"
+)
+
+// nodeMetrics is the registry shared by the exporters of the metrics config.
+var nodeMetrics = metrics.NewRegistry()
+
+const metricsExportTimeout = 30 * time.Second
+
+// recordStateMetrics updates the metrics of a state change.
+func recordStateMetrics(state AppState) {
+	up := 0.0
+	if state == StateRunning {
+		up = 1
+	}
+	nodeMetrics.Set("node.up", up)
+	switch state {
+	case StateStarting:
+		nodeMetrics.Add("node.starts", 1)
+	case StateError:
+		nodeMetrics.Add("node.errors", 1)
+	}
+}
+
+// StartMetricsExport pushes nodeMetrics to the configured exporters until ctx
+// is cancelled.
+func StartMetricsExport(ctx context.Context) {
+	go func() {
+		var exporters []metrics.Exporter
+		var exportersCfg metrics.Config
+		for {
+			// The config is only loaded once the node starts
+			cfg := currentAppConfig().Metrics
+			if !reflect.DeepEqual(cfg, exportersCfg) {
+				exporters = cfg.Exporters(store.GetID(), version.Version, userAgent())
+				exportersCfg = cfg
+			}
+			if len(exporters) > 0 {
+				exportMetrics(ctx, exporters)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(cfg.Interval()):
+			}
+		}
+	}()
+}
+
+func exportMetrics(ctx context.Context, exporters []metrics.Exporter) {
+	stateMu.Lock()
+	since := runningSince
+	stateMu.Unlock()
+	uptime := 0.0
+	if !since.IsZero() {
+		uptime = time.Since(since).Seconds()
+	}
+	nodeMetrics.Set("node.uptime_seconds", uptime)
+
+	snapshot := nodeMetrics.Snapshot()
+	ctx, cancel := context.WithTimeout(ctx, metricsExportTimeout)
+	defer cancel()
+	for _, exporter := range exporters {
+		if err := exporter.Export(ctx, snapshot, nodeMetrics.Started()); err != nil {
+			slog.Debug("failed to export metrics", "exporter", reflect.TypeOf(exporter).String(), "error", err)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+//go:build windows && unit_test
+
+package lifecycle
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMilestoneReached(t *testing.T) {
+	byID := map[string]milestone{}
+	for _, m := range milestones {
+		byID[m.ID] = m
+	}
+
+	tests := []struct {
+		id       string
+		stats    contributionStats
+		expected bool
+	}{
+		{"first-day", contributionStats{Running: 23 * time.Hour, Tokens: -1}, false},
+		{"first-day", contributionStats{Running: 24 * time.Hour, Tokens: -1}, true},
+		{"100-hours", contributionStats{Running: 99 * time.Hour, Tokens: 5_000_000}, false},
+		{"1m-tokens", contributionStats{Running: 500 * time.Hour, Tokens: -1}, false},
+		{"1m-tokens", contributionStats{Tokens: 999_999}, false},
+		{"1m-tokens", contributionStats{Tokens: 1_000_000}, true},
+	}
+	for _, tt := range tests {
+		m, ok := byID[tt.id]
+		if !ok {
+			t.Fatalf("unknown milestone %s", tt.id)
+		}
+		if got := m.reached(tt.stats); got != tt.expected {
+			t.Errorf("%s reached with %+v: expected %v, got %v", tt.id, tt.stats, tt.expected, got)
+		}
+	}
+}
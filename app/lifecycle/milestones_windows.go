@@ -0,0 +1,162 @@
+package lifecycle
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/ReEnvision-AI/systray/app/auth"
+	"github.com/ReEnvision-AI/systray/app/store"
+	"github.com/ReEnvision-AI/systray/app/tray/commontray"
+)
+
+// Contribution milestones award a badge, recorded in the store and on the
+// backend, and celebrate it with a notification. Running time comes from the
+// contribution history, tokens served from the backend's node stats, which
+// are only available while signed in.
+
+// Backend endpoints for milestones, relative to the Supabase URL
+var (
+	NodeStatsPath  = "/functions/v1/node-stats"
+	NodeBadgesPath = "/functions/v1/node-badges"
+)
+
+var MilestoneCheckInterval = time.Hour
+
+const milestoneRequestTimeout = 30 * time.Second
+
+type milestone struct {
+	ID      string
+	Title   string
+	Message string
+	Running time.Duration // Reached after running this long in total
+	Tokens  int64         // Reached after serving this many tokens
+}
+
+var milestones = []milestone{
+	{
+		ID:      "first-day",
+		Title:   "Your node contributed for a full day",
+		Message: "24 hours of compute shared. Thank you for being part of ReEnvision AI!",
+		Running: 24 * time.Hour,
+	},
+	{
+		ID:      "100-hours",
+		Title:   "100 hours contributed",
+		Message: "Your node has shared 100 hours of compute. You earned the 100 hours badge!",
+		Running: 100 * time.Hour,
+	},
+	{
+		ID:      "1m-tokens",
+		Title:   "1 million tokens served",
+		Message: "Your node has served a million tokens. You earned the 1M tokens badge!",
+		Tokens:  1_000_000,
+	},
+}
+
+// contributionStats are what milestones are measured against.
+type contributionStats struct {
+	Running time.Duration
+	Tokens  int64 // -1 when unknown
+}
+
+func (m milestone) reached(stats contributionStats) bool {
+	if m.Running > 0 {
+		return stats.Running >= m.Running
+	}
+	return stats.Tokens >= 0 && stats.Tokens >= m.Tokens
+}
+
+// StartMilestoneCheck awards milestones every MilestoneCheckInterval until
+// ctx is cancelled.
+func StartMilestoneCheck(ctx context.Context) {
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(MilestoneCheckInterval):
+			}
+			checkMilestones(ctx)
+		}
+	}()
+}
+
+func checkMilestones(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, milestoneRequestTimeout)
+	defer cancel()
+
+	stats := contributionStats{Running: contributedTotal(time.Now()), Tokens: -1}
+	client, s, err := backendSession(ctx, storedSession)
+	if err == nil {
+		if stats.Tokens, err = tokensServed(ctx, client, s); err != nil {
+			slog.Debug("failed to fetch node stats", "error", err)
+			stats.Tokens = -1
+		}
+	}
+
+	for _, m := range awardMilestones(stats, time.Now()) {
+		slog.Info("Milestone reached", "milestone", m.ID)
+		nodeMetrics.Add("node.badges", 1)
+		notify(commontray.NotifyInfo, m.Title, m.Message)
+	}
+	if client != nil {
+		syncBadges(ctx, client, s)
+	}
+}
+
+// awardMilestones records the badges of the milestones reached and returns
+// the ones that are new.
+func awardMilestones(stats contributionStats, now time.Time) []milestone {
+	var awarded []milestone
+	for _, m := range milestones {
+		if m.reached(stats) && store.AwardBadge(m.ID, now.UTC()) {
+			awarded = append(awarded, m)
+		}
+	}
+	return awarded
+}
+
+// tokensServed returns the tokens the node served according to the backend.
+func tokensServed(ctx context.Context, client *auth.Client, s *auth.Session) (int64, error) {
+	body, err := json.Marshal(map[string]string{"node_id": store.GetID()})
+	if err != nil {
+		return 0, err
+	}
+	req, err := client.NewRequest(ctx, http.MethodPost, NodeStatsPath, bytes.NewReader(body), s)
+	if err != nil {
+		return 0, err
+	}
+	var stats struct {
+		TokensServed int64 `json:"tokens_served"`
+	}
+	if err := client.Do(req, &stats); err != nil {
+		return 0, err
+	}
+	return stats.TokensServed, nil
+}
+
+// syncBadges records the badges the backend doesn't have yet.
+func syncBadges(ctx context.Context, client *auth.Client, s *auth.Session) {
+	for id, badge := range store.GetBadges() {
+		if badge.Synced {
+			continue
+		}
+		body, err := json.Marshal(map[string]any{"node_id": store.GetID(), "badge": id, "awarded_at": badge.AwardedAt})
+		if err != nil {
+			continue
+		}
+		req, err := client.NewRequest(ctx, http.MethodPost, NodeBadgesPath, bytes.NewReader(body), s)
+		if err == nil {
+			err = client.Do(req, nil)
+		}
+		if err != nil {
+			slog.Debug("failed to record badge", "badge", id, "error", err)
+			return // Retried at the next check
+		}
+		store.SetBadgeSynced(id)
+	}
+}
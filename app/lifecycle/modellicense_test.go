@@ -0,0 +1,26 @@
+//go:build windows && unit_test
+
+package lifecycle
+
+import "testing"
+
+func TestIsModelLicenseError(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected bool
+	}{
+		{"huggingface_hub.errors.GatedRepoError: 403 Client Error.", true},
+		{"Access to model meta-llama/Llama-3.1-8B-Instruct is restricted and you are not in the authorized list.", true},
+		{"Cannot access gated repo for url https://huggingface.co/... You must have access to it and be authenticated to access it.", true},
+		{"Downloading model shards: 100%", false},
+		{"requests.exceptions.HTTPError: 403 Client Error: Forbidden for url: https://huggingface.co/api/whoami-v2", false},
+		{"Access to model files is rate limited, retrying in 10 s", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		if got := isModelLicenseError(test.line); got != test.expected {
+			t.Errorf("isModelLicenseError(%q) = %v, expected %v", test.line, got, test.expected)
+		}
+	}
+}
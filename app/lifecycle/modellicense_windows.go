@@ -0,0 +1,72 @@
+package lifecycle
+
+import (
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/ReEnvision-AI/systray/app/tray/commontray"
+	"golang.org/x/sys/windows"
+)
+
+// ModelPageBaseURL is where the license for a gated model is accepted.
+var ModelPageBaseURL = "https://huggingface.co/"
+
+// licensePromptMu keeps a crash-looping container from stacking dialogs.
+var licensePromptMu sync.Mutex
+
+// modelLicenseMarkers are fragments of the errors huggingface_hub prints when
+// a gated repository refuses the download. A plain 403 may as well be an
+// invalid token or rate limiting, so it doesn't count.
+var modelLicenseMarkers = []string{
+	"gatedrepoerror",
+	"cannot access gated repo",
+	"is restricted and you are not in the authorized list",
+	"you must have access to it and be authenticated",
+}
+
+// isModelLicenseError reports whether a line of container output shows the
+// model download was refused because its license hasn't been accepted.
+func isModelLicenseError(line string) bool {
+	line = strings.ToLower(line)
+	for _, marker := range modelLicenseMarkers {
+		if strings.Contains(line, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// handleModelLicenseRequired explains that the model is gated, opens its
+// HuggingFace page, and restarts once the user confirms they accepted it.
+func handleModelLicenseRequired() {
+	if !licensePromptMu.TryLock() {
+		return
+	}
+	defer licensePromptMu.Unlock()
+
+	model := currentAppConfig().ModelName
+	modelURL := currentEndpoints().ModelPage + model
+	slog.Warn("Model license has not been accepted", "model", model)
+	notify(commontray.NotifyError, "You must accept the model license", model+" requires accepting its license on HuggingFace")
+
+	if messageBox("ReEnvision AI - Model license",
+		"The model "+model+" is gated. You must accept its license on HuggingFace, "+
+			"using the account your token belongs to, before it can be downloaded.\n\n"+
+			"Open the model page now?",
+		windows.MB_YESNO|windows.MB_ICONWARNING) != IDYES {
+		return
+	}
+	if err := openURL(modelURL); err != nil {
+		slog.Error("Failed to open model page", "url", modelURL, "error", err)
+		return
+	}
+
+	if messageBox("ReEnvision AI - Model license",
+		"Once you have accepted the license on "+modelURL+", click OK to start ReEnvision AI again.",
+		windows.MB_OKCANCEL|windows.MB_ICONINFORMATION) != IDOK {
+		return
+	}
+	slog.Info("User accepted the model license, retrying start")
+	handleStartRequest()
+}
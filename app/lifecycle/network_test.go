@@ -0,0 +1,60 @@
+//go:build windows && unit_test
+
+package lifecycle
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestStartDeferredWhileOffline(t *testing.T) {
+	mt := setupMockTray()
+	defer resetState()
+	defer networkOffline.Store(false)
+	now := time.Now()
+
+	networkChanged(false, now)
+	handleStartRequest()
+	stateMu.Lock()
+	state := currentState
+	stateMu.Unlock()
+	if state != StateStopped {
+		t.Fatalf("expected the node to stay stopped while offline, got %v", state)
+	}
+	if mt.statusText != waitingForNetworkText {
+		t.Errorf("expected status %q, got %q", waitingForNetworkText, mt.statusText)
+	}
+
+	if action, _ := networkChanged(false, now); action != networkNoAction {
+		t.Errorf("expected no action without a change, got %v", action)
+	}
+	if action, offlineFor := networkChanged(true, now.Add(time.Minute)); action != networkStartNode || offlineFor != time.Minute {
+		t.Errorf("expected the deferred start after a minute, got %v after %v", action, offlineFor)
+	}
+	if action, _ := networkChanged(true, now); action != networkNoAction {
+		t.Errorf("expected no action once online, got %v", action)
+	}
+}
+
+func TestRunningNodeOffline(t *testing.T) {
+	mt := setupMockTray()
+	defer resetState()
+	defer networkOffline.Store(false)
+	now := time.Now()
+
+	SetState(StateRunning)
+	networkChanged(false, now)
+	if mt.statusText != offlineText {
+		t.Errorf("expected status %q, got %q", offlineText, mt.statusText)
+	}
+	if interval := sendHeartbeat(context.Background()); interval <= 0 {
+		t.Errorf("expected the heartbeat to be skipped until the next interval, got %v", interval)
+	}
+	if action, _ := networkChanged(true, now.Add(time.Second)); action != networkReconnect {
+		t.Errorf("expected the node to reconnect, got %v", action)
+	}
+	if mt.statusText != "Running" {
+		t.Errorf("expected the status to be restored, got %q", mt.statusText)
+	}
+}
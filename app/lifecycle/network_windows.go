@@ -0,0 +1,182 @@
+package lifecycle
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ReEnvision-AI/systray/app/tray/commontray"
+	"golang.org/x/sys/windows"
+)
+
+// Windows notifies the app when network interfaces change, after which it
+// checks whether the internet is still reachable. While it isn't, heartbeats
+// are paused, a running node shows as offline and starts wait for the
+// network. Once it is back, a deferred start goes ahead, and a running node
+// whose peers have likely dropped it is restarted to rejoin the swarm.
+
+const (
+	networkSettleDelay = 2 * time.Second  // Interfaces change in bursts while connecting
+	networkPoll        = 30 * time.Second // Checked regardless, as not every outage changes an interface
+	peerReconnectAfter = time.Minute      // Outages after which peers have dropped the node
+)
+
+const (
+	offlineText           = "Offline"
+	waitingForNetworkText = "Waiting for the network"
+)
+
+// networkAction is what to do after the connectivity changed.
+type networkAction int
+
+const (
+	networkNoAction  networkAction = iota
+	networkStartNode               // Start the node deferred while offline
+	networkReconnect               // Check the running node rejoined the swarm
+)
+
+var (
+	networkOffline atomic.Bool
+
+	networkMu       sync.Mutex
+	offlineSince    time.Time // Guarded by networkMu
+	startWhenOnline bool      // A start was deferred, guarded by networkMu
+
+	networkChanges  = make(chan struct{}, 1)
+	networkCallback = sync.OnceValue(func() uintptr { return windows.NewCallback(networkChangeProc) })
+)
+
+// networkChangeProc is called by Windows when an interface changes.
+func networkChangeProc(callerContext, row, notificationType uintptr) uintptr {
+	select {
+	case networkChanges <- struct{}{}:
+	default:
+	}
+	return 0
+}
+
+// StartNetworkWatch follows the connectivity of the system until ctx is
+// cancelled.
+func StartNetworkWatch(ctx context.Context) {
+	if !networkUp() {
+		slog.Warn("No network connection")
+		networkChanged(false, time.Now())
+	} else {
+		refreshMetered()
+		refreshVPN()
+	}
+	var handle windows.Handle
+	if err := windows.NotifyIpInterfaceChange(windows.AF_UNSPEC, networkCallback(), nil, false, &handle); err != nil {
+		slog.Warn("Not notified of network changes, polling instead", "error", err)
+		handle = 0
+	}
+
+	go func() {
+		if handle != 0 {
+			defer windows.CancelMibChangeNotify2(handle) //nolint:errcheck
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-networkChanges:
+				time.Sleep(networkSettleDelay)
+				select {
+				case <-networkChanges:
+				default:
+				}
+			case <-time.After(networkPoll):
+			}
+			up := networkUp()
+			action, offlineFor := networkChanged(up, time.Now())
+			if up {
+				refreshMetered()
+				refreshVPN()
+			}
+			switch action {
+			case networkStartNode:
+				slog.Info("Starting the node deferred while offline")
+				go handleStartRequest()
+			case networkReconnect:
+				go reconnectAfterOutage(ctx, offlineFor)
+			}
+		}
+	}()
+}
+
+// networkChanged records whether the internet is reachable and returns what
+// to do about it, with how long it was unreachable when it came back.
+func networkChanged(up bool, now time.Time) (networkAction, time.Duration) {
+	networkMu.Lock()
+	defer networkMu.Unlock()
+	if up != networkOffline.Load() {
+		return networkNoAction, 0 // Unchanged
+	}
+	stateMu.Lock()
+	state := currentState
+	stateMu.Unlock()
+	running := state == StateRunning || state == StateLoading || state == StateStarting
+
+	if !up {
+		networkOffline.Store(true)
+		offlineSince = now
+		if running {
+			slog.Warn("Network connection lost, the node is offline")
+			setStatusText(state, offlineText)
+			notify(commontray.NotifyWarning, "Node offline", "The network connection was lost. The node resumes once it is back")
+		}
+		return networkNoAction, 0
+	}
+
+	networkOffline.Store(false)
+	offlineFor := now.Sub(offlineSince)
+	slog.Info("Network connection restored", "offline_for", offlineFor.Round(time.Second))
+	if startWhenOnline {
+		startWhenOnline = false
+		if state == StateStopped || state == StateError {
+			return networkStartNode, offlineFor
+		}
+	}
+	if running {
+		setStatusText(state, "")
+		return networkReconnect, offlineFor
+	}
+	return networkNoAction, offlineFor
+}
+
+// deferStartWhileOffline defers a start request until the network is back
+// and reports whether it did.
+func deferStartWhileOffline() bool {
+	networkMu.Lock()
+	defer networkMu.Unlock()
+	if !networkOffline.Load() {
+		return false
+	}
+	stateMu.Lock()
+	state := currentState
+	stateMu.Unlock()
+	if state != StateStopped && state != StateError {
+		return false // Already starting or running
+	}
+	slog.Info("No network connection, starting the node once it is back")
+	startWhenOnline = true
+	setStatusText(state, waitingForNetworkText)
+	notify(commontray.NotifyInfo, "Waiting for the network", "The node starts once the computer is connected to the internet")
+	return true
+}
+
+// reconnectAfterOutage restarts the running node if its peers have likely
+// dropped it during the outage or it no longer passes the health probe.
+func reconnectAfterOutage(ctx context.Context, offlineFor time.Duration) {
+	if offlineFor < peerReconnectAfter {
+		err := probeHealth(ctx, currentAppConfig().HealthCheck)
+		if err == nil {
+			return
+		}
+		slog.Info("Node is unhealthy after a network outage", "error", err)
+	}
+	slog.Info("Restarting node to rejoin the swarm after a network outage", "offline_for", offlineFor.Round(time.Second))
+	restartRunningNode()
+}
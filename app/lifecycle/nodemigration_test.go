@@ -0,0 +1,19 @@
+//go:build windows && unit_test
+
+package lifecycle
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseCacheManifest(t *testing.T) {
+	output := []byte("1024\t/cache/hub\n8\t/cache/modules\nnot du output\n")
+	expected := []cacheEntry{
+		{Path: "hub", Size: 1024 * 1024},
+		{Path: "modules", Size: 8 * 1024},
+	}
+	if got := parseCacheManifest(output); !slices.Equal(got, expected) {
+		t.Errorf("parseCacheManifest() = %v, expected %v", got, expected)
+	}
+}
@@ -0,0 +1,343 @@
+package lifecycle
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ReEnvision-AI/systray/app/auth"
+	"github.com/ReEnvision-AI/systray/app/format"
+	"github.com/ReEnvision-AI/systray/app/store"
+	"github.com/ReEnvision-AI/systray/app/tray/commontray"
+	"github.com/ReEnvision-AI/systray/version"
+	"github.com/google/uuid"
+	"golang.org/x/sys/windows"
+)
+
+// A node is moved by deactivating it on the old machine and writing its ID,
+// settings and config to an export file, which the new machine imports and
+// activates. The node keeps its ID, so its contribution history stays
+// attached to it.
+
+// Backend endpoints for node migration, relative to the Supabase URL
+var (
+	NodeDeactivatePath = "/functions/v1/deactivate-node"
+	NodeActivatePath   = "/functions/v1/activate-node"
+)
+
+const nodeExportFormatVersion = 1
+
+// nodeExport is the file written on the old machine and imported on the new one.
+type nodeExport struct {
+	FormatVersion int             `json:"format_version"`
+	NodeID        string          `json:"node_id"`
+	ExportedAt    time.Time       `json:"exported_at"`
+	AppVersion    string          `json:"app_version"`
+	Settings      store.Settings  `json:"settings"`
+	Config        json.RawMessage `json:"config,omitempty"`         // config.json of the old machine
+	CacheManifest []cacheEntry    `json:"cache_manifest,omitempty"` // Models cached on the old machine
+}
+
+type cacheEntry struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+// handleMoveNode deactivates the node on this machine and exports it so it
+// can be imported on another one. The app exits once the export is written.
+func handleMoveNode() {
+	if !confirm("Move this node to another machine",
+		"This stops the node and deactivates it on this machine, then saves an export file to your Downloads folder. "+
+			"Import that file on the new machine with \"Import a node\" in the tray menu to continue with the same node "+
+			"and contribution history.\n\nReEnvision AI will exit when the export is saved. Continue?") {
+		return
+	}
+	includeCache := messageBox("Move this node to another machine",
+		"Include the list of models cached on this machine in the export, so the new machine can tell what it will download?",
+		windows.MB_YESNO|windows.MB_ICONQUESTION) == IDYES
+
+	ctx, cancel := context.WithTimeout(context.Background(), DataRequestTimeout)
+	defer cancel()
+
+	path, err := moveNode(ctx, includeCache)
+	if err != nil {
+		if errors.Is(err, auth.ErrNoSession) {
+			return
+		}
+		slog.Error("Failed to move node", "error", err)
+		notify(commontray.NotifyError, "Unable to move this node", "Open the logs from the tray menu for details")
+		return
+	}
+
+	slog.Info("Node exported for migration", "path", path)
+	messageBox("Node exported",
+		"The node was saved to:\n\n"+path+"\n\nCopy this file to the new machine, install ReEnvision AI there and choose "+
+			"\"Import a node\" from the tray menu.",
+		windows.MB_OK|windows.MB_ICONINFORMATION)
+	handleQuit()
+}
+
+func moveNode(ctx context.Context, includeCache bool) (string, error) {
+	cfg, err := readConfig()
+	if err != nil {
+		return "", err
+	}
+	client, err := newAuthClient(cfg)
+	if err != nil {
+		return "", err
+	}
+	s, err := getSession(ctx, client)
+	if err != nil {
+		return "", err
+	}
+
+	export := nodeExport{
+		FormatVersion: nodeExportFormatVersion,
+		NodeID:        store.GetID(),
+		ExportedAt:    time.Now().UTC(),
+		AppVersion:    version.Version,
+		Settings:      store.GetSettings(),
+	}
+	if configFile, err := configFilePath(); err == nil {
+		if data, err := os.ReadFile(configFile); err == nil && json.Valid(data) {
+			export.Config = data
+		}
+	}
+	if includeCache {
+		if export.CacheManifest, err = cacheManifest(ctx, cfg.ContainerImage); err != nil {
+			slog.Warn("Unable to list cached models, exporting without them", "error", err)
+		}
+	}
+
+	stateMu.Lock()
+	running := currentState == StateRunning || currentState == StateLoading || currentState == StateStarting || currentState == StatePaused
+	stateMu.Unlock()
+	if running {
+		handleStopRequest()
+	}
+
+	// The export is written before deactivating, so a failed write can't
+	// leave the node deactivated without a file to import it from
+	dir, err := windows.KnownFolderPath(windows.FOLDERID_Downloads, 0)
+	if err != nil {
+		return "", fmt.Errorf("unable to locate Downloads folder: %w", err)
+	}
+	path := filepath.Join(dir, fmt.Sprintf("ReEnvisionAI-node-%s.json", export.NodeID[:8]))
+	data, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := writeFileSync(path, data, 0o600); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	if err := setNodeActive(ctx, client, s, NodeDeactivatePath, export.NodeID); err != nil {
+		if err := os.Remove(path); err != nil {
+			slog.Warn("Failed to remove node export", "path", path, "error", err)
+		}
+		return "", fmt.Errorf("failed to deactivate node: %w", err)
+	}
+
+	// The node lives on elsewhere now, this machine gets a new one if the app
+	// is started again
+	if err := store.Reset(); err != nil {
+		slog.Warn("Failed to reset store after moving node", "error", err)
+	}
+	return path, nil
+}
+
+// handleImportNode takes over a node exported on another machine.
+func handleImportNode() {
+	path, ok := openFileDialog("Import a ReEnvision AI node", "ReEnvision AI node export", "*.json")
+	if !ok {
+		return
+	}
+	export, err := readNodeExport(path)
+	if err != nil {
+		slog.Warn("Invalid node export", "path", path, "error", err)
+		messageBox("Import a node", "This file is not a ReEnvision AI node export:\n\n"+err.Error(), windows.MB_OK|windows.MB_ICONERROR)
+		return
+	}
+
+	current := store.GetID()
+	if export.NodeID == current {
+		messageBox("Import a node", "This machine already runs that node.", windows.MB_OK|windows.MB_ICONINFORMATION)
+		return
+	}
+	message := fmt.Sprintf("Continue with node %s, exported on %s, on this machine?\n\n"+
+		"The current node %s of this machine is replaced.", export.NodeID, export.ExportedAt.Local().Format("Jan 2 15:04"), current)
+	if n := len(export.CacheManifest); n > 0 {
+		var size int64
+		for _, entry := range export.CacheManifest {
+			size += entry.Size
+		}
+		message += fmt.Sprintf("\n\nThe old machine had %s cached models (%s), they will be downloaded when the node starts.",
+			format.Count(int64(n)), format.Bytes(size))
+	}
+	if !confirm("Import a node", message) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), DataRequestTimeout)
+	defer cancel()
+	if err := importNode(ctx, export); err != nil {
+		if errors.Is(err, auth.ErrNoSession) {
+			return
+		}
+		slog.Error("Failed to import node", "error", err)
+		notify(commontray.NotifyError, "Unable to import the node", "Open the logs from the tray menu for details")
+		return
+	}
+
+	if err := t.SetQuietMode(store.GetQuietMode()); err != nil {
+		slog.Warn("failed to apply quiet mode to tray", "error", err)
+	}
+	if err := t.SetTelemetryEnabled(store.GetTelemetryEnabled()); err != nil {
+		slog.Warn("failed to apply telemetry preference to tray", "error", err)
+	}
+	notify(commontray.NotifyInfo, "Node imported", "Start the node to continue contributing on this machine")
+}
+
+func readNodeExport(path string) (*nodeExport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var export nodeExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, err
+	}
+	if export.FormatVersion != nodeExportFormatVersion {
+		return nil, fmt.Errorf("unsupported export format %d", export.FormatVersion)
+	}
+	if _, err := uuid.Parse(export.NodeID); err != nil {
+		return nil, fmt.Errorf("invalid node ID %q", export.NodeID)
+	}
+	return &export, nil
+}
+
+func importNode(ctx context.Context, export *nodeExport) error {
+	cfg, err := readConfig()
+	if err != nil {
+		return err
+	}
+	client, err := newAuthClient(cfg)
+	if err != nil {
+		return err
+	}
+	s, err := getSession(ctx, client)
+	if err != nil {
+		return err
+	}
+	if err := setNodeActive(ctx, client, s, NodeActivatePath, export.NodeID); err != nil {
+		return fmt.Errorf("failed to activate node: %w", err)
+	}
+	// The replaced node would otherwise stay active without a machine
+	if current := store.GetID(); current != "" {
+		if err := setNodeActive(ctx, client, s, NodeDeactivatePath, current); err != nil {
+			slog.Warn("Failed to deactivate the replaced node", "node_id", current, "error", err)
+		}
+	}
+
+	// The container name is derived from the node ID, so stop the old one first
+	stateMu.Lock()
+	running := currentState == StateRunning || currentState == StateLoading || currentState == StateStarting || currentState == StatePaused
+	stateMu.Unlock()
+	if running {
+		handleStopRequest()
+	}
+
+	store.ImportNode(export.NodeID, export.Settings)
+	if len(export.Config) > 0 {
+		if err := importConfig(export.Config); err != nil {
+			slog.Warn("Failed to import config, keeping this machine's config", "error", err)
+		}
+	}
+	return nil
+}
+
+// importConfig replaces config.json with the exported one, keeping a backup.
+func importConfig(data json.RawMessage) error {
+	configFile, err := configFilePath()
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(configFile, configFile+".bak"); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return os.WriteFile(configFile, data, 0o644)
+}
+
+// writeFileSync writes the file and flushes it to disk before returning.
+func writeFileSync(path string, data []byte, perm os.FileMode) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func setNodeActive(ctx context.Context, client *auth.Client, s *auth.Session, path, nodeID string) error {
+	body, err := json.Marshal(map[string]string{"node_id": nodeID})
+	if err != nil {
+		return err
+	}
+	req, err := client.NewRequest(ctx, http.MethodPost, path, bytes.NewReader(body), s)
+	if err != nil {
+		return err
+	}
+	return client.Do(req, nil)
+}
+
+// cacheManifest lists the top level entries of the cache volume with their
+// sizes, using the node image since the volume lives in the Podman machine.
+func cacheManifest(ctx context.Context, image string) ([]cacheEntry, error) {
+	output, err := helperOutput(podmanCommand(ctx, "run", "--rm",
+		"--volume="+cacheVolumeName+":"+cacheVolumeMountPath+":ro",
+		"--entrypoint=sh", image,
+		"-c", "du -sk "+cacheVolumeMountPath+"/* 2>/dev/null || true",
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cache volume: %w", err)
+	}
+	return parseCacheManifest(output), nil
+}
+
+// parseCacheManifest parses the output of du -sk.
+func parseCacheManifest(output []byte) []cacheEntry {
+	var entries []cacheEntry
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		size, path, ok := strings.Cut(scanner.Text(), "\t")
+		if !ok {
+			continue
+		}
+		kb, err := strconv.ParseInt(strings.TrimSpace(size), 10, 64)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, cacheEntry{
+			Path: strings.TrimPrefix(path, cacheVolumeMountPath+"/"),
+			Size: kb * 1024,
+		})
+	}
+	return entries
+}
@@ -0,0 +1,175 @@
+package lifecycle
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/ReEnvision-AI/systray/app/format"
+	"github.com/ReEnvision-AI/systray/app/store"
+	"github.com/ReEnvision-AI/systray/app/tray/commontray"
+)
+
+// notify shows a tray notification. In quiet mode only errors are shown.
+func notify(level commontray.NotificationLevel, title, message string) {
+	notifyAction(level, title, message, nil)
+}
+
+// notifyAction shows a tray notification that sends on action when clicked.
+// Returns false if it was suppressed by quiet mode or couldn't be shown.
+func notifyAction(level commontray.NotificationLevel, title, message string, action chan struct{}) bool {
+	if level < commontray.NotifyError && store.GetQuietMode() {
+		slog.Debug("quiet mode enabled, suppressing notification", "title", title)
+		return false
+	}
+	if err := t.DisplayActionNotification(title, message, level, action); err != nil {
+		slog.Warn("failed to display notification", "title", title, "error", err)
+		return false
+	}
+	return true
+}
+
+// Recurring failures, like the cloud being unreachable, are aggregated per
+// class instead of notified every time. A class is notified once it failed
+// Threshold times in a row, then the notification is shown again with the
+// updated duration at most every Interval, and once more when it recovers.
+
+// Failure classes
+const (
+	failureHeartbeat = "heartbeat"
+)
+
+type failurePolicy struct {
+	Title     string // e.g. "Cloud connection lost", followed by how long ago
+	Message   string
+	Recovered string // Title of the notification on recovery, empty for none
+	Threshold int    // Consecutive failures before notifying
+	Interval  time.Duration
+}
+
+var failurePolicies = map[string]failurePolicy{
+	failureHeartbeat: {
+		Title:     "Cloud connection lost",
+		Message:   "Your node can't reach ReEnvision AI, so its contribution may not be counted",
+		Recovered: "Cloud connection restored",
+		Threshold: 3,
+		Interval:  30 * time.Minute,
+	},
+}
+
+type failureState struct {
+	Since    time.Time // First failure of the streak
+	Count    int
+	Notified time.Time // Zero until notified
+}
+
+var (
+	failuresMu sync.Mutex
+	failures   = map[string]*failureState{}
+)
+
+// reportFailure records a failure of class and notifies about it as its
+// policy allows.
+func reportFailure(class string, err error) {
+	policy := failurePolicies[class]
+	now := time.Now()
+
+	failuresMu.Lock()
+	state := failures[class]
+	if state == nil {
+		state = &failureState{Since: now}
+		failures[class] = state
+	}
+	state.Count++
+	nodeMetrics.Add("failures."+class, 1)
+	show := state.Count >= policy.Threshold && (state.Notified.IsZero() || now.Sub(state.Notified) >= policy.Interval)
+	if show {
+		state.Notified = now
+	}
+	since, count := state.Since, state.Count
+	failuresMu.Unlock()
+
+	slog.Debug("recurring failure", "class", class, "count", count, "since", since, "error", err)
+	if show {
+		title, message := failureNotification(policy, since, count, now)
+		notify(commontray.NotifyWarning, title, message)
+	}
+}
+
+// reportRecovery ends the failure streak of class.
+func reportRecovery(class string) {
+	failuresMu.Lock()
+	state := failures[class]
+	delete(failures, class)
+	failuresMu.Unlock()
+
+	if state == nil || state.Notified.IsZero() {
+		return
+	}
+	policy := failurePolicies[class]
+	slog.Info("recovered from recurring failure", "class", class, "count", state.Count, "duration", time.Since(state.Since))
+	if policy.Recovered != "" {
+		notify(commontray.NotifyInfo, policy.Recovered, fmt.Sprintf("After %s", format.Duration(time.Since(state.Since))))
+	}
+}
+
+// failureNotification returns the notification of a failure streak, e.g.
+// "Cloud connection lost 23 minutes ago".
+func failureNotification(policy failurePolicy, since time.Time, count int, now time.Time) (title, message string) {
+	ago := now.Sub(since)
+	switch {
+	case ago < time.Minute:
+		title = policy.Title
+	case ago < time.Hour:
+		minutes := int(ago / time.Minute)
+		unit := "minutes"
+		if minutes == 1 {
+			unit = "minute"
+		}
+		title = fmt.Sprintf("%s %d %s ago", policy.Title, minutes, unit)
+	default:
+		title = fmt.Sprintf("%s %s ago", policy.Title, format.Duration(ago))
+	}
+	return title, fmt.Sprintf("%s (%s failed attempts)", policy.Message, format.Count(int64(count)))
+}
+
+// showStartupNotice tells the user the node is starting after login, as the
+// app is otherwise invisible for minutes while Podman starts. The badge is
+// cleared when the container leaves the starting state.
+func showStartupNotice() {
+	notify(commontray.NotifyInfo, "ReEnvision AI", "ReEnvision AI is starting your node (this can take a few minutes)")
+	if err := t.ShowStartingBadge(true); err != nil {
+		slog.Warn("failed to show starting badge", "error", err)
+	}
+}
+
+func handleToggleStartupNotice() {
+	enabled := !store.GetStartupNotice()
+	store.SetStartupNotice(enabled)
+	slog.Info("Startup notification preference changed", "enabled", enabled)
+	if err := t.SetStartupNotice(enabled); err != nil {
+		slog.Warn("failed to update tray for startup notification preference", "error", err)
+	}
+}
+
+// handleToggleAdvancedSubmenu only updates the check mark, the menu is built
+// once at startup so the new layout applies the next time the app starts.
+func handleToggleAdvancedSubmenu() {
+	enabled := !store.GetAdvancedSubmenu()
+	store.SetAdvancedSubmenu(enabled)
+	slog.Info("Advanced submenu preference changed", "enabled", enabled)
+	if err := t.SetAdvancedSubmenu(enabled); err != nil {
+		slog.Warn("failed to update tray for advanced submenu preference", "error", err)
+	}
+	notify(commontray.NotifyInfo, "ReEnvision AI", "The menu layout changes the next time ReEnvision AI starts.")
+}
+
+func handleToggleQuietMode() {
+	quiet := !store.GetQuietMode()
+	store.SetQuietMode(quiet)
+	slog.Info("Quiet mode changed", "enabled", quiet)
+	if err := t.SetQuietMode(quiet); err != nil {
+		slog.Warn("failed to update tray for quiet mode", "error", err)
+	}
+}
@@ -0,0 +1,49 @@
+//go:build windows && unit_test
+
+package lifecycle
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestFailureNotification(t *testing.T) {
+	policy := failurePolicies[failureHeartbeat]
+	now := time.Now()
+	tests := []struct {
+		ago      time.Duration
+		expected string
+	}{
+		{30 * time.Second, "Cloud connection lost"},
+		{time.Minute, "Cloud connection lost 1 minute ago"},
+		{23 * time.Minute, "Cloud connection lost 23 minutes ago"},
+	}
+	for _, test := range tests {
+		if title, _ := failureNotification(policy, now.Add(-test.ago), 5, now); title != test.expected {
+			t.Errorf("expected %q for %v, got %q", test.expected, test.ago, title)
+		}
+	}
+}
+
+func TestReportFailureAggregates(t *testing.T) {
+	mt := setupMockTray()
+	defer delete(failures, failureHeartbeat)
+
+	policy := failurePolicies[failureHeartbeat]
+	for range policy.Threshold + 5 {
+		reportFailure(failureHeartbeat, errors.New("connection refused"))
+	}
+	if len(mt.notifications) != 1 {
+		t.Fatalf("expected a single notification, got %v", mt.notifications)
+	}
+
+	reportRecovery(failureHeartbeat)
+	if !slices.Contains(mt.notifications, policy.Recovered) {
+		t.Errorf("expected a recovery notification, got %v", mt.notifications)
+	}
+	if _, ok := failures[failureHeartbeat]; ok {
+		t.Error("expected recovery to end the failure streak")
+	}
+}
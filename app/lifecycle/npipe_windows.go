@@ -0,0 +1,133 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+// The Podman machine serves its API on a Windows named pipe. pipeConn is a
+// minimal net.Conn over a pipe opened for overlapped IO, so the HTTP client
+// can read and write at the same time. Deadlines aren't supported, requests
+// are bounded by their context, which closes the connection.
+
+const pipeBusyRetryInterval = 50 * time.Millisecond
+
+type pipeConn struct {
+	handle windows.Handle
+	path   string
+
+	mu       sync.Mutex
+	closed   bool
+	inflight sync.WaitGroup
+}
+
+type pipeAddr string
+
+func (a pipeAddr) Network() string { return "npipe" }
+func (a pipeAddr) String() string  { return string(a) }
+
+// dialPipe opens the named pipe at path, such as
+// \\.\pipe\podman-machine-default, waiting while all its instances are busy.
+func dialPipe(ctx context.Context, path string) (net.Conn, error) {
+	name, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		handle, err := windows.CreateFile(name,
+			windows.GENERIC_READ|windows.GENERIC_WRITE, 0, nil,
+			windows.OPEN_EXISTING, windows.FILE_FLAG_OVERLAPPED, 0)
+		if err == nil {
+			return &pipeConn{handle: handle, path: path}, nil
+		}
+		if !errors.Is(err, windows.ERROR_PIPE_BUSY) {
+			return nil, &os.PathError{Op: "open", Path: path, Err: err}
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(pipeBusyRetryInterval):
+		}
+	}
+}
+
+// do starts an overlapped read or write and waits for it to complete. The
+// operation is started under mu so Close can't miss it when cancelling.
+func (c *pipeConn) do(op func(*windows.Overlapped) error) (int, error) {
+	event, err := windows.CreateEvent(nil, 1, 0, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer windows.CloseHandle(event)
+	overlapped := windows.Overlapped{HEvent: event}
+
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return 0, net.ErrClosed
+	}
+	c.inflight.Add(1)
+	defer c.inflight.Done()
+	err = op(&overlapped)
+	c.mu.Unlock()
+	if err != nil && !errors.Is(err, windows.ERROR_IO_PENDING) {
+		return 0, err
+	}
+
+	var n uint32
+	err = windows.GetOverlappedResult(c.handle, &overlapped, &n, true)
+	return int(n), err
+}
+
+func (c *pipeConn) Read(b []byte) (int, error) {
+	n, err := c.do(func(o *windows.Overlapped) error { return windows.ReadFile(c.handle, b, nil, o) })
+	switch {
+	case errors.Is(err, windows.ERROR_BROKEN_PIPE), errors.Is(err, windows.ERROR_PIPE_NOT_CONNECTED):
+		return n, io.EOF
+	case errors.Is(err, windows.ERROR_OPERATION_ABORTED):
+		return n, net.ErrClosed
+	}
+	return n, err
+}
+
+func (c *pipeConn) Write(b []byte) (int, error) {
+	written := 0
+	for written < len(b) {
+		n, err := c.do(func(o *windows.Overlapped) error { return windows.WriteFile(c.handle, b[written:], nil, o) })
+		written += n
+		if errors.Is(err, windows.ERROR_OPERATION_ABORTED) {
+			return written, net.ErrClosed
+		} else if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// Close cancels pending reads and writes before closing the handle.
+func (c *pipeConn) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	_ = windows.CancelIoEx(c.handle, nil)
+	c.mu.Unlock()
+
+	c.inflight.Wait()
+	return windows.CloseHandle(c.handle)
+}
+
+func (c *pipeConn) LocalAddr() net.Addr                { return pipeAddr(c.path) }
+func (c *pipeConn) RemoteAddr() net.Addr               { return pipeAddr(c.path) }
+func (c *pipeConn) SetDeadline(t time.Time) error      { return nil }
+func (c *pipeConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *pipeConn) SetWriteDeadline(t time.Time) error { return nil }
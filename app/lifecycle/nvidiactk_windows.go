@@ -0,0 +1,85 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/ReEnvision-AI/systray/app/tray/commontray"
+)
+
+// Podman machine images don't always ship nvidia-ctk. When generating the
+// CDI spec fails because it is missing, the NVIDIA Container Toolkit is
+// installed with the steps documented by NVIDIA for dnf based distributions
+// and the generation retried, with the progress shown in the tray status.
+
+const (
+	nvidiaCTKRepoURL        = "https://nvidia.github.io/libnvidia-container/stable/rpm/nvidia-container-toolkit.repo"
+	nvidiaCTKInstallTimeout = 15 * time.Minute
+	nvidiaSetupTimeout      = 2 * time.Minute // For checking the GPU and generating the CDI spec
+)
+
+// nvidiaCTKInstallSteps are run in order, they aren't recorded in the store
+// since the check for nvidia-ctk is cheap.
+var nvidiaCTKInstallSteps = []MachineSetupStep{
+	{
+		Name:    "nvidia-ctk-repo",
+		Command: "curl -fsSL " + nvidiaCTKRepoURL + " | sudo tee /etc/yum.repos.d/nvidia-container-toolkit.repo",
+		Always:  true,
+	},
+	{
+		Name:    "nvidia-ctk-install",
+		Command: "sudo dnf install -y nvidia-container-toolkit",
+		Always:  true,
+	},
+}
+
+// generateNvidiaCDI generates the CDI spec, installing nvidia-ctk first if
+// the machine lacks it.
+func generateNvidiaCDI(ctx context.Context) error {
+	// IMPORTANT: This assumes passwordless sudo in the VM.
+	cdiCmd := fmt.Sprintf("sudo nvidia-ctk cdi generate --output=%s", nvidiaCDIConfPath)
+	step := MachineSetupStep{Name: nvidiaCDIStepName, Command: cdiCmd, Always: true}
+
+	cdiCtx, cancel := context.WithTimeout(ctx, nvidiaSetupTimeout)
+	defer cancel()
+	err := runMachineSetupStep(cdiCtx, step, "")
+	if err == nil || nvidiaCTKInstalled(cdiCtx) {
+		return err
+	}
+
+	slog.Warn("nvidia-ctk is missing in the Podman machine, installing the NVIDIA Container Toolkit", "error", err)
+	if err := installNvidiaCTK(ctx); err != nil {
+		notify(commontray.NotifyError, "Unable to install the NVIDIA Container Toolkit", "Open the logs from the tray menu for details")
+		return err
+	}
+	retryCtx, retryCancel := context.WithTimeout(ctx, nvidiaSetupTimeout)
+	defer retryCancel()
+	return runMachineSetupStep(retryCtx, step, "")
+}
+
+// nvidiaCTKInstalled reports whether nvidia-ctk is on the machine's PATH.
+func nvidiaCTKInstalled(ctx context.Context) bool {
+	return runHelper(helperCommand(ctx, "podman", "machine", "ssh", "command -v nvidia-ctk")) == nil
+}
+
+func installNvidiaCTK(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, nvidiaCTKInstallTimeout)
+	defer cancel()
+
+	notify(commontray.NotifyInfo, "Installing the NVIDIA Container Toolkit", "This is needed once for GPU support and takes a few minutes")
+	for i, step := range nvidiaCTKInstallSteps {
+		setStatusText(StateStarting, fmt.Sprintf("Installing NVIDIA Container Toolkit (%d/%d)...", i+1, len(nvidiaCTKInstallSteps)))
+		if err := runMachineSetupStep(ctx, step, ""); err != nil {
+			return fmt.Errorf("failed to install the NVIDIA Container Toolkit: %w", err)
+		}
+	}
+	setStatusText(StateStarting, "")
+	if !nvidiaCTKInstalled(ctx) {
+		return errors.New("nvidia-ctk is still missing after installing the NVIDIA Container Toolkit")
+	}
+	slog.Info("Installed the NVIDIA Container Toolkit in the Podman machine")
+	return nil
+}
@@ -0,0 +1,83 @@
+package lifecycle
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+)
+
+// Every start and stop request is an operation with an ID such as
+// "3f9a1c-start-7", the 7th start attempt of this app session. The ID of the
+// latest operation is added to every log entry, passed to the container and
+// hooks, and sent in heartbeats, so a single attempt can be followed from
+// the tray log to the container and the backend. It stays current after the
+// request returns, as a running node belongs to the start that launched it.
+
+const (
+	opStart = "start"
+	opStop  = "stop"
+)
+
+var (
+	sessionID     = newSessionID()
+	startAttempts atomic.Uint64
+	stopAttempts  atomic.Uint64
+
+	operationMu      sync.Mutex
+	currentOperation string
+)
+
+func newSessionID() string {
+	b := make([]byte, 3)
+	if _, err := rand.Read(b); err != nil {
+		return "000000"
+	}
+	return hex.EncodeToString(b)
+}
+
+// beginOperation makes a new operation of kind current and returns its ID.
+func beginOperation(kind string) string {
+	counter := &startAttempts
+	if kind == opStop {
+		counter = &stopAttempts
+	}
+	id := fmt.Sprintf("%s-%s-%d", sessionID, kind, counter.Add(1))
+
+	operationMu.Lock()
+	currentOperation = id
+	operationMu.Unlock()
+	slog.Debug("Operation started", "kind", kind)
+	return id
+}
+
+// operationID returns the ID of the current operation, empty before the
+// first start or stop.
+func operationID() string {
+	operationMu.Lock()
+	defer operationMu.Unlock()
+	return currentOperation
+}
+
+// operationHandler adds the current operation ID to log records.
+type operationHandler struct {
+	slog.Handler
+}
+
+func (h operationHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := operationID(); id != "" {
+		r.AddAttrs(slog.String("op", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h operationHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return operationHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h operationHandler) WithGroup(name string) slog.Handler {
+	return operationHandler{h.Handler.WithGroup(name)}
+}
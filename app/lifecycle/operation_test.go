@@ -0,0 +1,40 @@
+//go:build windows && unit_test
+
+package lifecycle
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestBeginOperation(t *testing.T) {
+	first := beginOperation(opStart)
+	second := beginOperation(opStart)
+	stop := beginOperation(opStop)
+
+	if !strings.HasPrefix(first, sessionID+"-start-") {
+		t.Errorf("unexpected start ID %q", first)
+	}
+	if first == second {
+		t.Errorf("start attempts share the ID %q", first)
+	}
+	if !strings.HasPrefix(stop, sessionID+"-stop-") {
+		t.Errorf("unexpected stop ID %q", stop)
+	}
+	if got := operationID(); got != stop {
+		t.Errorf("expected current operation %q, got %q", stop, got)
+	}
+}
+
+func TestOperationHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(operationHandler{slog.NewTextHandler(&buf, nil)}).With("component", "test")
+
+	id := beginOperation(opStart)
+	logger.Info("starting")
+	if !strings.Contains(buf.String(), "op="+id) {
+		t.Errorf("expected op=%s in log line %q", id, buf.String())
+	}
+}
@@ -0,0 +1,67 @@
+//go:build windows && unit_test
+
+package lifecycle
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInSchedule(t *testing.T) {
+	evenings := []scheduleWindow{
+		{Days: []string{"mon", "tue", "wed", "thu", "fri"}, Start: "18:00", End: "08:00"},
+		{Days: []string{"sat", "sun"}, Start: "00:00", End: "00:00"},
+	}
+	// 2026-03-02 is a Monday
+	at := func(day, hour, minute int) time.Time { return time.Date(2026, 3, day, hour, minute, 0, 0, time.Local) }
+
+	tests := []struct {
+		now      time.Time
+		expected bool
+	}{
+		{at(2, 12, 0), false}, // Monday noon
+		{at(2, 18, 0), true},  // Monday evening
+		{at(3, 7, 59), true},  // Tuesday morning, from Monday's window
+		{at(3, 8, 0), false},  // Window ended
+		{at(2, 7, 0), false},  // Monday morning, Sunday has no evening window
+		{at(7, 13, 0), true},  // Saturday, all day
+		{at(6, 23, 30), true}, // Friday night
+		{at(7, 7, 0), true},   // Saturday morning
+	}
+	for _, tt := range tests {
+		if got := inSchedule(evenings, tt.now); got != tt.expected {
+			t.Errorf("inSchedule at %s: expected %v, got %v", tt.now.Format("Mon 15:04"), tt.expected, got)
+		}
+	}
+
+	if !inSchedule(nil, at(2, 12, 0)) {
+		t.Error("expected no schedule to allow running")
+	}
+}
+
+func TestValidateSchedule(t *testing.T) {
+	if err := validateSchedule([]scheduleWindow{{Days: []string{"mon"}, Start: "09:00", End: "17:30"}}); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	for _, w := range []scheduleWindow{
+		{Start: "9", End: "17:00"},
+		{Start: "09:00", End: "25:00"},
+		{Days: []string{"monday"}, Start: "09:00", End: "17:00"},
+	} {
+		if err := validateSchedule([]scheduleWindow{w}); err == nil {
+			t.Errorf("expected %+v to be rejected", w)
+		}
+	}
+}
+
+func TestApplyOrgPolicy(t *testing.T) {
+	cfg := AppConfig{ModelName: "meta-llama/Llama-3.1-8B"}
+	applyOrgPolicy(&cfg, orgPolicy{})
+	if cfg.ModelName != "meta-llama/Llama-3.1-8B" {
+		t.Errorf("expected the configured model without a policy, got %s", cfg.ModelName)
+	}
+	applyOrgPolicy(&cfg, orgPolicy{ModelName: "mistralai/Mistral-7B-v0.1"})
+	if cfg.ModelName != "mistralai/Mistral-7B-v0.1" {
+		t.Errorf("expected the model of the policy, got %s", cfg.ModelName)
+	}
+}
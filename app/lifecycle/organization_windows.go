@@ -0,0 +1,362 @@
+package lifecycle
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ReEnvision-AI/systray/app/auth"
+	"github.com/ReEnvision-AI/systray/app/store"
+	"github.com/ReEnvision-AI/systray/app/tray/commontray"
+)
+
+// A node joins an organization, such as a university club or a company, with
+// an invite code so its contribution is pooled with the organization's other
+// nodes. Heartbeats carry the organization ID, and the organization's policy
+// is fetched from the backend and cached in the store: it can pick the model
+// served and restrict the node to a weekly schedule, outside of which the
+// node is paused.
+
+// Backend endpoints for organizations, relative to the Supabase URL
+var (
+	OrgJoinPath   = "/functions/v1/join-organization"
+	OrgLeavePath  = "/functions/v1/leave-organization"
+	OrgPolicyPath = "/functions/v1/organization-policy"
+)
+
+var (
+	OrgPolicyRefreshInterval = time.Hour
+	OrgScheduleCheckInterval = time.Minute
+)
+
+// orgPolicy is what an organization enforces on its nodes.
+type orgPolicy struct {
+	ModelName string           `json:"model_name"` // Served instead of the configured model, empty to keep it
+	Schedule  []scheduleWindow `json:"schedule"`   // When the node may run, always if empty
+}
+
+// scheduleWindow allows running between Start and End on Days, in local
+// time. A window ending before it starts runs past midnight.
+type scheduleWindow struct {
+	Days  []string `json:"days"`  // "mon" to "sun", every day if empty
+	Start string   `json:"start"` // HH:MM
+	End   string   `json:"end"`   // HH:MM
+}
+
+var scheduleDays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"} // In time.Weekday order
+
+// currentOrgPolicy returns the cached policy of the node's organization.
+func currentOrgPolicy() orgPolicy {
+	var policy orgPolicy
+	org := store.GetOrganization()
+	if org == nil || len(org.Policy) == 0 {
+		return policy
+	}
+	if err := json.Unmarshal(org.Policy, &policy); err != nil {
+		slog.Warn("Ignoring invalid organization policy", "organization", org.ID, "error", err)
+	}
+	return policy
+}
+
+// applyOrgPolicy overrides the config with the organization policy.
+func applyOrgPolicy(cfg *AppConfig, policy orgPolicy) {
+	if policy.ModelName != "" && policy.ModelName != cfg.ModelName {
+		slog.Info("Using the model of the organization policy", "model", policy.ModelName, "configured", cfg.ModelName)
+		cfg.ModelName = policy.ModelName
+	}
+}
+
+// parseClock parses HH:MM into minutes after midnight.
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q (expected HH:MM)", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// inSchedule reports whether now is in one of the windows, true if there are
+// none. Invalid windows are ignored.
+func inSchedule(windows []scheduleWindow, now time.Time) bool {
+	if len(windows) == 0 {
+		return true
+	}
+	minute := now.Hour()*60 + now.Minute()
+	today := scheduleDays[now.Weekday()]
+	yesterday := scheduleDays[(now.Weekday()+6)%7]
+	onDay := func(w scheduleWindow, day string) bool {
+		return len(w.Days) == 0 || slices.Contains(w.Days, day)
+	}
+
+	for _, w := range windows {
+		start, err := parseClock(w.Start)
+		if err != nil {
+			continue
+		}
+		end, err := parseClock(w.End)
+		if err != nil {
+			continue
+		}
+		switch {
+		case start < end:
+			if onDay(w, today) && minute >= start && minute < end {
+				return true
+			}
+		default: // Past midnight, or all day when start == end
+			if onDay(w, today) && minute >= start {
+				return true
+			}
+			if onDay(w, yesterday) && minute < end {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// handleJoinOrg asks for an invite code and joins its organization, or offers
+// to leave the current one.
+func handleJoinOrg() {
+	ctx, cancel := context.WithTimeout(context.Background(), DataRequestTimeout)
+	defer cancel()
+
+	if org := store.GetOrganization(); org != nil {
+		if !confirm("Leave organization",
+			"This node contributes with "+org.Name+". Leave the organization? You can join another one afterwards with an invite code.") {
+			return
+		}
+		if err := leaveOrg(ctx, org); err != nil {
+			if !errors.Is(err, auth.ErrNoSession) {
+				slog.Error("Failed to leave organization", "error", err)
+				notify(commontray.NotifyError, "Unable to leave the organization", "Open the logs from the tray menu for details")
+			}
+			return
+		}
+		notify(commontray.NotifyInfo, "You left "+org.Name, "The node now follows your own settings")
+		return
+	}
+
+	code, ok := promptForSecret("ReEnvision AI - Join an organization",
+		"Enter the invite code you received from your organization as the password.", "Invite code")
+	code = strings.TrimSpace(code)
+	if !ok || code == "" {
+		return
+	}
+	org, err := joinOrg(ctx, code)
+	if err != nil {
+		if errors.Is(err, auth.ErrNoSession) {
+			return
+		}
+		slog.Error("Failed to join organization", "error", err)
+		var apiErr *auth.APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+			notify(commontray.NotifyError, "Unknown invite code", "Check the code with your organization and try again")
+			return
+		}
+		notify(commontray.NotifyError, "Unable to join the organization", "Open the logs from the tray menu for details")
+		return
+	}
+	slog.Info("Joined organization", "organization", org.ID)
+	notify(commontray.NotifyInfo, "Welcome to "+org.Name, "Your node now contributes with your organization")
+	restartRunningNode()
+}
+
+func joinOrg(ctx context.Context, code string) (*store.Organization, error) {
+	client, s, err := backendSession(ctx, getSession)
+	if err != nil {
+		return nil, err
+	}
+	var org store.Organization
+	if err := orgRequest(ctx, client, s, OrgJoinPath, map[string]string{"invite_code": code}, &org); err != nil {
+		return nil, err
+	}
+	if org.ID == "" {
+		return nil, errors.New("the backend returned no organization")
+	}
+	if org.Name == "" {
+		org.Name = "your organization"
+	}
+	if policy, err := fetchOrgPolicy(ctx, client, s, org.ID); err != nil {
+		slog.Warn("Failed to fetch organization policy", "error", err)
+	} else {
+		org.Policy = policy
+	}
+	store.SetOrganization(&org)
+	return &org, nil
+}
+
+func leaveOrg(ctx context.Context, org *store.Organization) error {
+	client, s, err := backendSession(ctx, getSession)
+	if err != nil {
+		return err
+	}
+	if err := orgRequest(ctx, client, s, OrgLeavePath, map[string]string{"organization_id": org.ID}, nil); err != nil {
+		return err
+	}
+	store.SetOrganization(nil)
+	restartRunningNode()
+	return nil
+}
+
+// orgRequest posts fields with the node ID to path and decodes the response
+// into v, if not nil.
+func orgRequest(ctx context.Context, client *auth.Client, s *auth.Session, path string, fields map[string]string, v any) error {
+	body := map[string]string{"node_id": store.GetID()}
+	for k, val := range fields {
+		body[k] = val
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := client.NewRequest(ctx, http.MethodPost, path, bytes.NewReader(data), s)
+	if err != nil {
+		return err
+	}
+	return client.Do(req, v)
+}
+
+// fetchOrgPolicy returns the policy of the organization, validated.
+func fetchOrgPolicy(ctx context.Context, client *auth.Client, s *auth.Session, orgID string) (json.RawMessage, error) {
+	var policy json.RawMessage
+	if err := orgRequest(ctx, client, s, OrgPolicyPath, map[string]string{"organization_id": orgID}, &policy); err != nil {
+		return nil, err
+	}
+	var parsed orgPolicy
+	if err := json.Unmarshal(policy, &parsed); err != nil {
+		return nil, fmt.Errorf("invalid organization policy: %w", err)
+	}
+	if err := validateSchedule(parsed.Schedule); err != nil {
+		return nil, fmt.Errorf("invalid organization schedule: %w", err)
+	}
+	return policy, nil
+}
+
+func validateSchedule(windows []scheduleWindow) error {
+	for i, w := range windows {
+		if _, err := parseClock(w.Start); err != nil {
+			return fmt.Errorf("window %d: %w", i+1, err)
+		}
+		if _, err := parseClock(w.End); err != nil {
+			return fmt.Errorf("window %d: %w", i+1, err)
+		}
+		for _, day := range w.Days {
+			if !slices.Contains(scheduleDays, day) {
+				return fmt.Errorf("window %d: invalid day %q", i+1, day)
+			}
+		}
+	}
+	return nil
+}
+
+// restartRunningNode restarts a running node so changed settings apply.
+func restartRunningNode() {
+	stateMu.Lock()
+	running := currentState == StateRunning || currentState == StateLoading || currentState == StateStarting || currentState == StatePaused
+	stateMu.Unlock()
+	if running {
+		restartNode()
+	}
+}
+
+var (
+	scheduleMu       sync.Mutex
+	wasInSchedule    = true
+	pausedBySchedule bool
+)
+
+// StartOrgSync refreshes the organization policy every
+// OrgPolicyRefreshInterval and enforces its schedule until ctx is cancelled.
+func StartOrgSync(ctx context.Context) {
+	go func() {
+		lastRefresh := time.Now()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(OrgScheduleCheckInterval):
+			}
+			if time.Since(lastRefresh) >= OrgPolicyRefreshInterval {
+				lastRefresh = time.Now()
+				refreshOrgPolicy(ctx)
+			}
+			enforceSchedule(currentOrgPolicy().Schedule, time.Now())
+		}
+	}()
+}
+
+// refreshOrgPolicy fetches the policy of the node's organization, restarting
+// the node if the model changed.
+func refreshOrgPolicy(ctx context.Context) {
+	org := store.GetOrganization()
+	if org == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, DataRequestTimeout)
+	defer cancel()
+	client, s, err := backendSession(ctx, storedSession)
+	if err != nil {
+		if !errors.Is(err, auth.ErrNoSession) {
+			slog.Debug("failed to refresh organization policy", "error", err)
+		}
+		return
+	}
+	policy, err := fetchOrgPolicy(ctx, client, s, org.ID)
+	if err != nil {
+		slog.Warn("Failed to refresh organization policy", "organization", org.ID, "error", err)
+		return
+	}
+	if bytes.Equal(policy, org.Policy) {
+		return
+	}
+	previous := currentOrgPolicy()
+	org.Policy = policy
+	store.SetOrganization(org)
+	slog.Info("Organization policy changed", "organization", org.ID)
+	if currentOrgPolicy().ModelName != previous.ModelName {
+		restartRunningNode()
+	}
+}
+
+// enforceSchedule pauses the node when its schedule window ends and resumes
+// it when the next one begins. Only nodes it paused are resumed, and a node
+// started by the user outside the schedule is left alone until the next
+// window ends.
+func enforceSchedule(windows []scheduleWindow, now time.Time) {
+	in := inSchedule(windows, now)
+	scheduleMu.Lock()
+	changed := in != wasInSchedule
+	wasInSchedule = in
+	resume := in && pausedBySchedule
+	if in {
+		pausedBySchedule = false
+	}
+	scheduleMu.Unlock()
+	if !changed {
+		return
+	}
+
+	stateMu.Lock()
+	state := currentState
+	stateMu.Unlock()
+	switch {
+	case !in && (state == StateRunning || state == StateLoading || state == StateStarting || state == StatePaused):
+		slog.Info("Pausing node outside the organization schedule")
+		scheduleMu.Lock()
+		pausedBySchedule = true
+		scheduleMu.Unlock()
+		notify(commontray.NotifyInfo, "Node paused", "Your organization's schedule doesn't allow contributing right now. The node resumes in the next window")
+		handleStopRequest()
+	case resume && state == StateStopped:
+		slog.Info("Resuming node in the organization schedule")
+		handleStartRequest()
+	}
+}
@@ -0,0 +1,59 @@
+package lifecycle
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/ReEnvision-AI/systray/app/store"
+	"github.com/ReEnvision-AI/systray/app/tray/commontray"
+)
+
+const (
+	overflowCheckDelay   = 15 * time.Second // Give the shell time to place the new icon
+	overflowClickTimeout = 2 * time.Minute
+)
+
+// checkTrayOverflow runs once per install. Windows puts new tray icons in the
+// hidden icons area, which leads users to think the app isn't running, so
+// explain how to keep the icon visible, or pin it for them where supported.
+func checkTrayOverflow() {
+	if store.GetOverflowChecked() {
+		return
+	}
+	time.Sleep(overflowCheckDelay)
+
+	hidden, err := t.IconHidden()
+	if err != nil {
+		slog.Debug("unable to check if the tray icon is hidden", "error", err)
+		return
+	}
+	if !hidden {
+		store.SetOverflowChecked(true)
+		return
+	}
+	slog.Info("tray icon is in the hidden icons area")
+
+	// Only marked as checked once the hint was shown, quiet mode suppresses
+	// it and it should be shown on a later launch instead
+	if !t.CanPinIcon() {
+		if notifyAction(commontray.NotifyInfo, "ReEnvision AI is running in the background",
+			"Find it under ^ in the taskbar, then drag the icon onto the taskbar to keep it visible", nil) {
+			store.SetOverflowChecked(true)
+		}
+		return
+	}
+
+	pin := make(chan struct{}, 1)
+	if !notifyAction(commontray.NotifyInfo, "ReEnvision AI is running in the background",
+		"Its icon is hidden under ^ in the taskbar. Click here to keep it visible", pin) {
+		return
+	}
+	store.SetOverflowChecked(true)
+	select {
+	case <-pin:
+		if err := t.PinIcon(); err != nil {
+			slog.Warn("failed to pin tray icon", "error", err)
+		}
+	case <-time.After(overflowClickTimeout):
+	}
+}
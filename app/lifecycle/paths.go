@@ -7,6 +7,8 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+
+	"github.com/ReEnvision-AI/systray/app/datadir"
 )
 
 var (
@@ -20,6 +22,14 @@ var (
 	LogRotationCount = 5
 )
 
+// Why the app data was moved to a local folder when the app started, and
+// errors preparing it, logged once logging is set up
+var (
+	dataDirMoved       string
+	dataDirMoveError   error
+	dataDirCreateError error
+)
+
 func init() {
 	if runtime.GOOS == "windows" {
 		AppName += ".exe"
@@ -29,10 +39,7 @@ func init() {
 			// Handle error appropriately, maybe fall back to a default
 			return
 		}
-		AppDataDir = filepath.Join(localAppData, "ReEnvision AI")
-		UpdateStageDir = filepath.Join(AppDataDir, "updates")
-		AppLogFile = filepath.Join(AppDataDir, "app.log")
-		UpgradeLogFile = filepath.Join(AppDataDir, "upgrade.log")
+		setDataDirPaths()
 
 		exe, err := os.Executable()
 		if err != nil {
@@ -70,13 +77,46 @@ func init() {
 				slog.Error("failed to update PATH", "error", err)
 			}
 		}
+	}
+}
 
-		// Make sure our logging dir exists
-		if _, err := os.Stat(AppDataDir); errors.Is(err, os.ErrNotExist) {
-			slog.Info("Creating application data directory", "path", AppDataDir)
-			if err := os.MkdirAll(AppDataDir, 0o755); err != nil {
-				slog.Error("failed to create application data directory", "path", AppDataDir, "error", err)
-			}
+func setDataDirPaths() {
+	AppDataDir = datadir.Dir()
+	UpdateStageDir = filepath.Join(AppDataDir, "updates")
+	AppLogFile = filepath.Join(AppDataDir, "app.log")
+	UpgradeLogFile = filepath.Join(AppDataDir, "upgrade.log")
+}
+
+// prepareDataDir moves the app data and the config folder to a local folder
+// if needed, see datadir, and creates the data folder. Run calls it before
+// logging is set up, as the log file is kept in the data folder.
+func prepareDataDir() {
+	if runtime.GOOS != "windows" || os.Getenv("LOCALAPPDATA") == "" {
+		return
+	}
+	dataDirMoved, dataDirMoveError = datadir.Relocate()
+	setDataDirPaths()
+
+	if _, err := os.Stat(AppDataDir); errors.Is(err, os.ErrNotExist) {
+		if err := os.MkdirAll(AppDataDir, 0o755); err != nil {
+			dataDirCreateError = err
 		}
 	}
 }
+
+// logDataDir logs where the app data is kept when it isn't the local
+// application data folder, as moving it happens before logging is set up.
+func logDataDir() {
+	if dataDirCreateError != nil {
+		slog.Error("failed to create application data directory", "path", AppDataDir, "error", dataDirCreateError)
+	}
+	switch {
+	case dataDirMoveError != nil:
+		slog.Error("Failed to move the app data to a local folder", "reason", dataDirMoved, "error", dataDirMoveError)
+	case dataDirMoved != "":
+		slog.Info("Moved the app data to a local folder", "reason", dataDirMoved, "path", AppDataDir)
+	}
+	if reason := datadir.UnsafeReason(AppDataDir); reason != "" {
+		slog.Warn("The app data folder may be locked by sync or slow to write", "path", AppDataDir, "reason", reason)
+	}
+}
@@ -0,0 +1,87 @@
+package lifecycle
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/ReEnvision-AI/systray/app/tray/commontray"
+)
+
+// Pausing the node freezes its container with `podman pause`, giving the GPU
+// and CPU back for a game or a meeting without unloading the model, which
+// takes minutes to load again. Resuming with `podman unpause` has the node
+// contributing again within seconds. The swarm routes requests around the
+// node while it doesn't answer.
+
+const podmanPauseTimeout = 30 * time.Second
+
+var (
+	containerPaused atomic.Bool // Whether the node's container is frozen
+	pausedFrom      AppState    // The state to resume to, guarded by stateMu
+)
+
+// handlePauseRequest freezes the node's container.
+func handlePauseRequest() {
+	stateMu.Lock()
+	state, name := currentState, currentAppConfig().ContainerName
+	stateMu.Unlock()
+	if state != StateRunning && state != StateLoading {
+		slog.Info("Container isn't running, ignoring pause request", "state", state)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), podmanPauseTimeout)
+	defer cancel()
+	if output, err := helperCombinedOutput(podmanCommand(ctx, "pause", name)); err != nil {
+		slog.Error("Failed to pause container", "error", podmanError(err, output))
+		notify(commontray.NotifyError, "Unable to pause your node", "Open the logs from the tray menu for details")
+		return
+	}
+	containerPaused.Store(true)
+
+	stateMu.Lock()
+	if currentState != StateRunning && currentState != StateLoading {
+		// Stopped or restarted meanwhile
+		stateMu.Unlock()
+		return
+	}
+	pausedFrom = currentState
+	stateMu.Unlock()
+	slog.Info("Container paused")
+	SetState(StatePaused)
+}
+
+// handleResumeRequest unfreezes the node's container.
+func handleResumeRequest() {
+	stateMu.Lock()
+	state, resumeTo := currentState, pausedFrom
+	stateMu.Unlock()
+	if state != StatePaused {
+		slog.Info("Container isn't paused, ignoring resume request", "state", state)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), podmanPauseTimeout)
+	defer cancel()
+	if err := unpauseContainer(ctx); err != nil {
+		slog.Error("Failed to resume container", "error", err)
+		notify(commontray.NotifyError, "Unable to resume your node", "Stop and start it from the tray menu")
+		return
+	}
+	slog.Info("Container resumed")
+	SetState(resumeTo)
+}
+
+// unpauseContainer unfreezes the node's container if it is paused.
+func unpauseContainer(ctx context.Context) error {
+	if !containerPaused.Load() {
+		return nil
+	}
+	if output, err := helperCombinedOutput(podmanCommand(ctx, "unpause", currentAppConfig().ContainerName)); err != nil {
+		return podmanError(err, output)
+	}
+	containerPaused.Store(false)
+	return nil
+}
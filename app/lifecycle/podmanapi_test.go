@@ -0,0 +1,124 @@
+//go:build windows && unit_test
+
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testPodmanAPI(t *testing.T, handler http.HandlerFunc) *podmanAPI {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	saved, savedEnabled := appConfig, podmanAPIEnabled
+	t.Cleanup(func() { appConfig, podmanAPIEnabled = saved, savedEnabled })
+	podmanAPIEnabled = func() bool { return true }
+	appConfig = AppConfig{PodmanURL: "tcp://" + strings.TrimPrefix(server.URL, "http://")}
+	api, err := newPodmanAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return api
+}
+
+func TestNewPodmanAPI(t *testing.T) {
+	saved, savedEnabled := appConfig, podmanAPIEnabled
+	defer func() { appConfig, podmanAPIEnabled = saved, savedEnabled }()
+
+	podmanAPIEnabled = func() bool { return false }
+	appConfig = AppConfig{}
+	if _, err := newPodmanAPI(); !errors.Is(err, errPodmanAPIUnavailable) {
+		t.Errorf("expected the CLI to be used without the feature flag, got %v", err)
+	}
+	podmanAPIEnabled = func() bool { return true }
+
+	for _, cfg := range []AppConfig{
+		{PodmanConnection: podmanRootfulConnection},
+		{PodmanURL: "ssh://user@127.0.0.1:2222/run/podman/podman.sock"},
+	} {
+		appConfig = cfg
+		if _, err := newPodmanAPI(); !errors.Is(err, errPodmanAPIUnavailable) {
+			t.Errorf("expected the CLI to be used for %+v, got %v", cfg, err)
+		}
+	}
+	for _, cfg := range []AppConfig{{}, {PodmanURL: "npipe:////./pipe/podman-machine-default"}} {
+		appConfig = cfg
+		if _, err := newPodmanAPI(); err != nil {
+			t.Errorf("expected the API to be used for %+v, got %v", cfg, err)
+		}
+	}
+}
+
+func TestPodmanAPIErrors(t *testing.T) {
+	api := testPodmanAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/containers/missing/exists"):
+			w.WriteHeader(http.StatusNotFound)
+		case strings.HasSuffix(r.URL.Path, "/containers/node/exists"):
+			w.WriteHeader(http.StatusNoContent)
+		case strings.HasSuffix(r.URL.Path, "/containers/node/json"):
+			fmt.Fprint(w, `{"Id":"5f2e","Name":"node","Image":"9c1bd2c0a7e4","Config":{"Labels":{"node-id":"c1f0"}}}`)
+		case strings.HasSuffix(r.URL.Path, "/containers/node/stop"):
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, `{"cause":"no space left on device","message":"writing state: no space left on device","response":500}`)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+	})
+	ctx := context.Background()
+
+	if exists, err := api.containerExists(ctx, "missing"); exists || err != nil {
+		t.Errorf("expected missing container, got %v, %v", exists, err)
+	}
+	if exists, err := api.containerExists(ctx, "node"); !exists || err != nil {
+		t.Errorf("expected existing container, got %v, %v", exists, err)
+	}
+
+	if id, err := api.containerImageID(ctx, "node"); id != "9c1bd2c0a7e4" || err != nil {
+		t.Errorf("expected the image ID from inspect, got %q, %v", id, err)
+	}
+	if labels, err := api.containerLabels(ctx, "node"); labels[nodeIDLabel] != "c1f0" || err != nil {
+		t.Errorf("expected the labels from inspect, got %v, %v", labels, err)
+	}
+
+	err := api.stopContainer(ctx, "node", 10*time.Second)
+	var apiErr *podmanAPIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("expected an API error, got %v", err)
+	}
+	if !errors.Is(err, ErrPodmanNoSpace) {
+		t.Errorf("expected the error to be classified, got %v", err)
+	}
+}
+
+func TestWatchContainer(t *testing.T) {
+	api := testPodmanAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.URL.Query().Get("filters"), `"node"`) {
+			t.Errorf("expected events of the container, got %s", r.URL.RawQuery)
+		}
+		fmt.Fprintln(w, `{"Type":"container","Action":"start","Actor":{"Attributes":{"name":"node"}}}`)
+		fmt.Fprintln(w, `{"Type":"container","Action":"oom","Actor":{"Attributes":{"name":"node"}}}`)
+		fmt.Fprintln(w, `{"Type":"container","Action":"died","Actor":{"Attributes":{"name":"node","containerExitCode":"137"}}}`)
+	})
+	if err := api.watchContainer(context.Background(), "node"); err != nil {
+		t.Fatal(err)
+	}
+
+	err := containerExitError("node", errors.New("exit status 137"))
+	var exitErr *ContainerExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 137 || !exitErr.OOMKilled {
+		t.Errorf("expected an out of memory exit with code 137, got %v", err)
+	}
+	// The previous container dying doesn't explain the exit of the next one
+	if err := containerExitError("node-b", errors.New("exit status 1")); errors.As(err, &exitErr) {
+		t.Errorf("expected no exit details of another container, got %v", err)
+	}
+}
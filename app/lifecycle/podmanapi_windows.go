@@ -0,0 +1,311 @@
+package lifecycle
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ReEnvision-AI/systray/app/features"
+)
+
+// The libpod REST API gives structured errors and container state where the
+// CLI only has exit codes and output to parse. It is used to stop, check,
+// inspect and remove containers and to watch the node's container for its
+// exit code, with the CLI as fallback when the API can't be reached, e.g.
+// over an ssh connection. The container itself is still started with
+// `podman run`, as the sandbox, network and CPU pinning settings are built
+// as its arguments, and its logs are the output of that command. Commands
+// run inside the container, like the health check, use `podman exec`. The
+// API is only used once the new-runtime-backend feature flag is on for the
+// node.
+
+const (
+	podmanAPIVersion     = "v4.0.0"
+	podmanAPIDefaultPipe = `\\.\pipe\podman-machine-default`
+	podmanAPIBaseURL     = "http://d" // Host is ignored when dialling a pipe
+)
+
+// podmanAPIEnabled reports whether the feature flag allows the API, replaced
+// by tests.
+var podmanAPIEnabled = func() bool { return features.Enabled(features.NewRuntimeBackend) }
+
+// errPodmanAPIUnavailable is returned when the API can't be reached, callers
+// fall back to the CLI.
+var errPodmanAPIUnavailable = errors.New("the Podman API is unavailable")
+
+// podmanAPIError is an error response of the API.
+type podmanAPIError struct {
+	StatusCode int
+	Cause      string `json:"cause"`
+	Message    string `json:"message"`
+}
+
+func (e *podmanAPIError) Error() string {
+	return fmt.Sprintf("podman API returned %d: %s", e.StatusCode, e.Message)
+}
+
+type podmanAPI struct {
+	client  *http.Client
+	baseURL string
+}
+
+// newPodmanAPI returns a client for the configured Podman service, or
+// errPodmanAPIUnavailable if it is only reachable through the CLI.
+func newPodmanAPI() (*podmanAPI, error) {
+	if !podmanAPIEnabled() {
+		return nil, errPodmanAPIUnavailable
+	}
+	var dial func(ctx context.Context, network, addr string) (net.Conn, error)
+	baseURL := podmanAPIBaseURL
+
+	cfg := currentAppConfig()
+	switch {
+	case cfg.PodmanConnection != "":
+		return nil, errPodmanAPIUnavailable // Named connections are resolved by the CLI
+	case cfg.PodmanURL == "":
+		dial = func(ctx context.Context, _, _ string) (net.Conn, error) { return dialPipe(ctx, podmanAPIDefaultPipe) }
+	default:
+		u, err := url.Parse(cfg.PodmanURL)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errPodmanAPIUnavailable, err)
+		}
+		switch u.Scheme {
+		case "npipe":
+			// npipe:////./pipe/name
+			pipe := `\\` + strings.ReplaceAll(strings.TrimLeft(u.Path, "/"), "/", `\`)
+			dial = func(ctx context.Context, _, _ string) (net.Conn, error) { return dialPipe(ctx, pipe) }
+		case "tcp":
+			baseURL = "http://" + u.Host
+		default:
+			return nil, errPodmanAPIUnavailable
+		}
+	}
+
+	transport := &http.Transport{DialContext: dial}
+	return &podmanAPI{client: &http.Client{Transport: transport}, baseURL: baseURL}, nil
+}
+
+// do sends a request to the libpod API. Error responses are returned as
+// *podmanAPIError wrapped by podmanError, so known failures are classified
+// as with the CLI.
+func (p *podmanAPI) do(ctx context.Context, method, path string, query url.Values) (*http.Response, error) {
+	u := p.baseURL + "/" + podmanAPIVersion + "/libpod" + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("%w: %v", errPodmanAPIUnavailable, err)
+	}
+	if resp.StatusCode < http.StatusBadRequest {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	apiErr := &podmanAPIError{StatusCode: resp.StatusCode}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if json.Unmarshal(body, apiErr) != nil || apiErr.Message == "" {
+		apiErr.Message = strings.TrimSpace(string(body))
+	}
+	return nil, podmanError(apiErr, []byte(apiErr.Message))
+}
+
+// call sends a request and discards the response body.
+func (p *podmanAPI) call(ctx context.Context, method, path string, query url.Values) error {
+	resp, err := p.do(ctx, method, path, query)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// containerExists reports whether the named container exists.
+func (p *podmanAPI) containerExists(ctx context.Context, name string) (bool, error) {
+	err := p.call(ctx, http.MethodGet, "/containers/"+url.PathEscape(name)+"/exists", nil)
+	if isAPINotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// removeContainer force removes the named container.
+func (p *podmanAPI) removeContainer(ctx context.Context, name string) error {
+	err := p.call(ctx, http.MethodDelete, "/containers/"+url.PathEscape(name), url.Values{"force": {"true"}})
+	if isAPINotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// stopContainer stops the named container, killing it after timeout.
+// Stopping a container that isn't running or doesn't exist succeeds.
+func (p *podmanAPI) stopContainer(ctx context.Context, name string, timeout time.Duration) error {
+	query := url.Values{"timeout": {strconv.Itoa(int(timeout / time.Second))}}
+	err := p.call(ctx, http.MethodPost, "/containers/"+url.PathEscape(name)+"/stop", query)
+	if isAPINotFound(err) {
+		return nil
+	}
+	return err // 304 means it was already stopped
+}
+
+// containerInspect are the fields of a container's inspect output the app
+// uses.
+type containerInspect struct {
+	Image  string `json:"Image"`
+	Config struct {
+		Labels map[string]string `json:"Labels"`
+	} `json:"Config"`
+}
+
+// inspectContainer returns the inspect output of the named container.
+func (p *podmanAPI) inspectContainer(ctx context.Context, name string) (containerInspect, error) {
+	var inspect containerInspect
+	resp, err := p.do(ctx, http.MethodGet, "/containers/"+url.PathEscape(name)+"/json", nil)
+	if err != nil {
+		return inspect, err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(&inspect); err != nil {
+		return inspect, fmt.Errorf("failed to parse container inspect: %w", err)
+	}
+	return inspect, nil
+}
+
+// containerImageID returns the ID of the image the named container runs.
+func (p *podmanAPI) containerImageID(ctx context.Context, name string) (string, error) {
+	inspect, err := p.inspectContainer(ctx, name)
+	return inspect.Image, err
+}
+
+// containerLabels returns the labels of the named container.
+func (p *podmanAPI) containerLabels(ctx context.Context, name string) (map[string]string, error) {
+	inspect, err := p.inspectContainer(ctx, name)
+	return inspect.Config.Labels, err
+}
+
+func isAPINotFound(err error) bool {
+	var apiErr *podmanAPIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// podmanEvent is an entry of the events stream.
+type podmanEvent struct {
+	Type   string `json:"Type"`
+	Action string `json:"Action"`
+	Actor  struct {
+		Attributes map[string]string `json:"Attributes"`
+	} `json:"Actor"`
+}
+
+// containerExit is how the container exited, as seen in its events.
+type containerExit struct {
+	Code      int
+	OOMKilled bool
+}
+
+// ContainerExitError is the exit of the container process with the details
+// reported by Podman.
+type ContainerExitError struct {
+	containerExit
+	Err error
+}
+
+func (e *ContainerExitError) Error() string {
+	if e.OOMKilled {
+		return fmt.Sprintf("container ran out of memory and exited with code %d", e.Code)
+	}
+	return fmt.Sprintf("container exited with code %d", e.Code)
+}
+
+func (e *ContainerExitError) Unwrap() error {
+	return e.Err
+}
+
+// Exits of the watched containers by name, set by watchContainer when one
+// dies. Keyed by name, as the events of the previous container of a switch
+// may arrive after the next one is watched.
+var (
+	containerExitsMu sync.Mutex
+	containerExits   = map[string]containerExit{}
+)
+
+// watchContainer records the exit of the named container from the events
+// stream until ctx is cancelled.
+func (p *podmanAPI) watchContainer(ctx context.Context, name string) error {
+	filters, _ := json.Marshal(map[string][]string{"container": {name}, "type": {"container"}})
+	resp, err := p.do(ctx, http.MethodGet, "/events", url.Values{"stream": {"true"}, "filters": {string(filters)}})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var oom bool
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		var event podmanEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			slog.Debug("failed to parse Podman event", "error", err)
+			continue
+		}
+		switch event.Action {
+		case "oom":
+			oom = true
+		case "died":
+			code, _ := strconv.Atoi(event.Actor.Attributes["containerExitCode"])
+			containerExitsMu.Lock()
+			containerExits[name] = containerExit{Code: code, OOMKilled: oom}
+			containerExitsMu.Unlock()
+			slog.Info("Container died", "name", name, "exit_code", code, "oom_killed", oom)
+		}
+	}
+	return scanner.Err()
+}
+
+// startContainerWatch watches the container in the background, if the API
+// is available.
+func startContainerWatch(ctx context.Context, name string) {
+	containerExitsMu.Lock()
+	delete(containerExits, name)
+	containerExitsMu.Unlock()
+	api, err := newPodmanAPI()
+	if err != nil {
+		slog.Debug("not watching container events", "error", err)
+		return
+	}
+	go func() {
+		if err := api.watchContainer(ctx, name); err != nil && ctx.Err() == nil {
+			slog.Debug("stopped watching container events", "error", err)
+		}
+	}()
+}
+
+// containerExitError adds the exit details of the named container from the
+// events stream to the error of its `podman run`.
+func containerExitError(name string, err error) error {
+	containerExitsMu.Lock()
+	exit, ok := containerExits[name]
+	containerExitsMu.Unlock()
+	if !ok {
+		return err
+	}
+	return &ContainerExitError{containerExit: exit, Err: err}
+}
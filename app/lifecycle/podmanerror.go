@@ -0,0 +1,86 @@
+package lifecycle
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Podman failures the user can fix are recognised from the CLI output and
+// wrapped in a *PodmanError, so the start failure notification can say what
+// to do instead of pointing at the logs.
+
+var (
+	ErrPodmanMachineNotFound = errors.New("the Podman machine does not exist")
+	ErrPodmanMachineRunning  = errors.New("the Podman machine is already running")
+	ErrPodmanNameInUse       = errors.New("the container name is already in use")
+	ErrPodmanNoSpace         = errors.New("the Podman machine is out of disk space")
+	ErrPodmanUnreachable     = errors.New("unable to connect to Podman")
+)
+
+// PodmanError is a failed podman command whose output matched a known
+// failure. errors.Is matches both Kind and the error of the command.
+type PodmanError struct {
+	Kind   error  // One of the ErrPodman* errors
+	Remedy string // What the user can do about it
+	Output string // Output of the command
+	Err    error  // Error of the command
+}
+
+func (e *PodmanError) Error() string {
+	return fmt.Sprintf("%v: %v. Output: %s", e.Kind, e.Err, e.Output)
+}
+
+func (e *PodmanError) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+// The patterns match Podman's own messages, not errors it passes through
+// from the container or a registry, like a refused connection of a pull.
+var podmanErrorPatterns = []struct {
+	kind    error
+	pattern *regexp.Regexp // Matched against a line of the lower case output
+	remedy  string
+}{
+	{
+		ErrPodmanMachineNotFound,
+		regexp.MustCompile(`vm does not exist|machine does not exist|no such machine`),
+		"Reinstall ReEnvision AI, or run \"podman machine init\" to create the machine",
+	},
+	{
+		// "machine x: VM already running or starting" or, from podman machine
+		// start, `unable to start "x": "x" is already running`
+		ErrPodmanMachineRunning,
+		regexp.MustCompile(`(machine|vm) already running|unable to start "[^"]*": .*already running`),
+		"Nothing to do, the machine is up",
+	},
+	{
+		ErrPodmanNameInUse,
+		regexp.MustCompile(`the container name "[^"]*" is already in use`),
+		"Remove the container from Podman Desktop or restart the Podman machine",
+	},
+	{
+		ErrPodmanNoSpace,
+		regexp.MustCompile(`no space left on device`),
+		"Free up disk space, or recreate the cache volume from Advanced in the tray menu",
+	},
+	{
+		ErrPodmanUnreachable,
+		regexp.MustCompile(`cannot connect to podman|unable to connect to podman|wsl bootstrap script failed`),
+		"Restart the Podman machine from Podman Desktop, or restart Windows",
+	},
+}
+
+// podmanError wraps the error of a podman command with its output, as a
+// *PodmanError if the output shows a known failure.
+func podmanError(err error, output []byte) error {
+	text := strings.TrimSpace(string(output))
+	lower := strings.ToLower(text)
+	for _, p := range podmanErrorPatterns {
+		if p.pattern.MatchString(lower) {
+			return &PodmanError{Kind: p.kind, Remedy: p.remedy, Output: text, Err: err}
+		}
+	}
+	return fmt.Errorf("%w. Output: %s", err, text)
+}
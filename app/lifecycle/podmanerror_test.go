@@ -0,0 +1,62 @@
+//go:build windows && unit_test
+
+package lifecycle
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestPodmanError(t *testing.T) {
+	exitErr := &exec.ExitError{}
+	tests := []struct {
+		output   string
+		expected error
+	}{
+		{"Error: podman-machine-default: VM does not exist", ErrPodmanMachineNotFound},
+		{`Error: unable to start "podman-machine-default": machine already running`, ErrPodmanMachineRunning},
+		{`Error: unable to start "podman-machine-default": machine podman-machine-default: VM already running or starting`, ErrPodmanMachineRunning},
+		{`Error: unable to start "podman-machine-default": "podman-machine-default" is already running`, ErrPodmanMachineRunning},
+		{`Error: creating container storage: the container name "reai-1234" is already in use by 5f2e`, ErrPodmanNameInUse},
+		{"Error: writing blob: storing blob to file: write /var/tmp/storage: no space left on device", ErrPodmanNoSpace},
+		{"Cannot connect to Podman. Please verify your connection to the Linux system", ErrPodmanUnreachable},
+		{"Error: unable to connect to Podman socket: dial tcp 127.0.0.1:53248: connect: connection refused", ErrPodmanUnreachable},
+	}
+	for _, test := range tests {
+		err := podmanError(exitErr, []byte(test.output+"\n"))
+		if !errors.Is(err, test.expected) {
+			t.Errorf("podmanError(%q) = %v, expected %v", test.output, err, test.expected)
+		}
+		var podmanErr *PodmanError
+		if !errors.As(err, &podmanErr) || podmanErr.Remedy == "" {
+			t.Errorf("expected a *PodmanError with a remedy for %q", test.output)
+		}
+		if !errors.Is(err, exitErr) {
+			t.Errorf("expected %v to wrap the command error", err)
+		}
+	}
+}
+
+func TestPodmanErrorUnknown(t *testing.T) {
+	exitErr := &exec.ExitError{}
+	err := podmanError(exitErr, []byte("Error: something else\n"))
+	var podmanErr *PodmanError
+	if errors.As(err, &podmanErr) {
+		t.Errorf("expected no *PodmanError, got %v", podmanErr.Kind)
+	}
+	if !errors.Is(err, exitErr) || !strings.HasSuffix(err.Error(), "Output: Error: something else") {
+		t.Errorf("unexpected error %q", err)
+	}
+
+	// Errors passed through from a registry or the container aren't Podman's
+	for _, output := range []string{
+		"Error: initializing source docker://reai.example/node:latest: pinging container registry reai.example: Get \"https://reai.example/v2/\": dial tcp 10.0.0.5:443: connect: connection refused",
+		"Error: server is already running on port 31330",
+	} {
+		if err := podmanError(exitErr, []byte(output)); errors.As(err, &podmanErr) {
+			t.Errorf("podmanError(%q) = %v, expected no *PodmanError", output, podmanErr.Kind)
+		}
+	}
+}
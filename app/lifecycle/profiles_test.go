@@ -0,0 +1,49 @@
+//go:build windows && unit_test
+
+package lifecycle
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestProfiles(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), configFileName)
+	dir := profilesDir(configFile)
+	if names, err := listProfiles(configFile); err != nil || len(names) != 0 {
+		t.Fatalf("expected no profiles without the folder, got %v, %v", names, err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "nested.json"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		"small CPU model.json": `{"model_name": "org/small", "use_gpu": false}`,
+		"Llama-70B GPU.json":   `{"model_name": "org/llama-70b"}`,
+		"notes.txt":            "not a profile",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	names, err := listProfiles(configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"Llama-70B GPU", "small CPU model"}; !slices.Equal(names, want) {
+		t.Errorf("expected profiles %q, got %q", want, names)
+	}
+
+	cfg := AppConfig{ContainerImage: "node:1", ModelName: "org/model", UseGPU: true}
+	if err := applyProfile(&cfg, configFile, "small CPU model"); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ModelName != "org/small" || cfg.UseGPU || cfg.ContainerImage != "node:1" {
+		t.Errorf("profile should only change its fields, got %+v", cfg)
+	}
+	if err := applyProfile(&cfg, configFile, "deleted"); err != nil {
+		t.Errorf("a deleted profile should be ignored, got %v", err)
+	}
+}
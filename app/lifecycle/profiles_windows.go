@@ -0,0 +1,105 @@
+package lifecycle
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/ReEnvision-AI/systray/app/store"
+	"golang.org/x/sys/windows"
+)
+
+// Profiles are named sets of settings, e.g. a large model on the GPU and a
+// small one on the CPU, that power users switch between from the tray
+// depending on what the machine is doing. A profile is a JSON file in the
+// profiles folder next to config.json, named after the profile, holding the
+// config.json fields it changes. The active profile is applied over
+// config.json when the node starts.
+
+const profilesDirName = "profiles"
+
+// profilesDir returns the folder of the profiles of configFile.
+func profilesDir(configFile string) string {
+	return filepath.Join(filepath.Dir(configFile), profilesDirName)
+}
+
+// listProfiles returns the names of the profiles of configFile, sorted.
+func listProfiles(configFile string) ([]string, error) {
+	entries, err := os.ReadDir(profilesDir(configFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		if name, ok := strings.CutSuffix(entry.Name(), ".json"); ok && !entry.IsDir() && name != "" {
+			names = append(names, name)
+		}
+	}
+	slices.SortFunc(names, func(a, b string) int { return strings.Compare(strings.ToLower(a), strings.ToLower(b)) })
+	return names, nil
+}
+
+// applyProfile sets the fields of the profile name of configFile in cfg. A
+// profile that was deleted is ignored.
+func applyProfile(cfg *AppConfig, configFile, name string) error {
+	data, err := os.ReadFile(filepath.Join(profilesDir(configFile), name+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		slog.Warn("Active profile not found, using the default config", "profile", name)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read profile '%s': %w", name, err)
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return fmt.Errorf("failed to parse profile '%s': %w", name, err)
+	}
+	return nil
+}
+
+// refreshProfiles lists the profiles in the tray.
+func refreshProfiles() {
+	configFile, err := configFilePath()
+	if err != nil {
+		return
+	}
+	names, err := listProfiles(configFile)
+	if err != nil {
+		slog.Warn("Failed to list profiles", "error", err)
+	}
+	if err := t.SetProfiles(names, store.GetActiveProfile()); err != nil {
+		slog.Warn("failed to update tray profiles", "error", err)
+	}
+}
+
+// handleSelectProfile makes name the active profile and restarts a running
+// node with it.
+func handleSelectProfile(name string) {
+	previous := store.GetActiveProfile()
+	if name == previous {
+		return
+	}
+	configFile, err := configFilePath()
+	if err != nil {
+		slog.Error("Failed to locate config file", "error", err)
+		return
+	}
+	store.SetActiveProfile(name)
+	if _, err := readConfigFile(configFile); err != nil {
+		store.SetActiveProfile(previous)
+		slog.Warn("Not switching to invalid profile", "profile", name, "error", err)
+		messageBox("ReEnvision AI", "The profile can't be used:\n\n"+err.Error(), windows.MB_OK|windows.MB_ICONERROR)
+		refreshProfiles()
+		return
+	}
+	slog.Info("Switched profile", "profile", name, "previous", previous)
+	refreshProfiles()
+	restartRunningNode()
+}
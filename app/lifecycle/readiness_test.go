@@ -0,0 +1,30 @@
+//go:build windows && unit_test
+
+package lifecycle
+
+import "testing"
+
+func TestServerReadiness(t *testing.T) {
+	setupMockTray()
+	defer resetState()
+
+	if isServerReadyLine("Loading block 3/24") {
+		t.Error("a loading block line should not mark the server ready")
+	}
+	line := "Oct 16 12:00:00.000 [INFO] Announced that blocks [0, 1, 2] are online"
+	if !isServerReadyLine(line) {
+		t.Errorf("expected %q to mark the server ready", line)
+	}
+
+	SetState(StateStopping)
+	markServerReady()
+	if currentState != StateStopping {
+		t.Errorf("a stopping node should not become running, got %v", currentState)
+	}
+
+	SetState(StateLoading)
+	markServerReady()
+	if currentState != StateRunning {
+		t.Errorf("expected a loading node to become running, got %v", currentState)
+	}
+}
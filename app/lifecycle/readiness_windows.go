@@ -0,0 +1,76 @@
+package lifecycle
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// Once its container runs, the server still downloads and loads its blocks
+// for minutes before it serves anything, so the node shows as loading until
+// the server announces its blocks. Should a server version log differently,
+// the node counts as ready once the health probe passes after the startup
+// grace.
+
+// serverReadyMarkers are fragments of the lines the server logs once its
+// blocks are loaded and announced to the swarm.
+var serverReadyMarkers = []string{
+	"announced that blocks",
+	"server is ready",
+}
+
+// isServerReadyLine reports whether a line of container output shows the
+// server is ready.
+func isServerReadyLine(line string) bool {
+	line = strings.ToLower(line)
+	for _, marker := range serverReadyMarkers {
+		if strings.Contains(line, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// markServerReady moves a loading node to running.
+func markServerReady() {
+	stateMu.Lock()
+	loading := currentState == StateLoading
+	stateMu.Unlock()
+	if !loading {
+		return
+	}
+	slog.Info("Server is ready")
+	SetState(StateRunning)
+	go saveKnownGoodSettings()
+	go registerNodeIdentity()
+}
+
+// awaitServerReady probes a loading node after the startup grace, in case
+// the server's output didn't show it became ready, until ctx is cancelled or
+// the node leaves the loading state.
+func awaitServerReady(ctx context.Context) {
+	delay := healthStartupGrace
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		stateMu.Lock()
+		loading := currentState == StateLoading
+		stateMu.Unlock()
+		if !loading {
+			return
+		}
+		cfg := currentAppConfig().HealthCheck
+		if err := probeHealth(ctx, cfg); err != nil {
+			slog.Debug("server is not ready yet", "error", err)
+			delay = cfg.interval()
+			continue
+		}
+		slog.Info("Server passed the health probe without reporting it is ready")
+		markServerReady()
+		return
+	}
+}
@@ -0,0 +1,23 @@
+//go:build windows && unit_test
+
+package lifecycle
+
+import "testing"
+
+func TestReadOnlyRootError(t *testing.T) {
+	tests := []struct {
+		line string
+		path string
+		ok   bool
+	}{
+		{"OSError: [Errno 30] Read-only file system: '/root/.config/matplotlib'", "/root/.config/matplotlib", true},
+		{"touch: cannot touch '/opt/app/x': Read-only file system", "", true},
+		{"Loaded 12 blocks from /cache", "", false},
+	}
+	for _, test := range tests {
+		path, ok := readOnlyRootError(test.line)
+		if path != test.path || ok != test.ok {
+			t.Errorf("readOnlyRootError(%q) = %q, %v, expected %q, %v", test.line, path, ok, test.path, test.ok)
+		}
+	}
+}
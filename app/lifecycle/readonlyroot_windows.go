@@ -0,0 +1,73 @@
+package lifecycle
+
+import (
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"github.com/ReEnvision-AI/systray/app/tray/commontray"
+)
+
+// The container's root filesystem is mounted read-only so a compromised image
+// can't persist changes, with tmpfs mounts for the paths the model server
+// writes scratch data to. Downloaded models live in the cache volume. The
+// writable_root_fs config flag turns this off for images that need to write
+// elsewhere, which shows up as EROFS errors in the container output.
+
+// containerTmpfsMounts are the scratch paths of the model server.
+var containerTmpfsMounts = []string{
+	"/tmp:rw,nosuid,nodev,size=4g",
+	"/var/tmp:rw,nosuid,nodev,size=1g",
+	"/run:rw,nosuid,nodev,size=64m",
+	"/root/.cache:rw,nosuid,nodev,size=2g",  // pip, torch and HuggingFace scratch files
+	"/root/.triton:rw,nosuid,nodev,size=1g", // Triton kernel cache
+}
+
+// readOnlyRootReported keeps a crash-looping container from repeating the
+// notification, it is reset on every start.
+var readOnlyRootReported atomic.Bool
+
+// readOnlyRootArgs returns the podman run arguments for the read-only root.
+func readOnlyRootArgs() []string {
+	if currentAppConfig().WritableRootFS {
+		slog.Info("Container root filesystem is writable (writable_root_fs)")
+		return nil
+	}
+	args := []string{"--read-only", "--read-only-tmpfs=false"}
+	for _, mount := range containerTmpfsMounts {
+		args = append(args, "--tmpfs="+mount)
+	}
+	return args
+}
+
+// readOnlyPathPattern matches the path in errors like
+// "OSError: [Errno 30] Read-only file system: '/root/.config/matplotlib'"
+var readOnlyPathPattern = regexp.MustCompile(`(?i)read-only file system:? '([^']+)'`)
+
+// readOnlyRootError reports whether a line of container output shows a write
+// refused by the read-only root, and the path if the error names it.
+func readOnlyRootError(line string) (path string, ok bool) {
+	if !strings.Contains(strings.ToLower(line), "read-only file system") {
+		return "", false
+	}
+	if m := readOnlyPathPattern.FindStringSubmatch(line); m != nil {
+		return m[1], true
+	}
+	return "", true
+}
+
+// reportReadOnlyRootError tells the user once per start that the image needs
+// a writable root filesystem.
+func reportReadOnlyRootError(path string) {
+	if currentAppConfig().WritableRootFS || readOnlyRootReported.Swap(true) {
+		return
+	}
+	slog.Warn("Container tried to write outside its scratch mounts, the image may need writable_root_fs", "path", path)
+	message := "The node tried to write to its read-only filesystem"
+	if path != "" {
+		message += " at " + path
+	}
+	notify(commontray.NotifyWarning, "The node may not work correctly",
+		message+`. Set "writable_root_fs": true in config.json if it keeps failing`)
+}
@@ -0,0 +1,73 @@
+package lifecycle
+
+import (
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Secrets are masked before anything is written to the log file or kept in
+// memory. The HuggingFace token is on the command line of the container,
+// which is logged when it starts, and the server may print tokens, keys or
+// the user's email address. Besides values registered with registerSecret,
+// anything that looks like a token, key or email address is masked.
+
+const (
+	redactedValue   = "[redacted]"
+	minSecretLength = 8 // Shorter registered secrets would mask common words
+)
+
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`\bhf_[A-Za-z0-9]{20,}`),                                        // HuggingFace tokens
+	regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}`), // JWTs, like sessions and Supabase keys
+	regexp.MustCompile(`\b(?:sk|pk|rk)-[A-Za-z0-9_-]{16,}`),                            // API keys of common providers
+	regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),               // Email addresses
+}
+
+// secretValuePattern matches the values of options, parameters and headers
+// carrying secrets. Values starting with '-' are the next option.
+var secretValuePattern = regexp.MustCompile(`(?i)(--(?:token|api[_-]?key|password)[ =]|\b(?:token|api[_-]?key|apikey|password|secret|access_token|refresh_token)=|\bBearer\s+)([^\s&"',-][^\s&"',]*)`)
+
+var (
+	secretsMu    sync.RWMutex
+	knownSecrets []string
+)
+
+// registerSecret masks s wherever it is logged from now on.
+func registerSecret(s string) {
+	if len(s) < minSecretLength {
+		return
+	}
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	if !slices.Contains(knownSecrets, s) {
+		knownSecrets = append(knownSecrets, s)
+	}
+}
+
+// redactSecrets returns text with the secrets in it masked.
+func redactSecrets(text string) string {
+	secretsMu.RLock()
+	for _, secret := range knownSecrets {
+		text = strings.ReplaceAll(text, secret, redactedValue)
+	}
+	secretsMu.RUnlock()
+	for _, pattern := range secretPatterns {
+		text = pattern.ReplaceAllLiteralString(text, redactedValue)
+	}
+	return secretValuePattern.ReplaceAllString(text, "${1}"+redactedValue)
+}
+
+// redactingWriter masks secrets in what is written to w.
+type redactingWriter struct {
+	w io.Writer
+}
+
+func (r redactingWriter) Write(p []byte) (int, error) {
+	if _, err := r.w.Write([]byte(redactSecrets(string(p)))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,50 @@
+//go:build windows && unit_test
+
+package lifecycle
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReleaseNotesText(t *testing.T) {
+	if got, want := releaseNotesText("v1.4.0", "- Faster starts\n- Fewer crashes\n", ""), "ReEnvision AI v1.4.0\r\n\r\n- Faster starts\r\n- Fewer crashes"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	if got := releaseNotesText("", "", "https://example.com/notes"); !strings.Contains(got, "View online") {
+		t.Errorf("expected missing notes to point to the link, got %q", got)
+	}
+	if got := releaseNotesText("", "", ""); strings.Contains(got, "View online") {
+		t.Errorf("expected no link to be mentioned, got %q", got)
+	}
+}
+
+func TestFetchReleaseNotes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/notes.md":
+			w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+			w.Write([]byte("## What's new\n- Faster starts"))
+		case "/notes.html":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<html></html>"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	notes, err := fetchReleaseNotes(context.Background(), server.URL+"/notes.md")
+	if err != nil || notes != "## What's new\n- Faster starts" {
+		t.Errorf("expected the notes, got %q, %v", notes, err)
+	}
+	if _, err := fetchReleaseNotes(context.Background(), server.URL+"/notes.html"); err == nil {
+		t.Error("expected notes that aren't text to fail")
+	}
+	if _, err := fetchReleaseNotes(context.Background(), server.URL+"/missing"); err == nil {
+		t.Error("expected missing notes to fail")
+	}
+}
@@ -0,0 +1,173 @@
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+// The release notes of an update come with the update check, as text or as
+// a link, and are saved with the downloaded installer. Clicking "An update
+// is available" in the menu shows them, so users can review the changes
+// before restarting to update. Notes only given as a link are fetched if
+// they are plain text, otherwise the link is offered to open in the browser.
+
+// Control IDs of the release notes dialog
+const (
+	releaseNotesTextID   = 401
+	releaseNotesOnlineID = 402
+	releaseNotesUpdateID = 403
+)
+
+const (
+	releaseNotesTimeout = 30 * time.Second
+	maxReleaseNotesSize = 256 << 10
+)
+
+var (
+	releaseNotesDialogMu       sync.Mutex
+	activeReleaseNotesDialog   *releaseNotesDialog // The open dialog, guarded by releaseNotesDialogMu
+	releaseNotesDialogCallback = sync.OnceValue(func() uintptr { return windows.NewCallback(releaseNotesDialogProc) })
+)
+
+type releaseNotesDialog struct {
+	notes  string
+	url    string
+	update bool // Set when the user chose to restart to update
+}
+
+// handleShowReleaseNotes shows the release notes of the downloaded update,
+// sending on update if the user chooses to restart to update.
+func handleShowReleaseNotes(update chan<- struct{}) {
+	if !releaseNotesDialogMu.TryLock() {
+		return // Already open
+	}
+	defer releaseNotesDialogMu.Unlock()
+
+	var updateResp UpdateResponse
+	installer, err := stagedInstaller()
+	if err == nil {
+		updateResp, err = loadUpdateInfo(installer)
+	}
+	if err != nil {
+		slog.Debug("no saved update info", "error", err)
+	}
+	notes := updateResp.ReleaseNotes
+	if strings.TrimSpace(notes) == "" && updateResp.ReleaseNotesURL != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), releaseNotesTimeout)
+		notes, err = fetchReleaseNotes(ctx, updateResp.ReleaseNotesURL)
+		cancel()
+		if err != nil {
+			slog.Warn("failed to fetch release notes", "url", updateResp.ReleaseNotesURL, "error", err)
+		}
+	}
+
+	dlg := &releaseNotesDialog{notes: releaseNotesText(updateResp.UpdateVersion, notes, updateResp.ReleaseNotesURL), url: updateResp.ReleaseNotesURL}
+	activeReleaseNotesDialog = dlg
+	defer func() { activeReleaseNotesDialog = nil }()
+	if err := runDialog(releaseNotesDialogTemplate(dlg.url != ""), releaseNotesDialogCallback()); err != nil {
+		slog.Warn("failed to show release notes", "error", err)
+		return
+	}
+	if dlg.update {
+		update <- struct{}{}
+	}
+}
+
+// fetchReleaseNotes downloads release notes published as plain text or
+// Markdown.
+func fetchReleaseNotes(ctx context.Context, url string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", userAgent())
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/plain" && mediaType != "text/markdown" {
+		return "", fmt.Errorf("release notes are %q, not text", mediaType)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxReleaseNotesSize))
+	if err != nil {
+		return "", err
+	}
+	return string(body), nil
+}
+
+// releaseNotesText returns the text the dialog shows, with the line breaks
+// of an edit control.
+func releaseNotesText(version, notes, url string) string {
+	notes = strings.TrimSpace(notes)
+	if notes == "" {
+		notes = "No release notes came with this update."
+		if url != "" {
+			notes += " Click View online to read them in your browser."
+		}
+	}
+	if version != "" {
+		notes = "ReEnvision AI " + version + "\n\n" + notes
+	}
+	return strings.ReplaceAll(strings.ReplaceAll(notes, "\r\n", "\n"), "\n", "\r\n")
+}
+
+func releaseNotesDialogTemplate(online bool) []uint16 {
+	items := []dialogItem{
+		{class: dialogEdit, style: dialogChild | WS_TABSTOP | WS_VSCROLL | ES_MULTILINE | ES_AUTOVSCROLL | ES_READONLY, exStyle: WS_EX_CLIENTEDGE, x: 7, y: 7, cx: 286, cy: 180, id: releaseNotesTextID},
+		{class: dialogButton, style: dialogChild | WS_TABSTOP | BS_DEFPUSHBUTTON, x: 163, y: 194, cx: 76, cy: 14, id: releaseNotesUpdateID, title: "&Restart to update"},
+		{class: dialogButton, style: dialogChild | WS_TABSTOP, x: 243, y: 194, cx: 50, cy: 14, id: IDCANCEL, title: "Close"},
+	}
+	if online {
+		items = append(items, dialogItem{class: dialogButton, style: dialogChild | WS_TABSTOP, x: 7, y: 194, cx: 60, cy: 14, id: releaseNotesOnlineID, title: "View &online"})
+	}
+	return buildDialogTemplate("ReEnvision AI release notes", 300, 215, items)
+}
+
+func releaseNotesDialogProc(hwnd, msg, wParam, lParam uintptr) uintptr {
+	dlg := activeReleaseNotesDialog
+	if dlg == nil {
+		return dialogDefault
+	}
+
+	switch msg {
+	case WM_INITDIALOG:
+		pSendMessage.Call(dlgItem(hwnd, releaseNotesTextID), EM_SETLIMITTEXT, 0, 0) //nolint:errcheck
+		setDlgItemText(hwnd, releaseNotesTextID, dlg.notes)
+		pSetFocus.Call(dlgItem(hwnd, releaseNotesUpdateID)) //nolint:errcheck
+		return dialogFocusSet
+
+	case WM_COMMAND:
+		if wParam>>16&0xFFFF != BN_CLICKED {
+			return dialogDefault
+		}
+		switch uint16(wParam) {
+		case releaseNotesOnlineID:
+			if err := openURL(dlg.url); err != nil {
+				slog.Warn("failed to open release notes", "url", dlg.url, "error", err)
+			}
+		case releaseNotesUpdateID:
+			dlg.update = true
+			pEndDialog.Call(hwnd, IDOK) //nolint:errcheck
+		case IDCANCEL:
+			pEndDialog.Call(hwnd, IDCANCEL) //nolint:errcheck
+		default:
+			return dialogDefault
+		}
+		return dialogHandled
+	}
+	return dialogDefault
+}
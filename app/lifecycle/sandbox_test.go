@@ -0,0 +1,32 @@
+//go:build windows && unit_test
+
+package lifecycle
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+func TestModelServerSeccompProfile(t *testing.T) {
+	profile := modelServerSeccompProfile()
+	if _, err := json.Marshal(profile); err != nil {
+		t.Fatalf("failed to marshal profile: %v", err)
+	}
+
+	seen := map[string]bool{}
+	for _, rule := range profile.Syscalls {
+		for _, name := range rule.Names {
+			if seen[name] {
+				t.Errorf("syscall %s is listed twice", name)
+			}
+			seen[name] = true
+		}
+	}
+
+	for _, blocked := range []string{"mount", "ptrace", "unshare", "setns", "init_module", "bpf", "kexec_load", "io_uring_setup"} {
+		if slices.Contains(modelServerSyscalls, blocked) {
+			t.Errorf("syscall %s must not be allowed", blocked)
+		}
+	}
+}
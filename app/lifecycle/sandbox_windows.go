@@ -0,0 +1,167 @@
+package lifecycle
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// The container runs under a seccomp profile generated from the syscalls the
+// model server needs, instead of in privileged mode. The profile is written
+// to the config dir on every start so it follows app updates; a custom
+// profile can be configured with seccomp_profile, and container_sandbox
+// selects Podman's default profile or the old privileged mode as an escape
+// hatch for setups the profile breaks.
+
+// Values for AppConfig.ContainerSandbox
+const (
+	sandboxSeccomp    = "seccomp"    // The generated profile, or seccomp_profile, the default
+	sandboxDefault    = "default"    // Podman's default seccomp profile
+	sandboxPrivileged = "privileged" // No confinement, the behaviour before sandboxing
+)
+
+// seccompProfileName is versioned so a profile from an older app is never
+// mistaken for the current one.
+const seccompProfileName = "seccomp-model-server-v1.json"
+
+// sandboxArgs returns the podman run arguments confining the container.
+func sandboxArgs() ([]string, error) {
+	cfg := currentAppConfig()
+	switch cfg.ContainerSandbox {
+	case sandboxPrivileged:
+		slog.Warn("Container sandbox disabled, running privileged")
+		return []string{"--privileged"}, nil
+	case sandboxDefault:
+		return nil, nil
+	}
+
+	profile := cfg.SeccompProfile
+	if profile == "" {
+		var err error
+		if profile, err = writeSeccompProfile(); err != nil {
+			return nil, err
+		}
+	}
+	return []string{
+		"--security-opt=seccomp=" + profile,
+		"--security-opt=no-new-privileges",
+	}, nil
+}
+
+// writeSeccompProfile writes the generated profile next to config.json and
+// returns its path.
+func writeSeccompProfile() (string, error) {
+	configFile, err := configFilePath()
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(filepath.Dir(configFile), seccompProfileName)
+	data, err := json.MarshalIndent(modelServerSeccompProfile(), "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if existing, err := os.ReadFile(path); err == nil && slices.Equal(existing, data) {
+		return path, nil
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return "", fmt.Errorf("failed to write seccomp profile %s: %w", path, err)
+	}
+	slog.Info("Wrote seccomp profile", "path", path)
+	return path, nil
+}
+
+type seccompProfile struct {
+	DefaultAction   string        `json:"defaultAction"`
+	DefaultErrnoRet int           `json:"defaultErrnoRet"`
+	Architectures   []string      `json:"architectures"`
+	Syscalls        []seccompRule `json:"syscalls"`
+}
+
+type seccompRule struct {
+	Names    []string     `json:"names"`
+	Action   string       `json:"action"`
+	Args     []seccompArg `json:"args,omitempty"`
+	ErrnoRet int          `json:"errnoRet,omitempty"`
+}
+
+type seccompArg struct {
+	Index    uint   `json:"index"`
+	Value    uint64 `json:"value"`
+	ValueTwo uint64 `json:"valueTwo"`
+	Op       string `json:"op"`
+}
+
+const (
+	errnoEPERM  = 1
+	errnoENOSYS = 38
+
+	// CLONE_NEWNS|CLONE_NEWCGROUP|CLONE_NEWUTS|CLONE_NEWIPC|CLONE_NEWUSER|CLONE_NEWPID|CLONE_NEWNET
+	cloneNamespaceFlags = 0x7E020000
+)
+
+// modelServerSyscalls are allowed unconditionally. They cover Python, PyTorch
+// with CUDA, and the networking of the swarm. Namespaces, module loading,
+// mounts, tracing, BPF, keyrings and io_uring stay blocked.
+var modelServerSyscalls = []string{
+	"accept", "accept4", "access", "alarm", "arch_prctl", "bind", "brk",
+	"capget", "capset", "chdir", "chmod", "chown", "clock_getres", "clock_gettime",
+	"clock_nanosleep", "close", "close_range", "connect", "copy_file_range", "creat",
+	"dup", "dup2", "dup3", "epoll_create", "epoll_create1", "epoll_ctl", "epoll_pwait",
+	"epoll_pwait2", "epoll_wait", "eventfd", "eventfd2", "execve", "execveat", "exit",
+	"exit_group", "faccessat", "faccessat2", "fadvise64", "fallocate", "fchdir", "fchmod",
+	"fchmodat", "fchown", "fchownat", "fcntl", "fdatasync", "fgetxattr", "flistxattr",
+	"flock", "fork", "fstat", "fstatfs", "fsync", "ftruncate", "futex", "futex_waitv",
+	"get_mempolicy", "get_robust_list", "getcpu", "getcwd", "getdents", "getdents64",
+	"getegid", "geteuid", "getgid", "getgroups", "getitimer", "getpeername", "getpgid",
+	"getpgrp", "getpid", "getppid", "getpriority", "getrandom", "getresgid", "getresuid",
+	"getrlimit", "getrusage", "getsid", "getsockname", "getsockopt", "gettid",
+	"gettimeofday", "getuid", "getxattr", "inotify_add_watch", "inotify_init",
+	"inotify_init1", "inotify_rm_watch", "ioctl", "kill", "lchown", "lgetxattr", "link",
+	"linkat", "listen", "listxattr", "llistxattr", "lseek", "lstat", "madvise",
+	"mbind", "membarrier", "memfd_create", "mincore", "mkdir", "mkdirat", "mknod",
+	"mknodat", "mlock", "mlock2", "mlockall", "mmap", "mprotect", "mremap", "msync",
+	"munlock", "munlockall", "munmap", "nanosleep", "newfstatat", "open", "openat",
+	"openat2", "pause", "pipe", "pipe2", "poll", "ppoll", "prctl", "pread64", "preadv",
+	"preadv2", "prlimit64", "pselect6", "pwrite64", "pwritev", "pwritev2", "read",
+	"readahead", "readlink", "readlinkat", "readv", "recvfrom", "recvmmsg", "recvmsg",
+	"rename", "renameat", "renameat2", "restart_syscall", "rmdir", "rseq",
+	"rt_sigaction", "rt_sigpending", "rt_sigprocmask", "rt_sigqueueinfo", "rt_sigreturn",
+	"rt_sigsuspend", "rt_sigtimedwait", "rt_tgsigqueueinfo", "sched_get_priority_max",
+	"sched_get_priority_min", "sched_getaffinity", "sched_getattr", "sched_getparam",
+	"sched_getscheduler", "sched_rr_get_interval", "sched_setaffinity", "sched_setattr",
+	"sched_setparam", "sched_setscheduler", "sched_yield", "select", "sendfile",
+	"sendmmsg", "sendmsg", "sendto", "set_mempolicy", "set_robust_list", "set_tid_address",
+	"setfsgid", "setfsuid", "setgid", "setgroups", "setitimer", "setpgid", "setpriority",
+	"setregid", "setresgid", "setresuid", "setreuid", "setrlimit", "setsid", "setsockopt",
+	"setuid", "shmat", "shmctl", "shmdt", "shmget", "shutdown", "sigaltstack", "socket",
+	"socketpair", "splice", "stat", "statfs", "statx", "symlink", "symlinkat", "sync",
+	"sync_file_range", "syncfs", "sysinfo", "tee", "tgkill", "time", "timer_create",
+	"timer_delete", "timer_getoverrun", "timer_gettime", "timer_settime", "timerfd_create",
+	"timerfd_gettime", "timerfd_settime", "times", "tkill", "truncate", "umask", "uname",
+	"unlink", "unlinkat", "utime", "utimensat", "utimes", "vfork", "wait4", "waitid",
+	"write", "writev",
+}
+
+// modelServerSeccompProfile returns the seccomp profile of the model server.
+func modelServerSeccompProfile() seccompProfile {
+	return seccompProfile{
+		DefaultAction:   "SCMP_ACT_ERRNO",
+		DefaultErrnoRet: errnoEPERM,
+		Architectures:   []string{"SCMP_ARCH_X86_64", "SCMP_ARCH_X86", "SCMP_ARCH_X32"},
+		Syscalls: []seccompRule{
+			{Names: modelServerSyscalls, Action: "SCMP_ACT_ALLOW"},
+			// Threads and processes, but no new namespaces
+			{
+				Names:  []string{"clone"},
+				Action: "SCMP_ACT_ALLOW",
+				Args:   []seccompArg{{Index: 0, Value: cloneNamespaceFlags, ValueTwo: 0, Op: "SCMP_CMP_MASKED_EQ"}},
+			},
+			// clone3 passes its flags in memory where seccomp can't check them,
+			// so make libc fall back to clone
+			{Names: []string{"clone3"}, Action: "SCMP_ACT_ERRNO", ErrnoRet: errnoENOSYS},
+		},
+	}
+}
@@ -0,0 +1,199 @@
+package lifecycle
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ReEnvision-AI/systray/app/format"
+)
+
+// Besides whether it is ready, the server's output tells how far the model
+// download got, how many blocks the node serves, how many peers it is
+// connected to and its throughput. Lines showing these are parsed into
+// serverProgress, which the tray status, tooltip and status popup show.
+// Download progress bars redraw many times a second, so their lines are only
+// logged at debug level.
+
+// serverProgress is what the server's output showed about the node.
+type serverProgress struct {
+	Downloading bool    // Whether the model is being downloaded
+	Download    int     // Percent of the file being downloaded
+	Blocks      int     // Blocks the node announced it serves, -1 until announced
+	Peers       int     // Connected peers, -1 until reported
+	Throughput  float64 // Tokens per second the server reported, 0 until reported
+}
+
+var (
+	// Progress bars like "model-00001-of-00004.safetensors:  45%|####  | 2.2G/4.9G [00:31<00:40, 66.1MB/s]"
+	progressBarPattern   = regexp.MustCompile(`^\s*([^|]*?):\s*(\d{1,3})%\|`)
+	downloadFilePattern  = regexp.MustCompile(`(?i)download|fetching|\.(safetensors|bin|gguf|json|model|txt)$`)
+	announcedPattern     = regexp.MustCompile(`(?i)announced that blocks \[([^\]]*)\] are (\w+)`)
+	servingBlocksPattern = regexp.MustCompile(`(?i)serv(?:es|ing) (\d+) (?:transformer )?blocks`)
+	peersPattern         = regexp.MustCompile(`(?i)(?:connected to|found|with) (\d+) (?:other |remote )?peers?\b`)
+	// Like "Reporting throughput: 1285.5 tokens/sec for 24 blocks"
+	throughputPattern = regexp.MustCompile(`(?i)throughput:?\s*([\d.]+)\s*(?:tokens|tok)/s`)
+)
+
+var (
+	progressMu   sync.Mutex
+	nodeProgress = newServerProgress()
+)
+
+func newServerProgress() serverProgress {
+	return serverProgress{Blocks: -1, Peers: -1}
+}
+
+// parseLine updates the progress from a line of server output. Reports
+// whether the progress changed, and whether the line is a progress bar.
+func (p *serverProgress) parseLine(line string) (changed, progressBar bool) {
+	// Progress bars redraw after a carriage return, the last one is current
+	if i := strings.LastIndexByte(strings.TrimRight(line, "\r"), '\r'); i >= 0 {
+		line = line[i+1:]
+	}
+	before := *p
+	if m := progressBarPattern.FindStringSubmatch(line); m != nil {
+		progressBar = true
+		if downloadFilePattern.MatchString(m[1]) {
+			percent, _ := strconv.Atoi(m[2])
+			p.Downloading, p.Download = percent < 100, min(percent, 100)
+		}
+	} else if m := announcedPattern.FindStringSubmatch(line); m != nil {
+		switch strings.ToLower(m[2]) {
+		case "joining", "online":
+			p.Blocks = countListItems(m[1])
+		default:
+			p.Blocks = 0
+		}
+	} else if m := servingBlocksPattern.FindStringSubmatch(line); m != nil {
+		p.Blocks, _ = strconv.Atoi(m[1])
+	}
+	if m := peersPattern.FindStringSubmatch(line); m != nil {
+		p.Peers, _ = strconv.Atoi(m[1])
+	}
+	if m := throughputPattern.FindStringSubmatch(line); m != nil {
+		p.Throughput, _ = strconv.ParseFloat(m[1], 64)
+	}
+	return *p != before, progressBar
+}
+
+// countListItems counts the items of a printed Python list, like
+// "'model.0', 'model.1'".
+func countListItems(list string) int {
+	count := 0
+	for _, item := range strings.Split(list, ",") {
+		if strings.TrimSpace(item) != "" {
+			count++
+		}
+	}
+	return count
+}
+
+// summary describes the progress for the tray, empty if nothing was shown
+// yet.
+func (p serverProgress) summary() string {
+	return sentence(p.parts())
+}
+
+func (p serverProgress) parts() []string {
+	var parts []string
+	if p.Downloading {
+		parts = append(parts, fmt.Sprintf("downloading model %d%%", p.Download))
+	}
+	if p.Blocks >= 0 {
+		parts = append(parts, fmt.Sprintf("serving %d %s", p.Blocks, pluralize(p.Blocks, "block")))
+	}
+	if p.Peers >= 0 {
+		parts = append(parts, fmt.Sprintf("%d %s", p.Peers, pluralize(p.Peers, "peer")))
+	}
+	return parts
+}
+
+// throughputText returns the throughput for the tray, empty until reported.
+func (p serverProgress) throughputText() string {
+	if p.Throughput <= 0 {
+		return ""
+	}
+	return format.Count(int64(p.Throughput+0.5)) + " tokens/s"
+}
+
+// statusLine adds the blocks the node serves and its throughput to text if
+// it is the plain running status.
+func (p serverProgress) statusLine(text string) string {
+	if text != StateRunning.String() {
+		return text
+	}
+	var parts []string
+	if p.Blocks >= 0 {
+		parts = append(parts, fmt.Sprintf("%d %s", p.Blocks, pluralize(p.Blocks, "block")))
+	}
+	if throughput := p.throughputText(); throughput != "" {
+		parts = append(parts, throughput)
+	}
+	if len(parts) == 0 {
+		return text
+	}
+	return text + " (" + strings.Join(parts, ", ") + ")"
+}
+
+// contributionText describes the contribution of a node for the tooltip,
+// like "Up 2 h 15 min, serving 12 blocks, 8 peers, 1,285 tokens/s". uptime
+// is 0 unless the node is running.
+func contributionText(uptime time.Duration, p serverProgress) string {
+	var parts []string
+	if uptime > 0 {
+		parts = append(parts, "up "+format.Duration(uptime))
+	}
+	parts = append(parts, p.parts()...)
+	if throughput := p.throughputText(); throughput != "" {
+		parts = append(parts, throughput)
+	}
+	return sentence(parts)
+}
+
+// sentence joins parts, capitalizing the first.
+func sentence(parts []string) string {
+	if len(parts) == 0 {
+		return ""
+	}
+	s := strings.Join(parts, ", ")
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+func pluralize(n int, word string) string {
+	if n == 1 {
+		return word
+	}
+	return word + "s"
+}
+
+// recordServerProgress updates the node's progress from a line of server
+// output, showing it in the tray if it changed. Reports whether the line is
+// a progress bar.
+func recordServerProgress(line string) bool {
+	progressMu.Lock()
+	changed, progressBar := nodeProgress.parseLine(line)
+	progressMu.Unlock()
+	if changed {
+		refreshTrayStatus()
+	}
+	return progressBar
+}
+
+// currentServerProgress returns the latest progress of the node.
+func currentServerProgress() serverProgress {
+	progressMu.Lock()
+	defer progressMu.Unlock()
+	return nodeProgress
+}
+
+// resetServerProgress forgets the progress once the node's container starts
+// or exits.
+func resetServerProgress() {
+	progressMu.Lock()
+	defer progressMu.Unlock()
+	nodeProgress = newServerProgress()
+}
@@ -0,0 +1,76 @@
+//go:build windows && unit_test
+
+package lifecycle
+
+import (
+	"testing"
+	"time"
+)
+
+func TestServerProgressParseLine(t *testing.T) {
+	p := newServerProgress()
+	if p.summary() != "" {
+		t.Errorf("expected no summary before any output, got %q", p.summary())
+	}
+
+	changed, bar := p.parseLine("model-00001-of-00004.safetensors:   4%|▍         | 210M/4.98G [00:03<01:10, 67.6MB/s]\rmodel-00001-of-00004.safetensors:  45%|████▌     | 2.24G/4.98G [00:31<00:40, 66.1MB/s]")
+	if !changed || !bar || !p.Downloading || p.Download != 45 {
+		t.Errorf("expected the download at 45%%, got %+v (changed %v, bar %v)", p, changed, bar)
+	}
+	if changed, _ := p.parseLine("model-00001-of-00004.safetensors:  45%|████▌     | 2.25G/4.98G [00:32<00:40, 66.0MB/s]"); changed {
+		t.Error("expected the same percentage not to change the progress")
+	}
+	p.parseLine("model-00001-of-00004.safetensors: 100%|██████████| 4.98G/4.98G [01:15<00:00, 66.3MB/s]")
+	if p.Downloading {
+		t.Error("expected the download to be done")
+	}
+	if changed, bar := p.parseLine("Loading checkpoint shards:  50%|█████     | 1/2 [00:04<00:04,  4.10s/it]"); changed || !bar {
+		t.Errorf("expected loading shards to be a progress bar but not a download, changed %v, bar %v", changed, bar)
+	}
+
+	p.parseLine("Jan 01 12:00:00.000 [INFO] Announced that blocks ['Llama-3.1-8B-hf.12', 'Llama-3.1-8B-hf.13', 'Llama-3.1-8B-hf.14'] are joining")
+	if p.Blocks != 3 {
+		t.Errorf("expected 3 blocks, got %d", p.Blocks)
+	}
+	if _, bar := p.parseLine("Jan 01 12:00:05.000 [INFO] Connected to 8 peers"); bar || p.Peers != 8 {
+		t.Errorf("expected 8 peers from a line that isn't a progress bar, got %d", p.Peers)
+	}
+	if got, want := p.summary(), "Serving 3 blocks, 8 peers"; got != want {
+		t.Errorf("expected summary %q, got %q", want, got)
+	}
+
+	p.parseLine("Jan 01 13:00:00.000 [INFO] Announced that blocks ['Llama-3.1-8B-hf.12', 'Llama-3.1-8B-hf.13', 'Llama-3.1-8B-hf.14'] are offline")
+	if p.Blocks != 0 {
+		t.Errorf("expected no blocks once offline, got %d", p.Blocks)
+	}
+	p.parseLine("Server is serving 1 blocks")
+	p.parseLine("Found 1 peer")
+	if got, want := p.summary(), "Serving 1 block, 1 peer"; got != want {
+		t.Errorf("expected summary %q, got %q", want, got)
+	}
+}
+
+func TestContributionStats(t *testing.T) {
+	p := newServerProgress()
+	if got := p.statusLine("Running"); got != "Running" {
+		t.Errorf("expected the plain status before any output, got %q", got)
+	}
+	if got := contributionText(0, p); got != "" {
+		t.Errorf("expected no stats while stopped, got %q", got)
+	}
+
+	p.parseLine("Jan 01 12:00:00.000 [INFO] Announced that blocks ['Llama-3.1-8B-hf.12', 'Llama-3.1-8B-hf.13'] are online")
+	p.parseLine("Jan 01 12:00:05.000 [INFO] Reporting throughput: 1285.5 tokens/sec for 2 blocks")
+	if p.Throughput != 1285.5 {
+		t.Errorf("expected the throughput to be parsed, got %v", p.Throughput)
+	}
+	if got, want := p.statusLine("Running"), "Running (2 blocks, 1,286 tokens/s)"; got != want {
+		t.Errorf("expected status %q, got %q", want, got)
+	}
+	if got := p.statusLine(unhealthyText); got != unhealthyText {
+		t.Errorf("expected other status texts to be kept, got %q", got)
+	}
+	if got, want := contributionText(2*time.Hour+15*time.Minute, p), "Up 2 h 15 min, serving 2 blocks, 1,286 tokens/s"; got != want {
+		t.Errorf("expected stats %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,60 @@
+//go:build windows && unit_test
+
+package lifecycle
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseSettings(t *testing.T) {
+	tests := []struct {
+		image, model, port string
+		field              uint16
+	}{
+		{"", "org/model", "31330", settingsImageID},
+		{"example.com/node:latest", " ", "31330", settingsModelID},
+		{"example.com/node:latest", "org/model", "0", settingsPortID},
+		{"example.com/node:latest", "org/model", "70000", settingsPortID},
+		{" example.com/node:latest ", "org/model", "31330", 0},
+	}
+	for _, tt := range tests {
+		settings, problem, field := parseSettings(tt.image, tt.model, tt.port, true)
+		if field != tt.field || (problem == "") != (tt.field == 0) {
+			t.Errorf("parseSettings(%q, %q, %q) = %q, %d, want field %d", tt.image, tt.model, tt.port, problem, field, tt.field)
+		}
+		if field == 0 && (settings.ContainerImage != "example.com/node:latest" || settings.DefaultPort != 31330 || !settings.UseGPU) {
+			t.Errorf("unexpected settings %+v", settings)
+		}
+	}
+}
+
+func TestUpdateConfigFile(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), configFileName)
+	original := `{"container_name": "reai", "container_image": "old:1", "model_name": "org/old", "custom_field": {"kept": true}}`
+	if err := os.WriteFile(configFile, []byte(original), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	settings := nodeSettings{ContainerImage: "new:2", ModelName: "org/new", DefaultPort: 31331, UseGPU: true}
+	if err := updateConfigFile(configFile, settings); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := readConfigFile(configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ContainerImage != "new:2" || cfg.ModelName != "org/new" || cfg.DefaultPort != 31331 || !cfg.UseGPU || cfg.ContainerName != "reai" {
+		t.Errorf("settings not applied: %+v", cfg)
+	}
+	var fields map[string]json.RawMessage
+	data, _ := os.ReadFile(configFile)
+	if err := json.Unmarshal(data, &fields); err != nil || string(fields["custom_field"]) == "" {
+		t.Errorf("unknown field not kept: %s", data)
+	}
+	if backup, err := os.ReadFile(configFile + ".bak"); err != nil || string(backup) != original {
+		t.Errorf("expected a backup of the original file, got %q, %v", backup, err)
+	}
+}
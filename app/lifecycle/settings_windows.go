@@ -0,0 +1,265 @@
+package lifecycle
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/sys/windows"
+)
+
+// The settings dialog edits the common fields of config.json so users don't
+// have to find and hand-edit the file in AppData. Other fields, including
+// ones this version doesn't know, are kept as they are. The edited file is
+// validated like at startup before it replaces config.json, the previous one
+// kept as config.json.bak.
+
+// Control IDs of the settings dialog
+const (
+	settingsImageID = 201
+	settingsModelID = 202
+	settingsPortID  = 203
+	settingsGPUID   = 204
+	settingsErrorID = 205
+)
+
+const maxSettingLength = 1024
+
+// nodeSettings are the config fields the settings dialog edits.
+type nodeSettings struct {
+	ContainerImage string
+	ModelName      string
+	DefaultPort    uint64
+	UseGPU         bool
+}
+
+var (
+	settingsDialogMu       sync.Mutex
+	activeSettingsDialog   *settingsDialog // The open dialog, guarded by settingsDialogMu
+	settingsDialogCallback = sync.OnceValue(func() uintptr { return windows.NewCallback(settingsDialogProc) })
+)
+
+type settingsDialog struct {
+	configFile string
+	settings   nodeSettings
+	overridden []string // Environment variables overriding the edited settings
+	saved      bool
+}
+
+// handleSettings shows the settings dialog and offers to restart a running
+// node once the settings changed.
+func handleSettings() {
+	if !settingsDialogMu.TryLock() {
+		return // Already open
+	}
+	defer settingsDialogMu.Unlock()
+
+	configFile, err := configFilePath()
+	if err != nil {
+		slog.Error("Failed to locate config file", "error", err)
+		return
+	}
+	cfg, err := readConfigFile(configFile)
+	if err != nil {
+		slog.Error("Failed to read config file for the settings", "error", err)
+		messageBox("ReEnvision AI settings", "The configuration file can't be read, so it can't be edited here:\n\n"+err.Error(), windows.MB_OK|windows.MB_ICONERROR)
+		return
+	}
+
+	// The dialog edits the file, not the environment overrides
+	if data, err := os.ReadFile(configFile); err == nil {
+		var fileCfg AppConfig
+		if json.Unmarshal(data, &fileCfg) == nil {
+			cfg.ContainerImage, cfg.ModelName, cfg.DefaultPort, cfg.UseGPU = fileCfg.ContainerImage, fileCfg.ModelName, fileCfg.DefaultPort, fileCfg.UseGPU
+		}
+	}
+	if cfg.DefaultPort == 0 {
+		cfg.DefaultPort = fallbackPort
+	}
+	var overridden []string
+	for _, key := range []string{"CONTAINER_IMAGE", "MODEL_NAME", "DEFAULT_PORT", "USE_GPU"} {
+		if _, ok := lookupConfigEnv(configEnvPrefix + key); ok {
+			overridden = append(overridden, configEnvPrefix+key)
+		}
+	}
+
+	dlg := &settingsDialog{
+		configFile: configFile,
+		settings: nodeSettings{
+			ContainerImage: cfg.ContainerImage,
+			ModelName:      cfg.ModelName,
+			DefaultPort:    cfg.DefaultPort,
+			UseGPU:         cfg.UseGPU,
+		},
+		overridden: overridden,
+	}
+	activeSettingsDialog = dlg
+	defer func() { activeSettingsDialog = nil }()
+	if err := runDialog(settingsDialogTemplate(), settingsDialogCallback()); err != nil {
+		slog.Warn("failed to show settings dialog", "error", err)
+		return
+	}
+	if !dlg.saved {
+		return
+	}
+	slog.Info("Settings changed", "image", dlg.settings.ContainerImage, "model", dlg.settings.ModelName,
+		"port", dlg.settings.DefaultPort, "use_gpu", dlg.settings.UseGPU)
+
+	stateMu.Lock()
+	running := currentState == StateRunning || currentState == StateLoading || currentState == StateStarting || currentState == StatePaused
+	stateMu.Unlock()
+	if running && confirm("ReEnvision AI settings", "The settings apply the next time the node starts. Restart the node now?") {
+		restartNode()
+	}
+}
+
+func settingsDialogTemplate() []uint16 {
+	return buildDialogTemplate("ReEnvision AI settings", 250, 150, []dialogItem{
+		{class: dialogStatic, style: dialogChild, x: 7, y: 7, cx: 236, cy: 18, id: dialogLabelID,
+			title: "These settings are saved to config.json and apply when the node starts."},
+		{class: dialogStatic, style: dialogChild, x: 7, y: 28, cx: 236, cy: 9, id: dialogLabelID, title: "Container &image:"},
+		{class: dialogEdit, style: dialogChild | WS_TABSTOP | ES_AUTOHSCROLL, exStyle: WS_EX_CLIENTEDGE, x: 7, y: 38, cx: 236, cy: 13, id: settingsImageID},
+		{class: dialogStatic, style: dialogChild, x: 7, y: 56, cx: 236, cy: 9, id: dialogLabelID, title: "&Model:"},
+		{class: dialogEdit, style: dialogChild | WS_TABSTOP | ES_AUTOHSCROLL, exStyle: WS_EX_CLIENTEDGE, x: 7, y: 66, cx: 236, cy: 13, id: settingsModelID},
+		{class: dialogStatic, style: dialogChild, x: 7, y: 84, cx: 60, cy: 9, id: dialogLabelID, title: "&Port:"},
+		{class: dialogEdit, style: dialogChild | WS_TABSTOP | ES_NUMBER, exStyle: WS_EX_CLIENTEDGE, x: 7, y: 94, cx: 60, cy: 13, id: settingsPortID},
+		{class: dialogButton, style: dialogChild | WS_TABSTOP | BS_AUTOCHECKBOX, x: 80, y: 95, cx: 120, cy: 10, id: settingsGPUID, title: "Use the &GPU"},
+		{class: dialogStatic, style: dialogChild | SS_NOPREFIX, x: 7, y: 111, cx: 236, cy: 16, id: settingsErrorID},
+		{class: dialogButton, style: dialogChild | WS_TABSTOP | BS_DEFPUSHBUTTON, x: 139, y: 129, cx: 50, cy: 14, id: IDOK, title: "Save"},
+		{class: dialogButton, style: dialogChild | WS_TABSTOP, x: 193, y: 129, cx: 50, cy: 14, id: IDCANCEL, title: "Cancel"},
+	})
+}
+
+func settingsDialogProc(hwnd, msg, wParam, lParam uintptr) uintptr {
+	dlg := activeSettingsDialog
+	if dlg == nil {
+		return dialogDefault
+	}
+
+	switch msg {
+	case WM_INITDIALOG:
+		setDlgItemText(hwnd, settingsImageID, dlg.settings.ContainerImage)
+		setDlgItemText(hwnd, settingsModelID, dlg.settings.ModelName)
+		setDlgItemText(hwnd, settingsPortID, strconv.FormatUint(dlg.settings.DefaultPort, 10))
+		if dlg.settings.UseGPU {
+			pCheckDlgButton.Call(hwnd, settingsGPUID, 1) //nolint:errcheck
+		}
+		if len(dlg.overridden) > 0 {
+			setDlgItemText(hwnd, settingsErrorID, strings.Join(dlg.overridden, ", ")+" set in the environment override the settings here.")
+		} else if policy := currentOrgPolicy(); policy.ModelName != "" {
+			setDlgItemText(hwnd, settingsErrorID, "Your organization serves "+policy.ModelName+" instead of the model set here.")
+		}
+		return 1 // Focus the first field
+
+	case WM_CTLCOLORSTATIC:
+		if lParam != dlgItem(hwnd, settingsErrorID) {
+			return dialogDefault
+		}
+		return paintErrorText(wParam)
+
+	case WM_COMMAND:
+		if wParam>>16&0xFFFF != BN_CLICKED {
+			return dialogDefault
+		}
+		switch uint16(wParam) {
+		case IDOK:
+			checked, _, _ := pIsDlgButtonChecked.Call(hwnd, settingsGPUID)
+			settings, problem, field := parseSettings(
+				getDlgItemText(hwnd, settingsImageID, maxSettingLength),
+				getDlgItemText(hwnd, settingsModelID, maxSettingLength),
+				getDlgItemText(hwnd, settingsPortID, maxSettingLength),
+				checked != 0,
+			)
+			if problem != "" {
+				setDlgItemText(hwnd, settingsErrorID, problem)
+				pSetFocus.Call(dlgItem(hwnd, field)) //nolint:errcheck
+				return dialogHandled
+			}
+			if err := updateConfigFile(dlg.configFile, settings); err != nil {
+				slog.Warn("Failed to save settings", "error", err)
+				setDlgItemText(hwnd, settingsErrorID, err.Error())
+				return dialogHandled
+			}
+			dlg.settings, dlg.saved = settings, true
+			pEndDialog.Call(hwnd, IDOK) //nolint:errcheck
+		case IDCANCEL:
+			pEndDialog.Call(hwnd, IDCANCEL) //nolint:errcheck
+		default:
+			return dialogDefault
+		}
+		return dialogHandled
+	}
+	return dialogDefault
+}
+
+// parseSettings validates the entered settings. It returns what is wrong
+// and the ID of the field to fix, or "" if they can be saved.
+func parseSettings(image, model, port string, useGPU bool) (nodeSettings, string, uint16) {
+	settings := nodeSettings{
+		ContainerImage: strings.TrimSpace(image),
+		ModelName:      strings.TrimSpace(model),
+		UseGPU:         useGPU,
+	}
+	if settings.ContainerImage == "" || strings.ContainsAny(settings.ContainerImage, " \t") {
+		return settings, "Enter the container image, such as registry.example.com/node:latest.", settingsImageID
+	}
+	if settings.ModelName == "" || strings.ContainsAny(settings.ModelName, " \t") {
+		return settings, "Enter the HuggingFace name of the model, such as organization/model.", settingsModelID
+	}
+	p, err := strconv.ParseUint(strings.TrimSpace(port), 10, 16)
+	if err != nil || p == 0 {
+		return settings, "Enter a port between 1 and 65535.", settingsPortID
+	}
+	settings.DefaultPort = p
+	return settings, "", 0
+}
+
+// updateConfigFile writes settings to the config file, keeping its other
+// fields. The result must pass the startup validation before it replaces
+// the file, which is kept as a backup.
+func updateConfigFile(configFile string, settings nodeSettings) error {
+	data, err := os.ReadFile(configFile)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", configFile, err)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", configFile, err)
+	}
+	for key, value := range map[string]any{
+		"container_image": settings.ContainerImage,
+		"model_name":      settings.ModelName,
+		"default_port":    settings.DefaultPort,
+		"use_gpu":         settings.UseGPU,
+	} {
+		if fields[key], err = json.Marshal(value); err != nil {
+			return err
+		}
+	}
+	updated, err := json.MarshalIndent(fields, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := configFile + ".tmp"
+	if err := os.WriteFile(tmp, append(updated, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to save settings: %w", err)
+	}
+	if _, err := readConfigFile(tmp); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.WriteFile(configFile+".bak", data, 0o644); err != nil {
+		slog.Warn("Failed to back up config file", "error", err)
+	}
+	rememberConfigContent(append(updated, '\n'))
+	if err := os.Rename(tmp, configFile); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to save settings: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+//go:build windows && unit_test
+
+package lifecycle
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStateNotification(t *testing.T) {
+	tests := []struct {
+		previous, state AppState
+		want            string
+	}{
+		{StateLoading, StateRunning, "Node is running"},
+		{StateStarting, StateRunning, "Node is running"},
+		{StateRunning, StateError, "Node stopped with an error"},
+		{StateRunning, StateStopped, "Node stopped unexpectedly"},
+		{StateStarting, StateError, ""}, // Start failures are notified on their own
+		{StateStopping, StateStopped, ""},
+		{StateStarting, StateStopped, ""},
+		{StateStopped, StateStarting, ""},
+	}
+	for _, test := range tests {
+		_, title, _, ok := stateNotification(test.previous, test.state, errors.New("exit code 1"))
+		if ok != (test.want != "") || title != test.want {
+			t.Errorf("%s to %s: expected %q, got %q", test.previous, test.state, test.want, title)
+		}
+	}
+}
+
+func TestAllowStateNotification(t *testing.T) {
+	defer func() { stateNotified, stateSuppressed = nil, false }()
+	stateNotified = nil
+
+	now := time.Date(2024, 5, 6, 12, 0, 0, 0, time.Local)
+	cfg := StateNotifications{MaxPerHour: 2}
+	for i, want := range []bool{true, true, false, false} {
+		if got := allowStateNotification(cfg, now.Add(time.Duration(i)*time.Minute)); got != want {
+			t.Errorf("notification %d: expected allowed %v, got %v", i+1, want, got)
+		}
+	}
+	if !allowStateNotification(cfg, now.Add(stateNotifyPeriod)) {
+		t.Error("expected notifications again once the first left the period")
+	}
+
+	quiet := StateNotifications{DoNotDisturb: []scheduleWindow{{Start: "11:00", End: "13:00"}}}
+	if allowStateNotification(quiet, now.AddDate(0, 0, 1)) {
+		t.Error("expected no notifications while do not disturb")
+	}
+	if allowStateNotification(StateNotifications{Disabled: true}, now.Add(3*stateNotifyPeriod)) {
+		t.Error("expected no notifications when disabled")
+	}
+}
@@ -0,0 +1,101 @@
+package lifecycle
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/ReEnvision-AI/systray/app/tray/commontray"
+)
+
+// The user is notified when the node starts running, and when it fails or
+// stops without being asked to. Failures while starting have their own
+// notifications. A crash loop would notify on every restart, so at most
+// max_per_hour state notifications are shown per hour, and none during the
+// do_not_disturb windows of state_notifications in config.json.
+
+const (
+	defaultStateNotifyLimit = 4
+	stateNotifyPeriod       = time.Hour
+)
+
+// StateNotifications configures the notifications of the node changing
+// state.
+type StateNotifications struct {
+	Disabled     bool             `json:"disabled"`
+	DoNotDisturb []scheduleWindow `json:"do_not_disturb"` // When none are shown, in local time
+	MaxPerHour   int              `json:"max_per_hour"`   // 0 for defaultStateNotifyLimit
+}
+
+func (n StateNotifications) limit() int {
+	if n.MaxPerHour == 0 {
+		return defaultStateNotifyLimit
+	}
+	return n.MaxPerHour
+}
+
+var (
+	stateNotifyMu   sync.Mutex
+	stateNotified   []time.Time // When the state notifications of the last period were shown
+	stateSuppressed bool        // Whether the limit suppressed a notification this period
+)
+
+// stateNotification returns the notification of the node changing from
+// previous to state, ok false if the change isn't notified.
+func stateNotification(previous, state AppState, err error) (level commontray.NotificationLevel, title, message string, ok bool) {
+	if previous != StateRunning && previous != StateLoading && !(state == StateRunning && previous == StateStarting) {
+		return 0, "", "", false
+	}
+	switch state {
+	case StateRunning:
+		return commontray.NotifyInfo, "Node is running", "Your node is contributing to ReEnvision AI", true
+	case StateError:
+		message = "Open the logs from the tray menu for details"
+		if err != nil {
+			message = err.Error() + ". " + message
+		}
+		return commontray.NotifyError, "Node stopped with an error", message, true
+	case StateStopped:
+		return commontray.NotifyWarning, "Node stopped unexpectedly", "The node exited on its own. Start it again from the tray menu", true
+	}
+	return 0, "", "", false
+}
+
+// allowStateNotification reports whether a state notification may be shown
+// at now, counting it if so.
+func allowStateNotification(cfg StateNotifications, now time.Time) bool {
+	if cfg.Disabled || (len(cfg.DoNotDisturb) > 0 && inSchedule(cfg.DoNotDisturb, now)) {
+		return false
+	}
+	stateNotifyMu.Lock()
+	defer stateNotifyMu.Unlock()
+	for len(stateNotified) > 0 && now.Sub(stateNotified[0]) >= stateNotifyPeriod {
+		stateNotified = stateNotified[1:]
+	}
+	if len(stateNotified) == 0 {
+		stateSuppressed = false
+	}
+	if len(stateNotified) >= cfg.limit() {
+		if !stateSuppressed {
+			stateSuppressed = true
+			slog.Info("Too many state changes, suppressing their notifications", "limit", cfg.limit())
+		}
+		return false
+	}
+	stateNotified = append(stateNotified, now)
+	return true
+}
+
+// notifyStateChange notifies the user of the node changing from previous to
+// state, as state_notifications allows.
+func notifyStateChange(previous, state AppState, err error) {
+	level, title, message, ok := stateNotification(previous, state, err)
+	if !ok {
+		return
+	}
+	if !allowStateNotification(currentAppConfig().StateNotifications, time.Now()) {
+		slog.Debug("state notification suppressed", "title", title)
+		return
+	}
+	notify(level, title, message)
+}
@@ -0,0 +1,56 @@
+//go:build windows && unit_test
+
+package lifecycle
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteDaysCSV(t *testing.T) {
+	var buf bytes.Buffer
+	days := []DaySummary{{Date: "2026-03-01", RunningSeconds: 5400, Starts: 2, Errors: 1}}
+	if err := writeDaysCSV(&buf, days); err != nil {
+		t.Fatal(err)
+	}
+	want := "date,running_hours,starts,errors\n2026-03-01,1.50,2,1\n"
+	if buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+}
+
+func TestJournal(t *testing.T) {
+	useTempHistory(t)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	recordJournalEvent(StateStopped, StateStarting, nil, now)
+	recordJournalEvent(StateStarting, StateError, errors.New("boom, \"quoted\""), now.Add(time.Minute))
+
+	events, err := readJournal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2", len(events))
+	}
+	if events[1].Previous != hookStateName(StateStarting) || events[1].State != hookStateName(StateError) || events[1].Error != "boom, \"quoted\"" {
+		t.Errorf("unexpected event %+v", events[1])
+	}
+
+	var buf bytes.Buffer
+	if err := writeEventsCSV(&buf, events); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"boom, ""quoted"""`) {
+		t.Errorf("error not quoted in CSV: %s", buf.String())
+	}
+
+	if err := removeJournal(); err != nil {
+		t.Fatal(err)
+	}
+	if events, err := readJournal(); err != nil || len(events) != 0 {
+		t.Errorf("journal not removed: %v %v", events, err)
+	}
+}
@@ -0,0 +1,111 @@
+package lifecycle
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ReEnvision-AI/systray/app/store"
+	"github.com/ReEnvision-AI/systray/app/tray/commontray"
+)
+
+// The contribution history and the state journal can be exported for
+// volunteers keeping their own records. JSON holds both in one file, CSV
+// writes the days to the chosen file and the events next to it.
+
+var statsExportFilters = []fileFilter{
+	{"CSV files", "*.csv"},
+	{"JSON files", "*.json"},
+}
+
+// statsExport is the JSON export.
+type statsExport struct {
+	ExportedAt time.Time      `json:"exported_at"`
+	NodeID     string         `json:"node_id"`
+	Days       []DaySummary   `json:"days"`
+	Events     []JournalEvent `json:"events"`
+}
+
+// handleExportStats asks where to save the statistics and writes them.
+func handleExportStats() {
+	name := "ReEnvisionAI-statistics-" + time.Now().Format(historyDateFormat)
+	path, filter, ok := saveFileDialog("Export statistics", name, statsExportFilters)
+	if !ok {
+		return
+	}
+	events, err := readJournal()
+	if err != nil {
+		slog.Warn("Failed to read state journal", "error", err)
+	}
+	days := historySnapshot(time.Now())
+
+	if filter == 1 || strings.EqualFold(filepath.Ext(path), ".json") {
+		err = writeFileWith(path, func(w io.Writer) error {
+			return writeStatsJSON(w, statsExport{ExportedAt: time.Now().UTC(), NodeID: store.GetID(), Days: days, Events: events})
+		})
+	} else {
+		eventsPath := strings.TrimSuffix(path, filepath.Ext(path)) + "-events.csv"
+		err = errors.Join(
+			writeFileWith(path, func(w io.Writer) error { return writeDaysCSV(w, days) }),
+			writeFileWith(eventsPath, func(w io.Writer) error { return writeEventsCSV(w, events) }),
+		)
+	}
+	if err != nil {
+		slog.Error("Failed to export statistics", "path", path, "error", err)
+		notify(commontray.NotifyError, "Unable to export statistics", "Open the logs from the tray menu for details")
+		return
+	}
+	slog.Info("Exported statistics", "path", path, "days", len(days), "events", len(events))
+	notify(commontray.NotifyInfo, "Statistics exported", path)
+}
+
+// writeFileWith creates path and writes it with write.
+func writeFileWith(path string, write func(io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func writeStatsJSON(w io.Writer, export statsExport) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(export)
+}
+
+func writeDaysCSV(w io.Writer, days []DaySummary) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"date", "running_hours", "starts", "errors"})
+	for _, day := range days {
+		cw.Write([]string{
+			day.Date,
+			strconv.FormatFloat(float64(day.RunningSeconds)/3600, 'f', 2, 64),
+			strconv.Itoa(day.Starts),
+			strconv.Itoa(day.Errors),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeEventsCSV(w io.Writer, events []JournalEvent) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"time", "previous", "state", "operation", "error"})
+	for _, event := range events {
+		cw.Write([]string{event.Time.Format(time.RFC3339), event.Previous, event.State, event.Operation, event.Error})
+	}
+	cw.Flush()
+	return cw.Error()
+}
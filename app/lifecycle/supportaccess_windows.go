@@ -0,0 +1,258 @@
+package lifecycle
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ReEnvision-AI/systray/app/auth"
+	"github.com/ReEnvision-AI/systray/app/format"
+	"github.com/ReEnvision-AI/systray/app/store"
+	"github.com/ReEnvision-AI/systray/app/tray/commontray"
+	"github.com/ReEnvision-AI/systray/version"
+	"golang.org/x/sys/windows"
+)
+
+// Support access streams diagnostics of this node to the backend for a
+// limited time, so support staff can look at a wedged node with the user's
+// consent. Nothing is sent unless the user starts a session from the tray,
+// and the session can be revoked from the same menu entry at any time.
+
+// Backend endpoints for support sessions, relative to the Supabase URL
+var (
+	SupportSessionPath     = "/functions/v1/support-session"
+	SupportDiagnosticsPath = "/functions/v1/support-diagnostics"
+	SupportAccessDuration  = 30 * time.Minute
+	supportUploadInterval  = 30 * time.Second
+	supportRequestTimeout  = 30 * time.Second
+	supportLogTailBytes    = int64(64 * 1024)
+)
+
+var (
+	supportMu     sync.Mutex
+	supportCancel context.CancelFunc // Set while a support session is active
+)
+
+type supportSession struct {
+	ID   string `json:"session_id"`
+	Code string `json:"code"` // Short code the user reads out to support staff
+}
+
+type supportDiagnostics struct {
+	SessionID string `json:"session_id"`
+	NodeID    string `json:"node_id"`
+	Version   string `json:"version"`
+	State     string `json:"state"`
+	LogTail   string `json:"log_tail"`
+}
+
+// handleSupportAccess starts a support session after asking for consent, or
+// offers to revoke the active one.
+func handleSupportAccess() {
+	supportMu.Lock()
+	active := supportCancel
+	supportMu.Unlock()
+	if active != nil {
+		if confirm("Revoke support access",
+			"Support staff will stop receiving diagnostics from this node. Revoke support access now?") {
+			slog.Info("Support access revoked by user")
+			active()
+		}
+		return
+	}
+
+	if !confirm("Allow support access",
+		fmt.Sprintf("For the next %s, ReEnvision AI support staff will receive the app's recent logs, "+
+			"the node status and version every %s to help diagnose a problem with this node.\n\n"+
+			"No other files are shared, and you can revoke access at any time from the tray menu.\n\n"+
+			"Allow support access?", format.Duration(SupportAccessDuration), format.Duration(supportUploadInterval))) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), SupportAccessDuration)
+	supportMu.Lock()
+	if supportCancel != nil {
+		// Started from another click while the consent dialog was open
+		supportMu.Unlock()
+		cancel()
+		return
+	}
+	supportCancel = cancel
+	supportMu.Unlock()
+	defer func() {
+		supportMu.Lock()
+		supportCancel = nil
+		supportMu.Unlock()
+		cancel()
+		if err := t.SetSupportAccess(0); err != nil {
+			slog.Warn("failed to update tray for support access", "error", err)
+		}
+	}()
+
+	client, s, err := startSupportSession(ctx)
+	if err != nil {
+		if errors.Is(err, auth.ErrNoSession) {
+			return
+		}
+		slog.Error("Failed to start support session", "error", err)
+		notify(commontray.NotifyError, "Unable to allow support access", "Open the logs from the tray menu for details")
+		return
+	}
+	slog.Info("Support access started", "session_id", s.ID, "duration", SupportAccessDuration)
+	go showSupportCode(s.Code)
+
+	streamDiagnostics(ctx, client, s.ID)
+
+	endCtx, endCancel := context.WithTimeout(context.Background(), supportRequestTimeout)
+	defer endCancel()
+	if err := endSupportSession(endCtx, client, s.ID); err != nil {
+		slog.Warn("Failed to end support session", "session_id", s.ID, "error", err)
+	}
+	slog.Info("Support access ended", "session_id", s.ID)
+	notify(commontray.NotifyInfo, "Support access ended", "Support staff no longer receive diagnostics from this node")
+}
+
+func showSupportCode(code string) {
+	message := fmt.Sprintf("Support access is on. Give this code to ReEnvision AI support:\n\n%s\n\nCopy the code to the clipboard?", code)
+	if messageBox("Support access", message, windows.MB_YESNO|windows.MB_ICONINFORMATION) != IDYES {
+		return
+	}
+	if err := copyToClipboard(code); err != nil {
+		slog.Warn("failed to copy support code", "error", err)
+		notify(commontray.NotifyError, "Unable to copy the support code", err.Error())
+	}
+}
+
+func startSupportSession(ctx context.Context) (*auth.Client, *supportSession, error) {
+	cfg, err := readConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+	client, err := newAuthClient(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	session, err := getSession(ctx, client)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	body, err := json.Marshal(map[string]any{
+		"node_id":          store.GetID(),
+		"duration_seconds": int(SupportAccessDuration / time.Second),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	reqCtx, cancel := context.WithTimeout(ctx, supportRequestTimeout)
+	defer cancel()
+	req, err := client.NewRequest(reqCtx, http.MethodPost, SupportSessionPath, bytes.NewReader(body), session)
+	if err != nil {
+		return nil, nil, err
+	}
+	var s supportSession
+	if err := client.Do(req, &s); err != nil {
+		return nil, nil, err
+	}
+	if s.ID == "" {
+		return nil, nil, errors.New("backend returned no support session")
+	}
+	return client, &s, nil
+}
+
+// streamDiagnostics uploads diagnostics and updates the countdown in the
+// tray until the session expires or is revoked.
+func streamDiagnostics(ctx context.Context, client *auth.Client, sessionID string) {
+	deadline, _ := ctx.Deadline()
+	ticker := time.NewTicker(supportUploadInterval)
+	defer ticker.Stop()
+	for {
+		if err := t.SetSupportAccess(time.Until(deadline)); err != nil {
+			slog.Warn("failed to update tray for support access", "error", err)
+		}
+		if err := uploadDiagnostics(ctx, client, sessionID); err != nil && ctx.Err() == nil {
+			slog.Warn("Failed to upload support diagnostics", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func uploadDiagnostics(ctx context.Context, client *auth.Client, sessionID string) error {
+	session, err := getSession(ctx, client)
+	if err != nil {
+		return err
+	}
+	stateMu.Lock()
+	state := currentState.String()
+	stateMu.Unlock()
+	logTail, err := readLogTail(AppLogFile, supportLogTailBytes)
+	if err != nil {
+		slog.Debug("unable to read log for support diagnostics", "error", err)
+	}
+
+	body, err := json.Marshal(supportDiagnostics{
+		SessionID: sessionID,
+		NodeID:    store.GetID(),
+		Version:   version.String(),
+		State:     state,
+		LogTail:   logTail,
+	})
+	if err != nil {
+		return err
+	}
+	reqCtx, cancel := context.WithTimeout(ctx, supportRequestTimeout)
+	defer cancel()
+	req, err := client.NewRequest(reqCtx, http.MethodPost, SupportDiagnosticsPath, bytes.NewReader(body), session)
+	if err != nil {
+		return err
+	}
+	return client.Do(req, nil)
+}
+
+func endSupportSession(ctx context.Context, client *auth.Client, sessionID string) error {
+	session, err := getSession(ctx, client)
+	if err != nil {
+		return err
+	}
+	req, err := client.NewRequest(ctx, http.MethodDelete, SupportSessionPath+"?"+url.Values{"session_id": {sessionID}}.Encode(), nil, session)
+	if err != nil {
+		return err
+	}
+	return client.Do(req, nil)
+}
+
+// readLogTail returns up to the last n bytes of the file at path.
+func readLogTail(path string, n int64) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	if offset := info.Size() - n; offset > 0 {
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			return "", err
+		}
+	}
+	data, err := io.ReadAll(io.LimitReader(f, n))
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,55 @@
+//go:build windows && unit_test
+
+package lifecycle
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestRedactJSON(t *testing.T) {
+	data := []byte(`{
+		"supabaseUrl": "https://example.supabase.co",
+		"supabaseAnonKey": "anon-key",
+		"api-token": "local-token",
+		"heartbeat": {"url": "https://beat.example.com", "headers": {"Authorization": "Bearer secret"}},
+		"egress_allowlist": ["example.com"],
+		"use_gpu": true,
+		"hf_token": ""
+	}`)
+	redacted, err := redactJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"anon-key", "local-token", "Bearer secret"} {
+		if strings.Contains(string(redacted), secret) {
+			t.Errorf("expected %q to be redacted, got %s", secret, redacted)
+		}
+	}
+
+	var got map[string]any
+	if err := json.Unmarshal(redacted, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["supabaseUrl"] != "https://example.supabase.co" || got["use_gpu"] != true || got["egress_allowlist"].([]any)[0] != "example.com" {
+		t.Errorf("expected other values to be kept, got %v", got)
+	}
+	if got["supabaseAnonKey"] != redactedValue || got["hf_token"] != "" {
+		t.Errorf("expected set secrets to show as redacted and empty ones as empty, got %v", got)
+	}
+	headers := got["heartbeat"].(map[string]any)["headers"].(map[string]any)
+	if headers["Authorization"] != redactedValue {
+		t.Errorf("expected the header name to be kept with its value redacted, got %v", headers)
+	}
+
+	if _, err := redactJSON([]byte("{not json")); err == nil {
+		t.Error("expected an error for invalid JSON")
+	}
+}
+
+func TestRotatedLogFile(t *testing.T) {
+	if got := rotatedLogFile(`C:\Users\me\AppData\Local\ReEnvision AI\app.log`, 1); got != `C:\Users\me\AppData\Local\ReEnvision AI\app-1.log` {
+		t.Errorf("unexpected rotated log path %q", got)
+	}
+}
@@ -0,0 +1,213 @@
+package lifecycle
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ReEnvision-AI/systray/app/store"
+	"github.com/ReEnvision-AI/systray/app/tray/commontray"
+	"github.com/ReEnvision-AI/systray/version"
+	"golang.org/x/sys/windows"
+)
+
+// A support bundle is a zip file on the Desktop with everything support
+// usually asks for: the app logs, the latest node output, config.json and
+// the store with their secrets redacted, and what Podman and the GPU driver
+// report. Parts that can't be collected are listed in bundle.txt instead of
+// failing the bundle.
+
+const supportBundleCommandTimeout = 30 * time.Second
+
+// sensitiveKeys are parts of JSON keys whose values are left out of support
+// bundles.
+var sensitiveKeys = []string{"key", "token", "secret", "password", "auth", "header", "cookie"}
+
+var supportBundleMu sync.Mutex
+
+// handleSupportBundle writes a support bundle to the Desktop.
+func handleSupportBundle() {
+	if !supportBundleMu.TryLock() {
+		return // Already being written
+	}
+	defer supportBundleMu.Unlock()
+
+	dir, err := windows.KnownFolderPath(windows.FOLDERID_Desktop, 0)
+	if err != nil {
+		slog.Error("Unable to locate the Desktop folder", "error", err)
+		notify(commontray.NotifyError, "Unable to generate a support bundle", "Open the logs from the tray menu for details")
+		return
+	}
+	path := filepath.Join(dir, "ReEnvisionAI-support-"+time.Now().Format("20060102-150405")+".zip")
+	notify(commontray.NotifyInfo, "Generating support bundle", "This takes up to a minute")
+
+	ctx, cancel := context.WithTimeout(context.Background(), DataRequestTimeout)
+	defer cancel()
+	if err := writeFileWith(path, func(w io.Writer) error { return writeSupportBundle(ctx, w) }); err != nil {
+		os.Remove(path)
+		slog.Error("Failed to generate support bundle", "path", path, "error", err)
+		notify(commontray.NotifyError, "Unable to generate a support bundle", "Open the logs from the tray menu for details")
+		return
+	}
+	slog.Info("Support bundle generated", "path", path)
+	notify(commontray.NotifyInfo, "Support bundle saved to your Desktop", "Attach "+filepath.Base(path)+" to your support request")
+}
+
+// supportBundlePart is a file of the support bundle, collect returning its
+// contents.
+type supportBundlePart struct {
+	name    string
+	collect func(ctx context.Context) ([]byte, error)
+}
+
+func supportBundleParts() []supportBundlePart {
+	return []supportBundlePart{
+		{"app.log", func(context.Context) ([]byte, error) { return appLogForBundle(AppLogFile) }},
+		{"app-1.log", func(context.Context) ([]byte, error) { return os.ReadFile(rotatedLogFile(AppLogFile, 1)) }},
+		{"node-output.log", func(context.Context) ([]byte, error) {
+			lines, _ := containerLog.since(0)
+			text, _ := renderLogLines(lines, logFilter{})
+			return []byte(text), nil
+		}},
+		{"processes.txt", func(context.Context) ([]byte, error) {
+			return []byte(processesText(helperProcs.List(), time.Now())), nil
+		}},
+		{"antivirus.txt", func(context.Context) ([]byte, error) { return []byte(antivirusText(avInterferences())), nil }},
+		{"config.json", func(context.Context) ([]byte, error) {
+			configFile, err := configFilePath()
+			if err != nil {
+				return nil, err
+			}
+			return readRedactedJSON(configFile)
+		}},
+		{"store.json", func(context.Context) ([]byte, error) { return readRedactedJSON(store.Path()) }},
+		{"podman-version.txt", func(ctx context.Context) ([]byte, error) { return bundleCommandOutput(ctx, "podman", "version") }},
+		{"podman-info.txt", func(ctx context.Context) ([]byte, error) { return bundleCommandOutput(ctx, "podman", "info") }},
+		{"gpu.txt", func(ctx context.Context) ([]byte, error) { return bundleCommandOutput(ctx, "nvidia-smi") }},
+	}
+}
+
+// writeSupportBundle zips the parts of a support bundle to w.
+func writeSupportBundle(ctx context.Context, w io.Writer) error {
+	zw := zip.NewWriter(w)
+	var summary strings.Builder
+	fmt.Fprintf(&summary, "ReEnvision AI support bundle, %s\r\n", time.Now().Format(time.RFC3339))
+	fmt.Fprintf(&summary, "Version: %s\r\n", version.String())
+	fmt.Fprintf(&summary, "Node ID: %s\r\n", store.GetID())
+	stateMu.Lock()
+	fmt.Fprintf(&summary, "State: %s\r\n", currentState)
+	stateMu.Unlock()
+
+	for _, part := range supportBundleParts() {
+		data, err := part.collect(ctx)
+		if err != nil {
+			slog.Debug("support bundle part missing", "part", part.name, "error", err)
+			fmt.Fprintf(&summary, "Missing %s: %v\r\n", part.name, err)
+			if len(data) == 0 {
+				continue
+			}
+		}
+		if err := writeZipFile(zw, part.name, data); err != nil {
+			return err
+		}
+	}
+	if err := writeZipFile(zw, "bundle.txt", []byte(summary.String())); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func writeZipFile(zw *zip.Writer, name string, data []byte) error {
+	f, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	return err
+}
+
+// appLogForBundle reads the app log, or the lines kept in memory if it can't
+// be read.
+func appLogForBundle(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		return data, nil
+	}
+	return []byte(strings.Join(GetRecentLogs(), "\r\n")), fmt.Errorf("%w, included the latest lines kept in memory", err)
+}
+
+// rotatedLogFile returns the path of the nth older log, as rotateLogs names
+// it.
+func rotatedLogFile(logFile string, n int) string {
+	ext := filepath.Ext(logFile)
+	return fmt.Sprintf("%s-%d%s", strings.TrimSuffix(logFile, ext), n, ext)
+}
+
+// bundleCommandOutput runs a command for the support bundle, returning its
+// output even if it fails, as the output tells what went wrong.
+func bundleCommandOutput(ctx context.Context, name string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, supportBundleCommandTimeout)
+	defer cancel()
+	if name == "podman" {
+		return helperCombinedOutput(podmanCommand(ctx, args...))
+	}
+	return helperCombinedOutput(helperCommand(ctx, name, args...))
+}
+
+// readRedactedJSON reads a JSON file with the values of sensitive keys
+// redacted.
+func readRedactedJSON(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return redactJSON(data)
+}
+
+// redactJSON replaces the values of keys containing any of sensitiveKeys,
+// keeping the structure so support can tell what is set.
+func redactJSON(data []byte) ([]byte, error) {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(redactValue(v, false), "", "  ")
+}
+
+func redactValue(v any, sensitive bool) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			v[key] = redactValue(value, sensitive || isSensitiveKey(key))
+		}
+		return v
+	case []any:
+		for i, value := range v {
+			v[i] = redactValue(value, sensitive)
+		}
+		return v
+	case string:
+		if sensitive && v != "" {
+			return redactedValue
+		}
+	}
+	return v
+}
+
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return true
+		}
+	}
+	return false
+}
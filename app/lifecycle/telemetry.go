@@ -0,0 +1,45 @@
+package lifecycle
+
+import (
+	"fmt"
+	"log/slog"
+	"runtime"
+
+	"github.com/ReEnvision-AI/systray/app/store"
+	"github.com/ReEnvision-AI/systray/version"
+)
+
+// Telemetry is controlled by a single preference in the store. Every network
+// call that sends identifying or usage data must check telemetryEnabled first.
+//
+// With telemetry disabled the app runs in minimal mode:
+//   - update checks only send the OS, architecture and release channel, not the
+//     app version, commit or a timestamp, and the update is compared against
+//     the local version
+//   - the User-Agent header doesn't include version information
+//   - heartbeat extras, like the machine fingerprint, analytics and crash
+//     reports are not sent
+//
+// Minimal mode can be selected from the tray menu or by setting
+// "telemetry-enabled": false in the store file.
+
+func telemetryEnabled() bool {
+	return store.GetTelemetryEnabled()
+}
+
+// userAgent returns the User-Agent for requests to ReEnvision services.
+func userAgent() string {
+	if !telemetryEnabled() {
+		return "reai"
+	}
+	return fmt.Sprintf("reai/%s (%s %s; %s) Go/%s", version.Version, runtime.GOARCH, runtime.GOOS, version.Channel, runtime.Version())
+}
+
+func handleToggleTelemetry() {
+	enabled := !store.GetTelemetryEnabled()
+	store.SetTelemetryEnabled(enabled)
+	slog.Info("Telemetry preference changed", "enabled", enabled)
+	if err := t.SetTelemetryEnabled(enabled); err != nil {
+		slog.Warn("failed to update tray for telemetry preference", "error", err)
+	}
+}
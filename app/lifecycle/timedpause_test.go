@@ -0,0 +1,48 @@
+//go:build windows && unit_test
+
+package lifecycle
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ReEnvision-AI/systray/app/tray/commontray"
+)
+
+func TestPauseEnd(t *testing.T) {
+	now := time.Date(2024, 5, 31, 21, 45, 0, 0, time.Local)
+	tests := []struct {
+		choice string
+		want   time.Time
+	}{
+		{commontray.PauseFor30Minutes, time.Date(2024, 5, 31, 22, 15, 0, 0, time.Local)},
+		{commontray.PauseForHour, time.Date(2024, 5, 31, 22, 45, 0, 0, time.Local)},
+		{commontray.PauseUntilTomorrow, time.Date(2024, 6, 1, pauseTomorrowHour, 0, 0, 0, time.Local)},
+	}
+	for _, test := range tests {
+		until, ok := pauseEnd(test.choice, now)
+		if !ok || !until.Equal(test.want) {
+			t.Errorf("%s: expected %v, got %v (ok %v)", test.choice, test.want, until, ok)
+		}
+	}
+	if _, ok := pauseEnd("forever", now); ok {
+		t.Error("expected an unknown choice to be rejected")
+	}
+}
+
+func TestPausedUntilText(t *testing.T) {
+	now := time.Date(2024, 5, 31, 21, 45, 0, 0, time.Local)
+	tests := []struct {
+		until time.Time
+		want  string
+	}{
+		{time.Date(2024, 5, 31, 22, 15, 0, 0, time.Local), "Paused until 22:15"},
+		{time.Date(2024, 6, 1, 8, 0, 0, 0, time.Local), "Paused until tomorrow 08:00"},
+		{time.Date(2024, 6, 3, 8, 0, 0, 0, time.Local), "Paused until Mon 08:00"},
+	}
+	for _, test := range tests {
+		if got := pausedUntilText(test.until, now); got != test.want {
+			t.Errorf("expected %q, got %q", test.want, got)
+		}
+	}
+}
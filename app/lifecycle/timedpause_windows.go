@@ -0,0 +1,139 @@
+package lifecycle
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/ReEnvision-AI/systray/app/store"
+	"github.com/ReEnvision-AI/systray/app/tray/commontray"
+)
+
+// The node can also be paused for a time from the Pause for submenu, after
+// which it resumes by itself. The end of the pause is kept in the store, so
+// an app started during the pause, like after a reboot, leaves the node
+// stopped until then. Starting, stopping or resuming the node from the tray
+// ends the timed pause.
+
+const pauseTomorrowHour = 8 // Local hour a pause until tomorrow ends
+
+var (
+	timedPauseMu    sync.Mutex
+	timedPauseTimer *time.Timer // Ends the timed pause, nil if none
+)
+
+// pauseEnd returns when a timed pause chosen at now ends, ok false for an
+// unknown choice.
+func pauseEnd(choice string, now time.Time) (until time.Time, ok bool) {
+	switch choice {
+	case commontray.PauseFor30Minutes:
+		return now.Add(30 * time.Minute), true
+	case commontray.PauseForHour:
+		return now.Add(time.Hour), true
+	case commontray.PauseUntilTomorrow:
+		year, month, day := now.Date()
+		return time.Date(year, month, day+1, pauseTomorrowHour, 0, 0, 0, now.Location()), true
+	}
+	return time.Time{}, false
+}
+
+// pausedUntilText returns the status of a node paused until until, like
+// "Paused until 14:30".
+func pausedUntilText(until, now time.Time) string {
+	day := until.Format(time.DateOnly)
+	switch {
+	case day == now.Format(time.DateOnly):
+		return "Paused until " + until.Format("15:04")
+	case day == now.AddDate(0, 0, 1).Format(time.DateOnly):
+		return "Paused until tomorrow " + until.Format("15:04")
+	}
+	return "Paused until " + until.Format("Mon 15:04")
+}
+
+// handlePauseFor pauses the node until the end of the timed pause choice.
+func handlePauseFor(choice string) {
+	now := time.Now()
+	until, ok := pauseEnd(choice, now)
+	if !ok {
+		slog.Warn("Unknown timed pause", "choice", choice)
+		return
+	}
+	stateMu.Lock()
+	state := currentState
+	stateMu.Unlock()
+	switch state {
+	case StateRunning, StateLoading:
+		handlePauseRequest()
+	case StatePaused:
+	default:
+		slog.Info("Container isn't running, ignoring timed pause", "state", state)
+		return
+	}
+
+	stateMu.Lock()
+	paused := currentState == StatePaused
+	stateMu.Unlock()
+	if !paused {
+		return // Failed to pause, already notified
+	}
+	slog.Info("Node paused for a time", "until", until)
+	store.SetPausedUntil(until)
+	scheduleTimedPauseEnd(until)
+	setStatusText(StatePaused, pausedUntilText(until, now))
+}
+
+// resumeTimedPause continues a timed pause the store records, when the app
+// starts. Reports whether the node is paused, and shouldn't be started.
+func resumeTimedPause() bool {
+	until := store.GetPausedUntil()
+	if until.IsZero() {
+		return false
+	}
+	now := time.Now()
+	if !until.After(now) {
+		store.SetPausedUntil(time.Time{})
+		return false
+	}
+	slog.Info("Node is paused for a time, not starting it", "until", until)
+	scheduleTimedPauseEnd(until)
+	setStatusText(StateStopped, pausedUntilText(until, now))
+	return true
+}
+
+func scheduleTimedPauseEnd(until time.Time) {
+	timedPauseMu.Lock()
+	defer timedPauseMu.Unlock()
+	if timedPauseTimer != nil {
+		timedPauseTimer.Stop()
+	}
+	timedPauseTimer = time.AfterFunc(time.Until(until), endTimedPause)
+}
+
+// cancelTimedPause forgets the timed pause, as the user started, stopped or
+// resumed the node.
+func cancelTimedPause() {
+	timedPauseMu.Lock()
+	if timedPauseTimer != nil {
+		timedPauseTimer.Stop()
+		timedPauseTimer = nil
+	}
+	timedPauseMu.Unlock()
+	store.SetPausedUntil(time.Time{})
+}
+
+// endTimedPause resumes the node once its timed pause is over, starting it
+// if the app was restarted meanwhile.
+func endTimedPause() {
+	cancelTimedPause()
+	stateMu.Lock()
+	state := currentState
+	stateMu.Unlock()
+	switch state {
+	case StatePaused:
+		slog.Info("Timed pause is over, resuming the node")
+		handleResumeRequest()
+	case StateStopped:
+		slog.Info("Timed pause is over, starting the node")
+		handleStartRequest()
+	}
+}
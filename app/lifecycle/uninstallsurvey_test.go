@@ -0,0 +1,33 @@
+//go:build windows && unit_test
+
+package lifecycle
+
+import "testing"
+
+func TestUninstallReasonCode(t *testing.T) {
+	seen := map[string]bool{}
+	for i, reason := range uninstallReasons {
+		code := uninstallReasonCode(i)
+		if seen[code] {
+			t.Errorf("reason %q: code %q is used twice", reason.title, code)
+		}
+		seen[code] = true
+	}
+	if code := uninstallReasonCode(0); code != "" {
+		t.Errorf("expected no code for the first choice, got %q", code)
+	}
+	// CB_ERR, nothing selected
+	if code := uninstallReasonCode(-1); code != "" {
+		t.Errorf("expected no code without a choice, got %q", code)
+	}
+	if code := uninstallReasonCode(len(uninstallReasons)); code != "" {
+		t.Errorf("expected no code for an unknown choice, got %q", code)
+	}
+}
+
+func TestUninstallSurveyTemplate(t *testing.T) {
+	template := uninstallSurveyTemplate()
+	if items := int(template[4]); items != 5 {
+		t.Errorf("expected 5 controls, got %d", items)
+	}
+}
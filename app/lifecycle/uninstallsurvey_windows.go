@@ -0,0 +1,177 @@
+package lifecycle
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ReEnvision-AI/systray/app/heartbeat"
+	"github.com/ReEnvision-AI/systray/app/store"
+	"golang.org/x/sys/windows"
+)
+
+// Before removing the app, the uninstaller starts it with UninstallFlag. The
+// app then asks in a short survey why the user uninstalls it, and sends the
+// answer with a final heartbeat. Only the code of one of a few fixed reasons
+// is sent, never text the user typed. Nothing is asked or sent with telemetry
+// disabled, in anonymous mode or when uninstalling silently, and skipping the
+// survey sends nothing either.
+
+// UninstallFlag is passed by the uninstaller, the app shows the uninstall
+// survey and exits.
+const UninstallFlag = "--uninstall"
+
+const uninstalledState = "uninstalled" // State of the final heartbeat
+
+// Control IDs of the uninstall survey
+const (
+	uninstallMessageID = 400
+	uninstallReasonID  = 401
+)
+
+// uninstallReasons are the choices of the survey, in the order of the combo
+// box, with the codes sent to the backend.
+var uninstallReasons = []struct {
+	title string
+	code  string
+}{
+	{"Prefer not to say", ""},
+	{"It slowed down my computer", "performance"},
+	{"It made my computer loud, hot or use too much power", "power"},
+	{"I couldn't get the node running", "setup"},
+	{"The node kept failing", "errors"},
+	{"I don't see the benefit", "value"},
+	{"Privacy or security concerns", "privacy"},
+	{"I'm reinstalling or will be back later", "reinstall"},
+	{"Something else", "other"},
+}
+
+var (
+	activeUninstallSurvey   *uninstallSurvey // The open survey
+	uninstallSurveyCallback = sync.OnceValue(func() uintptr { return windows.NewCallback(uninstallSurveyProc) })
+)
+
+type uninstallSurvey struct {
+	reason string
+	sent   bool // Whether the user sent the survey rather than skipping it
+}
+
+// uninstalling reports whether the uninstaller started the app.
+func uninstalling() bool {
+	return slices.Contains(os.Args[1:], UninstallFlag)
+}
+
+// handleUninstall shows the uninstall survey and sends its answer.
+func handleUninstall() {
+	slog.Info("Started by the uninstaller")
+	if !telemetryEnabled() || store.GetAnonymousMode() {
+		return
+	}
+	cfg, err := readConfig()
+	if err != nil {
+		slog.Warn("Unable to load config for the uninstall survey", "error", err)
+		return
+	}
+	if cfg.Anonymous {
+		return
+	}
+	reason, ok := showUninstallSurvey()
+	if !ok {
+		slog.Info("Uninstall survey skipped")
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), heartbeatTimeout)
+	defer cancel()
+	if err := sendUninstallReason(ctx, cfg, reason); err != nil {
+		slog.Warn("Failed to send the uninstall survey", "error", err)
+		return
+	}
+	slog.Info("Sent the uninstall survey", "reason", reason)
+}
+
+// sendUninstallReason sends the final heartbeat with the reason the user
+// chose, "" if they preferred not to say.
+func sendUninstallReason(ctx context.Context, cfg AppConfig, reason string) error {
+	backend, err := newHeartbeatBackend(cfg)
+	if err != nil {
+		return err
+	}
+	_, err = backend.Send(ctx, heartbeat.Beat{
+		NodeID:          store.GetID(),
+		State:           uninstalledState,
+		SentAt:          time.Now().UTC(),
+		UninstallReason: reason,
+	})
+	return err
+}
+
+// uninstallReasonCode returns the code of the reason chosen at index of the
+// combo box, "" for none.
+func uninstallReasonCode(index int) string {
+	if index < 0 || index >= len(uninstallReasons) {
+		return ""
+	}
+	return uninstallReasons[index].code
+}
+
+// showUninstallSurvey asks why the user uninstalls the app, and returns the
+// code of the reason. ok is false if they skipped the survey.
+func showUninstallSurvey() (reason string, ok bool) {
+	survey := &uninstallSurvey{}
+	activeUninstallSurvey = survey
+	defer func() { activeUninstallSurvey = nil }()
+	if err := runDialog(uninstallSurveyTemplate(), uninstallSurveyCallback()); err != nil {
+		slog.Warn("failed to show uninstall survey", "error", err)
+		return "", false
+	}
+	return survey.reason, survey.sent
+}
+
+func uninstallSurveyTemplate() []uint16 {
+	return buildDialogTemplate("Uninstalling ReEnvision AI", 240, 90, []dialogItem{
+		{class: dialogStatic, style: dialogChild | SS_NOPREFIX, x: 7, y: 7, cx: 226, cy: 28, id: uninstallMessageID},
+		{class: dialogStatic, style: dialogChild, x: 7, y: 43, cx: 30, cy: 9, id: dialogLabelID, title: "&Reason:"},
+		{class: dialogComboBox, style: dialogChild | WS_TABSTOP | WS_VSCROLL | CBS_DROPDOWNLIST, x: 40, y: 41, cx: 193, cy: 120, id: uninstallReasonID},
+		{class: dialogButton, style: dialogChild | WS_TABSTOP | BS_DEFPUSHBUTTON, x: 129, y: 69, cx: 50, cy: 14, id: IDOK, title: "Send"},
+		{class: dialogButton, style: dialogChild | WS_TABSTOP, x: 183, y: 69, cx: 50, cy: 14, id: IDCANCEL, title: "Skip"},
+	})
+}
+
+func uninstallSurveyProc(hwnd, msg, wParam, lParam uintptr) uintptr {
+	survey := activeUninstallSurvey
+	if survey == nil {
+		return dialogDefault
+	}
+
+	switch msg {
+	case WM_INITDIALOG:
+		setDlgItemText(hwnd, uninstallMessageID, "Sorry to see you go. Would you tell us why you're uninstalling ReEnvision AI? "+
+			"Only the reason you choose is sent, to help us improve.")
+		titles := make([]string, len(uninstallReasons))
+		for i, reason := range uninstallReasons {
+			titles[i] = reason.title
+		}
+		fillComboBox(dlgItem(hwnd, uninstallReasonID), titles)
+		return 1 // Focus the first control
+
+	case WM_COMMAND:
+		if wParam>>16&0xFFFF != BN_CLICKED {
+			return dialogDefault
+		}
+		switch uint16(wParam) {
+		case IDOK:
+			i, _, _ := pSendMessage.Call(dlgItem(hwnd, uninstallReasonID), CB_GETCURSEL, 0, 0)
+			survey.reason, survey.sent = uninstallReasonCode(int(int32(i))), true
+			pEndDialog.Call(hwnd, IDOK) //nolint:errcheck
+		case IDCANCEL:
+			pEndDialog.Call(hwnd, IDCANCEL) //nolint:errcheck
+		default:
+			return dialogDefault
+		}
+		return dialogHandled
+	}
+	return dialogDefault
+}
@@ -18,6 +18,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/ReEnvision-AI/systray/app/features"
+	"github.com/ReEnvision-AI/systray/app/store"
 	"github.com/ReEnvision-AI/systray/version"
 )
 
@@ -25,17 +27,26 @@ var (
 	UpdateCheckURLBase  = "https://sociallyshaped.net/api/update"
 	UpdateDownloaded    = false
 	UpdateCheckInterval = 24 * time.Hour
+	FeatureFlagsURL     = "https://sociallyshaped.net/api/flags"
 )
 
 type UpdateResponse struct {
-	UpdateURL     string `json:"url"`
-	UpdateVersion string `json:"version"`
+	UpdateURL       string             `json:"url"`
+	UpdateVersion   string             `json:"version"`
+	Requirements    UpdateRequirements `json:"requirements"`      // Checked before upgrading
+	ReleaseNotes    string             `json:"release_notes"`     // Plain text or Markdown
+	ReleaseNotesURL string             `json:"release_notes_url"` // Where the notes are published, if not included
 }
 
 func IsNewReleaseAvailable(ctx context.Context) (bool, UpdateResponse) {
 	var updateResp UpdateResponse
 
-	requestURL, err := url.Parse(UpdateCheckURLBase)
+	if wait := updateCheckDelay(time.Now()); wait > 0 {
+		slog.Debug("update checks are rate limited", "retry_in", wait)
+		return false, updateResp
+	}
+
+	requestURL, err := url.Parse(currentEndpoints().UpdateCheck)
 	if err != nil {
 		return false, updateResp
 	}
@@ -43,38 +54,34 @@ func IsNewReleaseAvailable(ctx context.Context) (bool, UpdateResponse) {
 	query := requestURL.Query()
 	query.Add("os", runtime.GOOS)
 	query.Add("arch", runtime.GOARCH)
-	query.Add("version", version.Version)
-	query.Add("ts", strconv.FormatInt(time.Now().Unix(), 10))
-
-	//nonce, err := auth.NewNonce(rand.Reader, 16)
-	//if err != nil {
-	//	return false, updateResp
-	//}
-
-	//query.Add("nonce", nonce)
-	requestURL.RawQuery = query.Encode()
-
-	//data := []byte(fmt.Sprintf("%s,%s", http.MethodGet, requestURL.RequestURI()))
-	//signature, err := auth.Sign(ctx, data)
-	//if err != nil {
-	//	return false, updateResp
-	//}
+	query.Add("channel", version.Channel)
+	if telemetryEnabled() {
+		query.Add("version", version.Version)
+		query.Add("commit", version.Commit)
+		query.Add("ts", strconv.FormatInt(time.Now().Unix(), 10))
+	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL.String(), nil)
+	// The signing key identifies the install across checks, so it's only
+	// used with telemetry enabled, like the version
+	resp, signed, err := sendUpdateCheck(ctx, *requestURL, query, telemetryEnabled())
+	if err == nil && signed && (resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden) {
+		resp.Body.Close()
+		slog.Info("update server rejected the signed check, checking anonymously", "status_code", resp.StatusCode)
+		resp, signed, err = sendUpdateCheck(ctx, *requestURL, query, false)
+	}
 	if err != nil {
 		slog.Warn("failed to check for update", "error", err)
 		return false, updateResp
 	}
-	//req.Header.Set("Authorization", signature)
-	req.Header.Set("User-Agent", fmt.Sprintf("reai/%s (%s %s) Go/%s", version.Version, runtime.GOARCH, runtime.GOOS, runtime.Version()))
+	defer resp.Body.Close()
 
-	slog.Debug("checking for available update", "requestURL", requestURL)
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		slog.Warn("failed to check for update", "error", err)
+	if resp.StatusCode == http.StatusTooManyRequests {
+		delayUpdateChecks(resp.Header.Get("Retry-After"), time.Now())
 		return false, updateResp
 	}
-	defer resp.Body.Close()
+	if signed && (resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusNoContent) {
+		store.ForgetPreviousSigningKey() // The server saw the current key
+	}
 
 	if resp.StatusCode == http.StatusNoContent {
 		slog.Debug("check update response 204 (current version is up to date)")
@@ -103,6 +110,12 @@ func IsNewReleaseAvailable(ctx context.Context) (bool, UpdateResponse) {
 	// Extract the version string from the URL in the github release artifact path
 	updateResp.UpdateVersion = path.Base(path.Dir(updateResp.UpdateURL))
 
+	// Without the version in the request the server returns the latest release
+	if !telemetryEnabled() && strings.TrimPrefix(updateResp.UpdateVersion, "v") == strings.TrimPrefix(version.Version, "v") {
+		slog.Debug("latest release matches the current version")
+		return false, updateResp
+	}
+
 	slog.Info("New update available at " + updateResp.UpdateURL)
 	return true, updateResp
 }
@@ -139,6 +152,9 @@ func DownloadNewRelease(ctx context.Context, updateResp UpdateResponse) error {
 	_, err = os.Stat(stageFilename)
 	if err == nil {
 		slog.Info("update already downloaded")
+		if err := saveUpdateInfo(filepath.Dir(stageFilename), updateResp); err != nil {
+			slog.Warn("failed to save update requirements", "error", err)
+		}
 		return nil
 	}
 
@@ -161,7 +177,7 @@ func DownloadNewRelease(ctx context.Context, updateResp UpdateResponse) error {
 	_, err = os.Stat(filepath.Dir(stageFilename))
 	if errors.Is(err, os.ErrNotExist) {
 		if err := os.MkdirAll(filepath.Dir(stageFilename), 0o755); err != nil {
-			return fmt.Errorf("create ReEnvision AI dir %s: %v", filepath.Dir(stageFilename), err)
+			return fmt.Errorf("create ReEnvision AI dir %s: %w", filepath.Dir(stageFilename), err)
 		}
 	}
 
@@ -174,11 +190,15 @@ func DownloadNewRelease(ctx context.Context, updateResp UpdateResponse) error {
 	// Stream the download directly to the file
 	_, err = io.Copy(fp, resp.Body)
 	if err != nil {
-		// Clean up partially downloaded file on error
+		// Clean up partially downloaded file on error, which must be closed first
+		fp.Close()
 		os.Remove(stageFilename)
 		return fmt.Errorf("failed to write update to %s: %w", stageFilename, err)
 	}
 	slog.Info("new update downloaded " + stageFilename)
+	if err := saveUpdateInfo(filepath.Dir(stageFilename), updateResp); err != nil {
+		slog.Warn("failed to save update requirements", "error", err)
+	}
 
 	UpdateDownloaded = true
 	return nil
@@ -203,17 +223,47 @@ func cleanupOldDownloads() {
 	}
 }
 
+// featureFlagsURL returns the flags endpoint. The version is only sent when
+// telemetry is enabled, the same as for update checks.
+func featureFlagsURL(base string) string {
+	requestURL, err := url.Parse(base)
+	if err != nil {
+		return base
+	}
+	query := requestURL.Query()
+	query.Add("os", runtime.GOOS)
+	query.Add("arch", runtime.GOARCH)
+	if telemetryEnabled() {
+		query.Add("version", version.Version)
+	}
+	requestURL.RawQuery = query.Encode()
+	return requestURL.String()
+}
+
 func StartBackgroundUpdaterChecker(ctx context.Context, cb func(string) error) {
 	go func() {
 		// Don't blast an update message immediately after startup
 		time.Sleep(30 * time.Second)
 
 		for {
+			if err := features.Refresh(ctx, featureFlagsURL(currentEndpoints().FeatureFlags), userAgent()); err != nil {
+				slog.Warn("failed to refresh feature flags", "error", err)
+			}
 			available, resp := IsNewReleaseAvailable(ctx)
-			if available {
-				err := DownloadNewRelease(ctx, resp)
+			if available && meteredLimited() {
+				slog.Info("Not downloading the update on a metered connection", "version", resp.UpdateVersion)
+			} else if available {
+				err := downloadUpdateWhenAllowed(ctx, resp)
+				if ctx.Err() != nil {
+					slog.Debug("stopping background update checker")
+					return
+				}
 				if err != nil {
 					slog.Error("failed to download new release", "error", err)
+					if isDiskFull(err) {
+						handleDiskFull(err)
+					}
+					checkAntivirus(err)
 				}
 				err = cb(resp.UpdateVersion)
 				if err != nil {
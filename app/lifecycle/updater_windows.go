@@ -6,22 +6,17 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
-	"os/exec"
 	"path/filepath"
 )
 
 func DoUpgrade(cancel context.CancelFunc, done chan int) error {
-	files, err := filepath.Glob(filepath.Join(UpdateStageDir, "*", "*.exe"))
+	installerExe, err := stagedInstaller()
 	if err != nil {
-		return fmt.Errorf("failed to lookup downloads: %s", err)
+		return err
 	}
-	if len(files) == 0 {
-		return errors.New("no update downloads found")
-	} else if len(files) > 1 {
-		// Shouldn't happen
-		slog.Warn("multiple downloads found, using first one", "files", files)
+	if err := checkUpgradeRequirements(installerExe); err != nil {
+		return err
 	}
-	installerExe := files[0]
 	slog.Info("starting upgrade with " + installerExe)
 	slog.Info("upgrade log file " + UpgradeLogFile)
 
@@ -45,11 +40,16 @@ func DoUpgrade(cancel context.CancelFunc, done chan int) error {
 
 	slog.Debug("starting installer", "installer", installerExe, "args", installArgs)
 	os.Chdir(filepath.Dir(UpgradeLogFile)) //nolint:errcheck
-	cmd := exec.Command(installerExe, installArgs...)
+	cmd := persistentHelperCommand(context.Background(), installerExe, installArgs...)
+	cmd.SysProcAttr.HideWindow = false
 
+	// Not startHelper, the installer must outlive the app and its helper job
 	if err := cmd.Start(); err != nil {
 		return fmt.Errorf("unable to start ReEnvision AI app %w", err)
 	}
+	if err := setIOPriority(cmd.Process.Pid, helperIOPriority()); err != nil {
+		slog.Debug("failed to set installer IO priority", "error", err)
+	}
 
 	if cmd.Process != nil {
 		err = cmd.Process.Release()
@@ -66,3 +66,18 @@ func DoUpgrade(cancel context.CancelFunc, done chan int) error {
 	// Not reached
 	return nil
 }
+
+// stagedInstaller returns the path of the downloaded installer.
+func stagedInstaller() (string, error) {
+	files, err := filepath.Glob(filepath.Join(UpdateStageDir, "*", "*.exe"))
+	if err != nil {
+		return "", fmt.Errorf("failed to lookup downloads: %s", err)
+	}
+	if len(files) == 0 {
+		return "", errors.New("no update downloads found")
+	} else if len(files) > 1 {
+		// Shouldn't happen
+		slog.Warn("multiple downloads found, using first one", "files", files)
+	}
+	return files[0], nil
+}
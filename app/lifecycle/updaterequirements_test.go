@@ -0,0 +1,51 @@
+//go:build windows && unit_test
+
+package lifecycle
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestUnmetRequirements(t *testing.T) {
+	required := UpdateRequirements{Podman: "5.0", WSLKernel: "5.15.150", NvidiaDriver: "550.54"}
+	installed := installedVersions{Podman: "4.9.3", WSLKernel: "5.15.153.1-microsoft-standard-WSL2", NvidiaDriver: "537.13"}
+
+	got := unmetRequirements(required, installed, true)
+	want := []string{"Podman 5.0 or later (found 4.9.3)", "the NVIDIA driver 550.54 or later (found 537.13)"}
+	if !slices.Equal(got, want) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	if got := unmetRequirements(required, installed, false); len(got) != 1 {
+		t.Errorf("expected the driver to be ignored without the GPU, got %q", got)
+	}
+	if got := unmetRequirements(required, installedVersions{}, true); len(got) != 0 {
+		t.Errorf("expected unknown versions to meet the requirements, got %q", got)
+	}
+	if got := unmetRequirements(UpdateRequirements{}, installed, true); len(got) != 0 {
+		t.Errorf("expected no requirements to be met, got %q", got)
+	}
+}
+
+func TestUpdateInfoRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	saved := UpdateResponse{
+		UpdateURL:     "https://example.com/download/v1.4.0/ReEnvisionAISetup.exe",
+		UpdateVersion: "v1.4.0",
+		Requirements:  UpdateRequirements{Podman: "5.0"},
+	}
+	if err := saveUpdateInfo(dir, saved); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := loadUpdateInfo(filepath.Join(dir, "ReEnvisionAISetup.exe"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded != saved {
+		t.Errorf("expected %+v, got %+v", saved, loaded)
+	}
+	if err := checkUpgradeRequirements(filepath.Join(t.TempDir(), "ReEnvisionAISetup.exe")); err != nil {
+		t.Errorf("expected installers without saved requirements to upgrade, got %v", err)
+	}
+}
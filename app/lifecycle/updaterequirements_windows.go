@@ -0,0 +1,137 @@
+package lifecycle
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ReEnvision-AI/systray/app/tray/commontray"
+)
+
+// An update can need newer software than the machine has, like a Podman
+// version with a feature the new app uses. The update check delivers these
+// requirements, which are saved next to the downloaded installer and checked
+// before upgrading. If any isn't met, the upgrade is postponed with a
+// notification telling what to update, rather than leaving the node broken.
+// Requirements whose installed version can't be told don't block upgrades.
+
+// UpdateRequirements are the minimum versions an update needs, empty for
+// none.
+type UpdateRequirements struct {
+	Podman       string `json:"min_podman"`
+	WSLKernel    string `json:"min_wsl_kernel"`
+	NvidiaDriver string `json:"min_nvidia_driver"` // Only checked for nodes using the GPU
+}
+
+// installedVersions are the versions found on the machine, empty if unknown.
+type installedVersions struct {
+	Podman       string
+	WSLKernel    string
+	NvidiaDriver string
+}
+
+const (
+	updateInfoFile             = "update.json" // Saved next to the installer
+	upgradePreflightTimeout    = time.Minute
+	upgradePreflightCmdTimeout = 20 * time.Second
+)
+
+var errUpgradeDeferred = errors.New("upgrade postponed until its requirements are met")
+
+// saveUpdateInfo saves the update check response next to the installer
+// staged in dir, for checking its requirements before upgrading.
+func saveUpdateInfo(dir string, updateResp UpdateResponse) error {
+	data, err := json.Marshal(updateResp)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, updateInfoFile), data, 0o644)
+}
+
+// loadUpdateInfo loads the update check response saved next to installer.
+func loadUpdateInfo(installer string) (UpdateResponse, error) {
+	var updateResp UpdateResponse
+	data, err := os.ReadFile(filepath.Join(filepath.Dir(installer), updateInfoFile))
+	if err != nil {
+		return updateResp, err
+	}
+	err = json.Unmarshal(data, &updateResp)
+	return updateResp, err
+}
+
+// checkUpgradeRequirements returns errUpgradeDeferred, notifying the user,
+// if the machine doesn't meet the requirements of the staged installer.
+func checkUpgradeRequirements(installer string) error {
+	updateResp, err := loadUpdateInfo(installer)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			slog.Warn("failed to load update requirements", "error", err)
+		}
+		return nil // Downloaded by a version that didn't save them
+	}
+	if updateResp.Requirements == (UpdateRequirements{}) {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), upgradePreflightTimeout)
+	defer cancel()
+	installed := findInstalledVersions(ctx, updateResp.Requirements)
+	unmet := unmetRequirements(updateResp.Requirements, installed, currentAppConfig().UseGPU)
+	if len(unmet) == 0 {
+		slog.Info("Update requirements are met", "version", updateResp.UpdateVersion, "requirements", updateResp.Requirements, "installed", installed)
+		return nil
+	}
+	slog.Warn("Postponing upgrade, its requirements aren't met", "version", updateResp.UpdateVersion, "unmet", unmet)
+	notify(commontray.NotifyWarning, "Update postponed",
+		fmt.Sprintf("ReEnvision AI %s needs %s. Update and try again", updateResp.UpdateVersion, strings.Join(unmet, ", ")))
+	return errUpgradeDeferred
+}
+
+// findInstalledVersions finds the versions of the software required.
+func findInstalledVersions(ctx context.Context, required UpdateRequirements) installedVersions {
+	var installed installedVersions
+	version := func(name string, args ...string) string {
+		ctx, cancel := context.WithTimeout(ctx, upgradePreflightCmdTimeout)
+		defer cancel()
+		output, err := helperOutput(helperCommand(ctx, name, args...))
+		if err != nil {
+			slog.Debug("failed to find installed version", "command", name, "error", err)
+			return ""
+		}
+		return strings.TrimSpace(string(output))
+	}
+	if required.Podman != "" {
+		installed.Podman = version("podman", "version", "--format", "{{.Client.Version}}")
+	}
+	if required.WSLKernel != "" {
+		installed.WSLKernel = version("podman", "machine", "ssh", "uname -r")
+	}
+	if required.NvidiaDriver != "" && currentAppConfig().UseGPU {
+		installed.NvidiaDriver = nvidiaDriverVersion(ctx)
+	}
+	return installed
+}
+
+// unmetRequirements describes the requirements the installed versions don't
+// meet. Versions that aren't known are taken to meet them.
+func unmetRequirements(required UpdateRequirements, installed installedVersions, useGPU bool) []string {
+	var unmet []string
+	check := func(name, minimum, found string) {
+		if minimum == "" || found == "" || compareVersions(found, minimum) >= 0 {
+			return
+		}
+		unmet = append(unmet, fmt.Sprintf("%s %s or later (found %s)", name, minimum, found))
+	}
+	check("Podman", required.Podman, installed.Podman)
+	check("the WSL kernel", required.WSLKernel, installed.WSLKernel)
+	if useGPU {
+		check("the NVIDIA driver", required.NvidiaDriver, installed.NvidiaDriver)
+	}
+	return unmet
+}
@@ -0,0 +1,145 @@
+package lifecycle
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ReEnvision-AI/systray/app/auth"
+	"github.com/ReEnvision-AI/systray/app/store"
+)
+
+// Update checks are signed with the install's key from the store, so the
+// update server can authenticate clients and rate limit abusive ones. The
+// key is replaced every signingKeyLifetime. Until the server accepted a
+// request with the new key, requests are also signed with the previous one,
+// letting the server carry the client's history over. Servers that don't
+// know the key yet, as after a fresh install, may reject the signature, and
+// the check is then repeated anonymously. Checks are anonymous as well when
+// telemetry is disabled, as the key identifies the install. When the server
+// answers 429, no checks are made until its Retry-After passed.
+
+const (
+	signingKeyLifetime    = 90 * 24 * time.Hour
+	updateCheckBackoff    = time.Hour // Without a Retry-After
+	maxUpdateCheckBackoff = 7 * 24 * time.Hour
+	updateNonceLength     = 16
+)
+
+var (
+	updateCheckMu      sync.Mutex
+	updateCheckRetryAt time.Time // Guarded by updateCheckMu
+)
+
+// updateCheckDelay returns how long update checks are still rate limited.
+func updateCheckDelay(now time.Time) time.Duration {
+	updateCheckMu.Lock()
+	defer updateCheckMu.Unlock()
+	return updateCheckRetryAt.Sub(now)
+}
+
+// delayUpdateChecks rate limits update checks as the server asked with its
+// Retry-After header.
+func delayUpdateChecks(header string, now time.Time) {
+	delay := retryAfter(header, now)
+	slog.Info("Update server is rate limiting, delaying update checks", "retry_in", delay)
+	updateCheckMu.Lock()
+	defer updateCheckMu.Unlock()
+	updateCheckRetryAt = now.Add(delay)
+}
+
+// retryAfter parses a Retry-After header, seconds or an HTTP date, bounded
+// so a bad value can't stop update checks.
+func retryAfter(header string, now time.Time) time.Duration {
+	delay := updateCheckBackoff
+	if seconds, err := strconv.Atoi(header); err == nil {
+		delay = time.Duration(seconds) * time.Second
+	} else if at, err := http.ParseTime(header); err == nil {
+		delay = at.Sub(now)
+	}
+	return min(max(delay, time.Minute), maxUpdateCheckBackoff)
+}
+
+// updateSigningKeys returns the key signing update checks, creating or
+// replacing it as needed, and the previous key if the server may not know
+// the current one yet.
+func updateSigningKeys(now time.Time) (current, previous string, err error) {
+	current, created, previous := store.GetSigningKeys()
+	if current != "" && now.Sub(created) < signingKeyLifetime {
+		return current, previous, nil
+	}
+	key, err := auth.NewSigningKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	store.SetSigningKey(key, now)
+	if current != "" {
+		slog.Info("Replaced the update check signing key")
+	}
+	return key, current, nil
+}
+
+// sendUpdateCheck requests requestURL with query, signed unless sign is
+// false or signing fails. Returns whether the request was signed.
+func sendUpdateCheck(ctx context.Context, requestURL url.URL, query url.Values, sign bool) (*http.Response, bool, error) {
+	requestURL.RawQuery = query.Encode()
+	var headers map[string]string
+	if sign {
+		signedURL, signHeaders, err := signUpdateCheck(requestURL, query)
+		if err != nil {
+			slog.Warn("Unable to sign update check, checking anonymously", "error", err)
+			sign = false
+		} else {
+			requestURL, headers = signedURL, signHeaders
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL.String(), nil)
+	if err != nil {
+		return nil, false, err
+	}
+	req.Header.Set("User-Agent", userAgent())
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	slog.Debug("checking for available update", "requestURL", requestURL.String(), "signed", sign)
+	resp, err := http.DefaultClient.Do(req)
+	return resp, sign, err
+}
+
+// signUpdateCheck adds a nonce to the query of requestURL and returns it with
+// the headers signing the request.
+func signUpdateCheck(requestURL url.URL, query url.Values) (url.URL, map[string]string, error) {
+	current, previous, err := updateSigningKeys(time.Now())
+	if err != nil {
+		return requestURL, nil, err
+	}
+	nonce, err := auth.NewNonce(rand.Reader, updateNonceLength)
+	if err != nil {
+		return requestURL, nil, err
+	}
+	query = maps.Clone(query)
+	query.Set("nonce", nonce)
+	requestURL.RawQuery = query.Encode()
+
+	data := []byte(fmt.Sprintf("%s,%s", http.MethodGet, requestURL.RequestURI()))
+	signature, err := auth.Sign(current, data)
+	if err != nil {
+		return requestURL, nil, err
+	}
+	headers := map[string]string{"Authorization": signature}
+	if previous != "" {
+		if signature, err := auth.Sign(previous, data); err == nil {
+			headers["X-Previous-Authorization"] = signature
+		}
+	}
+	return requestURL, headers, nil
+}
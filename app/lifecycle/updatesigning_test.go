@@ -0,0 +1,46 @@
+//go:build windows && unit_test
+
+package lifecycle
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, 5, 6, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		header string
+		want   time.Duration
+	}{
+		{"", updateCheckBackoff},
+		{"garbage", updateCheckBackoff},
+		{"7200", 2 * time.Hour},
+		{now.Add(3 * time.Hour).Format(http.TimeFormat), 3 * time.Hour},
+		{"0", time.Minute},
+		{"-5", time.Minute},
+		{"100000000", maxUpdateCheckBackoff},
+	}
+	for _, test := range tests {
+		if got := retryAfter(test.header, now); got != test.want {
+			t.Errorf("Retry-After %q: expected %s, got %s", test.header, test.want, got)
+		}
+	}
+}
+
+func TestDelayUpdateChecks(t *testing.T) {
+	defer func() { updateCheckRetryAt = time.Time{} }()
+
+	now := time.Now()
+	if updateCheckDelay(now) > 0 {
+		t.Fatal("expected update checks not to be delayed")
+	}
+	delayUpdateChecks("600", now)
+	if got := updateCheckDelay(now.Add(time.Minute)); got != 9*time.Minute {
+		t.Errorf("expected checks to be delayed 9 more minutes, got %s", got)
+	}
+	if got := updateCheckDelay(now.Add(10 * time.Minute)); got > 0 {
+		t.Errorf("expected checks once Retry-After passed, got a delay of %s", got)
+	}
+}
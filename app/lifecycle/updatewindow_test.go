@@ -0,0 +1,47 @@
+//go:build windows && unit_test
+
+package lifecycle
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUpdateDownloadsAllowed(t *testing.T) {
+	night := []scheduleWindow{{Start: "23:00", End: "06:00"}}
+	noon := time.Date(2024, 5, 6, 12, 0, 0, 0, time.Local)
+	midnight := time.Date(2024, 5, 6, 0, 30, 0, 0, time.Local)
+
+	tests := []struct {
+		name      string
+		downloads UpdateDownloads
+		now       time.Time
+		idle      time.Duration
+		want      bool
+	}{
+		{"active user", UpdateDownloads{}, noon, time.Minute, false},
+		{"idle user", UpdateDownloads{}, noon, defaultUpdateIdle, true},
+		{"custom idle time", UpdateDownloads{IdleMinutes: 30}, noon, 20 * time.Minute, false},
+		{"any time", UpdateDownloads{AnyTime: true}, noon, 0, true},
+		{"off-peak hours", UpdateDownloads{Hours: night}, midnight, 0, true},
+		{"outside off-peak hours", UpdateDownloads{Hours: night}, noon, 0, false},
+		{"only off-peak hours", UpdateDownloads{Hours: night, IdleMinutes: -1}, noon, time.Hour, false},
+	}
+	for _, test := range tests {
+		if got := test.downloads.allowed(test.now, test.idle); got != test.want {
+			t.Errorf("%s: expected %v, got %v", test.name, test.want, got)
+		}
+	}
+}
+
+func TestUpdateDownloadsValidate(t *testing.T) {
+	if err := (UpdateDownloads{}).validate(); err != nil {
+		t.Errorf("expected the defaults to be valid, got %v", err)
+	}
+	if err := (UpdateDownloads{IdleMinutes: -1}).validate(); err == nil {
+		t.Error("expected an error when updates would never download")
+	}
+	if err := (UpdateDownloads{Hours: []scheduleWindow{{Start: "25:00", End: "06:00"}}}).validate(); err == nil {
+		t.Error("expected an error for invalid hours")
+	}
+}
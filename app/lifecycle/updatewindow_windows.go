@@ -0,0 +1,135 @@
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+// Installers are large, and downloading one during a video call can saturate
+// the user's connection. Update downloads wait until nobody has used the
+// keyboard or mouse for update_downloads.idle_minutes, or for the off-peak
+// update_downloads.hours. A download the user comes back during, outside of
+// those hours, is cancelled and started over once they are idle again.
+// update_downloads.any_time downloads updates as soon as they are found.
+
+// UpdateDownloads configures when app updates are downloaded.
+type UpdateDownloads struct {
+	AnyTime     bool             `json:"any_time"`     // Download as soon as an update is found
+	Hours       []scheduleWindow `json:"hours"`        // Off-peak hours, when updates download even while the user is active
+	IdleMinutes int              `json:"idle_minutes"` // 0 for defaultUpdateIdle, negative to only download during Hours
+}
+
+const (
+	defaultUpdateIdle         = 10 * time.Minute
+	updateWindowCheckInterval = time.Minute
+)
+
+var (
+	pGetLastInputInfo = user32.NewProc("GetLastInputInfo")
+	pGetTickCount     = kernel32.NewProc("GetTickCount")
+)
+
+// LASTINPUTINFO
+type lastInputInfo struct {
+	cbSize uint32
+	dwTime uint32
+}
+
+// idleAfter returns how long the user has to be idle for updates to download,
+// 0 if idleness doesn't allow them.
+func (d UpdateDownloads) idleAfter() time.Duration {
+	switch {
+	case d.IdleMinutes < 0:
+		return 0
+	case d.IdleMinutes == 0:
+		return defaultUpdateIdle
+	}
+	return time.Duration(d.IdleMinutes) * time.Minute
+}
+
+// allowed reports whether an update may download at now, with the user idle
+// for idle.
+func (d UpdateDownloads) allowed(now time.Time, idle time.Duration) bool {
+	if d.AnyTime || (len(d.Hours) > 0 && inSchedule(d.Hours, now)) {
+		return true
+	}
+	after := d.idleAfter()
+	return after > 0 && idle >= after
+}
+
+func (d UpdateDownloads) validate() error {
+	if err := validateSchedule(d.Hours); err != nil {
+		return fmt.Errorf("invalid hours: %w", err)
+	}
+	if !d.AnyTime && d.IdleMinutes < 0 && len(d.Hours) == 0 {
+		return fmt.Errorf("updates would never download, set hours or idle_minutes")
+	}
+	return nil
+}
+
+// userIdleTime returns how long ago the user last used the keyboard or mouse
+// in this session.
+func userIdleTime() (time.Duration, error) {
+	info := lastInputInfo{cbSize: uint32(unsafe.Sizeof(lastInputInfo{}))}
+	if r, _, err := pGetLastInputInfo.Call(uintptr(unsafe.Pointer(&info))); r == 0 {
+		return 0, fmt.Errorf("GetLastInputInfo: %w", err)
+	}
+	now, _, _ := pGetTickCount.Call()
+	// Both wrap around after 49.7 days, the difference stays right
+	return time.Duration(uint32(now)-info.dwTime) * time.Millisecond, nil
+}
+
+// updateDownloadAllowed reports whether update_downloads allows downloading
+// now. The user counts as active if their idle time can't be told.
+func updateDownloadAllowed() bool {
+	idle, err := userIdleTime()
+	if err != nil {
+		slog.Debug("failed to get the user's idle time", "error", err)
+	}
+	return currentAppConfig().UpdateDownloads.allowed(time.Now(), idle)
+}
+
+// downloadUpdateWhenAllowed downloads the update once update_downloads allows
+// it, starting over if the user comes back during the download. Returns
+// ctx.Err() if ctx is cancelled first.
+func downloadUpdateWhenAllowed(ctx context.Context, updateResp UpdateResponse) error {
+	for {
+		if !updateDownloadAllowed() {
+			slog.Info("Waiting for the user to be idle or for off-peak hours to download the update", "version", updateResp.UpdateVersion)
+			for !updateDownloadAllowed() {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(updateWindowCheckInterval):
+				}
+			}
+		}
+
+		downloadCtx, cancel := context.WithCancel(ctx)
+		var interrupted atomic.Bool
+		go func() {
+			for {
+				select {
+				case <-downloadCtx.Done():
+					return
+				case <-time.After(updateWindowCheckInterval):
+				}
+				if !updateDownloadAllowed() {
+					interrupted.Store(true)
+					cancel()
+					return
+				}
+			}
+		}()
+		err := DownloadNewRelease(downloadCtx, updateResp)
+		cancel()
+		if err == nil || !interrupted.Load() {
+			return err
+		}
+		slog.Info("The user is active, pausing the update download", "version", updateResp.UpdateVersion)
+	}
+}
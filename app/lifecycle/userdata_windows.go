@@ -0,0 +1,193 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/ReEnvision-AI/systray/app/auth"
+	"github.com/ReEnvision-AI/systray/app/secrets"
+	"github.com/ReEnvision-AI/systray/app/store"
+	"github.com/ReEnvision-AI/systray/app/tray/commontray"
+	"golang.org/x/sys/windows"
+)
+
+// Backend endpoints for personal data requests, relative to the Supabase URL
+var (
+	DataExportPath     = "/functions/v1/export-user-data"
+	DataDeletePath     = "/functions/v1/delete-user-data"
+	DataRequestTimeout = 5 * time.Minute
+)
+
+// localDataWiped is set once the user's data was deleted, the app exits
+// without saving the contribution history or journal again.
+var localDataWiped atomic.Bool
+
+// handleExportData downloads everything the backend stores about the signed
+// in user to the Downloads folder.
+func handleExportData() {
+	ctx, cancel := context.WithTimeout(context.Background(), DataRequestTimeout)
+	defer cancel()
+
+	path, err := exportData(ctx)
+	if err != nil {
+		if errors.Is(err, auth.ErrNoSession) {
+			return
+		}
+		slog.Error("Failed to export user data", "error", err)
+		notify(commontray.NotifyError, "Unable to download your data", "Open the logs from the tray menu for details")
+		return
+	}
+	slog.Info("Exported user data", "path", path)
+	notify(commontray.NotifyInfo, "Your data was downloaded", path)
+
+	cmd := exec.Command("explorer", "/select,", path)
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+	if err := cmd.Start(); err != nil {
+		slog.Warn("Failed to show exported data", "path", path, "error", err)
+	}
+}
+
+func exportData(ctx context.Context) (string, error) {
+	cfg, err := readConfig()
+	if err != nil {
+		return "", err
+	}
+	client, err := newAuthClient(cfg)
+	if err != nil {
+		return "", err
+	}
+	s, err := getSession(ctx, client)
+	if err != nil {
+		return "", err
+	}
+
+	notify(commontray.NotifyInfo, "Preparing your data", "This may take a few minutes")
+	req, err := client.NewRequest(ctx, http.MethodGet, DataExportPath, nil, s)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.HTTP.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(body))
+	}
+
+	dir, err := windows.KnownFolderPath(windows.FOLDERID_Downloads, 0)
+	if err != nil {
+		return "", fmt.Errorf("unable to locate Downloads folder: %w", err)
+	}
+	path := filepath.Join(dir, fmt.Sprintf("ReEnvisionAI-data-%s.json", time.Now().Format("2006-01-02")))
+	fp, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	defer fp.Close()
+	if _, err := io.Copy(fp, resp.Body); err != nil {
+		os.Remove(path)
+		return "", fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return path, nil
+}
+
+// handleDeleteData asks the backend to delete the signed in user's account
+// data, then wipes everything stored locally and exits.
+func handleDeleteData() {
+	if !confirm("Delete my account data",
+		"This permanently deletes your ReEnvision AI account data, including your contribution history. "+
+			"Local credentials, the node identity and downloaded models are removed as well, and the app will exit.\n\n"+
+			"Delete your data?") {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), DataRequestTimeout)
+	defer cancel()
+
+	if err := deleteData(ctx); err != nil {
+		if errors.Is(err, auth.ErrNoSession) {
+			return
+		}
+		slog.Error("Failed to delete user data", "error", err)
+		notify(commontray.NotifyError, "Unable to delete your data", "Open the logs from the tray menu for details")
+		return
+	}
+
+	stateMu.Lock()
+	running := currentState == StateRunning || currentState == StateLoading || currentState == StateStarting || currentState == StatePaused
+	stateMu.Unlock()
+	if running {
+		handleStopRequest()
+	}
+	wipeLocalData(ctx)
+
+	notify(commontray.NotifyInfo, "Your data was deleted", "ReEnvision AI will now exit")
+	handleQuit()
+}
+
+func deleteData(ctx context.Context) error {
+	cfg, err := readConfig()
+	if err != nil {
+		return err
+	}
+	client, err := newAuthClient(cfg)
+	if err != nil {
+		return err
+	}
+	s, err := getSession(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	notify(commontray.NotifyInfo, "Deleting your data", "This may take a few minutes")
+	req, err := client.NewRequest(ctx, http.MethodPost, DataDeletePath, nil, s)
+	if err != nil {
+		return err
+	}
+	return client.Do(req, nil)
+}
+
+// wipeLocalData removes credentials, the store and caches. Failures are
+// logged and don't stop the remaining cleanup. Nothing is saved to them again
+// until the app exits.
+func wipeLocalData(ctx context.Context) {
+	slog.Info("Wiping local data")
+	localDataWiped.Store(true)
+	clearSession()
+	if err := secrets.Default().Delete(hfTokenCredentialTarget); err != nil {
+		slog.Warn("Failed to delete HuggingFace token", "error", err)
+	}
+	// A cancelled start winds down by itself, and uses the volume until then
+	if !awaitStopped(podmanStopTimeout) {
+		slog.Warn("Node didn't stop in time, not removing cache volume")
+	} else if output, err := helperCombinedOutput(podmanCommand(ctx, "volume", "rm", "--force", cacheVolumeName)); err != nil {
+		slog.Warn("Failed to remove cache volume", "error", err, "output", string(output))
+	}
+	cleanupOldDownloads()
+	if err := removeHistory(); err != nil {
+		slog.Warn("Failed to delete contribution history", "error", err)
+	}
+	if err := removeJournal(); err != nil {
+		slog.Warn("Failed to delete state journal", "error", err)
+	}
+	if configFile, err := configFilePath(); err != nil {
+		slog.Warn("Failed to locate config backups", "error", err)
+	} else if err := removeConfigBackups(configFile); err != nil {
+		slog.Warn("Failed to delete config backups", "error", err)
+	}
+	if err := store.Discard(); err != nil {
+		slog.Warn("Failed to delete store", "error", err)
+	}
+}
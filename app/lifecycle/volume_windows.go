@@ -0,0 +1,123 @@
+package lifecycle
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/ReEnvision-AI/systray/app/format"
+	"github.com/ReEnvision-AI/systray/app/store"
+	"github.com/ReEnvision-AI/systray/app/tray/commontray"
+)
+
+const (
+	cacheVolumeName        = "reai-cache"
+	cacheVolumeMountPath   = "/cache"
+	cacheVolumeAppLabel    = "app=reenvision-ai"
+	cacheVolumeSizeTimeout = 30 * time.Second
+)
+
+// ensureCacheVolume creates the model cache volume if it doesn't exist yet.
+func ensureCacheVolume(ctx context.Context) error {
+	if err := runHelper(podmanCommand(ctx, "volume", "exists", cacheVolumeName)); err == nil {
+		slog.Debug("Cache volume exists", "name", cacheVolumeName)
+		return nil
+	}
+
+	slog.Info("Creating cache volume", "name", cacheVolumeName)
+	output, err := helperCombinedOutput(podmanCommand(ctx, "volume", "create",
+		"--label", cacheVolumeAppLabel,
+		"--label", nodeIDLabel+"="+store.GetID(),
+		cacheVolumeName,
+	))
+	if err != nil {
+		return fmt.Errorf("failed to create cache volume %s: %w", cacheVolumeName, podmanError(err, output))
+	}
+	return nil
+}
+
+// cacheVolumeSize returns the disk usage of the model cache volume in bytes.
+func cacheVolumeSize(ctx context.Context) (int64, error) {
+	output, err := helperOutput(podmanCommand(ctx, "system", "df", "--verbose", "--format", "json"))
+	if err != nil {
+		return 0, fmt.Errorf("failed to get podman disk usage: %w", err)
+	}
+
+	var report struct {
+		Volumes []struct {
+			VolumeName string
+			Size       int64
+		}
+	}
+	if err := json.Unmarshal(output, &report); err != nil {
+		return 0, fmt.Errorf("failed to parse podman disk usage: %w", err)
+	}
+	for _, volume := range report.Volumes {
+		if volume.VolumeName == cacheVolumeName {
+			return volume.Size, nil
+		}
+	}
+	return 0, fmt.Errorf("cache volume %s not found", cacheVolumeName)
+}
+
+func logCacheVolumeSize() {
+	ctx, cancel := context.WithTimeout(context.Background(), cacheVolumeSizeTimeout)
+	defer cancel()
+	size, err := cacheVolumeSize(ctx)
+	if err != nil {
+		slog.Warn("Unable to determine cache volume size", "error", err)
+		return
+	}
+	slog.Info("Cache volume size", "name", cacheVolumeName, "bytes", size)
+}
+
+// handleRecreateCacheVolume deletes and recreates the model cache volume,
+// stopping the container while it does so. Used to recover from a corrupted cache.
+func handleRecreateCacheVolume() {
+	message := "This deletes all downloaded models. They will be downloaded again the next time the node starts.\n\nRecreate the cache volume?"
+	sizeCtx, sizeCancel := context.WithTimeout(context.Background(), cacheVolumeSizeTimeout)
+	size, err := cacheVolumeSize(sizeCtx)
+	sizeCancel()
+	if err == nil {
+		message = fmt.Sprintf("This deletes all downloaded models (%s). They will be downloaded again the next time the node starts.\n\nRecreate the cache volume?", format.Bytes(size))
+	}
+	if !confirm("Recreate cache volume", message) {
+		return
+	}
+
+	stateMu.Lock()
+	wasRunning := currentState == StateRunning || currentState == StateLoading || currentState == StateStarting || currentState == StatePaused
+	stateMu.Unlock()
+	if wasRunning {
+		handleStopRequest()
+	}
+	// A cancelled start winds down by itself, and uses the volume until then
+	if !awaitStopped(podmanStopTimeout) {
+		slog.Error("Node didn't stop in time, not recreating the cache volume")
+		notify(commontray.NotifyError, "Unable to recreate the cache volume", "The node didn't stop in time. Try again in a minute")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	slog.Info("Recreating cache volume", "name", cacheVolumeName)
+	output, err := helperCombinedOutput(podmanCommand(ctx, "volume", "rm", "--force", cacheVolumeName))
+	if err == nil {
+		err = ensureCacheVolume(ctx)
+	} else {
+		err = fmt.Errorf("failed to remove cache volume %s: %w", cacheVolumeName, podmanError(err, output))
+	}
+	if err != nil {
+		slog.Error("Failed to recreate cache volume", "error", err)
+		notify(commontray.NotifyError, "Unable to recreate the cache volume", "Open the logs from the tray menu for details")
+		return
+	}
+	notify(commontray.NotifyInfo, "Cache volume recreated", "Models will be downloaded again when the node starts")
+
+	if wasRunning {
+		handleStartRequest()
+	}
+}
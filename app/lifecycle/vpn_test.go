@@ -0,0 +1,66 @@
+//go:build windows && unit_test
+
+package lifecycle
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/sys/windows"
+)
+
+func TestIsVPNAdapter(t *testing.T) {
+	const ethernet = 6
+	cfg := VPNConfig{
+		Allow: []string{"Corp*"},
+		Deny:  []string{"*hotspot shield*"},
+	}
+	tests := []struct {
+		ifType      uint32
+		name        string
+		description string
+		want        bool
+	}{
+		{ethernet, "Ethernet", "Intel(R) Ethernet Connection", false},
+		{ethernet, "vEthernet (WSL)", "Hyper-V Virtual Ethernet Adapter", false},
+		{ethernet, "Local Area Connection", "Cisco AnyConnect Secure Mobility Client Virtual Miniport Adapter", true},
+		{ethernet, "wg0", "WireGuard Tunnel", true},
+		{windows.IF_TYPE_PPP, "Office", "WAN Miniport (IKEv2)", true},
+		{windows.IF_TYPE_TUNNEL, "Teredo", "Teredo Tunneling Pseudo-Interface", false},
+		{windows.IF_TYPE_TUNNEL, "Tunnel", "Some Tunnel Adapter", true},
+		{ethernet, "Corp VPN", "Fortinet Virtual Ethernet Adapter", false},     // Allowed
+		{ethernet, "Ethernet 3", "Hotspot Shield 7.9.0 Network Adapter", true}, // Denied
+	}
+	for _, test := range tests {
+		if got := isVPNAdapter(test.ifType, test.name, test.description, cfg); got != test.want {
+			t.Errorf("%s (%s): expected VPN %v, got %v", test.name, test.description, test.want, got)
+		}
+	}
+}
+
+func TestVPNConfig(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), configFileName)
+	for content, want := range map[string]string{
+		`{"container_name": "reai", "container_image": "node:1", "model_name": "org/model"}`:                                             vpnPolicyWarn,
+		`{"container_name": "reai", "container_image": "node:1", "model_name": "org/model", "vpn": {"policy": "pause"}}`:                 vpnPolicyPause,
+		`{"container_name": "reai", "container_image": "node:1", "model_name": "org/model", "vpn": {"policy": "block"}}`:                 "",
+		`{"container_name": "reai", "container_image": "node:1", "model_name": "org/model", "vpn": {"allow": ["[Corp"]}}`:                "",
+		`{"container_name": "reai", "container_image": "node:1", "model_name": "org/model", "vpn": {"policy": "ignore", "deny": ["*"]}}`: vpnPolicyIgnore,
+	} {
+		if err := os.WriteFile(configFile, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := readConfigFile(configFile)
+		if want == "" {
+			if err == nil || !strings.Contains(err.Error(), "vpn") {
+				t.Errorf("expected an invalid vpn error for %s, got %v", content, err)
+			}
+			continue
+		}
+		if err != nil || cfg.VPN.Policy != want {
+			t.Errorf("expected vpn policy %q, got %q, %v", want, cfg.VPN.Policy, err)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package lifecycle
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"github.com/ReEnvision-AI/systray/app/tray/commontray"
+	"golang.org/x/sys/windows"
+)
+
+// The container uses host networking, so while a VPN is connected its peer
+// traffic may be tunneled through it, which corporate VPN users didn't
+// expect. Connected VPN adapters are looked for whenever the network changes
+// and when the node starts. By default the user is warned, and with the
+// "pause" policy the node is stopped while a VPN is connected and resumed
+// once it disconnects. Adapters are recognised by their type and name, and
+// the allow and deny lists in config.json correct the guess for an adapter.
+
+// Values for VPNConfig.Policy
+const (
+	vpnPolicyWarn   = "warn" // The default
+	vpnPolicyPause  = "pause"
+	vpnPolicyIgnore = "ignore"
+)
+
+// VPNConfig is what the node does while a VPN is connected.
+type VPNConfig struct {
+	Policy string   `json:"policy"` // One of "warn", "pause" or "ignore"
+	Allow  []string `json:"allow"`  // Adapters never treated as VPNs, by name or description with * wildcards
+	Deny   []string `json:"deny"`   // Adapters always treated as VPNs, matched like Allow
+}
+
+func (c VPNConfig) policy() string {
+	if c.Policy == "" {
+		return vpnPolicyWarn
+	}
+	return c.Policy
+}
+
+var errVPNConnected = errors.New("a VPN is connected")
+
+// vpnKeywords are fragments of the lower case names and descriptions of VPN
+// adapters.
+var vpnKeywords = []string{
+	"vpn", "wireguard", "wintun", "tap-windows", "anyconnect", "globalprotect",
+	"pangp", "fortinet", "juniper", "pulse secure", "zscaler", "nordlynx",
+}
+
+// transitionTunnels are fragments of the descriptions of the tunnel adapters
+// Windows uses for IPv6, which aren't VPNs.
+var transitionTunnels = []string{"teredo", "isatap", "6to4", "ip-https"}
+
+var (
+	vpnMu       sync.Mutex
+	activeVPNs  []string // Names of the VPN adapters connected when last checked
+	pausedByVPN bool
+)
+
+// isVPNAdapter reports whether a connected adapter is a VPN.
+func isVPNAdapter(ifType uint32, name, description string, cfg VPNConfig) bool {
+	switch {
+	case matchesAdapter(cfg.Deny, name, description):
+		return true
+	case matchesAdapter(cfg.Allow, name, description):
+		return false
+	}
+	lower := strings.ToLower(name + " " + description)
+	contains := func(fragments []string) bool {
+		return slices.ContainsFunc(fragments, func(f string) bool { return strings.Contains(lower, f) })
+	}
+	switch ifType {
+	case windows.IF_TYPE_PPP:
+		return true
+	case windows.IF_TYPE_TUNNEL:
+		return !contains(transitionTunnels)
+	}
+	return contains(vpnKeywords)
+}
+
+// matchesAdapter reports whether the name or description of an adapter
+// matches one of the patterns, ignoring case.
+func matchesAdapter(patterns []string, name, description string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		for _, s := range []string{name, description} {
+			if ok, _ := path.Match(pattern, strings.ToLower(s)); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// connectedVPNs returns the sorted names of the VPN adapters that are up.
+func connectedVPNs(cfg VPNConfig) ([]string, error) {
+	const flags = windows.GAA_FLAG_SKIP_UNICAST | windows.GAA_FLAG_SKIP_ANYCAST | windows.GAA_FLAG_SKIP_MULTICAST | windows.GAA_FLAG_SKIP_DNS_SERVER
+	size := uint32(16 * 1024)
+	var buf []byte
+	for {
+		buf = make([]byte, size)
+		err := windows.GetAdaptersAddresses(windows.AF_UNSPEC, flags, 0, (*windows.IpAdapterAddresses)(unsafe.Pointer(&buf[0])), &size)
+		if err == nil {
+			break
+		}
+		if err == windows.ERROR_NO_DATA {
+			return nil, nil
+		}
+		if err != windows.ERROR_BUFFER_OVERFLOW {
+			return nil, fmt.Errorf("unable to list network adapters: %w", err)
+		}
+	}
+
+	var names []string
+	for adapter := (*windows.IpAdapterAddresses)(unsafe.Pointer(&buf[0])); adapter != nil; adapter = adapter.Next {
+		if adapter.OperStatus != windows.IfOperStatusUp {
+			continue
+		}
+		name := windows.UTF16PtrToString(adapter.FriendlyName)
+		if isVPNAdapter(adapter.IfType, name, windows.UTF16PtrToString(adapter.Description), cfg) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// refreshVPN looks for connected VPNs and applies the vpn policy when one
+// connects or all disconnect.
+func refreshVPN() {
+	cfg := currentAppConfig().VPN
+	if cfg.policy() == vpnPolicyIgnore {
+		return
+	}
+	names, err := connectedVPNs(cfg)
+	if err != nil {
+		slog.Debug("unable to check for VPNs", "error", err)
+		return
+	}
+
+	vpnMu.Lock()
+	connected := len(names) > 0 && !slices.Equal(names, activeVPNs)
+	disconnected := len(names) == 0 && len(activeVPNs) > 0
+	activeVPNs = names
+	resume := disconnected && pausedByVPN
+	if disconnected {
+		pausedByVPN = false
+	}
+	vpnMu.Unlock()
+	if connected {
+		slog.Info("VPN connected", "adapters", names, "policy", cfg.policy())
+	} else if disconnected {
+		slog.Info("VPN disconnected")
+	}
+
+	stateMu.Lock()
+	state := currentState
+	stateMu.Unlock()
+	running := state == StateRunning || state == StateLoading
+	switch {
+	case connected && running && cfg.policy() == vpnPolicyPause:
+		slog.Info("Pausing node while the VPN is connected")
+		vpnMu.Lock()
+		pausedByVPN = true
+		vpnMu.Unlock()
+		notify(commontray.NotifyInfo, "Node paused", "A VPN is connected ("+strings.Join(names, ", ")+"). The node resumes once it disconnects")
+		handleStopRequest()
+	case connected && running:
+		warnVPN(names)
+	case resume && state == StateStopped:
+		slog.Info("Resuming node after the VPN disconnected")
+		go handleStartRequest()
+	}
+}
+
+// checkVPNStart returns errVPNConnected if the node is paused while a VPN is
+// connected, and otherwise warns about a connected VPN.
+func checkVPNStart() error {
+	cfg := currentAppConfig().VPN
+	if cfg.policy() == vpnPolicyIgnore {
+		return nil
+	}
+	names, err := connectedVPNs(cfg)
+	if err != nil {
+		slog.Debug("unable to check for VPNs", "error", err)
+		return nil
+	}
+	vpnMu.Lock()
+	activeVPNs = names
+	pause := len(names) > 0 && cfg.policy() == vpnPolicyPause
+	pausedByVPN = pause
+	vpnMu.Unlock()
+	switch {
+	case pause:
+		return fmt.Errorf("%w: %s", errVPNConnected, strings.Join(names, ", "))
+	case len(names) > 0:
+		warnVPN(names)
+	}
+	return nil
+}
+
+func warnVPN(names []string) {
+	notify(commontray.NotifyWarning, "VPN connected",
+		"The node's peer traffic may go through "+strings.Join(names, ", ")+`. Set vpn.policy to "pause" in config.json to pause the node while a VPN is connected`)
+}
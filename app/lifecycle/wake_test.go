@@ -0,0 +1,40 @@
+//go:build windows && unit_test
+
+package lifecycle
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWakeRestartPolicy(t *testing.T) {
+	if !confirmWakeRestart(wakeRestartAlways) || !confirmWakeRestart("") {
+		t.Error("expected a restart by default")
+	}
+	if confirmWakeRestart(wakeRestartNever) {
+		t.Error("expected no restart with the never policy")
+	}
+
+	configFile := filepath.Join(t.TempDir(), configFileName)
+	for content, want := range map[string]string{
+		`{"container_name": "reai", "container_image": "node:1", "model_name": "org/model"}`:                          wakeRestartAlways,
+		`{"container_name": "reai", "container_image": "node:1", "model_name": "org/model", "wake_restart": "ask"}`:   wakeRestartAsk,
+		`{"container_name": "reai", "container_image": "node:1", "model_name": "org/model", "wake_restart": "later"}`: "",
+	} {
+		if err := os.WriteFile(configFile, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := readConfigFile(configFile)
+		if want == "" {
+			if err == nil || !strings.Contains(err.Error(), "wake_restart") {
+				t.Errorf("expected an invalid wake_restart error, got %v", err)
+			}
+			continue
+		}
+		if err != nil || cfg.WakeRestart != want {
+			t.Errorf("expected wake_restart %q, got %q, %v", want, cfg.WakeRestart, err)
+		}
+	}
+}
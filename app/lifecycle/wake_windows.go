@@ -0,0 +1,107 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"time"
+)
+
+// After the system wakes, the network and the Podman machine in WSL take a
+// while to resume, and a node started right away often fails. The restart
+// after a wake therefore waits until the network is up and Podman answers,
+// for at most wakeReadyTimeout, after which it is attempted anyway. Whether
+// the node is restarted at all is set by wake_restart in config.json.
+
+// Values for AppConfig.WakeRestart
+const (
+	wakeRestartAlways = "always" // The default
+	wakeRestartNever  = "never"
+	wakeRestartAsk    = "ask"
+)
+
+const (
+	wakeSettleDelay  = 3 * time.Second // The least time given to the system to resume
+	wakeReadyTimeout = 2 * time.Minute
+	wakeReadyPoll    = 2 * time.Second
+)
+
+var errNetworkDown = errors.New("no route to the internet")
+
+// networkProbeAddrs are dialed over UDP to find a route to the internet.
+// Nothing is sent to them.
+var networkProbeAddrs = []string{"1.1.1.1:53", "[2606:4700:4700::1111]:53"}
+
+// networkUp reports whether the system has a route to the internet.
+func networkUp() bool {
+	for _, addr := range networkProbeAddrs {
+		if conn, err := net.Dial("udp", addr); err == nil {
+			conn.Close()
+			return true
+		}
+	}
+	return false
+}
+
+// awaitWakeReady waits until the network is up and the Podman service
+// answers after a wake, or until wakeReadyTimeout passed.
+func awaitWakeReady(ctx context.Context) {
+	start := time.Now()
+	select {
+	case <-ctx.Done():
+		return
+	case <-time.After(wakeSettleDelay):
+	}
+	ctx, cancel := context.WithTimeout(ctx, wakeReadyTimeout)
+	defer cancel()
+
+	ready := func(check func() error) bool {
+		for {
+			err := check()
+			if err == nil {
+				return true
+			}
+			slog.Debug("not resumed yet", "error", err)
+			select {
+			case <-ctx.Done():
+				return false
+			case <-time.After(wakeReadyPoll):
+			}
+		}
+	}
+	networkReady := ready(func() error {
+		if !networkUp() {
+			return errNetworkDown
+		}
+		return nil
+	})
+	if !networkReady {
+		slog.Warn("Network is still down after wake, starting anyway", "waited", time.Since(start).Round(time.Second))
+		return
+	}
+	podmanReady := ready(func() error {
+		output, err := helperCombinedOutput(podmanCommand(ctx, "info"))
+		if err != nil {
+			return podmanError(err, output)
+		}
+		return nil
+	})
+	if !podmanReady {
+		slog.Warn("Podman is not answering after wake, starting anyway", "waited", time.Since(start).Round(time.Second))
+		return
+	}
+	slog.Info("System resumed after wake", "waited", time.Since(start).Round(time.Second))
+}
+
+// confirmWakeRestart reports whether the node is restarted after a wake
+// under the wake_restart policy, asking the user if it says so.
+func confirmWakeRestart(policy string) bool {
+	switch policy {
+	case wakeRestartNever:
+		return false
+	case wakeRestartAsk:
+		return confirm("ReEnvision AI", "The node was running before the computer went to sleep. Start it again?")
+	}
+	return true
+}
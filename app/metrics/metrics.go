@@ -0,0 +1,129 @@
+// Package metrics keeps the node's metrics in a registry and exports them
+// to observability stacks.
+//
+// The lifecycle updates a single Registry, and each exporter configured in
+// the "metrics" block of config.json pushes a snapshot of it every interval,
+// so StatsD and OpenTelemetry collectors see the same values.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DefaultInterval = time.Minute
+
+// Kind is the type of a metric.
+type Kind int
+
+const (
+	Gauge   Kind = iota // A value that goes up and down
+	Counter             // A monotonic total since the app started
+)
+
+// Metric is a value in a snapshot of the registry.
+type Metric struct {
+	Name  string // Dotted lower case, e.g. "node.uptime_seconds"
+	Kind  Kind
+	Value float64
+}
+
+// Registry holds the current value of every metric. It is safe for
+// concurrent use.
+type Registry struct {
+	mu      sync.Mutex
+	kinds   map[string]Kind
+	values  map[string]float64
+	started time.Time
+}
+
+func NewRegistry() *Registry {
+	return &Registry{kinds: map[string]Kind{}, values: map[string]float64{}, started: time.Now()}
+}
+
+// Set sets a gauge.
+func (r *Registry) Set(name string, value float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.kinds[name] = Gauge
+	r.values[name] = value
+}
+
+// Add increments a counter.
+func (r *Registry) Add(name string, delta float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.kinds[name] = Counter
+	r.values[name] += delta
+}
+
+// Snapshot returns every metric, sorted by name.
+func (r *Registry) Snapshot() []Metric {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	metrics := make([]Metric, 0, len(r.values))
+	for name, value := range r.values {
+		metrics = append(metrics, Metric{Name: name, Kind: r.kinds[name], Value: value})
+	}
+	slices.SortFunc(metrics, func(a, b Metric) int { return strings.Compare(a.Name, b.Name) })
+	return metrics
+}
+
+// Started is when counting started, the start of every counter.
+func (r *Registry) Started() time.Time {
+	return r.started
+}
+
+// Exporter pushes snapshots of the registry.
+type Exporter interface {
+	Export(ctx context.Context, metrics []Metric, started time.Time) error
+}
+
+// Config selects the exporters, none by default.
+type Config struct {
+	StatsD          *StatsDConfig `json:"statsd"`
+	OTLP            *OTLPConfig   `json:"otlp"`
+	IntervalSeconds int           `json:"interval_seconds"` // 0 for DefaultInterval
+}
+
+// Interval returns how often metrics are exported.
+func (cfg Config) Interval() time.Duration {
+	if cfg.IntervalSeconds > 0 {
+		return time.Duration(cfg.IntervalSeconds) * time.Second
+	}
+	return DefaultInterval
+}
+
+// Validate checks the configured exporters.
+func (cfg Config) Validate() error {
+	if cfg.StatsD != nil && cfg.StatsD.Address == "" {
+		return errors.New("statsd needs an address")
+	}
+	if cfg.OTLP != nil && cfg.OTLP.Endpoint == "" {
+		return errors.New("otlp needs an endpoint")
+	}
+	return nil
+}
+
+// Exporters returns the configured exporters, identifying the node by
+// nodeID.
+func (cfg Config) Exporters(nodeID, version, userAgent string) []Exporter {
+	var exporters []Exporter
+	if cfg.StatsD != nil {
+		exporters = append(exporters, &StatsDExporter{Address: cfg.StatsD.Address, Prefix: cfg.StatsD.prefix()})
+	}
+	if cfg.OTLP != nil {
+		exporters = append(exporters, &OTLPExporter{
+			Endpoint:  cfg.OTLP.Endpoint,
+			Headers:   cfg.OTLP.Headers,
+			NodeID:    nodeID,
+			Version:   version,
+			UserAgent: userAgent,
+		})
+	}
+	return exporters
+}
@@ -0,0 +1,113 @@
+//go:build unit_test
+
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	r.Set("node.up", 1)
+	r.Add("node.starts", 1)
+	r.Add("node.starts", 2)
+	r.Set("node.up", 0)
+
+	expected := []Metric{
+		{Name: "node.starts", Kind: Counter, Value: 3},
+		{Name: "node.up", Kind: Gauge, Value: 0},
+	}
+	if got := r.Snapshot(); !slices.Equal(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	if err := (Config{}).Validate(); err != nil {
+		t.Errorf("expected an empty config to be valid, got %v", err)
+	}
+	if err := (Config{StatsD: &StatsDConfig{}}).Validate(); err == nil {
+		t.Error("expected an error for statsd without an address")
+	}
+	if err := (Config{OTLP: &OTLPConfig{}}).Validate(); err == nil {
+		t.Error("expected an error for otlp without an endpoint")
+	}
+	if n := len((Config{}).Exporters("id", "1.0", "reai")); n != 0 {
+		t.Errorf("expected no exporters by default, got %d", n)
+	}
+}
+
+func TestStatsDExporter(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	e := &StatsDExporter{Address: conn.LocalAddr().String(), Prefix: defaultStatsDPrefix}
+	r := NewRegistry()
+	r.Set("node.up", 1)
+	r.Add("node.starts", 2)
+	if err := e.Export(context.Background(), r.Snapshot(), r.Started()); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, maxStatsDPacket)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, expected := string(buf[:n]), "reai.node.starts:2|c\nreai.node.up:1|g"; got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+
+	// Counters are sent as increments, unchanged ones not at all
+	if lines := e.lines(r.Snapshot()); !slices.Equal(lines, []string{"reai.node.up:1|g"}) {
+		t.Errorf("unexpected lines %v", lines)
+	}
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var received otlpRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" || r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	cfg := Config{OTLP: &OTLPConfig{Endpoint: server.URL + "/v1/metrics", Headers: map[string]string{"Authorization": "Bearer secret"}}}
+	exporters := cfg.Exporters("node-1", "1.2.3", "reai")
+	r := NewRegistry()
+	r.Set("node.up", 1)
+	r.Add("node.starts", 1)
+	if err := exporters[0].Export(context.Background(), r.Snapshot(), r.Started()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(received.ResourceMetrics) != 1 || len(received.ResourceMetrics[0].ScopeMetrics) != 1 {
+		t.Fatalf("unexpected request %+v", received)
+	}
+	metrics := received.ResourceMetrics[0].ScopeMetrics[0].Metrics
+	if len(metrics) != 2 || metrics[0].Sum == nil || !metrics[0].Sum.IsMonotonic || metrics[1].Gauge == nil {
+		t.Errorf("unexpected metrics %+v", metrics)
+	}
+	for _, m := range metrics {
+		if !strings.HasPrefix(m.Name, "reai.") {
+			t.Errorf("expected the reai. prefix on %s", m.Name)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// OTLPConfig configures the OpenTelemetry exporter.
+type OTLPConfig struct {
+	Endpoint string            `json:"endpoint"` // OTLP/HTTP metrics URL, e.g. http://localhost:4318/v1/metrics
+	Headers  map[string]string `json:"headers"`  // Extra request headers, e.g. for auth
+}
+
+// OTLPExporter posts metrics to an OpenTelemetry collector with OTLP/HTTP in
+// its JSON encoding, which needs no SDK.
+type OTLPExporter struct {
+	Endpoint  string
+	Headers   map[string]string
+	NodeID    string
+	Version   string
+	UserAgent string
+	HTTP      *http.Client // nil for http.DefaultClient
+}
+
+func (e *OTLPExporter) Export(ctx context.Context, metrics []Metric, started time.Time) error {
+	body, err := json.Marshal(e.request(metrics, started, time.Now()))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", e.UserAgent)
+	for k, v := range e.Headers {
+		req.Header.Set(k, v)
+	}
+
+	client := e.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// The subset of the OTLP ExportMetricsServiceRequest used by the exporter.
+// Integers are strings in the JSON encoding.
+type (
+	otlpRequest struct {
+		ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+	}
+	otlpResourceMetrics struct {
+		Resource     otlpResource       `json:"resource"`
+		ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+	}
+	otlpResource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	}
+	otlpAttribute struct {
+		Key   string `json:"key"`
+		Value struct {
+			StringValue string `json:"stringValue"`
+		} `json:"value"`
+	}
+	otlpScopeMetrics struct {
+		Scope   otlpScope    `json:"scope"`
+		Metrics []otlpMetric `json:"metrics"`
+	}
+	otlpScope struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+	otlpMetric struct {
+		Name  string    `json:"name"`
+		Gauge *otlpData `json:"gauge,omitempty"`
+		Sum   *otlpSum  `json:"sum,omitempty"`
+	}
+	otlpData struct {
+		DataPoints []otlpDataPoint `json:"dataPoints"`
+	}
+	otlpSum struct {
+		otlpData
+		AggregationTemporality int  `json:"aggregationTemporality"`
+		IsMonotonic            bool `json:"isMonotonic"`
+	}
+	otlpDataPoint struct {
+		StartTimeUnixNano string  `json:"startTimeUnixNano,omitempty"`
+		TimeUnixNano      string  `json:"timeUnixNano"`
+		AsDouble          float64 `json:"asDouble"`
+	}
+)
+
+const otlpCumulative = 2 // AGGREGATION_TEMPORALITY_CUMULATIVE
+
+func (e *OTLPExporter) request(metrics []Metric, started, now time.Time) otlpRequest {
+	attribute := func(key, value string) otlpAttribute {
+		a := otlpAttribute{Key: key}
+		a.Value.StringValue = value
+		return a
+	}
+	nanos := func(t time.Time) string { return strconv.FormatInt(t.UnixNano(), 10) }
+
+	scope := otlpScopeMetrics{Scope: otlpScope{Name: "reai-systray", Version: e.Version}}
+	for _, m := range metrics {
+		point := otlpDataPoint{TimeUnixNano: nanos(now), AsDouble: m.Value}
+		metric := otlpMetric{Name: "reai." + m.Name}
+		switch m.Kind {
+		case Gauge:
+			metric.Gauge = &otlpData{DataPoints: []otlpDataPoint{point}}
+		case Counter:
+			point.StartTimeUnixNano = nanos(started)
+			metric.Sum = &otlpSum{otlpData: otlpData{DataPoints: []otlpDataPoint{point}}, AggregationTemporality: otlpCumulative, IsMonotonic: true}
+		}
+		scope.Metrics = append(scope.Metrics, metric)
+	}
+	return otlpRequest{ResourceMetrics: []otlpResourceMetrics{{
+		Resource: otlpResource{Attributes: []otlpAttribute{
+			attribute("service.name", "reai-node"),
+			attribute("service.instance.id", e.NodeID),
+			attribute("service.version", e.Version),
+		}},
+		ScopeMetrics: []otlpScopeMetrics{scope},
+	}}}
+}
@@ -0,0 +1,92 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const defaultStatsDPrefix = "reai."
+
+// StatsDConfig configures the StatsD exporter.
+type StatsDConfig struct {
+	Address string  `json:"address"` // host:port of the StatsD server, UDP
+	Prefix  *string `json:"prefix"`  // Prepended to metric names, defaults to "reai."
+}
+
+func (cfg StatsDConfig) prefix() string {
+	if cfg.Prefix == nil {
+		return defaultStatsDPrefix
+	}
+	return *cfg.Prefix
+}
+
+// maxStatsDPacket keeps datagrams under the common Ethernet MTU.
+const maxStatsDPacket = 1400
+
+// StatsDExporter sends gauges as gauges and counters as the increments since
+// the previous export.
+type StatsDExporter struct {
+	Address string
+	Prefix  string
+
+	mu       sync.Mutex
+	previous map[string]float64 // Counter values of the previous export
+}
+
+func (e *StatsDExporter) Export(ctx context.Context, metrics []Metric, started time.Time) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", e.Address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var packet bytes.Buffer
+	for _, line := range e.lines(metrics) {
+		if packet.Len() > 0 && packet.Len()+1+len(line) > maxStatsDPacket {
+			if _, err := conn.Write(packet.Bytes()); err != nil {
+				return err
+			}
+			packet.Reset()
+		}
+		if packet.Len() > 0 {
+			packet.WriteByte('\n')
+		}
+		packet.WriteString(line)
+	}
+	if packet.Len() > 0 {
+		if _, err := conn.Write(packet.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lines returns the StatsD lines of metrics, e.g. "reai.node.up:1|g".
+func (e *StatsDExporter) lines(metrics []Metric) []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.previous == nil {
+		e.previous = map[string]float64{}
+	}
+	var lines []string
+	for _, m := range metrics {
+		value := strconv.FormatFloat(m.Value, 'f', -1, 64)
+		switch m.Kind {
+		case Gauge:
+			lines = append(lines, fmt.Sprintf("%s%s:%s|g", e.Prefix, m.Name, value))
+		case Counter:
+			delta := m.Value - e.previous[m.Name]
+			e.previous[m.Name] = m.Value
+			if delta != 0 {
+				lines = append(lines, fmt.Sprintf("%s%s:%s|c", e.Prefix, m.Name, strconv.FormatFloat(delta, 'f', -1, 64)))
+			}
+		}
+	}
+	return lines
+}
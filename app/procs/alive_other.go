@@ -0,0 +1,17 @@
+//go:build !windows
+
+package procs
+
+import (
+	"os"
+	"syscall"
+)
+
+// alive reports whether the process pid is running.
+func alive(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	return process.Signal(syscall.Signal(0)) == nil
+}
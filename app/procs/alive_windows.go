@@ -0,0 +1,19 @@
+package procs
+
+import "golang.org/x/sys/windows"
+
+const stillActive = 259 // STILL_ACTIVE, the exit code of a running process
+
+// alive reports whether the process pid is running.
+func alive(pid int) bool {
+	process, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid))
+	if err != nil {
+		return false
+	}
+	defer windows.CloseHandle(process)
+	var code uint32
+	if err := windows.GetExitCodeProcess(process, &code); err != nil {
+		return false
+	}
+	return code == stillActive
+}
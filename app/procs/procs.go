@@ -0,0 +1,97 @@
+// Package procs keeps track of the child processes the app starts, like the
+// podman CLI and the node's container, so they can be listed in diagnostics
+// and killed when the app quits instead of being left behind.
+//
+// Processes are tracked from the time they start until they are waited for.
+package procs
+
+import (
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Process is a tracked child process.
+type Process struct {
+	PID     int
+	Command string // The command line, e.g. "podman machine ssh cat /proc/uptime"
+	Started time.Time
+	Running bool // False once the process exited, until it is waited for
+}
+
+// Registry tracks started child processes. It is safe for concurrent use.
+type Registry struct {
+	mu    sync.Mutex
+	procs map[*exec.Cmd]Process
+}
+
+func NewRegistry() *Registry {
+	return &Registry{procs: map[*exec.Cmd]Process{}}
+}
+
+// Track adds cmd once it started.
+func (r *Registry) Track(cmd *exec.Cmd) {
+	if cmd.Process == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.procs[cmd] = Process{PID: cmd.Process.Pid, Command: commandLine(cmd), Started: time.Now()}
+}
+
+// Done removes cmd once it was waited for.
+func (r *Registry) Done(cmd *exec.Cmd) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.procs, cmd)
+}
+
+// List returns the tracked processes, oldest first.
+func (r *Registry) List() []Process {
+	r.mu.Lock()
+	list := make([]Process, 0, len(r.procs))
+	for _, p := range r.procs {
+		list = append(list, p)
+	}
+	r.mu.Unlock()
+
+	for i := range list {
+		list[i].Running = alive(list[i].PID)
+	}
+	slices.SortFunc(list, func(a, b Process) int {
+		return a.Started.Compare(b.Started)
+	})
+	return list
+}
+
+// KillAll kills the tracked processes still running, returning how many
+// were killed. Whoever waits for them still calls Done.
+func (r *Registry) KillAll() int {
+	r.mu.Lock()
+	cmds := make([]*exec.Cmd, 0, len(r.procs))
+	for cmd := range r.procs {
+		cmds = append(cmds, cmd)
+	}
+	r.mu.Unlock()
+
+	killed := 0
+	for _, cmd := range cmds {
+		if alive(cmd.Process.Pid) && cmd.Process.Kill() == nil {
+			killed++
+		}
+	}
+	return killed
+}
+
+// commandLine returns the command line of cmd, with the program's base name.
+func commandLine(cmd *exec.Cmd) string {
+	args := slices.Clone(cmd.Args)
+	if len(args) == 0 {
+		args = []string{cmd.Path}
+	}
+	args[0] = strings.TrimSuffix(filepath.Base(args[0]), filepath.Ext(args[0]))
+	return strings.Join(args, " ")
+}
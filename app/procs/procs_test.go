@@ -0,0 +1,34 @@
+//go:build windows && unit_test
+
+package procs
+
+import (
+	"os/exec"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	cmd := exec.Command("cmd.exe", "/c", "ping -n 30 127.0.0.1 >nul")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	r.Track(cmd)
+
+	list := r.List()
+	if len(list) != 1 || list[0].PID != cmd.Process.Pid || !list[0].Running {
+		t.Fatalf("expected the running process to be listed, got %+v", list)
+	}
+	if want := "cmd /c ping -n 30 127.0.0.1 >nul"; list[0].Command != want {
+		t.Errorf("expected command %q, got %q", want, list[0].Command)
+	}
+
+	if killed := r.KillAll(); killed != 1 {
+		t.Errorf("expected 1 process killed, got %d", killed)
+	}
+	cmd.Wait()
+	r.Done(cmd)
+	if list := r.List(); len(list) != 0 {
+		t.Errorf("expected no processes once waited for, got %+v", list)
+	}
+}
@@ -0,0 +1,13 @@
+package secrets
+
+import "errors"
+
+// ErrNotFound is returned by Get when no secret exists with the given name.
+var ErrNotFound = errors.New("secret not found")
+
+// Store holds small secrets such as tokens and sessions.
+type Store interface {
+	Get(name string) ([]byte, error)
+	Set(name string, value []byte) error
+	Delete(name string) error
+}
@@ -0,0 +1,88 @@
+//go:build windows && unit_test
+
+package secrets
+
+import (
+	"errors"
+	"testing"
+)
+
+// memStore is an in-memory Store that can be made to fail like a blocked
+// Credential Manager.
+type memStore struct {
+	values map[string][]byte
+	err    error
+}
+
+func newMemStore() *memStore {
+	return &memStore{values: map[string][]byte{}}
+}
+
+func (m *memStore) Get(name string) ([]byte, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	v, ok := m.values[name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return v, nil
+}
+
+func (m *memStore) Set(name string, value []byte) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.values[name] = value
+	return nil
+}
+
+func (m *memStore) Delete(name string) error {
+	if m.err != nil {
+		return m.err
+	}
+	delete(m.values, name)
+	return nil
+}
+
+func TestFallbackStoreUsesPrimary(t *testing.T) {
+	primary, fallback := newMemStore(), newMemStore()
+	fallback.values["token"] = []byte("stale")
+	s := &fallbackStore{primary: primary, fallback: fallback}
+
+	if err := s.Set("token", []byte("fresh")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := fallback.values["token"]; ok {
+		t.Error("expected stale fallback copy to be removed")
+	}
+	v, err := s.Get("token")
+	if err != nil || string(v) != "fresh" {
+		t.Errorf("expected fresh, got %q %v", v, err)
+	}
+}
+
+func TestFallbackStoreWhenPrimaryUnavailable(t *testing.T) {
+	primary, fallback := newMemStore(), newMemStore()
+	primary.err = errors.New("blocked by policy")
+	s := &fallbackStore{primary: primary, fallback: fallback}
+
+	if err := s.Set("token", []byte("value")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	v, err := s.Get("token")
+	if err != nil || string(v) != "value" {
+		t.Errorf("expected value, got %q %v", v, err)
+	}
+	if err := s.Delete("token"); err != nil {
+		t.Errorf("expected the fallback delete to succeed, got %v", err)
+	}
+	if _, ok := fallback.values["token"]; ok {
+		t.Error("expected the fallback secret to be deleted")
+	}
+
+	primary.err = nil
+	if _, err := s.Get("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,173 @@
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"github.com/ReEnvision-AI/systray/app/datadir"
+	"github.com/danieljoos/wincred"
+	"golang.org/x/sys/windows"
+)
+
+var (
+	defaultStore     Store
+	defaultStoreOnce sync.Once
+)
+
+// Default returns the secret store backed by Windows Credential Manager,
+// falling back to DPAPI encrypted files when Credential Manager is unavailable,
+// for example when blocked by an enterprise policy.
+func Default() Store {
+	defaultStoreOnce.Do(func() {
+		defaultStore = &fallbackStore{
+			primary:  credentialManager{},
+			fallback: dpapiFiles{dir: filepath.Join(datadir.Dir(), "secrets")},
+		}
+	})
+	return defaultStore
+}
+
+// credentialManager stores secrets as generic credentials.
+type credentialManager struct{}
+
+func (credentialManager) Get(name string) ([]byte, error) {
+	cred, err := wincred.GetGenericCredential(name)
+	if err != nil {
+		if errors.Is(err, wincred.ErrElementNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return cred.CredentialBlob, nil
+}
+
+func (credentialManager) Set(name string, value []byte) error {
+	cred := wincred.NewGenericCredential(name)
+	cred.CredentialBlob = value
+	cred.Persist = wincred.PersistLocalMachine
+	return cred.Write()
+}
+
+func (credentialManager) Delete(name string) error {
+	cred, err := wincred.GetGenericCredential(name)
+	if err != nil {
+		if errors.Is(err, wincred.ErrElementNotFound) {
+			return nil
+		}
+		return err
+	}
+	return cred.Delete()
+}
+
+// dpapiFiles stores each secret in a file encrypted for the current user with DPAPI.
+type dpapiFiles struct {
+	dir string
+}
+
+func (d dpapiFiles) path(name string) string {
+	return filepath.Join(d.dir, strings.NewReplacer("/", "_", "\\", "_", ":", "_").Replace(name)+".bin")
+}
+
+func (d dpapiFiles) Get(name string) ([]byte, error) {
+	data, err := os.ReadFile(d.path(name))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return unprotect(data)
+}
+
+func (d dpapiFiles) Set(name string, value []byte) error {
+	data, err := protect(value)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(d.dir, 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(d.path(name), data, 0o600)
+}
+
+func (d dpapiFiles) Delete(name string) error {
+	if err := os.Remove(d.path(name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// fallbackStore uses the primary store and switches to the fallback when the
+// primary fails for a reason other than the secret not existing.
+type fallbackStore struct {
+	primary, fallback Store
+}
+
+func (f *fallbackStore) Get(name string) ([]byte, error) {
+	value, err := f.primary.Get(name)
+	if err == nil {
+		return value, nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		slog.Warn("credential manager unavailable, using fallback secret store", "name", name, "error", err)
+	}
+	// The secret may have been written to the fallback earlier
+	return f.fallback.Get(name)
+}
+
+func (f *fallbackStore) Set(name string, value []byte) error {
+	err := f.primary.Set(name, value)
+	if err == nil {
+		// Don't leave a stale copy behind
+		if err := f.fallback.Delete(name); err != nil {
+			slog.Debug("failed to remove fallback secret", "name", name, "error", err)
+		}
+		return nil
+	}
+	slog.Warn("credential manager unavailable, using fallback secret store", "name", name, "error", err)
+	return f.fallback.Set(name, value)
+}
+
+func (f *fallbackStore) Delete(name string) error {
+	if err := f.fallback.Delete(name); err != nil {
+		return err
+	}
+	if err := f.primary.Delete(name); err != nil {
+		// Unavailable, so the secret could only have been in the fallback
+		slog.Warn("credential manager unavailable, deleted fallback secret only", "name", name, "error", err)
+	}
+	return nil
+}
+
+func protect(data []byte) ([]byte, error) {
+	return cryptData(data, true)
+}
+
+func unprotect(data []byte) ([]byte, error) {
+	return cryptData(data, false)
+}
+
+func cryptData(data []byte, encrypt bool) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, errors.New("empty secret")
+	}
+	in := windows.DataBlob{Size: uint32(len(data)), Data: &data[0]}
+	var out windows.DataBlob
+	var err error
+	if encrypt {
+		err = windows.CryptProtectData(&in, nil, nil, 0, nil, windows.CRYPTPROTECT_UI_FORBIDDEN, &out)
+	} else {
+		err = windows.CryptUnprotectData(&in, nil, nil, 0, nil, windows.CRYPTPROTECT_UI_FORBIDDEN, &out)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("DPAPI failed: %w", err)
+	}
+	defer windows.LocalFree(windows.Handle(unsafe.Pointer(out.Data))) //nolint:errcheck
+	return append([]byte(nil), unsafe.Slice(out.Data, out.Size)...), nil
+}
@@ -1,26 +1,73 @@
 package store
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"log/slog"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 type Store struct {
-	ID           string `json:"id"`
-	FirstTimeRun bool   `json:"first-time-run"`
+	ID                        string            `json:"id"`
+	FirstTimeRun              bool              `json:"first-time-run"`
+	QuietMode                 bool              `json:"quiet-mode"`
+	TelemetryEnabled          *bool             `json:"telemetry-enabled,omitempty"` // Nil until changed, defaults to enabled
+	AnonymousMode             bool              `json:"anonymous-mode,omitempty"`    // Run without an account, contribution isn't credited
+	ActiveProfile             string            `json:"active-profile,omitempty"`    // Config profile applied over config.json, empty for none
+	APIToken                  string            `json:"api-token,omitempty"`
+	FeatureFlags              map[string]bool   `json:"feature-flags,omitempty"`               // Last flags evaluated from the server
+	StartupNotice             *bool             `json:"startup-notice,omitempty"`              // Nil until changed, defaults to enabled
+	Autostart                 *bool             `json:"autostart,omitempty"`                   // Nil until changed, defaults to enabled as the installer adds a Startup shortcut
+	AutoStartContainer        *bool             `json:"auto-start-container,omitempty"`        // Nil until changed, defaults to enabled
+	AdvancedSubmenu           *bool             `json:"advanced-submenu,omitempty"`            // Nil until changed, defaults to enabled
+	OverflowChecked           bool              `json:"overflow-checked,omitempty"`            // The hidden tray icon hint was considered
+	SeenAnnouncements         []string          `json:"seen-announcements,omitempty"`          // IDs of backend announcements already notified
+	MachineSetup              map[string]string `json:"machine-setup,omitempty"`               // Podman machine setup steps done, by name, with the machine version they ran on
+	Badges                    map[string]Badge  `json:"badges,omitempty"`                      // Contribution milestones reached, by ID
+	ContributionBeforeHistory int64             `json:"contribution-before-history,omitempty"` // Running seconds of days dropped from the contribution history
+	Organization              *Organization     `json:"organization,omitempty"`                // Nil unless the node joined an organization
+	SigningKey                string            `json:"signing-key,omitempty"`                 // Signs update checks, see auth.Sign
+	SigningKeyCreated         int64             `json:"signing-key-created,omitempty"`         // Unix seconds
+	PreviousSigningKey        string            `json:"previous-signing-key,omitempty"`        // Replaced key, until the server saw the new one
+	LastRunVersion            string            `json:"last-run-version,omitempty"`            // App version that last upgraded config.json, see SetLastRunVersion
+	PausedUntil               int64             `json:"paused-until,omitempty"`                // Unix seconds a timed pause of the node ends, 0 for none
 }
 
 var (
-	lock  sync.Mutex
-	store Store
+	lock    sync.Mutex
+	store   Store
+	damaged bool // The store file failed to parse, and a new store replaced it
+	created bool // A new store with a new node ID was created this run
+	closed  bool // Discard deleted the store, it isn't written again
 )
 
+// KnownGoodSuffix is appended to the path of a settings file for its copy
+// from the last time the node ran.
+const KnownGoodSuffix = ".lastgood"
+
+// DamagedSuffix is appended to the path of a file that failed to parse when
+// it is kept for support.
+const DamagedSuffix = ".damaged"
+
+// OnWriteError, if set, is called when the store can't be saved, e.g. as the
+// disk is full. The saved store is then left as it was.
+var OnWriteError func(err error)
+
+func writeFailed(err error) {
+	if OnWriteError != nil {
+		OnWriteError(err)
+	}
+}
+
 func GetID() string {
 	lock.Lock()
 	defer lock.Unlock()
@@ -49,6 +96,616 @@ func SetFirstTimeRun(val bool) {
 	writeStore(getStorePath())
 }
 
+// GetQuietMode reports whether the user asked for reduced motion and
+// error-only notifications.
+func GetQuietMode() bool {
+	lock.Lock()
+	defer lock.Unlock()
+	if store.ID == "" {
+		initStore()
+	}
+	return store.QuietMode
+}
+
+func SetQuietMode(val bool) {
+	lock.Lock()
+	defer lock.Unlock()
+	if store.ID == "" {
+		initStore()
+	}
+	if store.QuietMode == val {
+		return
+	}
+	store.QuietMode = val
+	writeStore(getStorePath())
+}
+
+// GetTelemetryEnabled reports whether identifying and usage data may be sent.
+func GetTelemetryEnabled() bool {
+	lock.Lock()
+	defer lock.Unlock()
+	if store.ID == "" {
+		initStore()
+	}
+	return store.TelemetryEnabled == nil || *store.TelemetryEnabled
+}
+
+func SetTelemetryEnabled(val bool) {
+	lock.Lock()
+	defer lock.Unlock()
+	if store.ID == "" {
+		initStore()
+	}
+	if store.TelemetryEnabled != nil && *store.TelemetryEnabled == val {
+		return
+	}
+	store.TelemetryEnabled = &val
+	writeStore(getStorePath())
+}
+
+// GetAnonymousMode reports whether the user chose to run the node without
+// an account.
+func GetAnonymousMode() bool {
+	lock.Lock()
+	defer lock.Unlock()
+	if store.ID == "" {
+		initStore()
+	}
+	return store.AnonymousMode
+}
+
+func SetAnonymousMode(val bool) {
+	lock.Lock()
+	defer lock.Unlock()
+	if store.ID == "" {
+		initStore()
+	}
+	if store.AnonymousMode == val {
+		return
+	}
+	store.AnonymousMode = val
+	writeStore(getStorePath())
+}
+
+// GetActiveProfile returns the name of the config profile in use, or "" for
+// the default config.
+func GetActiveProfile() string {
+	lock.Lock()
+	defer lock.Unlock()
+	if store.ID == "" {
+		initStore()
+	}
+	return store.ActiveProfile
+}
+
+func SetActiveProfile(name string) {
+	lock.Lock()
+	defer lock.Unlock()
+	if store.ID == "" {
+		initStore()
+	}
+	if store.ActiveProfile == name {
+		return
+	}
+	store.ActiveProfile = name
+	writeStore(getStorePath())
+}
+
+// GetStartupNotice reports whether to show a notification and badge while the
+// node starts after login.
+func GetStartupNotice() bool {
+	lock.Lock()
+	defer lock.Unlock()
+	if store.ID == "" {
+		initStore()
+	}
+	return store.StartupNotice == nil || *store.StartupNotice
+}
+
+func SetStartupNotice(val bool) {
+	lock.Lock()
+	defer lock.Unlock()
+	if store.ID == "" {
+		initStore()
+	}
+	if store.StartupNotice != nil && *store.StartupNotice == val {
+		return
+	}
+	store.StartupNotice = &val
+	writeStore(getStorePath())
+}
+
+// GetAutostart reports whether the app starts when the user logs in.
+func GetAutostart() bool {
+	lock.Lock()
+	defer lock.Unlock()
+	if store.ID == "" {
+		initStore()
+	}
+	return store.Autostart == nil || *store.Autostart
+}
+
+func SetAutostart(val bool) {
+	lock.Lock()
+	defer lock.Unlock()
+	if store.ID == "" {
+		initStore()
+	}
+	if store.Autostart != nil && *store.Autostart == val {
+		return
+	}
+	store.Autostart = &val
+	writeStore(getStorePath())
+}
+
+// GetAutoStartContainer reports whether the node starts when the app starts.
+func GetAutoStartContainer() bool {
+	lock.Lock()
+	defer lock.Unlock()
+	if store.ID == "" {
+		initStore()
+	}
+	return store.AutoStartContainer == nil || *store.AutoStartContainer
+}
+
+func SetAutoStartContainer(val bool) {
+	lock.Lock()
+	defer lock.Unlock()
+	if store.ID == "" {
+		initStore()
+	}
+	if store.AutoStartContainer != nil && *store.AutoStartContainer == val {
+		return
+	}
+	store.AutoStartContainer = &val
+	writeStore(getStorePath())
+}
+
+// GetAdvancedSubmenu reports whether rarely used tray menu entries are grouped
+// under an Advanced submenu. Applied the next time the app starts.
+func GetAdvancedSubmenu() bool {
+	lock.Lock()
+	defer lock.Unlock()
+	if store.ID == "" {
+		initStore()
+	}
+	return store.AdvancedSubmenu == nil || *store.AdvancedSubmenu
+}
+
+func SetAdvancedSubmenu(val bool) {
+	lock.Lock()
+	defer lock.Unlock()
+	if store.ID == "" {
+		initStore()
+	}
+	if store.AdvancedSubmenu != nil && *store.AdvancedSubmenu == val {
+		return
+	}
+	store.AdvancedSubmenu = &val
+	writeStore(getStorePath())
+}
+
+// GetOverflowChecked reports whether the app already checked if its tray
+// icon landed in the hidden icons area.
+func GetOverflowChecked() bool {
+	lock.Lock()
+	defer lock.Unlock()
+	if store.ID == "" {
+		initStore()
+	}
+	return store.OverflowChecked
+}
+
+func SetOverflowChecked(val bool) {
+	lock.Lock()
+	defer lock.Unlock()
+	if store.ID == "" {
+		initStore()
+	}
+	if store.OverflowChecked == val {
+		return
+	}
+	store.OverflowChecked = val
+	writeStore(getStorePath())
+}
+
+// GetSeenAnnouncements returns the IDs of the backend announcements the user
+// was already notified about.
+func GetSeenAnnouncements() []string {
+	lock.Lock()
+	defer lock.Unlock()
+	if store.ID == "" {
+		initStore()
+	}
+	return slices.Clone(store.SeenAnnouncements)
+}
+
+func SetSeenAnnouncements(ids []string) {
+	lock.Lock()
+	defer lock.Unlock()
+	if store.ID == "" {
+		initStore()
+	}
+	if slices.Equal(store.SeenAnnouncements, ids) {
+		return
+	}
+	store.SeenAnnouncements = slices.Clone(ids)
+	writeStore(getStorePath())
+}
+
+// GetMachineSetup returns what the Podman machine setup step name last ran
+// on, empty if it never did.
+func GetMachineSetup(name string) string {
+	lock.Lock()
+	defer lock.Unlock()
+	if store.ID == "" {
+		initStore()
+	}
+	return store.MachineSetup[name]
+}
+
+func SetMachineSetup(name, version string) {
+	lock.Lock()
+	defer lock.Unlock()
+	if store.ID == "" {
+		initStore()
+	}
+	if store.MachineSetup[name] == version {
+		return
+	}
+	if store.MachineSetup == nil {
+		store.MachineSetup = map[string]string{}
+	}
+	store.MachineSetup[name] = version
+	writeStore(getStorePath())
+}
+
+// Badge is a contribution milestone the node reached.
+type Badge struct {
+	AwardedAt time.Time `json:"awarded-at"`
+	Synced    bool      `json:"synced,omitempty"` // Recorded on the backend
+}
+
+// GetBadges returns the badges awarded so far, by milestone ID.
+func GetBadges() map[string]Badge {
+	lock.Lock()
+	defer lock.Unlock()
+	if store.ID == "" {
+		initStore()
+	}
+	return maps.Clone(store.Badges)
+}
+
+// AwardBadge records that the milestone id was reached at. Returns false if
+// it was awarded before.
+func AwardBadge(id string, at time.Time) bool {
+	lock.Lock()
+	defer lock.Unlock()
+	if store.ID == "" {
+		initStore()
+	}
+	if _, ok := store.Badges[id]; ok {
+		return false
+	}
+	if store.Badges == nil {
+		store.Badges = map[string]Badge{}
+	}
+	store.Badges[id] = Badge{AwardedAt: at}
+	writeStore(getStorePath())
+	return true
+}
+
+// SetBadgeSynced records that the backend received the badge id.
+func SetBadgeSynced(id string) {
+	lock.Lock()
+	defer lock.Unlock()
+	if store.ID == "" {
+		initStore()
+	}
+	badge, ok := store.Badges[id]
+	if !ok || badge.Synced {
+		return
+	}
+	badge.Synced = true
+	store.Badges[id] = badge
+	writeStore(getStorePath())
+}
+
+// GetContributionBeforeHistory returns the running seconds of the days
+// dropped from the contribution history.
+func GetContributionBeforeHistory() int64 {
+	lock.Lock()
+	defer lock.Unlock()
+	if store.ID == "" {
+		initStore()
+	}
+	return store.ContributionBeforeHistory
+}
+
+func AddContributionBeforeHistory(seconds int64) {
+	lock.Lock()
+	defer lock.Unlock()
+	if store.ID == "" {
+		initStore()
+	}
+	if seconds == 0 {
+		return
+	}
+	store.ContributionBeforeHistory += seconds
+	writeStore(getStorePath())
+}
+
+// Organization is the organization the node pools its contribution with.
+type Organization struct {
+	ID     string          `json:"id"`
+	Name   string          `json:"name"`
+	Policy json.RawMessage `json:"policy,omitempty"` // Last policy fetched from the backend
+}
+
+// GetOrganization returns the organization of the node, nil if none.
+func GetOrganization() *Organization {
+	lock.Lock()
+	defer lock.Unlock()
+	if store.ID == "" {
+		initStore()
+	}
+	if store.Organization == nil {
+		return nil
+	}
+	org := *store.Organization
+	org.Policy = slices.Clone(org.Policy)
+	return &org
+}
+
+// SetOrganization replaces the organization of the node, nil to leave it.
+func SetOrganization(org *Organization) {
+	lock.Lock()
+	defer lock.Unlock()
+	if store.ID == "" {
+		initStore()
+	}
+	if org != nil {
+		clone := *org
+		clone.Policy = slices.Clone(org.Policy)
+		org = &clone
+	}
+	store.Organization = org
+	writeStore(getStorePath())
+}
+
+// GetAPIToken returns the token that clients of the local control API must
+// present, generating one on first use.
+func GetAPIToken() string {
+	lock.Lock()
+	defer lock.Unlock()
+	if store.ID == "" {
+		initStore()
+	}
+	if store.APIToken == "" {
+		store.APIToken = newAPIToken()
+		writeStore(getStorePath())
+	}
+	return store.APIToken
+}
+
+// RotateAPIToken replaces the local control API token, invalidating the old one.
+func RotateAPIToken() string {
+	lock.Lock()
+	defer lock.Unlock()
+	if store.ID == "" {
+		initStore()
+	}
+	store.APIToken = newAPIToken()
+	writeStore(getStorePath())
+	return store.APIToken
+}
+
+func newAPIToken() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand doesn't fail on supported platforms
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// GetLastRunVersion returns the app version that last ran, empty if the
+// store is new or from a release before versions were recorded.
+func GetLastRunVersion() string {
+	lock.Lock()
+	defer lock.Unlock()
+	if store.ID == "" {
+		initStore()
+	}
+	return store.LastRunVersion
+}
+
+// SetLastRunVersion records the app version running, once config.json was
+// upgraded for it.
+func SetLastRunVersion(version string) {
+	lock.Lock()
+	defer lock.Unlock()
+	if store.ID == "" {
+		initStore()
+	}
+	if store.LastRunVersion == version {
+		return
+	}
+	store.LastRunVersion = version
+	writeStore(getStorePath())
+}
+
+// GetPausedUntil returns when the timed pause of the node ends, zero if it
+// isn't paused for a time.
+func GetPausedUntil() time.Time {
+	lock.Lock()
+	defer lock.Unlock()
+	if store.ID == "" {
+		initStore()
+	}
+	if store.PausedUntil == 0 {
+		return time.Time{}
+	}
+	return time.Unix(store.PausedUntil, 0)
+}
+
+// SetPausedUntil records when the timed pause of the node ends, zero to
+// clear it.
+func SetPausedUntil(until time.Time) {
+	lock.Lock()
+	defer lock.Unlock()
+	if store.ID == "" {
+		initStore()
+	}
+	var seconds int64
+	if !until.IsZero() {
+		seconds = until.Unix()
+	}
+	if store.PausedUntil == seconds {
+		return
+	}
+	store.PausedUntil = seconds
+	writeStore(getStorePath())
+}
+
+// GetSigningKeys returns the key signing requests to the app's endpoints,
+// when it was created, and the key it replaced if the server may not know
+// the current one yet. The current key is empty until SetSigningKey.
+func GetSigningKeys() (current string, created time.Time, previous string) {
+	lock.Lock()
+	defer lock.Unlock()
+	if store.ID == "" {
+		initStore()
+	}
+	return store.SigningKey, time.Unix(store.SigningKeyCreated, 0), store.PreviousSigningKey
+}
+
+// SetSigningKey replaces the signing key, keeping the current one as the
+// previous key.
+func SetSigningKey(key string, created time.Time) {
+	lock.Lock()
+	defer lock.Unlock()
+	if store.ID == "" {
+		initStore()
+	}
+	if store.SigningKey != "" {
+		store.PreviousSigningKey = store.SigningKey
+	}
+	store.SigningKey = key
+	store.SigningKeyCreated = created.Unix()
+	writeStore(getStorePath())
+}
+
+// ForgetPreviousSigningKey drops the previous signing key, once the server
+// accepted the current one.
+func ForgetPreviousSigningKey() {
+	lock.Lock()
+	defer lock.Unlock()
+	if store.ID == "" {
+		initStore()
+	}
+	if store.PreviousSigningKey == "" {
+		return
+	}
+	store.PreviousSigningKey = ""
+	writeStore(getStorePath())
+}
+
+// GetFeatureFlags returns a copy of the cached server-side feature flags.
+func GetFeatureFlags() map[string]bool {
+	lock.Lock()
+	defer lock.Unlock()
+	if store.ID == "" {
+		initStore()
+	}
+	return maps.Clone(store.FeatureFlags)
+}
+
+func SetFeatureFlags(flags map[string]bool) {
+	lock.Lock()
+	defer lock.Unlock()
+	if store.ID == "" {
+		initStore()
+	}
+	if maps.Equal(store.FeatureFlags, flags) {
+		return
+	}
+	store.FeatureFlags = maps.Clone(flags)
+	writeStore(getStorePath())
+}
+
+// Settings are the user preferences that move along with the node when it
+// is migrated to another machine.
+type Settings struct {
+	QuietMode        bool  `json:"quiet-mode"`
+	TelemetryEnabled *bool `json:"telemetry-enabled,omitempty"`
+	AnonymousMode    bool  `json:"anonymous-mode,omitempty"`
+	StartupNotice    *bool `json:"startup-notice,omitempty"`
+	AdvancedSubmenu  *bool `json:"advanced-submenu,omitempty"`
+}
+
+// GetSettings returns the settings exported for a node migration.
+func GetSettings() Settings {
+	lock.Lock()
+	defer lock.Unlock()
+	if store.ID == "" {
+		initStore()
+	}
+	return Settings{
+		QuietMode:        store.QuietMode,
+		TelemetryEnabled: store.TelemetryEnabled,
+		AnonymousMode:    store.AnonymousMode,
+		StartupNotice:    store.StartupNotice,
+		AdvancedSubmenu:  store.AdvancedSubmenu,
+	}
+}
+
+// ImportNode takes over the node ID and settings exported on another
+// machine. Values tied to this machine, like the API token, are kept.
+func ImportNode(id string, settings Settings) {
+	lock.Lock()
+	defer lock.Unlock()
+	if store.ID == "" {
+		initStore()
+	}
+	slog.Info("importing node", "previous_id", store.ID, "id", id)
+	store.ID = id
+	created = false
+	store.QuietMode = settings.QuietMode
+	store.TelemetryEnabled = settings.TelemetryEnabled
+	store.AnonymousMode = settings.AnonymousMode
+	store.StartupNotice = settings.StartupNotice
+	store.AdvancedSubmenu = settings.AdvancedSubmenu
+	store.FeatureFlags = nil // Rollouts are bucketed by node ID
+	writeStore(getStorePath())
+}
+
+// Reset deletes the store file, its known good copy and a damaged one kept
+// for support, and forgets all values, including the node ID.
+// A new store is created the next time a value is read.
+func Reset() error {
+	lock.Lock()
+	defer lock.Unlock()
+	store = Store{}
+	for _, path := range []string{getStorePath(), getStorePath() + KnownGoodSuffix, getStorePath() + DamagedSuffix} {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	slog.Info("store reset")
+	return nil
+}
+
+// Discard deletes the store like Reset, and stops saving it until the app
+// exits, so that values read meanwhile, like the node ID, don't recreate it.
+// Used when the user deleted their data and the app is about to exit.
+func Discard() error {
+	lock.Lock()
+	closed = true
+	lock.Unlock()
+	return Reset()
+}
+
 func initStore() {
 	storePath := getStorePath()
 	storeFile, err := os.Open(storePath)
@@ -58,8 +715,15 @@ func initStore() {
 			slog.Debug("loaded existing store", "path", storePath, "id", store.ID)
 			return // Successfully loaded and decoded
 		}
-		// Decoding failed, file is likely corrupt
+		// Decoding failed, file is likely corrupt. It's kept for support, and
+		// the known good copy may be restored, see RestoreKnownGood
 		slog.Warn("failed to decode store file, creating a new one", "path", storePath, "error", err)
+		storeFile.Close()
+		if err := os.Rename(storePath, storePath+DamagedSuffix); err != nil {
+			slog.Warn("failed to keep damaged store", "path", storePath, "error", err)
+		}
+		store = Store{}
+		damaged = true
 	} else if !errors.Is(err, os.ErrNotExist) {
 		// File could not be opened for a reason other than not existing
 		slog.Warn("unexpected error opening store, creating a new one", "path", storePath, "error", err)
@@ -68,10 +732,117 @@ func initStore() {
 	// If we get here, we need to create a new store
 	slog.Debug("initializing new store")
 	store.ID = uuid.NewString()
+	created = true
 	writeStore(storePath)
 }
 
+// Path returns the path of the store file.
+func Path() string {
+	return getStorePath()
+}
+
+// Damaged reports whether the store failed to parse when the app started, so
+// the node runs with a new ID.
+func Damaged() bool {
+	lock.Lock()
+	defer lock.Unlock()
+	if store.ID == "" {
+		initStore()
+	}
+	return damaged
+}
+
+// Created reports whether the store was created when the app started, as
+// there was none or it failed to parse, so the node runs with a new ID.
+func Created() bool {
+	lock.Lock()
+	defer lock.Unlock()
+	if store.ID == "" {
+		initStore()
+	}
+	return created
+}
+
+// SaveKnownGood keeps a copy of the store, once the node ran with it.
+func SaveKnownGood() error {
+	lock.Lock()
+	defer lock.Unlock()
+	if closed {
+		return nil
+	}
+	if store.ID == "" {
+		initStore()
+	}
+	payload, err := json.Marshal(store)
+	if err != nil {
+		return err
+	}
+	return writeFile(getStorePath()+KnownGoodSuffix, payload)
+}
+
+// KnownGoodID returns the node ID of the known good copy of the store, with
+// when it was saved.
+func KnownGoodID() (string, time.Time, error) {
+	known, saved, err := readKnownGood()
+	return known.ID, saved, err
+}
+
+// RestoreKnownGood replaces the store with its known good copy, including
+// the node ID.
+func RestoreKnownGood() error {
+	known, _, err := readKnownGood()
+	if err != nil {
+		return err
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	slog.Info("restoring known good store", "previous_id", store.ID, "id", known.ID)
+	store = known
+	damaged, created = false, false
+	writeStore(getStorePath())
+	return nil
+}
+
+func readKnownGood() (Store, time.Time, error) {
+	path := getStorePath() + KnownGoodSuffix
+	info, err := os.Stat(path)
+	if err != nil {
+		return Store{}, time.Time{}, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Store{}, time.Time{}, err
+	}
+	var known Store
+	if err := json.Unmarshal(data, &known); err != nil {
+		return Store{}, time.Time{}, err
+	}
+	if known.ID == "" {
+		return Store{}, time.Time{}, errors.New("known good store has no node ID")
+	}
+	return known, info.ModTime(), nil
+}
+
+// writeFile replaces the file at path with payload. It's written to a
+// temporary file first, so a full disk leaves the previous file intact
+// instead of a truncated one.
+func writeFile(path string, payload []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, payload, 0o755); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
 func writeStore(storeFilename string) {
+	if closed {
+		return
+	}
 	reaiDir := filepath.Dir(storeFilename)
 	_, err := os.Stat(reaiDir)
 	if errors.Is(err, os.ErrNotExist) {
@@ -86,14 +857,9 @@ func writeStore(storeFilename string) {
 		slog.Error("failed to marshal store", "error", err)
 		return
 	}
-	fp, err := os.OpenFile(storeFilename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o755)
-	if err != nil {
+	if err := writeFile(storeFilename, payload); err != nil {
 		slog.Error("failed to write store", "path", storeFilename, "error", err)
-		return
-	}
-	defer fp.Close()
-	if n, err := fp.Write(payload); err != nil || n != len(payload) {
-		slog.Error("failed to write store payload", "path", storeFilename, "bytes_written", n, "payload_length", len(payload), "error", err)
+		writeFailed(err)
 		return
 	}
 
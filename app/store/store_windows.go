@@ -1,11 +1,11 @@
 package store
 
 import (
-	"os"
 	"path/filepath"
+
+	"github.com/ReEnvision-AI/systray/app/datadir"
 )
 
 func getStorePath() string {
-	localAppData := os.Getenv("LOCALAPPDATA")
-	return filepath.Join(localAppData, "ReEnvision AI", "config.json")
+	return filepath.Join(datadir.Dir(), "config.json")
 }
@@ -0,0 +1,81 @@
+package commontray
+
+// Keys of the declarative menu entries, used by backends to update an entry
+const (
+	MenuShowLogs        = "show-logs"
+	MenuShowLiveLogs    = "show-live-logs"
+	MenuCopyDiagnostics = "copy-diagnostics"
+	MenuSupportBundle   = "support-bundle"
+	MenuRecreateCache   = "recreate-cache"
+	MenuQuietMode       = "quiet-mode"
+	MenuAutostart       = "autostart"
+	MenuAutoRun         = "auto-run"
+	MenuStartupNotice   = "startup-notice"
+	MenuGroupAdvanced   = "group-advanced"
+	MenuTelemetry       = "telemetry"
+	MenuAnonymous       = "anonymous-mode"
+	MenuExportData      = "export-data"
+	MenuDeleteData      = "delete-data"
+	MenuShowAPIToken    = "show-api-token"
+	MenuSupportAccess   = "support-access"
+	MenuMoveNode        = "move-node"
+	MenuImportNode      = "import-node"
+	MenuJoinOrg         = "join-org"
+	MenuExportStats     = "export-stats"
+	MenuSettings        = "settings"
+	MenuProfiles        = "profiles"
+	MenuAdvanced        = "advanced"
+	MenuShowAbout       = "show-about"
+	MenuQuit            = "quit"
+	menuActionSeparator = "actions-separator"
+)
+
+// MenuItem is an entry of the tray menu below the status and Start/Stop
+// entries, which backends manage themselves as they follow the app state.
+type MenuItem struct {
+	Key       string
+	Title     string        // '&' marks the keyboard mnemonic
+	Action    chan struct{} // Signalled when the entry is chosen, nil for separators and submenus
+	Advanced  bool          // Moved into the Advanced submenu when Options.AdvancedSubmenu is set
+	Separator bool
+	Submenu   bool // Entries are set by the backend at runtime, like the profiles
+}
+
+// Options control how a backend renders the menu.
+type Options struct {
+	AdvancedSubmenu bool // Group rarely used entries under an Advanced submenu
+}
+
+// Menu returns the menu entries in display order. The MenuAdvanced entry
+// marks where the Advanced submenu goes and is only rendered when
+// Options.AdvancedSubmenu is set.
+func Menu(cb Callbacks) []MenuItem {
+	return []MenuItem{
+		{Key: MenuShowLiveLogs, Title: "Show &live logs...", Action: cb.ShowLiveLogs},
+		{Key: MenuShowLogs, Title: "&View logs", Action: cb.ShowLogs, Advanced: true},
+		{Key: MenuCopyDiagnostics, Title: "&Copy diagnostics", Action: cb.CopyDiagnostics, Advanced: true},
+		{Key: MenuSupportBundle, Title: "&Generate support bundle", Action: cb.SupportBundle, Advanced: true},
+		{Key: MenuRecreateCache, Title: "Recreate cache &volume", Action: cb.RecreateCache, Advanced: true},
+		{Key: MenuSettings, Title: "S&ettings...", Action: cb.Settings},
+		{Key: MenuProfiles, Title: "&Profile", Submenu: true},
+		{Key: MenuQuietMode, Title: "Quiet &mode", Action: cb.ToggleQuiet},
+		{Key: MenuAutostart, Title: "Start wit&h Windows", Action: cb.ToggleAutostart},
+		{Key: MenuAutoRun, Title: "Start node at la&unch", Action: cb.ToggleAutoRun},
+		{Key: MenuStartupNotice, Title: "Startup &notification", Action: cb.StartupNotice},
+		{Key: MenuGroupAdvanced, Title: "Group ra&rely used entries", Action: cb.GroupAdvanced},
+		{Key: MenuTelemetry, Title: "Send &telemetry", Action: cb.ToggleTelemetry},
+		{Key: MenuAnonymous, Title: "Run &anonymously", Action: cb.ToggleAnonymous},
+		{Key: MenuJoinOrg, Title: "Join an &organization...", Action: cb.JoinOrg},
+		{Key: MenuExportData, Title: "Do&wnload my data", Action: cb.ExportData},
+		{Key: MenuDeleteData, Title: "&Delete my account data", Action: cb.DeleteData},
+		{Key: MenuExportStats, Title: "Export &statistics...", Action: cb.ExportStats, Advanced: true},
+		{Key: MenuShowAPIToken, Title: "Show &API token...", Action: cb.ShowAPIToken, Advanced: true},
+		{Key: MenuSupportAccess, Title: "Allow &support access...", Action: cb.SupportAccess, Advanced: true},
+		{Key: MenuMoveNode, Title: "&Move this node to another machine...", Action: cb.MoveNode, Advanced: true},
+		{Key: MenuImportNode, Title: "&Import a node...", Action: cb.ImportNode, Advanced: true},
+		{Key: MenuAdvanced, Title: "Ad&vanced"},
+		{Key: menuActionSeparator, Separator: true},
+		{Key: MenuShowAbout, Title: "A&bout ReEnvision AI", Action: cb.ShowAbout},
+		{Key: MenuQuit, Title: "&Quit ReEnvision AI", Action: cb.Quit},
+	}
+}
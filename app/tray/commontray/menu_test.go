@@ -0,0 +1,53 @@
+//go:build unit_test
+
+package commontray
+
+import "testing"
+
+func TestMenuSpec(t *testing.T) {
+	cb := Callbacks{
+		Quit:            make(chan struct{}),
+		ShowLogs:        make(chan struct{}),
+		ShowLiveLogs:    make(chan struct{}),
+		CopyDiagnostics: make(chan struct{}),
+		SupportBundle:   make(chan struct{}),
+		ToggleQuiet:     make(chan struct{}),
+		ToggleAutostart: make(chan struct{}),
+		ToggleAutoRun:   make(chan struct{}),
+		StartupNotice:   make(chan struct{}),
+		GroupAdvanced:   make(chan struct{}),
+		RecreateCache:   make(chan struct{}),
+		ToggleTelemetry: make(chan struct{}),
+		ExportData:      make(chan struct{}),
+		DeleteData:      make(chan struct{}),
+		ShowAPIToken:    make(chan struct{}),
+		ShowAbout:       make(chan struct{}),
+		SupportAccess:   make(chan struct{}),
+		MoveNode:        make(chan struct{}),
+		ImportNode:      make(chan struct{}),
+		JoinOrg:         make(chan struct{}),
+		ExportStats:     make(chan struct{}),
+		Settings:        make(chan struct{}),
+		ToggleAnonymous: make(chan struct{}),
+	}
+
+	seen := map[string]bool{}
+	for _, item := range Menu(cb) {
+		if seen[item.Key] {
+			t.Errorf("duplicate menu key %s", item.Key)
+		}
+		seen[item.Key] = true
+
+		switch {
+		case item.Separator, item.Submenu, item.Key == MenuAdvanced:
+			if item.Action != nil {
+				t.Errorf("menu entry %s should not have an action", item.Key)
+			}
+		case item.Action == nil:
+			t.Errorf("menu entry %s has no action", item.Key)
+		}
+	}
+	if !seen[MenuAdvanced] {
+		t.Error("menu spec is missing the Advanced submenu entry")
+	}
+}
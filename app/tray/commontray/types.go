@@ -1,20 +1,105 @@
 package commontray
 
+import "time"
+
 var (
 	Title   = "ReEnvision AI"
 	Tooltip = "ReEnvision AI"
 
-	UpdateIconName = "reai_update"
-	IconName       = "reai"
+	UpdateIconName   = "reai_update"
+	StartingIconName = "reai_starting"
+	RunningIconName  = "reai_running"
+	StoppedIconName  = "reai_stopped"
+	ErrorIconName    = "reai_error"
+	IconName         = "reai"
+)
+
+// StartingFrameCount is the number of frames of the animated starting icon,
+// named after StartingIconName with the suffixes _1 to _4.
+const StartingFrameCount = 4
+
+// Icons are the images the tray icon switches between.
+type Icons struct {
+	Normal         []byte
+	Update         []byte // Shown while an update is pending
+	Starting       []byte
+	StartingFrames [][]byte // Cycled while the node starts or stops, if any
+	Running        []byte
+	Stopped        []byte
+	Error          []byte
+}
+
+// IconState selects the tray icon showing what the node is doing.
+type IconState int
+
+const (
+	IconStopped  IconState = iota
+	IconStarting           // Also while loading or stopping
+	IconRunning
+	IconError
 )
 
+// Choices of the timed pause sent on Callbacks.PauseFor
+const (
+	PauseFor30Minutes  = "30m"
+	PauseForHour       = "1h"
+	PauseUntilTomorrow = "tomorrow"
+)
+
+type NotificationLevel int
+
+const (
+	NotifyInfo NotificationLevel = iota
+	NotifyWarning
+	NotifyError
+)
+
+// StatusInfo is the node status shown in the tray's status popup.
+type StatusInfo struct {
+	State        string
+	RunningSince time.Time     // Zero unless running
+	Throughput   string        // Empty when unknown
+	Progress     string        // What the server's output showed, like the blocks it serves, empty when unknown
+	Contributed  time.Duration // Time the node ran today
+	CanStart     bool
+	CanStop      bool
+	Anonymous    bool // Contribution isn't credited to an account
+}
+
 type Callbacks struct {
-	Quit           chan struct{}
-	Update         chan struct{}
-	DoFirstUse     chan struct{}
-	ShowLogs       chan struct{}
-	StartContainer chan struct{}
-	StopContainer  chan struct{}
+	Quit            chan struct{}
+	Update          chan struct{}
+	ReleaseNotes    chan struct{}
+	DoFirstUse      chan struct{}
+	ShowLogs        chan struct{}
+	ShowLiveLogs    chan struct{}
+	CopyDiagnostics chan struct{}
+	SupportBundle   chan struct{}
+	StartContainer  chan struct{}
+	StopContainer   chan struct{}
+	Restart         chan struct{}
+	Pause           chan struct{}
+	Resume          chan struct{}
+	PauseFor        chan string // One of the PauseFor choices
+	ToggleQuiet     chan struct{}
+	ToggleAutostart chan struct{}
+	ToggleAutoRun   chan struct{} // Whether the node starts when the app starts
+	StartupNotice   chan struct{} // Toggles the notification when starting at login
+	GroupAdvanced   chan struct{} // Toggles the Advanced submenu, applied on the next launch
+	RecreateCache   chan struct{}
+	ToggleTelemetry chan struct{}
+	ExportData      chan struct{}
+	DeleteData      chan struct{}
+	ShowAPIToken    chan struct{}
+	ShowAbout       chan struct{}
+	SupportAccess   chan struct{}
+	MoveNode        chan struct{}
+	ImportNode      chan struct{}
+	JoinOrg         chan struct{}
+	ExportStats     chan struct{}
+	Settings        chan struct{}
+	ToggleAnonymous chan struct{}
+	SelectProfile   chan string // Name of the chosen config profile, "" for the default
 }
 
 type ReaiTray interface {
@@ -22,8 +107,30 @@ type ReaiTray interface {
 	Run()
 	UpdateAvailable(ver string) error
 	DisplayFirstUseNotification() error
+	DisplayNotification(title, message string, level NotificationLevel) error
+	DisplayActionNotification(title, message string, level NotificationLevel, action chan struct{}) error
 	ChangeStatusText(text string) error
+	SetTooltip(text string) error
+	SetStatusInfo(info StatusInfo) error
+	SetQuietMode(quiet bool) error
+	SetAutostart(enabled bool) error
+	SetAutoRun(enabled bool) error
+	SetStartupNotice(enabled bool) error
+	SetAdvancedSubmenu(enabled bool) error
+	SetTelemetryEnabled(enabled bool) error
+	SetAnonymousMode(anonymous bool) error
+	SetProfiles(names []string, active string) error
+	SetSupportAccess(remaining time.Duration) error
+	SetAnnouncement(text string) error
+	SetGPUStatus(text string) error
+	SetStarting() error
+	ShowStartingBadge(show bool) error
+	SetIconState(state IconState) error
+	IconHidden() (bool, error)
+	CanPinIcon() bool
+	PinIcon() error
 	SetStarted() error
 	SetStopped() error
+	SetPaused() error // The node is running but paused
 	Quit()
 }
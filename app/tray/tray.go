@@ -8,21 +8,38 @@ import (
 	"github.com/ReEnvision-AI/systray/app/tray/commontray"
 )
 
-func NewTray() (commontray.ReaiTray, error) {
+func NewTray(opts commontray.Options) (commontray.ReaiTray, error) {
 	extension := ".png"
 	if runtime.GOOS == "windows" {
 		extension = ".ico"
 	}
-	iconName := commontray.UpdateIconName + extension
-	updateIcon, err := assets.GetIcon(iconName)
-	if err != nil {
-		return nil, fmt.Errorf("failed to load icon %s: %w", iconName, err)
+	var icons commontray.Icons
+	for _, icon := range []struct {
+		name string
+		data *[]byte
+	}{
+		{commontray.IconName, &icons.Normal},
+		{commontray.UpdateIconName, &icons.Update},
+		{commontray.StartingIconName, &icons.Starting},
+		{commontray.RunningIconName, &icons.Running},
+		{commontray.StoppedIconName, &icons.Stopped},
+		{commontray.ErrorIconName, &icons.Error},
+	} {
+		iconName := icon.name + extension
+		data, err := assets.GetIcon(iconName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load icon %s: %w", iconName, err)
+		}
+		*icon.data = data
 	}
-	iconName = commontray.IconName + extension
-	icon, err := assets.GetIcon(iconName)
-	if err != nil {
-		return nil, fmt.Errorf("failed to load icon %s: %w", iconName, err)
+	for i := range commontray.StartingFrameCount {
+		iconName := fmt.Sprintf("%s_%d%s", commontray.StartingIconName, i+1, extension)
+		data, err := assets.GetIcon(iconName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load icon %s: %w", iconName, err)
+		}
+		icons.StartingFrames = append(icons.StartingFrames, data)
 	}
 
-	return InitPlatformTray(icon, updateIcon)
+	return InitPlatformTray(icons, opts)
 }
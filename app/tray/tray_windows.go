@@ -5,6 +5,6 @@ import (
 	"github.com/ReEnvision-AI/systray/app/tray/wintray"
 )
 
-func InitPlatformTray(icon, updateIcon []byte) (commontray.ReaiTray, error) {
-	return wintray.InitTray(icon, updateIcon)
-}
\ No newline at end of file
+func InitPlatformTray(icons commontray.Icons, opts commontray.Options) (commontray.ReaiTray, error) {
+	return wintray.InitTray(icons, opts)
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log/slog"
 	"sync"
+	"time"
 	"unsafe"
 
 	"github.com/ReEnvision-AI/systray/app/power"
@@ -51,30 +52,35 @@ func nativeLoop() {
 // https://msdn.microsoft.com/en-us/library/windows/desktop/ms633573(v=vs.85).aspx
 func (t *winTray) wndProc(hWnd windows.Handle, message uint32, wParam, lParam uintptr) (lResult uintptr) {
 	const (
-		WM_RBUTTONUP       = 0x0205
-		WM_LBUTTONUP       = 0x0202
-		WM_COMMAND         = 0x0111
-		WM_ENDSESSION      = 0x0016
-		WM_CLOSE           = 0x0010
-		WM_DESTROY         = 0x0002
-		WM_MOUSEMOVE       = 0x0200
-		WM_LBUTTONDOWN     = 0x0201
-		WM_POWERBROADCAST  = 0x0218
-		PBT_APMSUSPEND     = 0x0004
-		PBT_APMRESUMEAUTO  = 0x0012
+		WM_RBUTTONUP         = 0x0205
+		WM_LBUTTONUP         = 0x0202
+		WM_COMMAND           = 0x0111
+		WM_CONTEXTMENU       = 0x007B
+		WM_ENDSESSION        = 0x0016
+		WM_CLOSE             = 0x0010
+		WM_DESTROY           = 0x0002
+		WM_MOUSEMOVE         = 0x0200
+		WM_LBUTTONDOWN       = 0x0201
+		WM_POWERBROADCAST    = 0x0218
+		PBT_APMSUSPEND       = 0x0004
+		PBT_APMRESUMEAUTO    = 0x0012
 		PBT_APMRESUMESUSPEND = 0x0007
+		NIN_KEYSELECT        = WM_USER + 1
+		NIN_BALLOONSHOW      = WM_USER + 2
+		NIN_BALLOONTIMEOUT   = WM_USER + 4
+		NIN_BALLOONUSERCLICK = WM_USER + 5
 	)
 	switch message {
 	case WM_COMMAND:
 		menuItemId := int32(wParam)
 		// https://docs.microsoft.com/en-us/windows/win32/menurc/wm-command#menus
 		switch menuItemId {
-		case quitMenuID:
+		case updateAvailableMenuID:
 			select {
-			case t.callbacks.Quit <- struct{}{}:
+			case t.callbacks.ReleaseNotes <- struct{}{}:
 			// should not happen but in case not listening
 			default:
-				slog.Error("no listener on Quit")
+				slog.Error("no listener on ReleaseNotes")
 			}
 		case updateMenuID:
 			select {
@@ -83,13 +89,6 @@ func (t *winTray) wndProc(hWnd windows.Handle, message uint32, wParam, lParam ui
 			default:
 				slog.Error("no listener on Update")
 			}
-		case diagLogsMenuID:
-			select {
-			case t.callbacks.ShowLogs <- struct{}{}:
-			// should not happen but in case not listening
-			default:
-				slog.Error("no listener on ShowLogs")
-			}
 		case startMenuID:
 			select {
 			case t.callbacks.StartContainer <- struct{}{}:
@@ -104,10 +103,62 @@ func (t *winTray) wndProc(hWnd windows.Handle, message uint32, wParam, lParam ui
 			default:
 				slog.Error("no listener on StopContainer")
 			}
+		case restartMenuID:
+			select {
+			case t.callbacks.Restart <- struct{}{}:
+			// should not happen but in case not listening
+			default:
+				slog.Error("no listener on Restart")
+			}
+		case pauseMenuID:
+			action, name := t.callbacks.Pause, "Pause"
+			if t.paused.Load() {
+				action, name = t.callbacks.Resume, "Resume"
+			}
+			select {
+			case action <- struct{}{}:
+			// should not happen but in case not listening
+			default:
+				slog.Error("no listener on " + name)
+			}
 		default:
-			slog.Debug("Unexpected menu item id", "id", menuItemId)
+			if profile, ok := t.profileAt(uint32(menuItemId)); ok {
+				select {
+				case t.callbacks.SelectProfile <- profile:
+				// should not happen but in case not listening
+				default:
+					slog.Error("no listener on SelectProfile")
+				}
+				break
+			}
+			if choice, ok := pauseForChoice(uint32(menuItemId)); ok {
+				select {
+				case t.callbacks.PauseFor <- choice:
+				// should not happen but in case not listening
+				default:
+					slog.Error("no listener on PauseFor")
+				}
+				break
+			}
+			action, ok := t.menuActions[uint32(menuItemId)]
+			if !ok {
+				slog.Debug("Unexpected menu item id", "id", menuItemId)
+				break
+			}
+			select {
+			case action <- struct{}{}:
+			// should not happen but in case not listening
+			default:
+				slog.Error("no listener on menu item", "id", menuItemId)
+			}
 		}
 	case WM_CLOSE:
+		if t.popup.window != 0 {
+			pDestroyWindow.Call(uintptr(t.popup.window)) //nolint:errcheck
+			if err := t.popup.wcex.unregister(); err != nil {
+				slog.Error("failed to unregister status popup", "error", err)
+			}
+		}
 		boolRet, _, err := pDestroyWindow.Call(uintptr(t.window))
 		if boolRet == 0 {
 			slog.Error("failed to destroy window", "error", err)
@@ -133,40 +184,57 @@ func (t *winTray) wndProc(hWnd windows.Handle, message uint32, wParam, lParam ui
 		switch lParam {
 		case WM_MOUSEMOVE, WM_LBUTTONDOWN:
 			// Ignore these...
-		case WM_RBUTTONUP, WM_LBUTTONUP:
+		case WM_RBUTTONUP:
+			// Newer notify icon versions follow this with WM_CONTEXTMENU, which shows the menu
+			if !t.legacyNotify {
+				break
+			}
+			fallthrough
+		case WM_CONTEXTMENU:
 			err := t.showMenu()
 			if err != nil {
 				slog.Error("failed to show menu", "error", err)
 			}
-		case 0x405: // TODO - how is this magic value derived for the notification left click
-			if t.pendingUpdate {
-				select {
-				case t.callbacks.Update <- struct{}{}:
-				// should not happen but in case not listening
-				default:
-					slog.Error("no listener on Update")
-				}
-			} else {
+		case WM_LBUTTONUP:
+			err := t.togglePopup()
+			if err != nil {
+				slog.Error("failed to show status popup", "error", err)
+			}
+		case NIN_KEYSELECT:
+			// Enter on a focused icon can be reported twice, only open the menu once
+			if time.Since(t.lastKeySelect) < 500*time.Millisecond {
+				break
+			}
+			t.lastKeySelect = time.Now()
+			err := t.showMenu()
+			if err != nil {
+				slog.Error("failed to show menu", "error", err)
+			}
+		case NIN_BALLOONUSERCLICK: // Notification left click
+			t.muNID.RLock()
+			notifyClick := t.notifyClick
+			t.muNID.RUnlock()
+			if notifyClick != nil {
 				select {
-				case t.callbacks.DoFirstUse <- struct{}{}:
+				case notifyClick <- struct{}{}:
 				// should not happen but in case not listening
 				default:
-					slog.Error("no listener on DoFirstUse")
+					slog.Error("no listener on notification click")
 				}
 			}
-		case 0x404: // Middle click or close notification
+		case NIN_BALLOONTIMEOUT: // Notification closed or timed out
 			// slog.Debug("doing nothing on close of first time notification")
+		case NIN_BALLOONSHOW:
+			// Nothing to do when a notification is shown
 		default:
-			// 0x402 also seems common - what is it?
 			slog.Debug("unmanaged app message", "lParam", fmt.Sprintf("0x%x", lParam))
 		}
+	case wmStatusChanged:
+		t.refreshPopup()
 	case t.wmTaskbarCreated: // on explorer.exe restarts
-		t.muNID.Lock()
-		err := t.nid.add()
-		if err != nil {
+		if err := t.restoreNotifyIcon(); err != nil {
 			slog.Error("failed to refresh the taskbar on explorer restart", "error", err)
 		}
-		t.muNID.Unlock()
 	case WM_POWERBROADCAST:
 		power.HandlePowerBroadcast(wParam, lParam)
 	default:
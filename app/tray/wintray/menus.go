@@ -5,42 +5,70 @@ package wintray
 import (
 	"fmt"
 	"log/slog"
+	"slices"
+	"strings"
+	"time"
 	"unsafe"
 
+	"github.com/ReEnvision-AI/systray/app/format"
+	"github.com/ReEnvision-AI/systray/app/tray/commontray"
 	"golang.org/x/sys/windows"
 )
 
 const (
 	_ = iota
 	statusMenuID
+	gpuStatusMenuID
+	announcementMenuID
 	statusSeparatorMenuID
 	updateAvailableMenuID
 	updateMenuID
 	separatorMenuID
 	startMenuID
 	stopMenuID
+	restartMenuID
+	pauseMenuID
+	pauseForMenuID
+	pauseFor30MinutesMenuID // In the pause for submenu
+	pauseForHourMenuID
+	pauseUntilTomorrowMenuID
 	runSeparatorMenuID
-	diagLogsMenuID
-	diagSeparatorMenuID
-	quitMenuID
+
+	// Entries of the commontray menu spec get consecutive IDs from here
+	firstMenuItemID = 100
+	// Entries of the profiles submenu get consecutive IDs from here
+	firstProfileMenuID = 1000
 )
 
-func (t *winTray) initMenus() error {
-	if err := t.addOrUpdateMenuItem(diagLogsMenuID, 0, diagLogsMenuTitle, false); err != nil {
+// menuItemRef locates a rendered entry of the commontray menu spec
+type menuItemRef struct {
+	id, parent uint32
+}
+
+func (t *winTray) initMenus(opts commontray.Options) error {
+	if err := t.addMenuSpec(commontray.Menu(t.callbacks), opts); err != nil {
+		return err
+	}
+	if err := t.addOrUpdateMenuItem(stopMenuID, 0, stopContainerTitle, true); err != nil {
 		return fmt.Errorf("unable to create menu entries %w", err)
 	}
-	if err := t.addSeparatorMenuItem(diagSeparatorMenuID, 0); err != nil {
+	if err := t.addOrUpdateMenuItem(startMenuID, 0, startContainerTitle, false); err != nil {
 		return fmt.Errorf("unable to create menu entries %w", err)
 	}
-	if err := t.addOrUpdateMenuItem(quitMenuID, 0, quitMenuTitle, false); err != nil {
+	if err := t.addOrUpdateMenuItem(restartMenuID, 0, restartContainerTitle, true); err != nil {
 		return fmt.Errorf("unable to create menu entries %w", err)
 	}
-	if err := t.addOrUpdateMenuItem(stopMenuID, 0, stopContainerTitle, true); err != nil {
+	if err := t.addOrUpdateMenuItem(pauseMenuID, 0, pauseContainerTitle, true); err != nil {
 		return fmt.Errorf("unable to create menu entries %w", err)
 	}
-	if err := t.addOrUpdateMenuItem(startMenuID, 0, startContainerTitle, false); err != nil {
+	if err := t.addOrUpdateSubmenu(pauseForMenuID, 0, pauseForMenuTitle, true); err != nil {
 		return fmt.Errorf("unable to create menu entries %w", err)
 	}
+	for _, item := range pauseForItems {
+		if err := t.addOrUpdateMenuItem(item.id, pauseForMenuID, item.title, false); err != nil {
+			return fmt.Errorf("unable to create menu entries %w", err)
+		}
+	}
 	if err := t.addSeparatorMenuItem(runSeparatorMenuID, 0); err != nil {
 		return fmt.Errorf("unable to create menu entries %w", err)
 	}
@@ -54,10 +82,82 @@ func (t *winTray) initMenus() error {
 	return nil
 }
 
+// addMenuSpec renders the declarative menu entries in order, moving advanced
+// entries into a submenu if requested.
+func (t *winTray) addMenuSpec(items []commontray.MenuItem, opts commontray.Options) error {
+	t.menuItems = make(map[string]menuItemRef, len(items))
+	t.menuActions = make(map[uint32]chan struct{}, len(items))
+
+	var advancedID uint32
+	if opts.AdvancedSubmenu {
+		for i, item := range items {
+			if item.Key == commontray.MenuAdvanced {
+				advancedID = firstMenuItemID + uint32(i)
+			}
+		}
+		if advancedID != 0 {
+			// Created first as advanced entries may come before the submenu's entry
+			if err := t.createSubmenu(advancedID); err != nil {
+				return fmt.Errorf("unable to create advanced submenu %w", err)
+			}
+		}
+	}
+
+	for i, item := range items {
+		id := firstMenuItemID + uint32(i)
+		var parent uint32
+		if item.Advanced && advancedID != 0 {
+			parent = advancedID
+		}
+
+		var err error
+		switch {
+		case item.Key == commontray.MenuAdvanced && advancedID == 0:
+			continue
+		case item.Separator:
+			err = t.addSeparatorMenuItem(id, parent)
+		case item.Submenu:
+			err = t.addOrUpdateSubmenu(id, parent, item.Title, false)
+		default:
+			err = t.addOrUpdateMenuItem(id, parent, item.Title, false)
+		}
+		if err != nil {
+			return fmt.Errorf("unable to create menu entries %w", err)
+		}
+		t.menuItems[item.Key] = menuItemRef{id: id, parent: parent}
+		if item.Action != nil {
+			t.menuActions[id] = item.Action
+		}
+	}
+	return nil
+}
+
+// setMenuItemTitle changes the title of an entry of the menu spec.
+func (t *winTray) setMenuItemTitle(key, title string) error {
+	ref, ok := t.menuItems[key]
+	if !ok {
+		return fmt.Errorf("unknown menu entry %s", key)
+	}
+	if err := t.addOrUpdateMenuItem(ref.id, ref.parent, title, false); err != nil {
+		return fmt.Errorf("unable to create menu entries %w", err)
+	}
+	return nil
+}
+
+// setMenuItemChecked shows or hides the check mark of an entry of the menu
+// spec, for settings that are on or off.
+func (t *winTray) setMenuItemChecked(key string, checked bool) error {
+	ref, ok := t.menuItems[key]
+	if !ok {
+		return fmt.Errorf("unknown menu entry %s", key)
+	}
+	return t.checkMenuItem(ref.id, ref.parent, checked)
+}
+
 func (t *winTray) UpdateAvailable(ver string) error {
 	if !t.updateNotified {
 		slog.Debug("updating menu and sending notification for new update")
-		if err := t.addOrUpdateMenuItem(updateAvailableMenuID, 0, updateAvailableMenuTitle, true); err != nil {
+		if err := t.addOrUpdateMenuItem(updateAvailableMenuID, 0, updateAvailableMenuTitle, false); err != nil {
 			return fmt.Errorf("unable to create menu entries %w", err)
 		}
 		if err := t.addOrUpdateMenuItem(updateMenuID, 0, updateMenuTitle, false); err != nil {
@@ -66,22 +166,30 @@ func (t *winTray) UpdateAvailable(ver string) error {
 		if err := t.addSeparatorMenuItem(separatorMenuID, 0); err != nil {
 			return fmt.Errorf("unable to create menu entries %w", err)
 		}
-		iconFilePath, err := iconBytesToFilePath(wt.updateIcon)
-		if err != nil {
-			return fmt.Errorf("unable to write icon data to temp file: %w", err)
-		}
-		if err := wt.setIcon(iconFilePath); err != nil {
-			return fmt.Errorf("unable to set icon: %w", err)
-		}
 		t.updateNotified = true
 
+		t.muIcon.Lock()
 		t.pendingUpdate = true
+		err := t.showIcon()
+		t.muIcon.Unlock()
+		if err != nil {
+			return err
+		}
+		if t.quietMode.Load() {
+			slog.Debug("quiet mode enabled, skipping update notification")
+			return nil
+		}
 		// Now pop up the notification
+		if t.showToast(updateTitle, fmt.Sprintf(updateMessage, ver), nil, []toastButton{{updateButton, t.callbacks.Update}}) {
+			return nil
+		}
 		t.muNID.Lock()
 		defer t.muNID.Unlock()
+		t.notifyClick = t.callbacks.Update
 		copy(t.nid.InfoTitle[:], windows.StringToUTF16(updateTitle))
 		copy(t.nid.Info[:], windows.StringToUTF16(fmt.Sprintf(updateMessage, ver)))
 		t.nid.Flags |= NIF_INFO
+		t.nid.InfoFlags = NIIF_INFO
 		t.nid.Timeout = 10
 		t.nid.Size = uint32(unsafe.Sizeof(*wt.nid))
 		err = t.nid.modify()
@@ -92,6 +200,121 @@ func (t *winTray) UpdateAvailable(ver string) error {
 	return nil
 }
 
+func (t *winTray) SetTelemetryEnabled(enabled bool) error {
+	return t.setMenuItemChecked(commontray.MenuTelemetry, enabled)
+}
+
+func (t *winTray) SetAnonymousMode(anonymous bool) error {
+	return t.setMenuItemChecked(commontray.MenuAnonymous, anonymous)
+}
+
+func (t *winTray) SetAutostart(enabled bool) error {
+	return t.setMenuItemChecked(commontray.MenuAutostart, enabled)
+}
+
+func (t *winTray) SetAutoRun(enabled bool) error {
+	return t.setMenuItemChecked(commontray.MenuAutoRun, enabled)
+}
+
+func (t *winTray) SetStartupNotice(enabled bool) error {
+	return t.setMenuItemChecked(commontray.MenuStartupNotice, enabled)
+}
+
+func (t *winTray) SetAdvancedSubmenu(enabled bool) error {
+	return t.setMenuItemChecked(commontray.MenuGroupAdvanced, enabled)
+}
+
+// SetProfiles lists the config profiles in the profiles submenu with the
+// active one checked. The default config is listed first, as the empty name.
+// The submenu is disabled when there are no profiles.
+func (t *winTray) SetProfiles(names []string, active string) error {
+	ref, ok := t.menuItems[commontray.MenuProfiles]
+	if !ok {
+		return fmt.Errorf("unknown menu entry %s", commontray.MenuProfiles)
+	}
+	entries := append([]string{""}, names...)
+
+	t.muProfiles.Lock()
+	defer t.muProfiles.Unlock()
+	for i := range t.profiles {
+		if err := t.removeMenuItem(firstProfileMenuID+uint32(i), ref.id); err != nil {
+			return err
+		}
+	}
+	t.profiles = entries
+	for i, name := range entries {
+		id := firstProfileMenuID + uint32(i)
+		title := defaultProfileMenuTitle
+		if name != "" {
+			// Profile names come from file names, so an '&' is not a mnemonic
+			title = strings.ReplaceAll(name, "&", "&&")
+		}
+		if err := t.addOrUpdateMenuItem(id, ref.id, title, false); err != nil {
+			return fmt.Errorf("unable to create menu entries %w", err)
+		}
+	}
+	if i := slices.Index(entries, active); i >= 0 {
+		last := firstProfileMenuID + uint32(len(entries)-1)
+		if err := t.checkRadioMenuItem(ref.id, firstProfileMenuID, last, firstProfileMenuID+uint32(i)); err != nil {
+			return err
+		}
+	}
+	return t.addOrUpdateSubmenu(ref.id, ref.parent, profilesMenuTitle, len(names) == 0)
+}
+
+// profileAt returns the profile of a profiles submenu entry.
+func (t *winTray) profileAt(menuItemID uint32) (string, bool) {
+	t.muProfiles.Lock()
+	defer t.muProfiles.Unlock()
+	if menuItemID < firstProfileMenuID || menuItemID-firstProfileMenuID >= uint32(len(t.profiles)) {
+		return "", false
+	}
+	return t.profiles[menuItemID-firstProfileMenuID], true
+}
+
+// SetAnnouncement shows a backend announcement as a disabled line below the
+// status, or removes the line when text is empty.
+func (t *winTray) SetAnnouncement(text string) error {
+	if text == "" {
+		return t.removeMenuItem(announcementMenuID, 0)
+	}
+	// The text comes from the backend, so an '&' in it is not a mnemonic
+	if err := t.addOrUpdateMenuItem(announcementMenuID, 0, strings.ReplaceAll(text, "&", "&&"), true); err != nil {
+		return fmt.Errorf("unable to create menu entries %w", err)
+	}
+	return nil
+}
+
+// SetGPUStatus shows the GPU usage as a disabled line below the status, or
+// removes the line when text is empty.
+func (t *winTray) SetGPUStatus(text string) error {
+	if text == "" {
+		return t.removeMenuItem(gpuStatusMenuID, 0)
+	}
+	if err := t.addOrUpdateMenuItem(gpuStatusMenuID, 0, text, true); err != nil {
+		return fmt.Errorf("unable to create menu entries %w", err)
+	}
+	return nil
+}
+
+// SetSupportAccess shows the time left on an active support session in the
+// menu, or offers to start one when remaining is zero.
+func (t *winTray) SetSupportAccess(remaining time.Duration) error {
+	title := supportAccessOffTitle
+	if remaining > 0 {
+		title = fmt.Sprintf(supportAccessOnTitle, format.Duration(remaining))
+	}
+	return t.setMenuItemTitle(commontray.MenuSupportAccess, title)
+}
+
+func (t *winTray) SetQuietMode(quiet bool) error {
+	t.quietMode.Store(quiet)
+	if err := t.setMenuItemChecked(commontray.MenuQuietMode, quiet); err != nil {
+		return err
+	}
+	return t.refreshIcon()
+}
+
 func (t *winTray) ChangeStatusText(text string) error {
 	if err := t.addOrUpdateMenuItem(statusMenuID, 0, "Status: "+text, true); err != nil {
 		return fmt.Errorf("unable to create menu entries %w", err)
@@ -99,6 +322,54 @@ func (t *winTray) ChangeStatusText(text string) error {
 	return nil
 }
 
+func (t *winTray) SetStarting() error {
+	if err := t.addOrUpdateMenuItem(startMenuID, 0, startContainerTitle, true); err != nil {
+		return fmt.Errorf("unable to create menu entries %w", err)
+	}
+	if err := t.addOrUpdateMenuItem(stopMenuID, 0, cancelStartTitle, false); err != nil {
+		return fmt.Errorf("unable to create menu entries %w", err)
+	}
+	if err := t.addOrUpdateMenuItem(restartMenuID, 0, restartContainerTitle, false); err != nil {
+		return fmt.Errorf("unable to create menu entries %w", err)
+	}
+	return t.setPauseMenuItem(false, true)
+}
+
+// ShowStartingBadge shows or hides the progress badge on the tray icon.
+func (t *winTray) ShowStartingBadge(show bool) error {
+	t.muIcon.Lock()
+	defer t.muIcon.Unlock()
+	if t.startingBadge == show {
+		return nil
+	}
+	t.startingBadge = show
+	return t.showIcon()
+}
+
+// SetIconState shows the icon of the node state, animated while the node
+// starts or stops.
+func (t *winTray) SetIconState(state commontray.IconState) error {
+	// The animation is started or stopped with the state it belongs to, so
+	// concurrent calls can't leave it running for a stale state
+	t.muIcon.Lock()
+	defer t.muIcon.Unlock()
+	if t.iconState == state {
+		return nil
+	}
+	t.iconState = state
+
+	if t.stopAnimation != nil {
+		close(t.stopAnimation)
+		t.stopAnimation = nil
+	}
+	if state == commontray.IconStarting && len(t.icons.StartingFrames) > 0 {
+		t.iconFrame.Store(0)
+		t.stopAnimation = make(chan struct{})
+		go t.animateIcon(t.stopAnimation)
+	}
+	return t.showIcon()
+}
+
 func (t *winTray) SetStarted() error {
 	if err := t.addOrUpdateMenuItem(startMenuID, 0, startContainerTitle, true); err != nil {
 		return fmt.Errorf("unable to create menu entries %w", err)
@@ -106,7 +377,10 @@ func (t *winTray) SetStarted() error {
 	if err := t.addOrUpdateMenuItem(stopMenuID, 0, stopContainerTitle, false); err != nil {
 		return fmt.Errorf("unable to create menu entries %w", err)
 	}
-	return nil
+	if err := t.addOrUpdateMenuItem(restartMenuID, 0, restartContainerTitle, false); err != nil {
+		return fmt.Errorf("unable to create menu entries %w", err)
+	}
+	return t.setPauseMenuItem(false, false)
 
 }
 
@@ -117,6 +391,61 @@ func (t *winTray) SetStopped() error {
 	if err := t.addOrUpdateMenuItem(stopMenuID, 0, stopContainerTitle, true); err != nil {
 		return fmt.Errorf("unable to create menu entries %w", err)
 	}
+	if err := t.addOrUpdateMenuItem(restartMenuID, 0, restartContainerTitle, true); err != nil {
+		return fmt.Errorf("unable to create menu entries %w", err)
+	}
+	return t.setPauseMenuItem(false, true)
+
+}
+
+// SetPaused shows the node is paused, its pause entry resuming it.
+func (t *winTray) SetPaused() error {
+	if err := t.addOrUpdateMenuItem(startMenuID, 0, startContainerTitle, true); err != nil {
+		return fmt.Errorf("unable to create menu entries %w", err)
+	}
+	if err := t.addOrUpdateMenuItem(stopMenuID, 0, stopContainerTitle, false); err != nil {
+		return fmt.Errorf("unable to create menu entries %w", err)
+	}
+	if err := t.addOrUpdateMenuItem(restartMenuID, 0, restartContainerTitle, false); err != nil {
+		return fmt.Errorf("unable to create menu entries %w", err)
+	}
+	return t.setPauseMenuItem(true, false)
+}
+
+// setPauseMenuItem makes the pause entry pause the node, or resume it if
+// paused. The pause for submenu is disabled along with it.
+func (t *winTray) setPauseMenuItem(paused, disabled bool) error {
+	t.paused.Store(paused)
+	title := pauseContainerTitle
+	if paused {
+		title = resumeContainerTitle
+	}
+	if err := t.addOrUpdateMenuItem(pauseMenuID, 0, title, disabled); err != nil {
+		return fmt.Errorf("unable to create menu entries %w", err)
+	}
+	if err := t.addOrUpdateSubmenu(pauseForMenuID, 0, pauseForMenuTitle, disabled); err != nil {
+		return fmt.Errorf("unable to create menu entries %w", err)
+	}
 	return nil
+}
 
+// pauseForItems are the entries of the pause for submenu
+var pauseForItems = []struct {
+	id     uint32
+	title  string
+	choice string
+}{
+	{pauseFor30MinutesMenuID, pauseFor30MinutesTitle, commontray.PauseFor30Minutes},
+	{pauseForHourMenuID, pauseForHourTitle, commontray.PauseForHour},
+	{pauseUntilTomorrowMenuID, pauseUntilTomorrowTitle, commontray.PauseUntilTomorrow},
+}
+
+// pauseForChoice returns the timed pause of a pause for submenu entry.
+func pauseForChoice(menuItemID uint32) (string, bool) {
+	for _, item := range pauseForItems {
+		if item.id == menuItemID {
+			return item.choice, true
+		}
+	}
+	return "", false
 }
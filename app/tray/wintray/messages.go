@@ -8,10 +8,26 @@ const (
 	updateTitle      = "Update available"
 	updateMessage    = "ReEnvision AI version %s is ready to install"
 
-	quitMenuTitle            = "Quit ReEnvision AI"
+	// Buttons of toast notifications
+	firstTimeButton = "Get started"
+	updateButton    = "Restart to update"
+	viewLogsButton  = "View logs"
+
+	// Menu titles use '&' to mark the keyboard mnemonic for each item
 	updateAvailableMenuTitle = "An update is available"
-	updateMenuTitle          = "Restart to update"
-	diagLogsMenuTitle        = "View logs"
-	startContainerTitle      = "Start"
-	stopContainerTitle       = "Stop"
+	updateMenuTitle          = "&Restart to update"
+	profilesMenuTitle        = "&Profile"
+	defaultProfileMenuTitle  = "&Default"
+	supportAccessOffTitle    = "Allow &support access..."
+	supportAccessOnTitle     = "Revoke &support access (%s left)"
+	startContainerTitle      = "&Start"
+	stopContainerTitle       = "S&top"
+	cancelStartTitle         = "&Cancel start"
+	restartContainerTitle    = "Re&start"
+	pauseContainerTitle      = "Pa&use"
+	resumeContainerTitle     = "Res&ume"
+	pauseForMenuTitle        = "Pause &for"
+	pauseFor30MinutesTitle   = "&30 minutes"
+	pauseForHourTitle        = "&1 hour"
+	pauseUntilTomorrowTitle  = "Until &tomorrow"
 )
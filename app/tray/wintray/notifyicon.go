@@ -64,3 +64,18 @@ func (nid *notifyIconData) delete() error {
 	}
 	return nil
 }
+
+// Sets the notification behavior version so the shell sends keyboard
+// selection (NIN_KEYSELECT) and WM_CONTEXTMENU notifications.
+func (nid *notifyIconData) setVersion(version uint32) error {
+	const NIM_SETVERSION = 0x00000004
+	nid.Timeout = version // uTimeout and uVersion share storage
+	res, _, err := pShellNotifyIcon.Call(
+		uintptr(NIM_SETVERSION),
+		uintptr(unsafe.Pointer(nid)),
+	)
+	if res == 0 {
+		return err
+	}
+	return nil
+}
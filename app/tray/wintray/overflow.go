@@ -0,0 +1,116 @@
+//go:build windows
+
+package wintray
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+)
+
+// Windows 11 keeps the per-icon taskbar settings, including whether the icon
+// is pinned to the taskbar, in a subkey per icon
+const notifyIconSettingsKey = `Control Panel\NotifyIconSettings`
+
+var errIconSettingsNotFound = errors.New("no taskbar settings for this app")
+
+// IconHidden reports whether the icon is in the hidden icons (overflow) area
+// rather than on the taskbar.
+func (t *winTray) IconHidden() (bool, error) {
+	t.muNID.RLock()
+	id := struct {
+		Size     uint32
+		Wnd      windows.Handle
+		ID       uint32
+		GuidItem windows.GUID
+	}{Wnd: t.nid.Wnd, ID: t.nid.ID}
+	t.muNID.RUnlock()
+	id.Size = uint32(unsafe.Sizeof(id))
+
+	var icon windows.Rect
+	hr, _, _ := pShellNotifyIconGetRect.Call(uintptr(unsafe.Pointer(&id)), uintptr(unsafe.Pointer(&icon)))
+	if hr != 0 {
+		return false, fmt.Errorf("unable to locate tray icon: %w", windows.Errno(hr))
+	}
+
+	className, _ := windows.UTF16PtrFromString("Shell_TrayWnd")
+	taskbar, _, err := pFindWindow.Call(uintptr(unsafe.Pointer(className)), 0)
+	if taskbar == 0 {
+		return false, fmt.Errorf("unable to find taskbar: %w", err)
+	}
+	var bar windows.Rect
+	boolRet, _, err := pGetWindowRect.Call(taskbar, uintptr(unsafe.Pointer(&bar)))
+	if boolRet == 0 {
+		return false, fmt.Errorf("unable to get taskbar position: %w", err)
+	}
+
+	onTaskbar := icon.Left < icon.Right && icon.Top < icon.Bottom &&
+		icon.Left >= bar.Left && icon.Right <= bar.Right &&
+		icon.Top >= bar.Top && icon.Bottom <= bar.Bottom
+	return !onTaskbar, nil
+}
+
+// CanPinIcon reports whether PinIcon is supported, which needs the taskbar
+// settings that Windows 11 creates once the icon has been shown.
+func (t *winTray) CanPinIcon() bool {
+	key, err := openIconSettings(registry.QUERY_VALUE)
+	if err != nil {
+		return false
+	}
+	key.Close()
+	return true
+}
+
+// PinIcon moves the icon from the hidden icons area onto the taskbar, the
+// same as turning it on in Settings > Personalization > Taskbar.
+func (t *winTray) PinIcon() error {
+	key, err := openIconSettings(registry.SET_VALUE)
+	if err != nil {
+		return err
+	}
+	defer key.Close()
+	if err := key.SetDWordValue("IsPromoted", 1); err != nil {
+		return fmt.Errorf("unable to pin tray icon: %w", err)
+	}
+	slog.Info("pinned tray icon to the taskbar")
+	return nil
+}
+
+// openIconSettings finds the NotifyIconSettings subkey of this executable.
+// Paths are stored with known folders as GUIDs, so match on the file name.
+func openIconSettings(access uint32) (registry.Key, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return 0, err
+	}
+	exeName := filepath.Base(exe)
+
+	settings, err := registry.OpenKey(registry.CURRENT_USER, notifyIconSettingsKey, registry.ENUMERATE_SUB_KEYS)
+	if err != nil {
+		return 0, errIconSettingsNotFound
+	}
+	defer settings.Close()
+	names, err := settings.ReadSubKeyNames(-1)
+	if err != nil {
+		return 0, err
+	}
+	for _, name := range names {
+		key, err := registry.OpenKey(settings, name, registry.QUERY_VALUE|access)
+		if err != nil {
+			continue
+		}
+		path, _, err := key.GetStringValue("ExecutablePath")
+		if err == nil && strings.EqualFold(filepath.Base(path), exeName) {
+			return key, nil
+		}
+		key.Close()
+	}
+	return 0, errIconSettingsNotFound
+}
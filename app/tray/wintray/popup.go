@@ -0,0 +1,367 @@
+//go:build windows
+
+package wintray
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+	"unsafe"
+
+	"github.com/ReEnvision-AI/systray/app/format"
+	"github.com/ReEnvision-AI/systray/app/tray/commontray"
+	"golang.org/x/sys/windows"
+)
+
+// The status popup is a small borderless window shown next to the tray on a
+// left click. It shows the node state at a glance with Start/Stop buttons,
+// while the right-click menu keeps the full set of actions. It is created on
+// first use and hidden again as soon as it loses focus.
+
+const (
+	popupClassName = "ReAIStatusPopup"
+
+	// Sizes in pixels at 96 DPI
+	popupWidth        = 260
+	popupHeight       = 172
+	popupPadding      = 12
+	popupLineHeight   = 20
+	popupButtonWidth  = 80
+	popupButtonHeight = 26
+
+	popupStartButtonID = 1
+	popupStopButtonID  = 2
+
+	// Posted to the tray window so the popup is only touched on the UI thread
+	wmStatusChanged = WM_USER + 2
+
+	// Clicking the icon while the popup is open deactivates the popup before
+	// the click arrives, so a click this soon after hiding closes it instead
+	popupReopenDelay = 300 * time.Millisecond
+)
+
+type statusPopup struct {
+	window   windows.Handle
+	wcex     *wndClassEx
+	startBtn windows.Handle
+	stopBtn  windows.Handle
+	font     windows.Handle
+	dpi      uint32
+	visible  bool
+	hiddenAt time.Time
+}
+
+// SetStatusInfo updates what the status popup shows. It is repainted
+// immediately if it is open.
+func (t *winTray) SetStatusInfo(info commontray.StatusInfo) error {
+	t.muStatus.Lock()
+	t.status = info
+	t.muStatus.Unlock()
+
+	boolRet, _, err := pPostMessage.Call(uintptr(t.window), wmStatusChanged, 0, 0)
+	if boolRet == 0 {
+		return fmt.Errorf("unable to refresh status popup: %w", err)
+	}
+	return nil
+}
+
+// refreshPopup repaints the status popup after a status change.
+func (t *winTray) refreshPopup() {
+	if t.popup.window == 0 {
+		return
+	}
+	t.updatePopupButtons()
+	pInvalidateRect.Call(uintptr(t.popup.window), 0, 1) //nolint:errcheck
+}
+
+// togglePopup shows the status popup next to the cursor, or hides it if it
+// was open.
+func (t *winTray) togglePopup() error {
+	if t.popup.visible || time.Since(t.popup.hiddenAt) < popupReopenDelay {
+		t.hidePopup()
+		return nil
+	}
+	if t.popup.window == 0 {
+		if err := t.createPopup(); err != nil {
+			return err
+		}
+	}
+	t.updatePopupButtons()
+
+	p := point{}
+	boolRet, _, err := pGetCursorPos.Call(uintptr(unsafe.Pointer(&p)))
+	if boolRet == 0 {
+		return err
+	}
+	x, y, w, h := t.popupBounds(p)
+
+	const (
+		HWND_TOPMOST   = ^uintptr(0) // (HWND)-1
+		SWP_SHOWWINDOW = 0x0040
+	)
+	boolRet, _, err = pSetWindowPos.Call(uintptr(t.popup.window), HWND_TOPMOST,
+		uintptr(x), uintptr(y), uintptr(w), uintptr(h), SWP_SHOWWINDOW)
+	if boolRet == 0 {
+		return fmt.Errorf("unable to show status popup: %w", err)
+	}
+	// The popup must be the foreground window to be told when it loses focus
+	boolRet, _, err = pSetForegroundWindow.Call(uintptr(t.popup.window))
+	if boolRet == 0 {
+		slog.Warn("failed to bring status popup to foreground", "error", err)
+	}
+	t.popup.visible = true
+	return nil
+}
+
+func (t *winTray) hidePopup() {
+	if !t.popup.visible {
+		return
+	}
+	pShowWindow.Call(uintptr(t.popup.window), uintptr(SW_HIDE)) //nolint:errcheck
+	t.popup.visible = false
+	t.popup.hiddenAt = time.Now()
+}
+
+func (t *winTray) createPopup() error {
+	const (
+		WS_POPUP         = 0x80000000
+		WS_BORDER        = 0x00800000
+		WS_EX_TOOLWINDOW = 0x00000080
+		WS_EX_TOPMOST    = 0x00000008
+		DEFAULT_GUI_FONT = 17
+	)
+	classNamePtr, err := windows.UTF16PtrFromString(popupClassName)
+	if err != nil {
+		return err
+	}
+	t.popup.wcex = &wndClassEx{
+		WndProc:    windows.NewCallback(t.popupProc),
+		Instance:   t.instance,
+		Cursor:     t.cursor,
+		Background: windows.Handle(6), // (COLOR_WINDOW + 1)
+		ClassName:  classNamePtr,
+	}
+	if err := t.popup.wcex.register(); err != nil {
+		return fmt.Errorf("unable to register status popup class: %w", err)
+	}
+
+	// Owned by the hidden tray window so it never gets a taskbar button
+	windowHandle, _, err := pCreateWindowEx.Call(
+		uintptr(WS_EX_TOOLWINDOW|WS_EX_TOPMOST),
+		uintptr(unsafe.Pointer(classNamePtr)),
+		0,
+		uintptr(WS_POPUP|WS_BORDER),
+		0, 0, popupWidth, popupHeight,
+		uintptr(t.window),
+		0,
+		uintptr(t.instance),
+		0,
+	)
+	if windowHandle == 0 {
+		return fmt.Errorf("unable to create status popup: %w", err)
+	}
+	t.popup.window = windows.Handle(windowHandle)
+
+	t.popup.dpi = 96
+	if pGetDpiForWindow.Find() == nil {
+		if dpi, _, _ := pGetDpiForWindow.Call(windowHandle); dpi != 0 {
+			t.popup.dpi = uint32(dpi)
+		}
+	}
+	font, _, _ := pGetStockObject.Call(DEFAULT_GUI_FONT)
+	t.popup.font = windows.Handle(font)
+
+	if t.popup.startBtn, err = t.createPopupButton(startContainerTitle, popupStartButtonID); err != nil {
+		return err
+	}
+	if t.popup.stopBtn, err = t.createPopupButton(stopContainerTitle, popupStopButtonID); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *winTray) createPopupButton(title string, id uintptr) (windows.Handle, error) {
+	const (
+		WS_CHILD      = 0x40000000
+		WS_VISIBLE    = 0x10000000
+		WS_TABSTOP    = 0x00010000
+		BS_PUSHBUTTON = 0x00000000
+		WM_SETFONT    = 0x0030
+	)
+	classNamePtr, _ := windows.UTF16PtrFromString("BUTTON")
+	titlePtr, err := windows.UTF16PtrFromString(title)
+	if err != nil {
+		return 0, err
+	}
+
+	// Buttons sit side by side in the bottom right corner, Stop rightmost
+	x := popupWidth - popupPadding - popupButtonWidth
+	if id == popupStartButtonID {
+		x -= popupButtonWidth + popupPadding/2
+	}
+	y := popupHeight - popupPadding - popupButtonHeight
+	handle, _, err := pCreateWindowEx.Call(
+		0,
+		uintptr(unsafe.Pointer(classNamePtr)),
+		uintptr(unsafe.Pointer(titlePtr)),
+		uintptr(WS_CHILD|WS_VISIBLE|WS_TABSTOP|BS_PUSHBUTTON),
+		uintptr(t.scale(int32(x))), uintptr(t.scale(int32(y))),
+		uintptr(t.scale(popupButtonWidth)), uintptr(t.scale(popupButtonHeight)),
+		uintptr(t.popup.window),
+		id,
+		uintptr(t.instance),
+		0,
+	)
+	if handle == 0 {
+		return 0, fmt.Errorf("unable to create status popup button: %w", err)
+	}
+	pSendMessage.Call(handle, WM_SETFONT, uintptr(t.popup.font), 0) //nolint:errcheck
+	return windows.Handle(handle), nil
+}
+
+func (t *winTray) updatePopupButtons() {
+	t.muStatus.Lock()
+	canStart, canStop := t.status.CanStart, t.status.CanStop
+	t.muStatus.Unlock()
+	pEnableWindow.Call(uintptr(t.popup.startBtn), boolToUintptr(canStart)) //nolint:errcheck
+	pEnableWindow.Call(uintptr(t.popup.stopBtn), boolToUintptr(canStop))   //nolint:errcheck
+}
+
+// popupBounds places the popup next to the cursor, kept inside the work area
+// so it lands above, below or beside the taskbar wherever it is docked.
+func (t *winTray) popupBounds(cursor point) (x, y, w, h int32) {
+	const SPI_GETWORKAREA = 0x0030
+	w, h = t.scale(popupWidth), t.scale(popupHeight)
+	work := windows.Rect{Right: cursor.X + w, Bottom: cursor.Y + h}
+	pSystemParametersInfo.Call(SPI_GETWORKAREA, 0, uintptr(unsafe.Pointer(&work)), 0) //nolint:errcheck
+
+	x = min(max(cursor.X-w/2, work.Left), work.Right-w)
+	y = min(max(cursor.Y-h, work.Top), work.Bottom-h)
+	return x, y, w, h
+}
+
+func (t *winTray) scale(v int32) int32 {
+	return v * int32(t.popup.dpi) / 96
+}
+
+func (t *winTray) paintPopup(hWnd windows.Handle) {
+	const (
+		TRANSPARENT = 1
+		DT_LEFT     = 0x0000
+		DT_SINGLE   = 0x0020
+		DT_NOPREFIX = 0x0800
+		DT_END      = 0x8000 // DT_END_ELLIPSIS
+	)
+	ps := struct {
+		Hdc         windows.Handle
+		Erase       int32
+		Paint       windows.Rect
+		Restore     int32
+		IncUpdate   int32
+		RGBReserved [32]byte
+	}{}
+	hdc, _, _ := pBeginPaint.Call(uintptr(hWnd), uintptr(unsafe.Pointer(&ps)))
+	if hdc == 0 {
+		return
+	}
+	defer pEndPaint.Call(uintptr(hWnd), uintptr(unsafe.Pointer(&ps))) //nolint:errcheck
+
+	pSelectObject.Call(hdc, uintptr(t.popup.font)) //nolint:errcheck
+	pSetBkMode.Call(hdc, TRANSPARENT)              //nolint:errcheck
+
+	t.muStatus.Lock()
+	status := t.status
+	t.muStatus.Unlock()
+
+	uptime := "-"
+	if !status.RunningSince.IsZero() {
+		uptime = format.Duration(time.Since(status.RunningSince))
+	}
+	throughput := status.Throughput
+	if throughput == "" {
+		throughput = "-"
+	}
+	progress := status.Progress
+	if progress == "" {
+		progress = "-"
+	}
+	title, contributed := commontray.Title, format.Duration(status.Contributed)+" contributed"
+	if status.Anonymous {
+		title += " (anonymous)"
+		contributed = format.Duration(status.Contributed) + ", not credited"
+	}
+	lines := []string{
+		title,
+		"Status: " + status.State,
+		"Uptime: " + uptime,
+		"Throughput: " + throughput,
+		"Node: " + progress,
+		"Today: " + contributed,
+	}
+
+	for i, line := range lines {
+		text, err := windows.UTF16FromString(line)
+		if err != nil {
+			continue
+		}
+		top := t.scale(int32(popupPadding + i*popupLineHeight))
+		r := windows.Rect{
+			Left:   t.scale(popupPadding),
+			Top:    top,
+			Right:  t.scale(popupWidth - popupPadding),
+			Bottom: top + t.scale(popupLineHeight),
+		}
+		pDrawText.Call(hdc, uintptr(unsafe.Pointer(&text[0])), uintptr(len(text)-1), //nolint:errcheck
+			uintptr(unsafe.Pointer(&r)), DT_LEFT|DT_SINGLE|DT_NOPREFIX|DT_END)
+	}
+}
+
+// popupProc handles the messages of the status popup window.
+func (t *winTray) popupProc(hWnd windows.Handle, message uint32, wParam, lParam uintptr) (lResult uintptr) {
+	const (
+		WM_ACTIVATE = 0x0006
+		WM_PAINT    = 0x000F
+		WM_COMMAND  = 0x0111
+		WA_INACTIVE = 0
+	)
+	switch message {
+	case WM_PAINT:
+		t.paintPopup(hWnd)
+	case WM_ACTIVATE:
+		if wParam&0xFFFF == WA_INACTIVE {
+			t.hidePopup()
+		}
+	case WM_COMMAND:
+		var target chan struct{}
+		switch wParam & 0xFFFF {
+		case popupStartButtonID:
+			target = t.callbacks.StartContainer
+		case popupStopButtonID:
+			target = t.callbacks.StopContainer
+		}
+		if target != nil {
+			t.hidePopup()
+			select {
+			case target <- struct{}{}:
+			// should not happen but in case not listening
+			default:
+				slog.Error("no listener on status popup button", "id", wParam&0xFFFF)
+			}
+		}
+	default:
+		lResult, _, _ = pDefWindowProc.Call(
+			uintptr(hWnd),
+			uintptr(message),
+			wParam,
+			lParam,
+		)
+	}
+	return
+}
+
+func boolToUintptr(b bool) uintptr {
+	if b {
+		return 1
+	}
+	return 0
+}
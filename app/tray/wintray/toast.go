@@ -0,0 +1,357 @@
+//go:build windows
+
+package wintray
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+
+	"github.com/ReEnvision-AI/systray/app/tray/commontray"
+	"golang.org/x/sys/windows"
+)
+
+// Notifications are shown as Windows toasts, which can have buttons and are
+// kept in the action center while Focus Assist holds them back. A toast is
+// attributed to the app user model ID of the Start menu shortcut the
+// installer creates, so where no toast can be shown, like a build run
+// without installing, notifications fall back to balloon tips.
+
+// appUserModelID identifies the app to the shell, it must match the
+// AppUserModelID of the shortcuts in install.iss.
+const appUserModelID = "ReEnvisionAI.Tray"
+
+var (
+	iidUnknown                        = windows.GUID{Data1: 0x00000000, Data2: 0x0000, Data3: 0x0000, Data4: [8]byte{0xC0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x46}}
+	iidAgileObject                    = windows.GUID{Data1: 0x94EA2B94, Data2: 0xE9CC, Data3: 0x49E0, Data4: [8]byte{0xC0, 0xFF, 0xEE, 0x64, 0xCA, 0x8F, 0x5B, 0x90}}
+	iidToastNotificationManagerStatic = windows.GUID{Data1: 0x50AC103F, Data2: 0xD235, Data3: 0x4598, Data4: [8]byte{0xBB, 0xEF, 0x98, 0xFE, 0x4D, 0x1A, 0x3A, 0xD4}}
+	iidToastNotificationFactory       = windows.GUID{Data1: 0x04124B20, Data2: 0x82C6, Data3: 0x4229, Data4: [8]byte{0xB1, 0x09, 0xFD, 0x9E, 0xD4, 0x66, 0x2B, 0x53}}
+	iidXmlDocument                    = windows.GUID{Data1: 0xF7F3A506, Data2: 0x1E87, Data3: 0x42D6, Data4: [8]byte{0xBC, 0xFB, 0xB8, 0xC8, 0x09, 0xFA, 0x54, 0x94}}
+	iidXmlDocumentIO                  = windows.GUID{Data1: 0x6CD0E74E, Data2: 0xEE65, Data3: 0x4489, Data4: [8]byte{0x9E, 0xBF, 0xCA, 0x43, 0xE8, 0x7B, 0xA6, 0x37}}
+	iidToastActivatedEventArgs        = windows.GUID{Data1: 0xE3BF92F3, Data2: 0xC197, Data3: 0x436F, Data4: [8]byte{0x82, 0x65, 0x06, 0x25, 0x82, 0x4F, 0x8D, 0xAC}}
+	iidToastActivatedHandler          = windows.GUID{Data1: 0xAB54DE2D, Data2: 0x97D9, Data3: 0x5528, Data4: [8]byte{0xB6, 0xAD, 0x10, 0x5A, 0xFE, 0x15, 0x65, 0x30}} // TypedEventHandler<ToastNotification, Object>
+)
+
+// Methods of the Windows Runtime interfaces by their index in the vtable,
+// after the IUnknown and IInspectable methods
+const (
+	methodQueryInterface            = 0
+	methodRelease                   = 2
+	methodCreateToastNotifierWithId = 7  // IToastNotificationManagerStatics
+	methodCreateToastNotification   = 6  // IToastNotificationFactory
+	methodLoadXml                   = 6  // IXmlDocumentIO
+	methodShow                      = 6  // IToastNotifier
+	methodAddActivated              = 11 // IToastNotification
+	methodGetArguments              = 6  // IToastActivatedEventArgs
+)
+
+// comObject is a COM or Windows Runtime object called through its vtable.
+type comObject struct {
+	vtbl *[16]uintptr
+}
+
+func (o *comObject) call(method int, args ...uintptr) error {
+	hr, _, _ := syscall.SyscallN(o.vtbl[method], append([]uintptr{uintptr(unsafe.Pointer(o))}, args...)...)
+	if int32(hr) < 0 {
+		return windows.Errno(hr)
+	}
+	return nil
+}
+
+func (o *comObject) queryInterface(iid *windows.GUID) (*comObject, error) {
+	var obj *comObject
+	if err := o.call(methodQueryInterface, uintptr(unsafe.Pointer(iid)), uintptr(unsafe.Pointer(&obj))); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+func (o *comObject) release() {
+	o.call(methodRelease) //nolint:errcheck
+}
+
+// hstring is a Windows Runtime string.
+type hstring uintptr
+
+func newHString(s string) (hstring, error) {
+	u, err := windows.UTF16FromString(s)
+	if err != nil {
+		return 0, err
+	}
+	var h hstring
+	hr, _, _ := pWindowsCreateString.Call(uintptr(unsafe.Pointer(&u[0])), uintptr(len(u)-1), uintptr(unsafe.Pointer(&h)))
+	if hr != 0 {
+		return 0, windows.Errno(hr)
+	}
+	return h, nil
+}
+
+func (h hstring) String() string {
+	var length uint32
+	p, _, _ := pWindowsGetStringRawBuffer.Call(uintptr(h), uintptr(unsafe.Pointer(&length)))
+	if p == 0 || length == 0 {
+		return ""
+	}
+	return windows.UTF16ToString(unsafe.Slice(*(**uint16)(unsafe.Pointer(&p)), length))
+}
+
+func (h hstring) delete() {
+	pWindowsDeleteString.Call(uintptr(h)) //nolint:errcheck
+}
+
+// activationFactory returns the interface iid of the factory of a Windows
+// Runtime class.
+func activationFactory(class string, iid *windows.GUID) (*comObject, error) {
+	name, err := newHString(class)
+	if err != nil {
+		return nil, err
+	}
+	defer name.delete()
+	var factory *comObject
+	hr, _, _ := pRoGetActivationFactory.Call(uintptr(name), uintptr(unsafe.Pointer(iid)), uintptr(unsafe.Pointer(&factory)))
+	if hr != 0 {
+		return nil, fmt.Errorf("unable to get factory of %s: %w", class, windows.Errno(hr))
+	}
+	return factory, nil
+}
+
+// toastHandler is the handler of the Activated event of toasts, a COM
+// object implemented in Go. There is one for the life of the process, so it
+// isn't reference counted.
+type toastHandler struct {
+	vtbl *[4]uintptr
+}
+
+var (
+	toastHandlerVtbl  [4]uintptr
+	activatedHandler  = &toastHandler{vtbl: &toastHandlerVtbl}
+	initToastsOnce    sync.Once
+	initToastsErr     error
+	toastsUnavailable atomic.Bool
+)
+
+// initToasts keeps the multithreaded apartment alive for the toasts and
+// their handler, which may be called on any thread.
+func initToasts() error {
+	initToastsOnce.Do(func() {
+		if err := pCoIncrementMTAUsage.Find(); err != nil {
+			initToastsErr = err
+			return
+		}
+		var cookie uintptr
+		if hr, _, _ := pCoIncrementMTAUsage.Call(uintptr(unsafe.Pointer(&cookie))); hr != 0 {
+			initToastsErr = fmt.Errorf("unable to initialize COM: %w", windows.Errno(hr))
+			return
+		}
+		toastHandlerVtbl = [4]uintptr{
+			windows.NewCallback(func(this *toastHandler, iid *windows.GUID, obj **toastHandler) uintptr {
+				switch *iid {
+				case iidUnknown, iidAgileObject, iidToastActivatedHandler:
+					*obj = this
+					return 0
+				}
+				*obj = nil
+				return uintptr(windows.E_NOINTERFACE)
+			}),
+			windows.NewCallback(func(this *toastHandler) uintptr { return 1 }), // AddRef
+			windows.NewCallback(func(this *toastHandler) uintptr { return 1 }), // Release
+			windows.NewCallback(func(this *toastHandler, sender uintptr, args *comObject) uintptr {
+				wt.toastActivated(args)
+				return 0
+			}),
+		}
+	})
+	return initToastsErr
+}
+
+// toastButton is a button of a toast, which sends on action when clicked.
+type toastButton struct {
+	label  string
+	action chan struct{}
+}
+
+// toastXML returns the content of a toast. The arguments of the toast are
+// its sequence number and 0 for a click on the body, or the index of the
+// button from 1.
+func toastXML(seq uint64, title, message string, buttons []toastButton) string {
+	escape := func(s string) string {
+		var b strings.Builder
+		xml.EscapeText(&b, []byte(s)) //nolint:errcheck
+		return b.String()
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, `<toast launch="%d/0"><visual><binding template="ToastGeneric">`, seq)
+	fmt.Fprintf(&b, `<text>%s</text><text>%s</text></binding></visual>`, escape(title), escape(message))
+	if len(buttons) > 0 {
+		b.WriteString("<actions>")
+		for i, button := range buttons {
+			fmt.Fprintf(&b, `<action content="%s" arguments="%d/%d"/>`, escape(button.label), seq, i+1)
+		}
+		b.WriteString("</actions>")
+	}
+	b.WriteString("</toast>")
+	return b.String()
+}
+
+// showToast shows a toast that sends on click when its body is clicked, and
+// reports whether it could. click may be nil.
+func (t *winTray) showToast(title, message string, click chan struct{}, buttons []toastButton) bool {
+	if toastsUnavailable.Load() {
+		return false
+	}
+	if err := t.tryShowToast(title, message, click, buttons); err != nil {
+		slog.Info("unable to show toast notifications, using balloons", "error", err)
+		toastsUnavailable.Store(true)
+		return false
+	}
+	return true
+}
+
+func (t *winTray) tryShowToast(title, message string, click chan struct{}, buttons []toastButton) error {
+	if err := initToasts(); err != nil {
+		return err
+	}
+	t.muToast.Lock()
+	defer t.muToast.Unlock()
+	seq := t.toastSeq + 1
+
+	className, err := newHString("Windows.Data.Xml.Dom.XmlDocument")
+	if err != nil {
+		return err
+	}
+	defer className.delete()
+	var inspectable *comObject
+	if hr, _, _ := pRoActivateInstance.Call(uintptr(className), uintptr(unsafe.Pointer(&inspectable))); hr != 0 {
+		return fmt.Errorf("unable to create toast content: %w", windows.Errno(hr))
+	}
+	defer inspectable.release()
+	doc, err := inspectable.queryInterface(&iidXmlDocument)
+	if err != nil {
+		return err
+	}
+	defer doc.release()
+	docIO, err := inspectable.queryInterface(&iidXmlDocumentIO)
+	if err != nil {
+		return err
+	}
+	defer docIO.release()
+	content, err := newHString(toastXML(seq, title, message, buttons))
+	if err != nil {
+		return err
+	}
+	defer content.delete()
+	if err := docIO.call(methodLoadXml, uintptr(content)); err != nil {
+		return fmt.Errorf("unable to load toast content: %w", err)
+	}
+
+	factory, err := activationFactory("Windows.UI.Notifications.ToastNotification", &iidToastNotificationFactory)
+	if err != nil {
+		return err
+	}
+	defer factory.release()
+	var toast *comObject
+	if err := factory.call(methodCreateToastNotification, uintptr(unsafe.Pointer(doc)), uintptr(unsafe.Pointer(&toast))); err != nil {
+		return fmt.Errorf("unable to create toast: %w", err)
+	}
+	var token int64
+	if err := toast.call(methodAddActivated, uintptr(unsafe.Pointer(activatedHandler)), uintptr(unsafe.Pointer(&token))); err != nil {
+		toast.release()
+		return fmt.Errorf("unable to handle toast clicks: %w", err)
+	}
+
+	manager, err := activationFactory("Windows.UI.Notifications.ToastNotificationManager", &iidToastNotificationManagerStatic)
+	if err != nil {
+		toast.release()
+		return err
+	}
+	defer manager.release()
+	id, err := newHString(appUserModelID)
+	if err != nil {
+		toast.release()
+		return err
+	}
+	defer id.delete()
+	var notifier *comObject
+	if err := manager.call(methodCreateToastNotifierWithId, uintptr(id), uintptr(unsafe.Pointer(&notifier))); err != nil {
+		toast.release()
+		return fmt.Errorf("unable to create toast notifier: %w", err)
+	}
+	defer notifier.release()
+	if err := notifier.call(methodShow, uintptr(unsafe.Pointer(toast))); err != nil {
+		toast.release()
+		return fmt.Errorf("unable to show toast: %w", err)
+	}
+
+	// Only the latest toast responds to clicks, like the balloon it replaces.
+	// It's kept as Windows only raises the events of toasts still referenced.
+	if t.toast != nil {
+		t.toast.release()
+	}
+	t.toast = toast
+	t.toastSeq = seq
+	t.toastActions = []chan struct{}{click}
+	for _, button := range buttons {
+		t.toastActions = append(t.toastActions, button.action)
+	}
+	return nil
+}
+
+// toastActivated sends on the action of the body or button of the latest
+// toast that was clicked.
+func (t *winTray) toastActivated(args *comObject) {
+	eventArgs, err := args.queryInterface(&iidToastActivatedEventArgs)
+	if err != nil {
+		slog.Debug("unexpected toast activation", "error", err)
+		return
+	}
+	defer eventArgs.release()
+	var arguments hstring
+	if err := eventArgs.call(methodGetArguments, uintptr(unsafe.Pointer(&arguments))); err != nil {
+		slog.Debug("unable to get toast arguments", "error", err)
+		return
+	}
+	defer arguments.delete()
+
+	seq, index, _ := strings.Cut(arguments.String(), "/")
+	i, err := strconv.Atoi(index)
+	t.muToast.Lock()
+	var action chan struct{}
+	if err == nil && seq == strconv.FormatUint(t.toastSeq, 10) && i >= 0 && i < len(t.toastActions) {
+		action = t.toastActions[i]
+	}
+	t.muToast.Unlock()
+	if action != nil {
+		select {
+		case action <- struct{}{}:
+		// should not happen but in case not listening
+		default:
+			slog.Error("no listener on notification click")
+		}
+	}
+}
+
+// setAppUserModelID attributes the windows and toasts of the process to the
+// app's shortcuts.
+func setAppUserModelID() {
+	id, err := windows.UTF16PtrFromString(appUserModelID)
+	if err != nil {
+		return
+	}
+	if hr, _, _ := pSetCurrentProcessExplicitAppUserModelID.Call(uintptr(unsafe.Pointer(id))); hr != 0 {
+		slog.Debug("unable to set app user model ID", "error", windows.Errno(hr))
+	}
+}
+
+// notifyLevelButtons returns the buttons of a notification of the level.
+func (t *winTray) notifyLevelButtons(level commontray.NotificationLevel) []toastButton {
+	if level == commontray.NotifyError {
+		return []toastButton{{viewLogsButton, t.callbacks.ShowLogs}}
+	}
+	return nil
+}
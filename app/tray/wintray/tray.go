@@ -9,7 +9,9 @@ import (
 	"path/filepath"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
 	"unsafe"
 
 	"github.com/ReEnvision-AI/systray/app/tray/commontray"
@@ -40,29 +42,88 @@ type winTray struct {
 	wmSystrayMessage,
 	wmTaskbarCreated uint32
 
-	pendingUpdate  bool
+	paused         atomic.Bool // The pause entry resumes the node
 	updateNotified bool
+	quietMode      atomic.Bool   // No icon badging and no informational balloons
+	notifyClick    chan struct{} // Callback for a click on the current balloon, may be nil
+	legacyNotify   bool          // The shell rejected NOTIFYICON_VERSION, no keyboard notifications
+	lastKeySelect  time.Time
 
-	callbacks  commontray.Callbacks
-	normalIcon []byte
-	updateIcon []byte
+	tooltip string
+
+	menuItems   map[string]menuItemRef   // Rendered entries of the menu spec by key
+	menuActions map[uint32]chan struct{} // Callbacks of the menu spec entries by menu ID
+	muProfiles  sync.Mutex
+	profiles    []string // Config profiles in the profiles submenu, by menu ID from firstProfileMenuID
+
+	muStatus sync.Mutex
+	status   commontray.StatusInfo // Shown in the status popup
+	popup    statusPopup
+
+	callbacks commontray.Callbacks
+	icons     commontray.Icons
+
+	muIcon        sync.Mutex // Guards the icon state below and serializes icon changes
+	iconState     commontray.IconState
+	startingBadge bool
+	pendingUpdate bool
+	stopAnimation chan struct{} // Closed to stop the animation
+
+	muToast      sync.Mutex
+	toast        *comObject      // The latest toast, nil until one is shown
+	toastSeq     uint64          // Sequence number of the latest toast
+	toastActions []chan struct{} // Callbacks of the body and buttons of the latest toast, may be nil
+
+	iconFrame atomic.Int32 // Frame of the animated starting icon
 }
 
+// iconFrameInterval is how long each frame of the animated starting icon is
+// shown.
+const iconFrameInterval = 400 * time.Millisecond
+
 var wt winTray
 
 func (t *winTray) GetCallbacks() commontray.Callbacks {
 	return t.callbacks
 }
 
-func InitTray(icon, updateIcon []byte) (*winTray, error) {
+func InitTray(icons commontray.Icons, opts commontray.Options) (*winTray, error) {
 	wt.callbacks.Quit = make(chan struct{})
 	wt.callbacks.Update = make(chan struct{})
+	wt.callbacks.ReleaseNotes = make(chan struct{})
 	wt.callbacks.ShowLogs = make(chan struct{})
+	wt.callbacks.ShowLiveLogs = make(chan struct{})
+	wt.callbacks.CopyDiagnostics = make(chan struct{})
+	wt.callbacks.SupportBundle = make(chan struct{})
 	wt.callbacks.DoFirstUse = make(chan struct{})
 	wt.callbacks.StartContainer = make(chan struct{})
 	wt.callbacks.StopContainer = make(chan struct{})
-	wt.normalIcon = icon
-	wt.updateIcon = updateIcon
+	wt.callbacks.Restart = make(chan struct{})
+	wt.callbacks.Pause = make(chan struct{})
+	wt.callbacks.Resume = make(chan struct{})
+	wt.callbacks.PauseFor = make(chan string)
+	wt.callbacks.ToggleQuiet = make(chan struct{})
+	wt.callbacks.ToggleAutostart = make(chan struct{})
+	wt.callbacks.ToggleAutoRun = make(chan struct{})
+	wt.callbacks.StartupNotice = make(chan struct{})
+	wt.callbacks.GroupAdvanced = make(chan struct{})
+	wt.callbacks.RecreateCache = make(chan struct{})
+	wt.callbacks.ToggleTelemetry = make(chan struct{})
+	wt.callbacks.ExportData = make(chan struct{})
+	wt.callbacks.DeleteData = make(chan struct{})
+	wt.callbacks.ShowAPIToken = make(chan struct{})
+	wt.callbacks.ShowAbout = make(chan struct{})
+	wt.callbacks.SupportAccess = make(chan struct{})
+	wt.callbacks.MoveNode = make(chan struct{})
+	wt.callbacks.ImportNode = make(chan struct{})
+	wt.callbacks.JoinOrg = make(chan struct{})
+	wt.callbacks.ExportStats = make(chan struct{})
+	wt.callbacks.Settings = make(chan struct{})
+	wt.callbacks.ToggleAnonymous = make(chan struct{})
+	wt.callbacks.SelectProfile = make(chan string)
+	wt.icons = icons
+	wt.tooltip = commontray.Tooltip
+	setAppUserModelID()
 	if err := wt.initInstance(); err != nil {
 		return nil, fmt.Errorf("unable to init instance: %w", err)
 	}
@@ -71,15 +132,11 @@ func InitTray(icon, updateIcon []byte) (*winTray, error) {
 		return nil, fmt.Errorf("unable to create menu: %w", err)
 	}
 
-	iconFilePath, err := iconBytesToFilePath(wt.normalIcon)
-	if err != nil {
-		return nil, fmt.Errorf("unable to write icon data to temp file: %w", err)
-	}
-	if err := wt.setIcon(iconFilePath); err != nil {
-		return nil, fmt.Errorf("unable to set icon: %w", err)
+	if err := wt.refreshIcon(); err != nil {
+		return nil, err
 	}
 
-	return &wt, wt.initMenus()
+	return &wt, wt.initMenus(opts)
 }
 
 func (t *winTray) initInstance() error {
@@ -184,7 +241,15 @@ func (t *winTray) initInstance() error {
 	}
 	t.nid.Size = uint32(unsafe.Sizeof(*t.nid))
 
-	return t.nid.add()
+	if err := t.nid.add(); err != nil {
+		return err
+	}
+	// Opt in to keyboard notifications so the icon can be operated without a mouse
+	if err := t.nid.setVersion(NOTIFYICON_VERSION); err != nil {
+		slog.Warn("failed to set notify icon version, keyboard activation unavailable", "error", err)
+		t.legacyNotify = true
+	}
+	return nil
 }
 
 func (t *winTray) createMenu() error {
@@ -293,6 +358,62 @@ func (t *winTray) addOrUpdateMenuItem(menuItemId uint32, parentId uint32, title
 	return nil
 }
 
+// checkMenuItem shows or hides the check mark of an entry. Updating the
+// entry with addOrUpdateMenuItem clears it.
+func (t *winTray) checkMenuItem(menuItemId, parentId uint32, checked bool) error {
+	flags := uintptr(MF_BYCOMMAND | MF_UNCHECKED)
+	if checked {
+		flags = MF_BYCOMMAND | MF_CHECKED
+	}
+	t.muMenus.RLock()
+	menu := t.menus[parentId]
+	t.muMenus.RUnlock()
+	// Returns the previous state, or -1 if the entry doesn't exist
+	if ret, _, err := pCheckMenuItem.Call(uintptr(menu), uintptr(menuItemId), flags); int32(ret) == -1 {
+		return fmt.Errorf("failed to check menu item: %w", err)
+	}
+	return nil
+}
+
+// checkRadioMenuItem marks the entry checkedId of the group of entries with
+// the IDs first to last with a bullet, unmarking the others of the group.
+func (t *winTray) checkRadioMenuItem(parentId, first, last, checkedId uint32) error {
+	t.muMenus.RLock()
+	menu := t.menus[parentId]
+	t.muMenus.RUnlock()
+	boolRet, _, err := pCheckMenuRadioItem.Call(uintptr(menu), uintptr(first), uintptr(last), uintptr(checkedId), MF_BYCOMMAND)
+	if boolRet == 0 {
+		return fmt.Errorf("failed to check menu item: %w", err)
+	}
+	return nil
+}
+
+// createSubmenu creates the submenu opened by the entry menuItemId, if it
+// doesn't exist yet. Entries are added to it with menuItemId as their parent,
+// also before the entry itself is added.
+func (t *winTray) createSubmenu(menuItemId uint32) error {
+	t.muMenus.Lock()
+	defer t.muMenus.Unlock()
+	if _, exists := t.menus[menuItemId]; exists {
+		return nil
+	}
+	submenu, _, err := pCreatePopupMenu.Call()
+	if submenu == 0 {
+		return fmt.Errorf("unable to create submenu: %w", err)
+	}
+	t.menus[menuItemId] = windows.Handle(submenu)
+	return nil
+}
+
+// addOrUpdateSubmenu adds an entry opening a submenu to the menu parentId,
+// which may itself be a submenu, or updates its title.
+func (t *winTray) addOrUpdateSubmenu(menuItemId, parentId uint32, title string, disabled bool) error {
+	if err := t.createSubmenu(menuItemId); err != nil {
+		return err
+	}
+	return t.addOrUpdateMenuItem(menuItemId, parentId, title, disabled)
+}
+
 func (t *winTray) addSeparatorMenuItem(menuItemId, parentId uint32) error {
 	mi := menuItemInfo{
 		Mask: MIIM_FTYPE | MIIM_ID | MIIM_STATE,
@@ -320,6 +441,46 @@ func (t *winTray) addSeparatorMenuItem(menuItemId, parentId uint32) error {
 	return nil
 }
 
+// removeMenuItem deletes an entry added with addOrUpdateMenuItem, if present.
+func (t *winTray) removeMenuItem(menuItemId, parentId uint32) error {
+	if t.getVisibleItemIndex(parentId, menuItemId) == -1 {
+		return nil
+	}
+	t.muMenus.RLock()
+	menu := t.menus[parentId]
+	t.muMenus.RUnlock()
+	boolRet, _, err := pDeleteMenu.Call(uintptr(menu), uintptr(menuItemId), MF_BYCOMMAND)
+	if boolRet == 0 {
+		return fmt.Errorf("failed to delete menu item: %w", err)
+	}
+	t.delFromVisibleItems(parentId, menuItemId)
+	t.forgetMenuItem(menuItemId)
+	return nil
+}
+
+// forgetMenuItem drops a deleted entry and, as deleting an entry destroys
+// its submenu, the entries of its submenus.
+func (t *winTray) forgetMenuItem(menuItemId uint32) {
+	t.muMenuOf.Lock()
+	delete(t.menuOf, menuItemId)
+	t.muMenuOf.Unlock()
+
+	t.muMenus.Lock()
+	_, isSubmenu := t.menus[menuItemId]
+	delete(t.menus, menuItemId)
+	t.muMenus.Unlock()
+	if !isSubmenu {
+		return
+	}
+	t.muVisibleItems.Lock()
+	children := t.visibleItems[menuItemId]
+	delete(t.visibleItems, menuItemId)
+	t.muVisibleItems.Unlock()
+	for _, child := range children {
+		t.forgetMenuItem(child)
+	}
+}
+
 func (t *winTray) showMenu() error {
 	p := point{}
 	boolRet, _, err := pGetCursorPos.Call(uintptr(unsafe.Pointer(&p)))
@@ -407,9 +568,7 @@ func (t *winTray) setIcon(src string) error {
 	defer t.muNID.Unlock()
 	t.nid.Icon = h
 	t.nid.Flags |= NIF_ICON | NIF_TIP
-	if toolTipUTF16, err := syscall.UTF16FromString(commontray.Tooltip); err == nil {
-		copy(t.nid.Tip[:], toolTipUTF16)
-	} else {
+	if err := t.copyTooltip(); err != nil {
 		return err
 	}
 	t.nid.Size = uint32(unsafe.Sizeof(*t.nid))
@@ -417,6 +576,33 @@ func (t *winTray) setIcon(src string) error {
 	return t.nid.modify()
 }
 
+// SetTooltip sets the alternative text of the tray icon. This is shown on
+// hover and is what screen readers announce when the icon gets focus.
+func (t *winTray) SetTooltip(text string) error {
+	t.muNID.Lock()
+	defer t.muNID.Unlock()
+	t.tooltip = text
+	t.nid.Flags |= NIF_TIP
+	if err := t.copyTooltip(); err != nil {
+		return err
+	}
+	t.nid.Size = uint32(unsafe.Sizeof(*t.nid))
+
+	return t.nid.modify()
+}
+
+// copyTooltip copies the current tooltip into the notify icon data, truncating
+// it to fit. Callers must hold muNID.
+func (t *winTray) copyTooltip() error {
+	toolTipUTF16, err := syscall.UTF16FromString(t.tooltip)
+	if err != nil {
+		return err
+	}
+	clear(t.nid.Tip[:])
+	copy(t.nid.Tip[:len(t.nid.Tip)-1], toolTipUTF16)
+	return nil
+}
+
 // Loads an image from file to be shown in tray or menu item.
 // LoadImage: https://msdn.microsoft.com/en-us/library/windows/desktop/ms648045(v=vs.85).aspx
 func (t *winTray) loadIconFrom(src string) (windows.Handle, error) {
@@ -448,12 +634,148 @@ func (t *winTray) loadIconFrom(src string) (windows.Handle, error) {
 	return h, nil
 }
 
+// restoreNotifyIcon adds the icon back after explorer.exe restarted, as the
+// shell forgets all notify icons when it exits. The notify icon version,
+// tooltip and any badge are applied again.
+func (t *winTray) restoreNotifyIcon() error {
+	t.muNID.Lock()
+	t.nid.Flags &^= NIF_INFO // Don't replay the last notification
+	t.nid.Size = uint32(unsafe.Sizeof(*t.nid))
+	if err := t.nid.add(); err != nil {
+		t.muNID.Unlock()
+		return err
+	}
+	t.legacyNotify = false
+	if err := t.nid.setVersion(NOTIFYICON_VERSION); err != nil {
+		slog.Warn("failed to set notify icon version, keyboard activation unavailable", "error", err)
+		t.legacyNotify = true
+	}
+	t.muNID.Unlock()
+
+	slog.Info("restored tray icon after explorer restart")
+	return t.refreshIcon()
+}
+
+// refreshIcon shows the icon matching the current tray state. Badges are
+// suppressed in quiet mode.
+func (t *winTray) refreshIcon() error {
+	t.muIcon.Lock()
+	defer t.muIcon.Unlock()
+	return t.showIcon()
+}
+
+// showIcon shows the icon matching the current tray state. Callers must hold
+// muIcon.
+func (t *winTray) showIcon() error {
+	icon := t.stateIcon()
+	switch {
+	case t.quietMode.Load():
+	case t.startingBadge:
+		icon = t.startingIcon()
+	case t.pendingUpdate:
+		icon = t.icons.Update
+	}
+	iconFilePath, err := iconBytesToFilePath(icon)
+	if err != nil {
+		return fmt.Errorf("unable to write icon data to temp file: %w", err)
+	}
+	if err := t.setIcon(iconFilePath); err != nil {
+		return fmt.Errorf("unable to set icon: %w", err)
+	}
+	return nil
+}
+
+// stateIcon returns the icon of the node state, or the app icon if there is
+// none for it. Callers must hold muIcon.
+func (t *winTray) stateIcon() []byte {
+	var icon []byte
+	switch t.iconState {
+	case commontray.IconStopped:
+		icon = t.icons.Stopped
+	case commontray.IconStarting:
+		icon = t.startingIcon()
+	case commontray.IconRunning:
+		icon = t.icons.Running
+	case commontray.IconError:
+		icon = t.icons.Error
+	}
+	if len(icon) == 0 {
+		return t.icons.Normal
+	}
+	return icon
+}
+
+// startingIcon returns the current frame of the starting icon while it is
+// animated. Callers must hold muIcon.
+func (t *winTray) startingIcon() []byte {
+	frames := t.icons.StartingFrames
+	if t.quietMode.Load() || len(frames) == 0 || t.iconState != commontray.IconStarting {
+		return t.icons.Starting
+	}
+	return frames[int(t.iconFrame.Load())%len(frames)]
+}
+
+// animateIcon cycles the frames of the starting icon until stop is closed.
+func (t *winTray) animateIcon(stop chan struct{}) {
+	ticker := time.NewTicker(iconFrameInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		if t.quietMode.Load() {
+			continue
+		}
+		t.iconFrame.Add(1)
+		if err := t.refreshIcon(); err != nil {
+			slog.Debug("failed to animate tray icon", "error", err)
+		}
+	}
+}
+
 func (t *winTray) DisplayFirstUseNotification() error {
+	if t.showToast(firstTimeTitle, firstTimeMessage, t.callbacks.DoFirstUse, []toastButton{{firstTimeButton, t.callbacks.DoFirstUse}}) {
+		return nil
+	}
 	t.muNID.Lock()
 	defer t.muNID.Unlock()
+	t.notifyClick = t.callbacks.DoFirstUse
 	copy(t.nid.InfoTitle[:], windows.StringToUTF16(firstTimeTitle))
 	copy(t.nid.Info[:], windows.StringToUTF16(firstTimeMessage))
 	t.nid.Flags |= NIF_INFO
+	t.nid.InfoFlags = NIIF_INFO // Balloons with an info icon are announced by screen readers
+	t.nid.Size = uint32(unsafe.Sizeof(*wt.nid))
+
+	return t.nid.modify()
+}
+
+func (t *winTray) DisplayNotification(title, message string, level commontray.NotificationLevel) error {
+	return t.DisplayActionNotification(title, message, level, nil)
+}
+
+// DisplayActionNotification shows a notification that sends on action when
+// the user clicks it. action may be nil. Errors have a button to view the
+// logs.
+func (t *winTray) DisplayActionNotification(title, message string, level commontray.NotificationLevel, action chan struct{}) error {
+	if t.showToast(title, message, action, t.notifyLevelButtons(level)) {
+		return nil
+	}
+	t.muNID.Lock()
+	defer t.muNID.Unlock()
+	t.notifyClick = action
+	copy(t.nid.InfoTitle[:], windows.StringToUTF16(title))
+	copy(t.nid.Info[:], windows.StringToUTF16(message))
+	t.nid.Flags |= NIF_INFO
+	switch level {
+	case commontray.NotifyError:
+		t.nid.InfoFlags = NIIF_ERROR
+	case commontray.NotifyWarning:
+		t.nid.InfoFlags = NIIF_WARNING
+	default:
+		t.nid.InfoFlags = NIIF_INFO
+	}
 	t.nid.Size = uint32(unsafe.Sizeof(*wt.nid))
 
 	return t.nid.modify()
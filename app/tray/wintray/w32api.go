@@ -12,32 +12,59 @@ var (
 	k32 = windows.NewLazySystemDLL("Kernel32.dll")
 	u32 = windows.NewLazySystemDLL("User32.dll")
 	s32 = windows.NewLazySystemDLL("Shell32.dll")
+	g32 = windows.NewLazySystemDLL("Gdi32.dll")
+	cb  = windows.NewLazySystemDLL("combase.dll") // Windows Runtime, Windows 8 and later
 
-	pCreatePopupMenu       = u32.NewProc("CreatePopupMenu")
-	pCreateWindowEx        = u32.NewProc("CreateWindowExW")
-	pDefWindowProc         = u32.NewProc("DefWindowProcW")
-	pDestroyWindow         = u32.NewProc("DestroyWindow")
-	pDispatchMessage       = u32.NewProc("DispatchMessageW")
-	pGetCursorPos          = u32.NewProc("GetCursorPos")
-	pGetMessage            = u32.NewProc("GetMessageW")
-	pGetModuleHandle       = k32.NewProc("GetModuleHandleW")
-	pInsertMenuItem        = u32.NewProc("InsertMenuItemW")
-	pLoadCursor            = u32.NewProc("LoadCursorW")
-	pLoadIcon              = u32.NewProc("LoadIconW")
-	pLoadImage             = u32.NewProc("LoadImageW")
-	pPostMessage           = u32.NewProc("PostMessageW")
-	pPostQuitMessage       = u32.NewProc("PostQuitMessage")
-	pRegisterClass         = u32.NewProc("RegisterClassExW")
-	pRegisterWindowMessage = u32.NewProc("RegisterWindowMessageW")
-	pSetForegroundWindow   = u32.NewProc("SetForegroundWindow")
-	pSetMenuInfo           = u32.NewProc("SetMenuInfo")
-	pSetMenuItemInfo       = u32.NewProc("SetMenuItemInfoW")
-	pShellNotifyIcon       = s32.NewProc("Shell_NotifyIconW")
-	pShowWindow            = u32.NewProc("ShowWindow")
-	pTrackPopupMenu        = u32.NewProc("TrackPopupMenu")
-	pTranslateMessage      = u32.NewProc("TranslateMessage")
-	pUnregisterClass       = u32.NewProc("UnregisterClassW")
-	pUpdateWindow          = u32.NewProc("UpdateWindow")
+	pBeginPaint                              = u32.NewProc("BeginPaint")
+	pCheckMenuItem                           = u32.NewProc("CheckMenuItem")
+	pCheckMenuRadioItem                      = u32.NewProc("CheckMenuRadioItem")
+	pCoIncrementMTAUsage                     = cb.NewProc("CoIncrementMTAUsage")
+	pCreatePopupMenu                         = u32.NewProc("CreatePopupMenu")
+	pCreateWindowEx                          = u32.NewProc("CreateWindowExW")
+	pDefWindowProc                           = u32.NewProc("DefWindowProcW")
+	pDeleteMenu                              = u32.NewProc("DeleteMenu")
+	pDestroyWindow                           = u32.NewProc("DestroyWindow")
+	pDispatchMessage                         = u32.NewProc("DispatchMessageW")
+	pDrawText                                = u32.NewProc("DrawTextW")
+	pEnableWindow                            = u32.NewProc("EnableWindow")
+	pEndPaint                                = u32.NewProc("EndPaint")
+	pFindWindow                              = u32.NewProc("FindWindowW")
+	pGetCursorPos                            = u32.NewProc("GetCursorPos")
+	pGetDpiForWindow                         = u32.NewProc("GetDpiForWindow") // Windows 10 1607 and later
+	pGetMessage                              = u32.NewProc("GetMessageW")
+	pGetModuleHandle                         = k32.NewProc("GetModuleHandleW")
+	pGetStockObject                          = g32.NewProc("GetStockObject")
+	pGetWindowRect                           = u32.NewProc("GetWindowRect")
+	pInsertMenuItem                          = u32.NewProc("InsertMenuItemW")
+	pInvalidateRect                          = u32.NewProc("InvalidateRect")
+	pLoadCursor                              = u32.NewProc("LoadCursorW")
+	pLoadIcon                                = u32.NewProc("LoadIconW")
+	pLoadImage                               = u32.NewProc("LoadImageW")
+	pPostMessage                             = u32.NewProc("PostMessageW")
+	pPostQuitMessage                         = u32.NewProc("PostQuitMessage")
+	pRoActivateInstance                      = cb.NewProc("RoActivateInstance")
+	pRoGetActivationFactory                  = cb.NewProc("RoGetActivationFactory")
+	pRegisterClass                           = u32.NewProc("RegisterClassExW")
+	pRegisterWindowMessage                   = u32.NewProc("RegisterWindowMessageW")
+	pSelectObject                            = g32.NewProc("SelectObject")
+	pSendMessage                             = u32.NewProc("SendMessageW")
+	pSetBkMode                               = g32.NewProc("SetBkMode")
+	pSetCurrentProcessExplicitAppUserModelID = s32.NewProc("SetCurrentProcessExplicitAppUserModelID")
+	pSetForegroundWindow                     = u32.NewProc("SetForegroundWindow")
+	pSetMenuInfo                             = u32.NewProc("SetMenuInfo")
+	pSetMenuItemInfo                         = u32.NewProc("SetMenuItemInfoW")
+	pSetWindowPos                            = u32.NewProc("SetWindowPos")
+	pShellNotifyIcon                         = s32.NewProc("Shell_NotifyIconW")
+	pShellNotifyIconGetRect                  = s32.NewProc("Shell_NotifyIconGetRect")
+	pShowWindow                              = u32.NewProc("ShowWindow")
+	pSystemParametersInfo                    = u32.NewProc("SystemParametersInfoW")
+	pTrackPopupMenu                          = u32.NewProc("TrackPopupMenu")
+	pTranslateMessage                        = u32.NewProc("TranslateMessage")
+	pUnregisterClass                         = u32.NewProc("UnregisterClassW")
+	pUpdateWindow                            = u32.NewProc("UpdateWindow")
+	pWindowsCreateString                     = cb.NewProc("WindowsCreateString")
+	pWindowsDeleteString                     = cb.NewProc("WindowsDeleteString")
+	pWindowsGetStringRawBuffer               = cb.NewProc("WindowsGetStringRawBuffer")
 )
 
 const (
@@ -50,6 +77,8 @@ const (
 	LR_DEFAULTSIZE      = 0x00000040 // Loads default-size icon for windows(SM_CXICON x SM_CYICON) if cx, cy are set to zero
 	LR_LOADFROMFILE     = 0x00000010 // Loads the stand-alone image from the file
 	MF_BYCOMMAND        = 0x00000000
+	MF_CHECKED          = 0x00000008
+	MF_UNCHECKED        = 0x00000000
 	MFS_DISABLED        = 0x00000003
 	MFT_SEPARATOR       = 0x00000800
 	MFT_STRING          = 0x00000000
@@ -64,6 +93,10 @@ const (
 	NIF_TIP             = 0x00000004
 	NIF_INFO            = 0x00000010
 	NIF_MESSAGE         = 0x00000001
+	NIIF_INFO           = 0x00000001
+	NIIF_WARNING        = 0x00000002
+	NIIF_ERROR          = 0x00000003
+	NOTIFYICON_VERSION  = 3
 	SW_HIDE             = 0
 	TPM_BOTTOMALIGN     = 0x0020
 	TPM_LEFTALIGN       = 0x0000
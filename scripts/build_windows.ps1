@@ -5,6 +5,10 @@ if (Test-Path $envFile) {
             $env:HF_TOKEN = $matches[1]
             Write-Host "HF_TOKEN set from .env file"
         }
+        if ($_ -match "^SUPABASE_ANON_KEY=(.+)") {
+            $env:SUPABASE_ANON_KEY = $matches[1]
+            Write-Host "SUPABASE_ANON_KEY set from .env file"
+        }
     }
 }
 
@@ -41,7 +45,15 @@ function buildsetup() {
   else {
     $script:PKG_VERSION = "0.0.0"
   }
-  write-host "Building ReEnvision AI App $script:VERSION with package version $script:PKG_VERSION"
+  $script:COMMIT = (git rev-parse --short=7 HEAD)
+  $script:BUILD_DATE = (Get-Date).ToUniversalTime().ToString("yyyy-MM-dd")
+  if ($env:CHANNEL) {
+    $script:CHANNEL = $env:CHANNEL
+  }
+  else {
+    $script:CHANNEL = "stable"
+  }
+  write-host "Building ReEnvision AI App $script:VERSION ($script:COMMIT, $script:BUILD_DATE, $script:CHANNEL) with package version $script:PKG_VERSION"
 
 }
 
@@ -49,8 +61,12 @@ function buildApp() {
   write-host "Building ReEnvision AI App"
   set-location "${script:SRC_DIR}\app"
   & go-winres make
+  & go generate ./assets
+  if ($LASTEXITCODE -ne 0) {
+    exit($LASTEXITCODE)
+  }
   #& windres -l 0 -o reai.syso reai.rc
-  & go build -trimpath -ldflags "-s -w -H windowsgui -X=github.com/ReEnvision-AI/systray/version.Version=$script:VERSION" -o "${script:SRC_DIR}\dist\windows\ReEnvisionAI.exe" .
+  & go build -trimpath -ldflags "-s -w -H windowsgui -X=github.com/ReEnvision-AI/systray/version.Version=$script:VERSION -X=github.com/ReEnvision-AI/systray/version.Commit=$script:COMMIT -X=github.com/ReEnvision-AI/systray/version.BuildDate=$script:BUILD_DATE -X=github.com/ReEnvision-AI/systray/version.Channel=$script:CHANNEL" -o "${script:SRC_DIR}\dist\windows\ReEnvisionAI.exe" .
   if ($LASTEXITCODE -ne 0) {
     exit($LASTEXITCODE)
   }
@@ -65,6 +81,18 @@ function gatherDistributables() {
     $dest = Join-Path -Path "${script:SRC_DIR}\dist\windows" -ChildPath $_.Name
     Copy-Item -Path $_.FullName -Destination $dest -Force
   }
+  # The anon key in win_files\config.json isn't one the backend accepts,
+  # packaged builds get the real key from the build environment
+  $configPath = "${script:SRC_DIR}\dist\windows\config.json"
+  if ($env:SUPABASE_ANON_KEY) {
+    $config = Get-Content -Raw -Path $configPath | ConvertFrom-Json
+    $config.supabaseAnonKey = $env:SUPABASE_ANON_KEY
+    # Without a byte order mark, which the app's JSON parser rejects
+    [IO.File]::WriteAllText($configPath, ($config | ConvertTo-Json -Depth 10))
+  }
+  else {
+    write-host "SUPABASE_ANON_KEY not set, signing in won't work with the packaged config"
+  }
   write-host "Distributables gathered successfully"
 }
 
@@ -105,4 +133,5 @@ finally {
   set-location $script:SRC_DIR
   $env:PKG_VERSION = ""
   $env:HF_TOKEN = ""
+  $env:SUPABASE_ANON_KEY = ""
 }
\ No newline at end of file
@@ -1,3 +1,30 @@
 package version
 
-var Version string = "0.0.0"
+import "strings"
+
+// Set at build time with -ldflags "-X=github.com/ReEnvision-AI/systray/version.<Name>=<value>"
+var (
+	Version   string = "0.0.0"
+	Commit    string = ""    // Short git commit hash
+	BuildDate string = ""    // UTC build date, e.g. 2025-01-31
+	Channel   string = "dev" // Release channel: stable, beta or dev
+)
+
+// String returns the version with whatever build metadata is known, e.g.
+// 1.2.3 (abc1234, 2025-01-31, stable).
+func String() string {
+	details := []string{}
+	if Commit != "" {
+		details = append(details, Commit)
+	}
+	if BuildDate != "" {
+		details = append(details, BuildDate)
+	}
+	if Channel != "" {
+		details = append(details, Channel)
+	}
+	if len(details) == 0 {
+		return Version
+	}
+	return Version + " (" + strings.Join(details, ", ") + ")"
+}